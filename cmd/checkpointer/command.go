@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os/signal"
+	"time"
+
+	"github.com/gemyago/top-k-system-go/internal/app/aggregation"
+	"github.com/gemyago/top-k-system-go/internal/diag"
+	"github.com/gemyago/top-k-system-go/internal/services"
+	"go.uber.org/dig"
+	"golang.org/x/sys/unix"
+)
+
+type commandParams struct {
+	dig.In `ignore-unexported:"true"`
+
+	RootLogger *slog.Logger
+
+	AggregationCommands *aggregation.Commands
+
+	*services.ShutdownHooks
+
+	noop bool
+}
+
+// runCommand will run the given function with a context that is cancelled on
+// SIGINT/SIGTERM and will perform the graceful shutdown afterwards.
+func runCommand(params commandParams, run func(ctx context.Context) error) error {
+	rootLogger := params.RootLogger
+	rootCtx := context.Background()
+
+	shutdown := func() error {
+		rootLogger.InfoContext(rootCtx, "Trying to shut down gracefully")
+		ts := time.Now()
+
+		err := params.ShutdownHooks.PerformShutdown(rootCtx)
+		if err != nil {
+			rootLogger.ErrorContext(rootCtx, "Failed to shut down gracefully", diag.ErrAttr(err))
+		}
+
+		rootLogger.InfoContext(rootCtx, "Service stopped",
+			slog.Duration("duration", time.Since(ts)),
+		)
+		return err
+	}
+
+	signalCtx, cancel := signal.NotifyContext(rootCtx, unix.SIGINT, unix.SIGTERM)
+	defer cancel()
+
+	startupErrors := make(chan error)
+	go func() {
+		if params.noop {
+			rootLogger.InfoContext(signalCtx, "NOOP: Exiting now")
+			startupErrors <- nil
+			return
+		}
+		startupErrors <- run(signalCtx)
+	}()
+
+	var startupErr error
+	select {
+	case startupErr = <-startupErrors:
+		if startupErr != nil {
+			rootLogger.ErrorContext(rootCtx, "Command failed", "err", startupErr)
+		}
+	case <-signalCtx.Done(): // coverage-ignore
+		// We will attempt to shut down in both cases
+		// so doing it once on a next line
+	}
+	return errors.Join(startupErr, shutdown())
+}
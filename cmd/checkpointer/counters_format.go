@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	countersFormatCSV   = "csv"
+	countersFormatJSONL = "jsonl"
+)
+
+// counterRecord is a single record of the exported (or imported) counters.
+type counterRecord struct {
+	ItemID string `json:"itemId"`
+	Count  int64  `json:"count"`
+}
+
+func (r counterRecord) validate() error {
+	if r.ItemID == "" {
+		return errors.New("itemId is required")
+	}
+	if r.Count < 0 {
+		return fmt.Errorf("count must not be negative, got %d", r.Count)
+	}
+	return nil
+}
+
+type countersWriter interface {
+	write(record counterRecord) error
+
+	// flush must be called when all records are written
+	flush() error
+}
+
+type csvCountersWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvCountersWriter) write(record counterRecord) error {
+	if !w.headerWritten {
+		if err := w.writer.Write([]string{"itemId", "count"}); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	return w.writer.Write([]string{record.ItemID, strconv.FormatInt(record.Count, 10)})
+}
+
+func (w *csvCountersWriter) flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type jsonlCountersWriter struct {
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (w *jsonlCountersWriter) write(record counterRecord) error {
+	return w.encoder.Encode(record)
+}
+
+func (w *jsonlCountersWriter) flush() error {
+	return w.buffer.Flush()
+}
+
+func newCountersWriter(format string, out io.Writer) (countersWriter, error) {
+	switch format {
+	case countersFormatCSV:
+		return &csvCountersWriter{writer: csv.NewWriter(out)}, nil
+	case countersFormatJSONL:
+		buffer := bufio.NewWriter(out)
+		return &jsonlCountersWriter{buffer: buffer, encoder: json.NewEncoder(buffer)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported counters format: %s", format)
+	}
+}
+
+type countersReader interface {
+	// read returns io.EOF when there are no more records
+	read() (counterRecord, error)
+}
+
+type csvCountersReader struct {
+	reader *csv.Reader
+	line   int
+}
+
+func (r *csvCountersReader) read() (counterRecord, error) {
+	for {
+		fields, err := r.reader.Read()
+		if err != nil {
+			return counterRecord{}, err
+		}
+		r.line++
+
+		// header is optional
+		if r.line == 1 && fields[0] == "itemId" {
+			continue
+		}
+
+		count, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return counterRecord{}, fmt.Errorf("line %d: bad count: %w", r.line, err)
+		}
+		record := counterRecord{ItemID: fields[0], Count: count}
+		if err = record.validate(); err != nil {
+			return counterRecord{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		return record, nil
+	}
+}
+
+type jsonlCountersReader struct {
+	decoder *json.Decoder
+	line    int
+}
+
+func (r *jsonlCountersReader) read() (counterRecord, error) {
+	var record counterRecord
+	if err := r.decoder.Decode(&record); err != nil {
+		if errors.Is(err, io.EOF) {
+			return counterRecord{}, err
+		}
+		return counterRecord{}, fmt.Errorf("line %d: %w", r.line+1, err)
+	}
+	r.line++
+	if err := record.validate(); err != nil {
+		return counterRecord{}, fmt.Errorf("line %d: %w", r.line, err)
+	}
+	return record, nil
+}
+
+func newCountersReader(format string, in io.Reader) (countersReader, error) {
+	switch format {
+	case countersFormatCSV:
+		reader := csv.NewReader(in)
+		reader.FieldsPerRecord = 2
+		reader.ReuseRecord = true
+		return &csvCountersReader{reader: reader}, nil
+	case countersFormatJSONL:
+		return &jsonlCountersReader{decoder: json.NewDecoder(bufio.NewReader(in))}, nil
+	default:
+		return nil, fmt.Errorf("unsupported counters format: %s", format)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountersWriter(t *testing.T) {
+	randomRecords := func() []counterRecord {
+		records := make([]counterRecord, 2+rand.IntN(5))
+		for i := range records {
+			records[i] = counterRecord{ItemID: faker.UUIDHyphenated(), Count: rand.Int64()}
+		}
+		return records
+	}
+
+	t.Run("should write csv records with header", func(t *testing.T) {
+		var out bytes.Buffer
+		writer, err := newCountersWriter(countersFormatCSV, &out)
+		require.NoError(t, err)
+
+		records := randomRecords()
+		wantLines := []string{"itemId,count"}
+		for _, record := range records {
+			require.NoError(t, writer.write(record))
+			wantLines = append(wantLines, record.ItemID+","+strconv.FormatInt(record.Count, 10))
+		}
+		require.NoError(t, writer.flush())
+
+		assert.Equal(t, strings.Join(wantLines, "\n")+"\n", out.String())
+	})
+	t.Run("should write jsonl records", func(t *testing.T) {
+		var out bytes.Buffer
+		writer, err := newCountersWriter(countersFormatJSONL, &out)
+		require.NoError(t, err)
+
+		records := randomRecords()
+		for _, record := range records {
+			require.NoError(t, writer.write(record))
+		}
+		require.NoError(t, writer.flush())
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, len(records))
+		for i, line := range lines {
+			var got counterRecord
+			require.NoError(t, json.Unmarshal([]byte(line), &got))
+			assert.Equal(t, records[i], got)
+		}
+	})
+	t.Run("should fail on unsupported format", func(t *testing.T) {
+		_, err := newCountersWriter(faker.Word(), &bytes.Buffer{})
+		require.Error(t, err)
+	})
+}
+
+func TestCountersReader(t *testing.T) {
+	readAll := func(t *testing.T, reader countersReader) ([]counterRecord, error) {
+		t.Helper()
+		records := []counterRecord{}
+		err := readCounters(reader, func(itemID string, count int64) {
+			records = append(records, counterRecord{ItemID: itemID, Count: count})
+		})
+		return records, err
+	}
+
+	t.Run("should read csv records written by the writer", func(t *testing.T) {
+		var data bytes.Buffer
+		writer, err := newCountersWriter(countersFormatCSV, &data)
+		require.NoError(t, err)
+		records := make([]counterRecord, 2+rand.IntN(5))
+		for i := range records {
+			records[i] = counterRecord{ItemID: faker.UUIDHyphenated(), Count: rand.Int64()}
+			require.NoError(t, writer.write(records[i]))
+		}
+		require.NoError(t, writer.flush())
+
+		reader, err := newCountersReader(countersFormatCSV, &data)
+		require.NoError(t, err)
+		got, err := readAll(t, reader)
+		require.NoError(t, err)
+		assert.Equal(t, records, got)
+	})
+	t.Run("should read csv records without header", func(t *testing.T) {
+		itemID := faker.UUIDHyphenated()
+		count := rand.Int64()
+		reader, err := newCountersReader(
+			countersFormatCSV,
+			strings.NewReader(itemID+","+strconv.FormatInt(count, 10)+"\n"),
+		)
+		require.NoError(t, err)
+		got, err := readAll(t, reader)
+		require.NoError(t, err)
+		assert.Equal(t, []counterRecord{{ItemID: itemID, Count: count}}, got)
+	})
+	t.Run("should read jsonl records written by the writer", func(t *testing.T) {
+		var data bytes.Buffer
+		writer, err := newCountersWriter(countersFormatJSONL, &data)
+		require.NoError(t, err)
+		records := make([]counterRecord, 2+rand.IntN(5))
+		for i := range records {
+			records[i] = counterRecord{ItemID: faker.UUIDHyphenated(), Count: rand.Int64()}
+			require.NoError(t, writer.write(records[i]))
+		}
+		require.NoError(t, writer.flush())
+
+		reader, err := newCountersReader(countersFormatJSONL, &data)
+		require.NoError(t, err)
+		got, err := readAll(t, reader)
+		require.NoError(t, err)
+		assert.Equal(t, records, got)
+	})
+	t.Run("should fail on invalid records", func(t *testing.T) {
+		cases := []struct {
+			format string
+			data   string
+		}{
+			{countersFormatCSV, "itemId,count\n" + faker.UUIDHyphenated() + "," + faker.Word() + "\n"},
+			{countersFormatCSV, faker.UUIDHyphenated() + ",1,2\n"},
+			{countersFormatCSV, ",10\n"},
+			{countersFormatCSV, faker.UUIDHyphenated() + ",-1\n"},
+			{countersFormatJSONL, `{"itemId":"` + faker.UUIDHyphenated() + `","count":"bad"}` + "\n"},
+			{countersFormatJSONL, `{"count":10}` + "\n"},
+			{countersFormatJSONL, `{"itemId":"` + faker.UUIDHyphenated() + `","count":-10}` + "\n"},
+		}
+		for _, tc := range cases {
+			t.Run(tc.format+" "+tc.data, func(t *testing.T) {
+				reader, err := newCountersReader(tc.format, strings.NewReader(tc.data))
+				require.NoError(t, err)
+				_, err = readAll(t, reader)
+				require.Error(t, err)
+			})
+		}
+	})
+	t.Run("should fail on unsupported format", func(t *testing.T) {
+		_, err := newCountersReader(faker.Word(), strings.NewReader(""))
+		require.Error(t, err)
+	})
+}
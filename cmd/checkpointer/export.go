@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gemyago/top-k-system-go/internal/services/blobstorage"
+	"github.com/spf13/cobra"
+	"go.uber.org/dig"
+)
+
+func newExportCmd(container *dig.Container) *cobra.Command {
+	type invokeCmdParams struct {
+		dig.In
+
+		CommandParams commandParams
+
+		// services
+		blobstorage.Storage
+	}
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export counters of the check point",
+		Long: "Export counters of the check point for offline analysis. " +
+			"Counters are written to stdout unless the output file is specified. " +
+			"Logs are written to stderr, so they are not mixed with counters.",
+		Annotations: map[string]string{logsToStderrAnnotation: "true"},
+	}
+	noop := false
+	var offset int64
+	format := countersFormatCSV
+	outputFileName := ""
+	cmd.Flags().BoolVar(
+		&noop,
+		"noop",
+		false,
+		"Do not start. Just setup deps and exit. Useful for testing if setup is all working.",
+	)
+	cmd.Flags().Int64Var(&offset, "offset", 0, "Offset of the check point to export. Current one is used if not set")
+	cmd.Flags().StringVar(&format, "format", format, "Output format: csv or jsonl")
+	cmd.Flags().StringVarP(&outputFileName, "output-file", "o", outputFileName,
+		"Blob storage file name to write counters to")
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if _, err := newCountersWriter(format, io.Discard); err != nil {
+			return err
+		}
+		return container.Invoke(func(params invokeCmdParams) error {
+			params.CommandParams.noop = noop
+			return runCommand(params.CommandParams, func(ctx context.Context) error {
+				exportCounters := func(out io.Writer) error {
+					writer, _ := newCountersWriter(format, out)
+					if err := params.CommandParams.AggregationCommands.ExportCheckPoint(
+						ctx,
+						offset,
+						func(itemID string, count int64) error {
+							return writer.write(counterRecord{ItemID: itemID, Count: count})
+						},
+					); err != nil {
+						return err
+					}
+					return writer.flush()
+				}
+				if outputFileName == "" {
+					return exportCounters(cmd.OutOrStdout())
+				}
+
+				reader, writer := io.Pipe()
+				exportDone := make(chan error)
+				go func() {
+					err := exportCounters(writer)
+					writer.CloseWithError(err)
+					exportDone <- err
+				}()
+				uploadErr := params.Storage.Upload(ctx, outputFileName, reader)
+
+				// unblocks the export if the upload has failed before reading everything
+				_ = reader.Close()
+				if exportErr := <-exportDone; exportErr != nil && !errors.Is(exportErr, io.ErrClosedPipe) {
+					return exportErr
+				}
+				if uploadErr != nil {
+					return fmt.Errorf("failed to upload %s: %w", outputFileName, uploadErr)
+				}
+				return nil
+			})
+		})
+	}
+	return cmd
+}
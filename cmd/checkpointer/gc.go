@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"go.uber.org/dig"
+)
+
+func newGCCmd(container *dig.Container) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Prune outdated check points and delete blobs that are no longer referenced",
+	}
+	noop := false
+	cmd.Flags().BoolVar(
+		&noop,
+		"noop",
+		false,
+		"Do not start. Just setup deps and exit. Useful for testing if setup is all working.",
+	)
+	cmd.RunE = func(_ *cobra.Command, _ []string) error {
+		return container.Invoke(func(params commandParams) error {
+			params.noop = noop
+			return runCommand(params, params.AggregationCommands.PruneCheckPoints)
+		})
+	}
+	return cmd
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gemyago/top-k-system-go/internal/app/aggregation"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"go.uber.org/dig"
+)
+
+// readCounters will read all records and invoke the add function for each of them.
+func readCounters(reader countersReader, add func(itemID string, count int64)) error {
+	for {
+		record, err := reader.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		add(record.ItemID, record.Count)
+	}
+}
+
+func newImportCmd(container *dig.Container) *cobra.Command {
+	type invokeCmdParams struct {
+		dig.In
+
+		CommandParams commandParams
+	}
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Create check point from historical counters",
+		Long: "Create check point from the file with historical counters (itemId,count). " +
+			"The check point is anchored at a given offset so the aggregator will continue from the next one.",
+	}
+	noop := false
+	force := false
+	var offset int64
+	format := countersFormatCSV
+	inputFileName := ""
+	cmd.Flags().BoolVar(
+		&noop,
+		"noop",
+		false,
+		"Do not start. Just setup deps and exit. Useful for testing if setup is all working.",
+	)
+	cmd.Flags().Int64Var(&offset, "offset", 0, "Offset to anchor the check point at")
+	cmd.Flags().StringVar(&format, "format", format, "Input format: csv or jsonl")
+	cmd.Flags().StringVarP(&inputFileName, "input-file", "i", inputFileName,
+		"Local file to read counters from")
+	cmd.Flags().BoolVar(&force, "force", force,
+		"Import even if there are existing check points (offset must be above the current one)")
+	lo.Must0(cmd.MarkFlagRequired("input-file"))
+	cmd.RunE = func(_ *cobra.Command, _ []string) error {
+		if offset < 0 {
+			return errors.New("offset must not be negative")
+		}
+		if _, err := newCountersReader(format, nil); err != nil {
+			return err
+		}
+		inputFile, err := os.Open(inputFileName)
+		if err != nil {
+			return fmt.Errorf("failed to open input file: %w", err)
+		}
+		defer inputFile.Close()
+		return container.Invoke(func(params invokeCmdParams) error {
+			params.CommandParams.noop = noop
+			return runCommand(params.CommandParams, func(ctx context.Context) error {
+				countersReader, _ := newCountersReader(format, inputFile)
+				return params.CommandParams.AggregationCommands.ImportCheckPoint(ctx, aggregation.ImportCheckPointParams{
+					LastOffset: offset,
+					Force:      force,
+					ReadCounters: func(add func(itemID string, count int64)) error {
+						return readCounters(countersReader, add)
+					},
+				})
+			})
+		})
+	}
+	return cmd
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gemyago/top-k-system-go/internal/app/aggregation"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"go.uber.org/dig"
+)
+
+const defaultInspectTopN = 10
+
+func writeCheckPointDetails(out io.Writer, details *aggregation.CheckPointDetails) error {
+	const padding = 2
+	w := tabwriter.NewWriter(out, 0, 0, padding, ' ', 0)
+	fmt.Fprintf(w, "Offset:\t%d\n", details.LastOffset)
+	fmt.Fprintf(w, "Current:\t%t\n", details.Current)
+	fmt.Fprintf(w, "Created at:\t%s\n",
+		lo.If(details.CreatedAt.IsZero(), "-").Else(details.CreatedAt.Format(time.RFC3339)),
+	)
+	fmt.Fprintf(w, "Counters blob:\t%s\n", details.CountersBlobFileName)
+	fmt.Fprintf(w, "All time items blob:\t%s\n", details.AllTimeItemsFileName)
+	fmt.Fprintf(w, "Codec:\t%s\n", lo.If(details.Codec == "", "-").Else(details.Codec))
+	fmt.Fprintf(w, "Items count:\t%d\n", details.ItemsCount)
+	fmt.Fprintf(w, "Total events:\t%d\n", details.TotalEvents)
+	fmt.Fprintf(w, "\nTop %d items:\n", len(details.TopItems))
+	fmt.Fprintln(w, "RANK\tITEM ID\tCOUNT")
+	for i, item := range details.TopItems {
+		fmt.Fprintf(w, "%d\t%s\t%d\n", i+1, item.ItemID, item.Count)
+	}
+	return w.Flush()
+}
+
+func newInspectCmd(container *dig.Container) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "Print details of the check point",
+	}
+	noop := false
+	var offset int64
+	topN := defaultInspectTopN
+	cmd.Flags().BoolVar(
+		&noop,
+		"noop",
+		false,
+		"Do not start. Just setup deps and exit. Useful for testing if setup is all working.",
+	)
+	cmd.Flags().Int64Var(&offset, "offset", 0, "Offset of the check point to inspect. Current one is used if not set")
+	cmd.Flags().IntVarP(&topN, "top", "n", topN, "Number of top items to print")
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if topN <= 0 {
+			return errors.New("top must be positive")
+		}
+		return container.Invoke(func(params commandParams) error {
+			params.noop = noop
+			return runCommand(params, func(ctx context.Context) error {
+				details, err := params.AggregationCommands.InspectCheckPoint(ctx, aggregation.InspectCheckPointParams{
+					Offset: offset,
+					TopN:   topN,
+				})
+				if err != nil {
+					return err
+				}
+				return writeCheckPointDetails(cmd.OutOrStdout(), details)
+			})
+		})
+	}
+	return cmd
+}
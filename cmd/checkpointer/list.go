@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gemyago/top-k-system-go/internal/app/aggregation"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"go.uber.org/dig"
+)
+
+func writeCheckPoints(out io.Writer, checkPoints []aggregation.CheckPoint) error {
+	const padding = 2
+	w := tabwriter.NewWriter(out, 0, 0, padding, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tOFFSET\tCREATED AT\tCOUNTERS\tALL TIME ITEMS")
+	for _, checkPoint := range checkPoints {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n",
+			lo.If(checkPoint.Current, "*").Else(""),
+			checkPoint.LastOffset,
+			lo.If(checkPoint.CreatedAt.IsZero(), "-").Else(checkPoint.CreatedAt.Format(time.RFC3339)),
+			checkPoint.CountersBlobFileName,
+			checkPoint.AllTimeItemsFileName,
+		)
+	}
+	return w.Flush()
+}
+
+func newListCmd(container *dig.Container) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List known check points",
+	}
+	noop := false
+	cmd.Flags().BoolVar(
+		&noop,
+		"noop",
+		false,
+		"Do not start. Just setup deps and exit. Useful for testing if setup is all working.",
+	)
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		return container.Invoke(func(params commandParams) error {
+			params.noop = noop
+			return runCommand(params, func(ctx context.Context) error {
+				checkPoints, err := params.AggregationCommands.ListCheckPoints(ctx)
+				if err != nil {
+					return err
+				}
+				return writeCheckPoints(cmd.OutOrStdout(), checkPoints)
+			})
+		})
+	}
+	return cmd
+}
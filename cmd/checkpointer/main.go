@@ -12,6 +12,14 @@ func setupCommands() *cobra.Command {
 	rootCmd := newRootCmd(container)
 	rootCmd.AddCommand(
 		newCreateCheckPointCmd(container),
+		newGCCmd(container),
+		newListCmd(container),
+		newRollbackCmd(container),
+		newInspectCmd(container),
+		newExportCmd(container),
+		newImportCmd(container),
+		newRebuildCmd(container),
+		newVerifyCmd(container),
 	)
 	return rootCmd
 }
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/go-faker/faker/v4"
@@ -15,6 +19,11 @@ func TestMain(t *testing.T) {
 			rootCmd.SetArgs([]string{"create-check-point", "--noop", "--logs-file", "../../test.log"})
 			require.NoError(t, rootCmd.Execute())
 		})
+		t.Run("should invoke the command with gc in noop mode", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SetArgs([]string{"create-check-point", "--noop", "--gc", "--logs-file", "../../test.log"})
+			require.NoError(t, rootCmd.Execute())
+		})
 		t.Run("should fail if bad log level", func(t *testing.T) {
 			rootCmd := setupCommands()
 			rootCmd.SilenceErrors = true
@@ -30,4 +39,153 @@ func TestMain(t *testing.T) {
 			assert.Error(t, rootCmd.Execute())
 		})
 	})
+	t.Run("gc", func(t *testing.T) {
+		t.Run("should invoke the command in noop mode", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SetArgs([]string{"gc", "--noop", "--logs-file", "../../test.log"})
+			require.NoError(t, rootCmd.Execute())
+		})
+	})
+	t.Run("list", func(t *testing.T) {
+		t.Run("should invoke the command in noop mode", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SetArgs([]string{"list", "--noop", "--logs-file", "../../test.log"})
+			require.NoError(t, rootCmd.Execute())
+		})
+	})
+	t.Run("rollback", func(t *testing.T) {
+		t.Run("should invoke the command in noop mode", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SetArgs([]string{"rollback", "--offset", "1", "--noop", "--logs-file", "../../test.log"})
+			require.NoError(t, rootCmd.Execute())
+		})
+	})
+	t.Run("inspect", func(t *testing.T) {
+		t.Run("should invoke the command in noop mode", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SetArgs([]string{"inspect", "--offset", "1", "-n", "5", "--noop", "--logs-file", "../../test.log"})
+			require.NoError(t, rootCmd.Execute())
+		})
+		t.Run("should fail if top is not positive", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SilenceErrors = true
+			rootCmd.SilenceUsage = true
+			rootCmd.SetArgs([]string{"inspect", "-n", "0", "--noop", "--logs-file", "../../test.log"})
+			assert.Error(t, rootCmd.Execute())
+		})
+	})
+	t.Run("export", func(t *testing.T) {
+		t.Run("should invoke the command in noop mode", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SetArgs([]string{"export", "--format", "jsonl", "--noop", "--logs-file", "../../test.log"})
+			require.NoError(t, rootCmd.Execute())
+		})
+		t.Run("should write logs to stderr", func(t *testing.T) {
+			rootCmd := setupCommands()
+			var stdout, stderr bytes.Buffer
+			rootCmd.SetOut(&stdout)
+			rootCmd.SetErr(&stderr)
+			rootCmd.SetArgs([]string{"export", "--noop", "-l", "info"})
+			require.NoError(t, rootCmd.Execute())
+			assert.Empty(t, stdout.String())
+			assert.Contains(t, stderr.String(), "NOOP")
+		})
+		t.Run("should fail if format is not supported", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SilenceErrors = true
+			rootCmd.SilenceUsage = true
+			rootCmd.SetArgs([]string{"export", "--format", "xml", "--noop", "--logs-file", "../../test.log"})
+			assert.Error(t, rootCmd.Execute())
+		})
+	})
+	t.Run("import", func(t *testing.T) {
+		t.Run("should invoke the command in noop mode", func(t *testing.T) {
+			inputFileName := filepath.Join(t.TempDir(), faker.Word()+".jsonl")
+			require.NoError(t, os.WriteFile(inputFileName, []byte(`{"itemId":"item-1","count":10}`), 0o600))
+			rootCmd := setupCommands()
+			rootCmd.SetArgs([]string{
+				"import", "-i", inputFileName, "--offset", "10", "--format", "jsonl",
+				"--noop", "--logs-file", "../../test.log",
+			})
+			require.NoError(t, rootCmd.Execute())
+		})
+		t.Run("should fail if input file does not exist", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SilenceErrors = true
+			rootCmd.SilenceUsage = true
+			rootCmd.SetArgs([]string{
+				"import", "-i", filepath.Join(t.TempDir(), faker.Word()), "--noop", "--logs-file", "../../test.log",
+			})
+			assert.ErrorIs(t, rootCmd.Execute(), fs.ErrNotExist)
+		})
+		t.Run("should fail if format is not supported", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SilenceErrors = true
+			rootCmd.SilenceUsage = true
+			rootCmd.SetArgs([]string{
+				"import", "-i", faker.Word(), "--format", "xml", "--noop", "--logs-file", "../../test.log",
+			})
+			assert.Error(t, rootCmd.Execute())
+		})
+		t.Run("should fail if offset is negative", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SilenceErrors = true
+			rootCmd.SilenceUsage = true
+			rootCmd.SetArgs([]string{
+				"import", "-i", faker.Word(), "--offset", "-1", "--noop", "--logs-file", "../../test.log",
+			})
+			assert.Error(t, rootCmd.Execute())
+		})
+	})
+	t.Run("rebuild", func(t *testing.T) {
+		t.Run("should invoke the command in noop mode", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SetArgs([]string{
+				"rebuild", "--since-offset", "10", "--dry-run", "-n", "5", "--noop", "--logs-file", "../../test.log",
+			})
+			require.NoError(t, rootCmd.Execute())
+		})
+		t.Run("should fail if since offset is negative", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SilenceErrors = true
+			rootCmd.SilenceUsage = true
+			rootCmd.SetArgs([]string{
+				"rebuild", "--since-offset", "-1", "--noop", "--logs-file", "../../test.log",
+			})
+			assert.Error(t, rootCmd.Execute())
+		})
+		t.Run("should fail if diffs is negative", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SilenceErrors = true
+			rootCmd.SilenceUsage = true
+			rootCmd.SetArgs([]string{
+				"rebuild", "-n", "-1", "--noop", "--logs-file", "../../test.log",
+			})
+			assert.Error(t, rootCmd.Execute())
+		})
+	})
+	t.Run("verify", func(t *testing.T) {
+		t.Run("should invoke the command in noop mode", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SetArgs([]string{
+				"verify", "--server-url", "http://localhost:8080", "-n", "10", "-s", "20",
+				"--noop", "--logs-file", "../../test.log",
+			})
+			require.NoError(t, rootCmd.Execute())
+		})
+		t.Run("should fail if top is not positive", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SilenceErrors = true
+			rootCmd.SilenceUsage = true
+			rootCmd.SetArgs([]string{"verify", "-n", "0", "--noop", "--logs-file", "../../test.log"})
+			assert.Error(t, rootCmd.Execute())
+		})
+		t.Run("should fail if sample is negative", func(t *testing.T) {
+			rootCmd := setupCommands()
+			rootCmd.SilenceErrors = true
+			rootCmd.SilenceUsage = true
+			rootCmd.SetArgs([]string{"verify", "-s", "-1", "--noop", "--logs-file", "../../test.log"})
+			assert.Error(t, rootCmd.Execute())
+		})
+	})
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/gemyago/top-k-system-go/internal/app/aggregation"
+	"github.com/spf13/cobra"
+	"go.uber.org/dig"
+)
+
+const defaultRebuildDiffsLimit = 20
+
+func writeRebuildResult(out io.Writer, result *aggregation.RebuildCheckPointResult, diffsLimit int) error {
+	const padding = 2
+	w := tabwriter.NewWriter(out, 0, 0, padding, ' ', 0)
+	fmt.Fprintf(w, "Rebuilt offset:\t%d\n", result.LastOffset)
+	fmt.Fprintf(w, "Items count:\t%d\n", result.ItemsCount)
+	fmt.Fprintf(w, "Total events:\t%d\n", result.TotalEvents)
+	if result.Diffs == nil {
+		return w.Flush()
+	}
+	if result.Current != nil {
+		fmt.Fprintf(w, "Current offset:\t%d\n", result.Current.LastOffset)
+	} else {
+		fmt.Fprintf(w, "Current offset:\t-\n")
+	}
+	fmt.Fprintf(w, "Differences:\t%d\n", len(result.Diffs))
+	if len(result.Diffs) == 0 {
+		return w.Flush()
+	}
+	diffs := result.Diffs[:min(diffsLimit, len(result.Diffs))]
+	fmt.Fprintf(w, "\nFirst %d differences:\n", len(diffs))
+	fmt.Fprintln(w, "ITEM ID\tCURRENT\tREBUILT\tDELTA")
+	for _, diff := range diffs {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%+d\n",
+			diff.ItemID, diff.CurrentCount, diff.RebuiltCount, diff.RebuiltCount-diff.CurrentCount,
+		)
+	}
+	return w.Flush()
+}
+
+func newRebuildCmd(container *dig.Container) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rebuild",
+		Short: "Recompute check point from the stream",
+		Long: "Ignore the current check point and aggregate the stream from a given offset till its tail. " +
+			"The result is written as a new check point or compared with the current one in the dry run mode.",
+	}
+	noop := false
+	dryRun := false
+	var sinceOffset int64
+	diffsLimit := defaultRebuildDiffsLimit
+	cmd.Flags().BoolVar(
+		&noop,
+		"noop",
+		false,
+		"Do not start. Just setup deps and exit. Useful for testing if setup is all working.",
+	)
+	cmd.Flags().Int64Var(&sinceOffset, "since-offset", 0, "Offset to start aggregating from")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", dryRun,
+		"Do not write the check point. Print differences with the current one instead")
+	cmd.Flags().IntVarP(&diffsLimit, "diffs", "n", diffsLimit, "Number of differences to print in the dry run mode")
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if sinceOffset < 0 {
+			return errors.New("since offset must not be negative")
+		}
+		if diffsLimit < 0 {
+			return errors.New("diffs must not be negative")
+		}
+		return container.Invoke(func(params commandParams) error {
+			params.noop = noop
+			return runCommand(params, func(ctx context.Context) error {
+				result, err := params.AggregationCommands.RebuildCheckPoint(ctx, aggregation.RebuildCheckPointParams{
+					SinceOffset: sinceOffset,
+					DryRun:      dryRun,
+				})
+				if err != nil {
+					return err
+				}
+				return writeRebuildResult(cmd.OutOrStdout(), result, diffsLimit)
+			})
+		})
+	}
+	return cmd
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"go.uber.org/dig"
+)
+
+func newRollbackCmd(container *dig.Container) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Make a historical check point current",
+		Long: "Make a historical check point current. The aggregator will restore the state " +
+			"from it and replay the events from its offset on a next start. Servers producing " +
+			"live check points must be stopped first, otherwise their next check point will " +
+			"replace the rolled back one.",
+	}
+	noop := false
+	var offset int64
+	cmd.Flags().BoolVar(
+		&noop,
+		"noop",
+		false,
+		"Do not start. Just setup deps and exit. Useful for testing if setup is all working.",
+	)
+	cmd.Flags().Int64Var(&offset, "offset", 0, "Offset of the check point to rollback to (see list command)")
+	lo.Must0(cmd.MarkFlagRequired("offset"))
+	cmd.RunE = func(_ *cobra.Command, _ []string) error {
+		return container.Invoke(func(params commandParams) error {
+			params.noop = noop
+			return runCommand(params, func(ctx context.Context) error {
+				return params.AggregationCommands.RollbackCheckPoint(ctx, offset)
+			})
+		})
+	}
+	return cmd
+}
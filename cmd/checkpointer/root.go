@@ -16,6 +16,10 @@ import (
 	"go.uber.org/dig"
 )
 
+// logsToStderrAnnotation marks commands that write their results to stdout,
+// so logs of such commands are written to stderr.
+const logsToStderrAnnotation = "logsToStderr"
+
 func newRootCmd(container *dig.Container) *cobra.Command {
 	logsOutputFile := ""
 
@@ -29,7 +33,7 @@ func newRootCmd(container *dig.Container) *cobra.Command {
 		&logsOutputFile,
 		"logs-file",
 		"",
-		"Produce logs to file instead of stdout. Used for tests only.",
+		"Produce logs to file instead of stdout (stderr for commands writing results to stdout). Used for tests only.",
 	)
 	cmd.PersistentFlags().Bool(
 		"json-logs",
@@ -44,7 +48,7 @@ func newRootCmd(container *dig.Container) *cobra.Command {
 	)
 	cfg := config.New()
 	lo.Must0(cfg.BindPFlags(cmd.PersistentFlags()))
-	cmd.PersistentPreRunE = func(_ *cobra.Command, _ []string) error {
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, _ []string) error {
 		err := config.Load(cfg, config.NewLoadOpts().WithEnv(cfg.GetString("env")))
 		if err != nil {
 			return err
@@ -55,12 +59,13 @@ func newRootCmd(container *dig.Container) *cobra.Command {
 			return err
 		}
 
-		rootLogger := diag.SetupRootLogger(
-			diag.NewRootLoggerOpts().
-				WithJSONLogs(cfg.GetBool("jsonLogs")).
-				WithLogLevel(logLevel).
-				WithOptionalOutputFile(logsOutputFile),
-		)
+		rootLoggerOpts := diag.NewRootLoggerOpts().
+			WithJSONLogs(cfg.GetBool("jsonLogs")).
+			WithLogLevel(logLevel)
+		if cmd.Annotations[logsToStderrAnnotation] != "" {
+			rootLoggerOpts = rootLoggerOpts.WithOutput(cmd.ErrOrStderr())
+		}
+		rootLogger := diag.SetupRootLogger(rootLoggerOpts.WithOptionalOutputFile(logsOutputFile))
 
 		err = errors.Join(
 			config.Provide(container, cfg),
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gemyago/top-k-system-go/internal/app/aggregation"
+	"github.com/spf13/cobra"
+	"go.uber.org/dig"
+)
+
+const (
+	defaultVerifyServerURL  = "http://localhost:8080"
+	defaultVerifyTopN       = 100
+	defaultVerifySampleSize = 100
+	defaultVerifyTimeout    = 30 * time.Second
+)
+
+var errStateMismatch = errors.New("live state does not match the check point")
+
+// liveStateClient fetches the snapshot of the live state from the running server.
+type liveStateClient struct {
+	serverURL  string
+	httpClient *http.Client
+}
+
+func (c *liveStateClient) fetchLiveState(
+	ctx context.Context,
+	params aggregation.GetStateSnapshotParams,
+) (*aggregation.GetStateSnapshotResponse, error) {
+	body, err := json.Marshal(struct {
+		Limit   int      `json:"limit"`
+		ItemIDs []string `json:"itemIds"`
+	}{Limit: params.Limit, ItemIDs: params.ItemIDs})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		strings.TrimSuffix(c.serverURL, "/")+"/items/snapshot",
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+	var snapshot aggregation.GetStateSnapshotResponse
+	if err = json.NewDecoder(res.Body).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode state snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+func formatTopKItem(item *aggregation.TopKItem) string {
+	if item == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s (%d)", item.ItemID, item.Count)
+}
+
+func writeVerifyResult(out io.Writer, result *aggregation.VerifyCheckPointResult) error {
+	const padding = 2
+	w := tabwriter.NewWriter(out, 0, 0, padding, ' ', 0)
+	fmt.Fprintf(w, "Check point offset:\t%d\n", result.CheckPoint.LastOffset)
+	fmt.Fprintf(w, "Compared at offset:\t%d\n", result.LastOffset)
+	fmt.Fprintf(w, "Top items compared:\t%d\n", result.TopItemsCount)
+	fmt.Fprintf(w, "Top items mismatches:\t%d\n", len(result.TopItemsMismatches))
+	fmt.Fprintf(w, "Sampled items compared:\t%d\n", result.SampledItemsCount)
+	fmt.Fprintf(w, "Counters mismatches:\t%d\n", len(result.CountersMismatches))
+	if len(result.TopItemsMismatches) > 0 {
+		fmt.Fprintln(w, "\nTop items mismatches:")
+		fmt.Fprintln(w, "RANK\tCHECK POINT\tLIVE")
+		for _, mismatch := range result.TopItemsMismatches {
+			fmt.Fprintf(w, "%d\t%s\t%s\n",
+				mismatch.Rank, formatTopKItem(mismatch.CheckPointItem), formatTopKItem(mismatch.LiveItem),
+			)
+		}
+	}
+	if len(result.CountersMismatches) > 0 {
+		fmt.Fprintln(w, "\nCounters mismatches:")
+		fmt.Fprintln(w, "ITEM ID\tCHECK POINT\tLIVE")
+		for _, mismatch := range result.CountersMismatches {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", mismatch.ItemID, mismatch.CheckPointCount, mismatch.LiveCount)
+		}
+	}
+	return w.Flush()
+}
+
+func newVerifyCmd(container *dig.Container) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Compare the live state of the running server with the check point",
+		Long: "Load the check point, aggregate it till the offset reported by the running server and compare " +
+			"top items and counters of randomly sampled items. Fails if mismatches are found.",
+	}
+	noop := false
+	var offset int64
+	serverURL := defaultVerifyServerURL
+	topN := defaultVerifyTopN
+	sampleSize := defaultVerifySampleSize
+	timeout := defaultVerifyTimeout
+	cmd.Flags().BoolVar(
+		&noop,
+		"noop",
+		false,
+		"Do not start. Just setup deps and exit. Useful for testing if setup is all working.",
+	)
+	cmd.Flags().Int64Var(&offset, "offset", 0, "Offset of the check point to verify with. Current one is used if not set")
+	cmd.Flags().StringVar(&serverURL, "server-url", serverURL, "Base URL of the running server")
+	cmd.Flags().IntVarP(&topN, "top", "n", topN, "Number of top items to compare")
+	cmd.Flags().IntVarP(&sampleSize, "sample", "s", sampleSize, "Number of random items to compare counters of")
+	cmd.Flags().DurationVar(&timeout, "timeout", timeout, "Timeout of the request to the server")
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		if topN <= 0 {
+			return errors.New("top must be positive")
+		}
+		if sampleSize < 0 {
+			return errors.New("sample must not be negative")
+		}
+		client := &liveStateClient{
+			serverURL:  serverURL,
+			httpClient: &http.Client{Timeout: timeout},
+		}
+		return container.Invoke(func(params commandParams) error {
+			params.noop = noop
+			return runCommand(params, func(ctx context.Context) error {
+				result, err := params.AggregationCommands.VerifyCheckPoint(ctx, aggregation.VerifyCheckPointParams{
+					Offset:         offset,
+					TopN:           topN,
+					SampleSize:     sampleSize,
+					FetchLiveState: client.fetchLiveState,
+				})
+				if err != nil {
+					return err
+				}
+				if err = writeVerifyResult(cmd.OutOrStdout(), result); err != nil {
+					return err
+				}
+				if !result.Matches() {
+					return errStateMismatch
+				}
+				return nil
+			})
+		})
+	}
+	return cmd
+}
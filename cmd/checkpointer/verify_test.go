@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gemyago/top-k-system-go/internal/app/aggregation"
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveStateClient(t *testing.T) {
+	newClient := func(t *testing.T, handler http.HandlerFunc) *liveStateClient {
+		srv := httptest.NewServer(handler)
+		t.Cleanup(srv.Close)
+		return &liveStateClient{serverURL: srv.URL + "/", httpClient: srv.Client()}
+	}
+
+	t.Run("should fetch state snapshot", func(t *testing.T) {
+		wantParams := aggregation.GetStateSnapshotParams{
+			Limit:   1 + rand.IntN(100),
+			ItemIDs: []string{faker.UUIDHyphenated(), faker.UUIDHyphenated()},
+		}
+		wantSnapshot := &aggregation.GetStateSnapshotResponse{
+			LastOffset: rand.Int64(),
+			TopItems:   []aggregation.TopKItem{{ItemID: faker.UUIDHyphenated(), Count: rand.Int64N(100)}},
+			ItemsCounters: map[string]int64{
+				wantParams.ItemIDs[0]: rand.Int64N(100),
+			},
+		}
+		client := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "/items/snapshot", r.URL.Path)
+			var body struct {
+				Limit   int      `json:"limit"`
+				ItemIDs []string `json:"itemIds"`
+			}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, wantParams.Limit, body.Limit)
+			assert.Equal(t, wantParams.ItemIDs, body.ItemIDs)
+			assert.NoError(t, json.NewEncoder(w).Encode(wantSnapshot))
+		})
+
+		got, err := client.fetchLiveState(context.Background(), wantParams)
+		require.NoError(t, err)
+		assert.Equal(t, wantSnapshot, got)
+	})
+	t.Run("should fail if unexpected status", func(t *testing.T) {
+		client := newClient(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+		_, err := client.fetchLiveState(context.Background(), aggregation.GetStateSnapshotParams{})
+		require.ErrorContains(t, err, "503")
+	})
+	t.Run("should fail if bad response", func(t *testing.T) {
+		client := newClient(t, func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(faker.Word()))
+		})
+		_, err := client.fetchLiveState(context.Background(), aggregation.GetStateSnapshotParams{})
+		require.Error(t, err)
+	})
+}
+
+func TestWriteVerifyResult(t *testing.T) {
+	t.Run("should write mismatches", func(t *testing.T) {
+		itemID := faker.UUIDHyphenated()
+		var out bytes.Buffer
+		require.NoError(t, writeVerifyResult(&out, &aggregation.VerifyCheckPointResult{
+			TopItemsMismatches: []aggregation.TopItemMismatch{
+				{Rank: 1, CheckPointItem: &aggregation.TopKItem{ItemID: itemID, Count: 10}},
+			},
+			CountersMismatches: []aggregation.CounterMismatch{
+				{ItemID: itemID, CheckPointCount: 10, LiveCount: 9},
+			},
+		}))
+		assert.Contains(t, out.String(), itemID+" (10)")
+		assert.Contains(t, out.String(), "Counters mismatches:")
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/gemyago/top-k-system-go/internal/api/http/routes"
 	"github.com/gemyago/top-k-system-go/internal/api/http/server"
 	"github.com/gemyago/top-k-system-go/internal/app/aggregation"
+	"github.com/gemyago/top-k-system-go/internal/app/ingestion"
 	"github.com/gemyago/top-k-system-go/internal/di"
 	"github.com/gemyago/top-k-system-go/internal/diag"
 	"github.com/gemyago/top-k-system-go/internal/services"
@@ -25,6 +26,7 @@ type runHTTPServerParams struct {
 
 	HTTPServer          *server.HTTPServer
 	AggregationCommands *aggregation.Commands
+	IngestionCommands   *ingestion.Commands
 
 	*services.ShutdownHooks
 
@@ -71,6 +73,9 @@ func runHTTPServer(params runHTTPServerParams) error {
 		}
 		startupErrors <- params.AggregationCommands.StartAggregator(signalCtx)
 	}()
+	if !params.noop {
+		go params.IngestionCommands.StartHeartbeats(signalCtx)
+	}
 
 	var startupErr error
 	select {
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// unmatchedRoute is used as a route label of requests that were not matched
+// by any route, to keep the cardinality of the metric bounded.
+const unmatchedRoute = "unmatched"
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// NewMetricsMiddleware creates a middleware that will observe latency and status of requests.
+// Requests are labeled with the matched route pattern so it should be placed after
+// middlewares that replace the request (e.g to update the context), right before the router.
+func NewMetricsMiddleware(registerer prometheus.Registerer) Middleware {
+	requestDuration := promauto.With(registerer).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of http requests",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			startedAt := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, req)
+
+			// router will set the pattern once matched
+			route := req.Pattern
+			if route == "" {
+				route = unmatchedRoute
+			}
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			requestDuration.WithLabelValues(
+				req.Method, route, strconv.Itoa(status),
+			).Observe(time.Since(startedAt).Seconds())
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gemyago/top-k-system-go/internal/diag"
+	"github.com/go-faker/faker/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	requestsCount := func(t *testing.T, registry *prometheus.Registry, method, route, status string) uint64 {
+		families, err := registry.Gather()
+		require.NoError(t, err)
+		for _, family := range families {
+			if family.GetName() != "http_request_duration_seconds" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				labels := map[string]string{}
+				for _, label := range metric.GetLabel() {
+					labels[label.GetName()] = label.GetValue()
+				}
+				if labels["method"] == method && labels["route"] == route && labels["status"] == status {
+					return metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+		return 0
+	}
+
+	t.Run("should observe requests with matched route and status", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		wantStatus := 200 + rand.Intn(399)
+		resource := faker.Word()
+		route := "/" + resource + "/{id}"
+		mux := http.NewServeMux()
+		mux.Handle("POST "+route, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(wantStatus)
+		}))
+		handler := NewMetricsMiddleware(registry)(mux)
+
+		req := httptest.NewRequest(http.MethodPost, "/"+resource+"/"+faker.UUIDHyphenated(), http.NoBody)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, wantStatus, w.Code)
+		assert.Equal(t, uint64(1),
+			requestsCount(t, registry, http.MethodPost, "POST "+route, strconv.Itoa(wantStatus)),
+		)
+	})
+
+	t.Run("should use 200 status if not written explicitly", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		mux := http.NewServeMux()
+		mux.Handle("GET /data", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(faker.Sentence()))
+		}))
+		handler := NewMetricsMiddleware(registry)(mux)
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/data", http.NoBody))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/data", http.NoBody))
+		assert.Equal(t, uint64(2), requestsCount(t, registry, http.MethodGet, "GET /data", "200"))
+	})
+
+	t.Run("should label unmatched routes", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		handler := NewMetricsMiddleware(registry)(http.NewServeMux())
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+faker.Word(), http.NoBody))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, uint64(1), requestsCount(t, registry, http.MethodGet, unmatchedRoute, "404"))
+	})
+
+	t.Run("should observe status of recovered panics", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		mux := http.NewServeMux()
+		mux.Handle("GET /panic", http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+			panic(faker.Sentence())
+		}))
+		handler := Chain(
+			NewMetricsMiddleware(registry),
+			NewRecovererMiddleware(diag.RootTestLogger()),
+		)(mux)
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", http.NoBody))
+		assert.Equal(t, 1, testutil.CollectAndCount(registry, "http_request_duration_seconds"))
+		assert.Equal(t, uint64(1), requestsCount(t, registry, http.MethodGet, "GET /panic", "500"))
+	})
+}
@@ -3,13 +3,35 @@ package middleware
 import (
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/gemyago/top-k-system-go/internal/diag"
 	"github.com/gofrs/uuid/v5"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
+const tracerName = "github.com/gemyago/top-k-system-go/internal/api/http"
+
 type TracingMiddlewareCfg struct {
-	generateUUID func() string
+	generateUUID   func() string
+	tracerProvider trace.TracerProvider
+	propagator     propagation.TextMapPropagator
+}
+
+// WithTracerProvider sets the provider of request spans. Spans are not recorded by default.
+func (cfg *TracingMiddlewareCfg) WithTracerProvider(provider trace.TracerProvider) *TracingMiddlewareCfg {
+	cfg.tracerProvider = provider
+	return cfg
+}
+
+// WithPropagator sets the propagator to extract the trace context of incoming requests.
+func (cfg *TracingMiddlewareCfg) WithPropagator(propagator propagation.TextMapPropagator) *TracingMiddlewareCfg {
+	cfg.propagator = propagator
+	return cfg
 }
 
 func NewTracingMiddlewareCfg() *TracingMiddlewareCfg {
@@ -17,21 +39,75 @@ func NewTracingMiddlewareCfg() *TracingMiddlewareCfg {
 		generateUUID: func() string {
 			return uuid.Must(uuid.NewV4()).String()
 		},
+		tracerProvider: noop.NewTracerProvider(),
+		propagator:     propagation.TraceContext{},
 	}
 }
 
+// NewTracingMiddleware creates a middleware that will start the server span of the request
+// (continuing the trace of the caller if any) and set the correlation id of the logs.
 func NewTracingMiddleware(cfg *TracingMiddlewareCfg) Middleware {
 	generateUUID := cfg.generateUUID
+	tracer := cfg.tracerProvider.Tracer(tracerName)
+	propagator := cfg.propagator
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			correlationID := req.Header.Get("x-correlation-id")
+			ctx := propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+			// span is renamed once the route is matched (see NewTracingRouteMiddleware)
+			ctx, span := tracer.Start(ctx, req.Method,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(req.Method),
+					semconv.URLPath(req.URL.Path),
+				),
+			)
+			defer span.End()
+
+			correlationID := req.Header.Get(diag.CorrelationIDHeader)
 			if correlationID == "" {
 				correlationID = generateUUID()
 			}
-			logAttributes := diag.GetLogAttributesFromContext(req.Context())
+			w.Header().Set(diag.CorrelationIDHeader, correlationID)
+			logAttributes := diag.GetLogAttributesFromContext(ctx)
 			logAttributes.CorrelationID = slog.StringValue(correlationID)
-			nextCtx := diag.SetLogAttributesToContext(req.Context(), logAttributes)
-			next.ServeHTTP(w, req.WithContext(nextCtx))
+			nextCtx := diag.SetLogAttributesToContext(ctx, logAttributes)
+
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, req.WithContext(nextCtx))
+
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+		})
+	}
+}
+
+// NewTracingRouteMiddleware creates a middleware that will name the request span after
+// the matched route pattern. Similarly to the metrics middleware, it should be placed
+// right before the router.
+func NewTracingRouteMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, req)
+
+			// router will set the pattern once matched, unmatched requests
+			// keep the method as the span name
+			if req.Pattern == "" {
+				return
+			}
+			_, route, found := strings.Cut(req.Pattern, " ")
+			if !found {
+				route = req.Pattern
+			}
+			span := trace.SpanFromContext(req.Context())
+			span.SetName(req.Pattern)
+			span.SetAttributes(semconv.HTTPRoute(route))
 		})
 	}
 }
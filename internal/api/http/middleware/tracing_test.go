@@ -8,6 +8,12 @@ import (
 	"github.com/gemyago/top-k-system-go/internal/diag"
 	"github.com/go-faker/faker/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestTracingMiddleware(t *testing.T) {
@@ -19,6 +25,7 @@ func TestTracingMiddleware(t *testing.T) {
 		mw(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
 			logAttributes := diag.GetLogAttributesFromContext(r.Context())
 			assert.NotEmpty(t, logAttributes.CorrelationID.String())
+			assert.Equal(t, logAttributes.CorrelationID.String(), res.Header().Get("X-Correlation-ID"))
 			nextCalled = true
 		})).ServeHTTP(res, req)
 		assert.True(t, nextCalled)
@@ -36,5 +43,79 @@ func TestTracingMiddleware(t *testing.T) {
 			nextCalled = true
 		})).ServeHTTP(res, req)
 		assert.True(t, nextCalled)
+		assert.Equal(t, wantCorrelationID, res.Header().Get("X-Correlation-ID"))
+	})
+	t.Run("start server span continuing the trace of the caller", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		req := httptest.NewRequest(http.MethodGet, "/something", http.NoBody)
+		req.Header.Add("traceparent", "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01")
+		res := httptest.NewRecorder()
+		mw := NewTracingMiddleware(NewTracingMiddlewareCfg().WithTracerProvider(provider))
+		var gotSpanContext trace.SpanContext
+		mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotSpanContext = trace.SpanContextFromContext(r.Context())
+			w.WriteHeader(http.StatusAccepted)
+		})).ServeHTTP(res, req)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		span := spans[0]
+		assert.Equal(t, gotSpanContext, span.SpanContext())
+		assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", span.SpanContext().TraceID().String())
+		assert.Equal(t, "0102030405060708", span.Parent().SpanID().String())
+		assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+		assert.Equal(t, http.MethodGet, span.Name())
+		assert.Contains(t, span.Attributes(), semconv.HTTPResponseStatusCode(http.StatusAccepted))
+		assert.Equal(t, codes.Unset, span.Status().Code)
+	})
+	t.Run("mark span as failed on server errors", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		req := httptest.NewRequest(http.MethodGet, "/something", http.NoBody)
+		res := httptest.NewRecorder()
+		mw := NewTracingMiddleware(NewTracingMiddlewareCfg().WithTracerProvider(provider))
+		mw(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})).ServeHTTP(res, req)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+	})
+}
+
+func TestTracingRouteMiddleware(t *testing.T) {
+	newTracedHandler := func(provider trace.TracerProvider) http.Handler {
+		mux := http.NewServeMux()
+		mux.Handle("GET /items/{itemID}", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		return Chain(
+			NewTracingMiddleware(NewTracingMiddlewareCfg().WithTracerProvider(provider)),
+			NewTracingRouteMiddleware(),
+		)(mux)
+	}
+
+	t.Run("name span after the matched route", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		handler := newTracedHandler(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		req := httptest.NewRequest(http.MethodGet, "/items/"+faker.UUIDHyphenated(), http.NoBody)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "GET /items/{itemID}", spans[0].Name())
+		assert.Contains(t, spans[0].Attributes(), semconv.HTTPRoute("/items/{itemID}"))
+	})
+	t.Run("keep method as a name of unmatched requests", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		handler := newTracedHandler(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		req := httptest.NewRequest(http.MethodGet, "/"+faker.Word(), http.NoBody)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, http.MethodGet, spans[0].Name())
 	})
 }
@@ -1,9 +1,11 @@
 package routes
 
 import (
+	"encoding/json"
 	"log/slog"
 	"net/http"
 
+	"github.com/gemyago/top-k-system-go/internal/diag"
 	"go.uber.org/dig"
 )
 
@@ -11,8 +13,14 @@ type HealthCheckDeps struct {
 	dig.In
 
 	RootLogger *slog.Logger
+
+	// app layer
+	Queries aggregationQueries
 }
 
+// NewHealthCheckRoutesGroup will mount the liveness (/health), readiness (/ready)
+// and aggregation progress (/status) endpoints. The instance is ready once the
+// live state is restored and caught up with the stream.
 func NewHealthCheckRoutesGroup(deps HealthCheckDeps) Group {
 	return Group{
 		Mount: MountFunc(func(r router) {
@@ -21,6 +29,35 @@ func NewHealthCheckRoutesGroup(deps HealthCheckDeps) Group {
 				w.WriteHeader(http.StatusOK)
 				WriteData(req, log, w, []byte("OK"))
 			}))
+			r.Handle("GET /ready", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				status, err := deps.Queries.GetAggregationStatus(req.Context())
+				if err != nil {
+					log.ErrorContext(req.Context(), "Failed to get aggregation status", diag.ErrAttr(err))
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				if !status.Ready() {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					WriteData(req, log, w, []byte(status.Phase))
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+				WriteData(req, log, w, []byte("OK"))
+			}))
+			r.Handle("GET /status", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				status, err := deps.Queries.GetAggregationStatus(req.Context())
+				if err != nil {
+					log.ErrorContext(req.Context(), "Failed to get aggregation status", diag.ErrAttr(err))
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				if err = json.NewEncoder(w).Encode(status); err != nil {
+					log.ErrorContext(req.Context(), "Failed to encode response", diag.ErrAttr(err))
+					return
+				}
+			}))
 		}),
 	}
 }
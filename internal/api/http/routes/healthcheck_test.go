@@ -1,12 +1,20 @@
 package routes
 
 import (
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gemyago/top-k-system-go/internal/app/aggregation"
 	"github.com/gemyago/top-k-system-go/internal/diag"
+	"github.com/go-faker/faker/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHealthCheckRoutes(t *testing.T) {
@@ -14,10 +22,11 @@ func TestHealthCheckRoutes(t *testing.T) {
 		HealthCheckDeps
 		Mux *http.ServeMux
 	}
-	makeDeps := func() mockDeps {
+	makeDeps := func(t *testing.T) mockDeps {
 		mux := http.NewServeMux()
 		deps := HealthCheckDeps{
 			RootLogger: diag.RootTestLogger(),
+			Queries:    aggregation.NewMockQueries(t),
 		}
 		return mockDeps{
 			HealthCheckDeps: deps,
@@ -29,7 +38,7 @@ func TestHealthCheckRoutes(t *testing.T) {
 		t.Run("should respond with OK", func(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/health", http.NoBody)
 			w := httptest.NewRecorder()
-			deps := makeDeps()
+			deps := makeDeps(t)
 			NewHealthCheckRoutesGroup(deps.HealthCheckDeps).Mount(deps.Mux)
 			deps.Mux.ServeHTTP(w, req)
 
@@ -37,4 +46,96 @@ func TestHealthCheckRoutes(t *testing.T) {
 			assert.Equal(t, "OK", w.Body.String())
 		})
 	})
+
+	t.Run("GET /ready", func(t *testing.T) {
+		t.Run("should respond with OK if aggregation is live", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ready", http.NoBody)
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			mockQueries.EXPECT().GetAggregationStatus(mock.Anything).Return(&aggregation.AggregationStatus{
+				Phase: aggregation.AggregationPhaseLive,
+			}, nil)
+			NewHealthCheckRoutesGroup(deps.HealthCheckDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "OK", w.Body.String())
+		})
+		t.Run("should respond with service unavailable if aggregation is not live", func(t *testing.T) {
+			for _, phase := range []aggregation.AggregationPhase{
+				aggregation.AggregationPhaseStarting,
+				aggregation.AggregationPhaseRestoring,
+				aggregation.AggregationPhaseCatchingUp,
+			} {
+				req := httptest.NewRequest(http.MethodGet, "/ready", http.NoBody)
+				w := httptest.NewRecorder()
+				deps := makeDeps(t)
+				mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+				mockQueries.EXPECT().GetAggregationStatus(mock.Anything).Return(&aggregation.AggregationStatus{
+					Phase: phase,
+				}, nil)
+				NewHealthCheckRoutesGroup(deps.HealthCheckDeps).Mount(deps.Mux)
+				deps.Mux.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusServiceUnavailable, w.Code, phase)
+				assert.Equal(t, string(phase), w.Body.String())
+			}
+		})
+		t.Run("should handle query error", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ready", http.NoBody)
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			mockQueries.EXPECT().GetAggregationStatus(mock.Anything).Return(nil, errors.New(faker.Sentence()))
+			NewHealthCheckRoutesGroup(deps.HealthCheckDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+		})
+	})
+
+	t.Run("GET /status", func(t *testing.T) {
+		t.Run("should respond with aggregation status", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/status", http.NoBody)
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+			completedAt := time.Now().UTC()
+			offsetLag := rand.Int64N(1000)
+			wantStatus := &aggregation.AggregationStatus{
+				Phase: aggregation.AggregationPhaseCatchingUp,
+				Restore: aggregation.AggregationRestoreStatus{
+					CheckPointOffset: rand.Int64N(1000),
+					RestoredBlobs:    2,
+					TotalBlobs:       2,
+					RestoredItems:    rand.IntN(1000),
+					StartedAt:        completedAt.Add(-time.Duration(1+rand.IntN(1000)) * time.Second),
+					CompletedAt:      &completedAt,
+				},
+				LastOffset: rand.Int64N(1000),
+				OffsetLag:  &offsetLag,
+			}
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			mockQueries.EXPECT().GetAggregationStatus(mock.Anything).Return(wantStatus, nil)
+			NewHealthCheckRoutesGroup(deps.HealthCheckDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			var gotStatus aggregation.AggregationStatus
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &gotStatus))
+			assert.Equal(t, *wantStatus, gotStatus)
+		})
+		t.Run("should handle query error", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/status", http.NoBody)
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			mockQueries.EXPECT().GetAggregationStatus(mock.Anything).Return(nil, errors.New(faker.Sentence()))
+			NewHealthCheckRoutesGroup(deps.HealthCheckDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+		})
+	})
 }
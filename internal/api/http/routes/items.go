@@ -3,9 +3,11 @@ package routes
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gemyago/top-k-system-go/internal/app/aggregation"
 	"github.com/gemyago/top-k-system-go/internal/app/models"
@@ -23,6 +25,67 @@ type aggregationQueries interface {
 		_ context.Context,
 		params aggregation.GetTopKItemsParams,
 	) (*aggregation.GetTopKItemsResponse, error)
+
+	GetStateSnapshot(
+		ctx context.Context,
+		params aggregation.GetStateSnapshotParams,
+	) (*aggregation.GetStateSnapshotResponse, error)
+
+	GetAggregationStatus(_ context.Context) (*aggregation.AggregationStatus, error)
+
+	GetItemStats(
+		_ context.Context,
+		params aggregation.GetItemStatsParams,
+	) (*aggregation.GetItemStatsResponse, error)
+
+	GetItemsCounts(
+		_ context.Context,
+		params aggregation.GetItemsCountsParams,
+	) (*aggregation.GetItemsCountsResponse, error)
+
+	GetItemRank(
+		_ context.Context,
+		params aggregation.GetItemRankParams,
+	) (*aggregation.GetItemRankResponse, error)
+}
+
+// maxSnapshotItemIDs is a maximum number of items that can be requested in the snapshot.
+const maxSnapshotItemIDs = 10000
+
+// maxCountsItemIDs is a maximum number of items that can be requested in the counts lookup.
+const maxCountsItemIDs = 1000
+
+// maxRequestItemIDSize is a size of a single item ID (including quotes and separator)
+// allowed in the request body. Used to limit the body before it is decoded.
+const maxRequestItemIDSize = 256
+
+// maxRequestBodyOverhead is a size of the request body allowed besides item IDs.
+const maxRequestBodyOverhead = 1024
+
+// itemIDsBodyLimit is a maximum size of the request body with a given number of item IDs.
+func itemIDsBodyLimit(maxItemIDs int) int64 {
+	return int64(maxItemIDs*maxRequestItemIDSize + maxRequestBodyOverhead)
+}
+
+// decodeErrorStatus will respond with 413 if the body is above the limit.
+func decodeErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// topItemsWindowAllTime is a default window of top items.
+const topItemsWindowAllTime = "all-time"
+
+type itemsCountsRequest struct {
+	ItemIDs []string `json:"itemIds"`
+}
+
+type stateSnapshotRequest struct {
+	Limit   int      `json:"limit"`
+	ItemIDs []string `json:"itemIds"`
 }
 
 type ItemsRoutesDeps struct {
@@ -30,6 +93,9 @@ type ItemsRoutesDeps struct {
 
 	RootLogger *slog.Logger
 
+	// config
+	AllTimeItemsCapacity int `name:"config.aggregator.topK.allTime.capacity"`
+
 	// app layer
 	Commands ingestionCommands
 	Queries  aggregationQueries
@@ -41,21 +107,176 @@ type ItemsRoutesDeps struct {
 func NewItemsRoutesGroup(deps ItemsRoutesDeps) Group {
 	commands := deps.Commands
 	logger := deps.RootLogger.WithGroup("items-routes")
+
+	// topItemsCapacities are max limits of top items per window
+	topItemsCapacities := map[string]int{
+		topItemsWindowAllTime: deps.AllTimeItemsCapacity,
+	}
 	return Group{
 		Mount: func(r router) {
 			r.Handle("GET /items/top", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				query := r.URL.Query()
+				window := query.Get("window")
+				if window == "" {
+					window = topItemsWindowAllTime
+				}
+				capacity, ok := topItemsCapacities[window]
+				if !ok {
+					logger.ErrorContext(r.Context(), "Unknown window", slog.String("window", window))
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
 				limit, err := strconv.ParseInt(query.Get("limit"), 10, 64)
 				if err != nil {
 					logger.ErrorContext(r.Context(), "Failed to parse limit", diag.ErrAttr(err))
 					w.WriteHeader(http.StatusBadRequest)
 					return
 				}
+				if limit <= 0 || limit > int64(capacity) {
+					logger.ErrorContext(r.Context(), "Limit is out of range",
+						slog.Int64("limit", limit),
+						slog.Int("capacity", capacity),
+					)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				var maxStaleness time.Duration
+				if rawMaxStaleness := query.Get("maxStaleness"); rawMaxStaleness != "" {
+					maxStaleness, err = time.ParseDuration(rawMaxStaleness)
+					if err != nil || maxStaleness < 0 {
+						logger.ErrorContext(r.Context(), "Failed to parse max staleness",
+							slog.String("maxStaleness", rawMaxStaleness),
+						)
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+				}
 				resp, err := deps.Queries.GetTopKItems(r.Context(), aggregation.GetTopKItemsParams{
-					Limit: int(limit),
+					Limit:        int(limit),
+					MaxStaleness: maxStaleness,
 				})
 				if err != nil {
 					logger.ErrorContext(r.Context(), "Failed to get top items", diag.ErrAttr(err))
+					if errors.Is(err, aggregation.ErrLiveStateNotReady) || errors.Is(err, aggregation.ErrLiveStateStale) {
+						w.WriteHeader(http.StatusServiceUnavailable)
+						return
+					}
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				if err = json.NewEncoder(w).Encode(resp); err != nil {
+					logger.ErrorContext(r.Context(), "Failed to encode response", diag.ErrAttr(err))
+					return
+				}
+			}))
+			r.Handle("GET /items/{itemID}/stats", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				itemID := r.PathValue("itemID")
+				resp, err := deps.Queries.GetItemStats(r.Context(), aggregation.GetItemStatsParams{
+					ItemID: itemID,
+				})
+				if err != nil {
+					logger.ErrorContext(r.Context(), "Failed to get item stats", slog.String("itemID", itemID), diag.ErrAttr(err))
+					if errors.Is(err, aggregation.ErrLiveStateNotReady) {
+						w.WriteHeader(http.StatusServiceUnavailable)
+						return
+					}
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				if err = json.NewEncoder(w).Encode(resp); err != nil {
+					logger.ErrorContext(r.Context(), "Failed to encode response", diag.ErrAttr(err))
+					return
+				}
+			}))
+			r.Handle("GET /items/{itemID}/rank", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				itemID := r.PathValue("itemID")
+				resp, err := deps.Queries.GetItemRank(r.Context(), aggregation.GetItemRankParams{
+					ItemID: itemID,
+				})
+				if err != nil {
+					logger.ErrorContext(r.Context(), "Failed to get item rank", slog.String("itemID", itemID), diag.ErrAttr(err))
+					switch {
+					case errors.Is(err, aggregation.ErrRankIndexDisabled):
+						w.WriteHeader(http.StatusNotImplemented)
+					case errors.Is(err, aggregation.ErrLiveStateNotReady):
+						w.WriteHeader(http.StatusServiceUnavailable)
+					default:
+						w.WriteHeader(http.StatusInternalServerError)
+					}
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				if err = json.NewEncoder(w).Encode(resp); err != nil {
+					logger.ErrorContext(r.Context(), "Failed to encode response", diag.ErrAttr(err))
+					return
+				}
+			}))
+			r.Handle("POST /items/counts", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body itemsCountsRequest
+				r.Body = http.MaxBytesReader(w, r.Body, itemIDsBodyLimit(maxCountsItemIDs))
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					logger.ErrorContext(r.Context(), "Failed to decode counts request", diag.ErrAttr(err))
+					w.WriteHeader(decodeErrorStatus(err))
+					return
+				}
+				if len(body.ItemIDs) > maxCountsItemIDs {
+					logger.ErrorContext(r.Context(), "Too many items requested",
+						slog.Int("itemIDsCount", len(body.ItemIDs)),
+					)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				resp, err := deps.Queries.GetItemsCounts(r.Context(), aggregation.GetItemsCountsParams{
+					ItemIDs: body.ItemIDs,
+				})
+				if err != nil {
+					logger.ErrorContext(r.Context(), "Failed to get items counts", diag.ErrAttr(err))
+					if errors.Is(err, aggregation.ErrLiveStateNotReady) {
+						w.WriteHeader(http.StatusServiceUnavailable)
+						return
+					}
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				if err = json.NewEncoder(w).Encode(resp); err != nil {
+					logger.ErrorContext(r.Context(), "Failed to encode response", diag.ErrAttr(err))
+					return
+				}
+			}))
+			r.Handle("POST /items/snapshot", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body stateSnapshotRequest
+				r.Body = http.MaxBytesReader(w, r.Body, itemIDsBodyLimit(maxSnapshotItemIDs))
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					logger.ErrorContext(r.Context(), "Failed to decode snapshot request", diag.ErrAttr(err))
+					w.WriteHeader(decodeErrorStatus(err))
+					return
+				}
+				if body.Limit <= 0 || body.Limit > deps.AllTimeItemsCapacity || len(body.ItemIDs) > maxSnapshotItemIDs {
+					logger.ErrorContext(r.Context(), "Invalid snapshot request",
+						slog.Int("limit", body.Limit),
+						slog.Int("capacity", deps.AllTimeItemsCapacity),
+						slog.Int("itemIDsCount", len(body.ItemIDs)),
+					)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				resp, err := deps.Queries.GetStateSnapshot(r.Context(), aggregation.GetStateSnapshotParams{
+					Limit:   body.Limit,
+					ItemIDs: body.ItemIDs,
+				})
+				if err != nil {
+					logger.ErrorContext(r.Context(), "Failed to get state snapshot", diag.ErrAttr(err))
+					if errors.Is(err, aggregation.ErrLiveStateUnavailable) {
+						w.WriteHeader(http.StatusServiceUnavailable)
+						return
+					}
 					w.WriteHeader(http.StatusInternalServerError)
 					return
 				}
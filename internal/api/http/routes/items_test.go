@@ -1,13 +1,17 @@
 package routes
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand/v2"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gemyago/top-k-system-go/internal/app/aggregation"
 	"github.com/gemyago/top-k-system-go/internal/app/ingestion"
@@ -28,10 +32,11 @@ func TestItemsRoutes(t *testing.T) {
 	makeDeps := func(t *testing.T) mockDeps {
 		mux := http.NewServeMux()
 		deps := ItemsRoutesDeps{
-			RootLogger: diag.RootTestLogger(),
-			Commands:   ingestion.NewMockCommands(t),
-			Queries:    aggregation.NewMockQueries(t),
-			Time:       services.NewMockNow(),
+			RootLogger:           diag.RootTestLogger(),
+			AllTimeItemsCapacity: 200 + rand.IntN(1000),
+			Commands:             ingestion.NewMockCommands(t),
+			Queries:              aggregation.NewMockQueries(t),
+			Time:                 services.NewMockNow(),
 		}
 		return mockDeps{
 			ItemsRoutesDeps: deps,
@@ -84,6 +89,79 @@ func TestItemsRoutes(t *testing.T) {
 			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &gotResponse))
 		})
 
+		t.Run("should pass max staleness", func(t *testing.T) {
+			wantLimit := 100 + rand.IntN(100)
+			wantMaxStaleness := time.Duration(1+rand.IntN(1000)) * time.Second
+			req := httptest.NewRequest(
+				http.MethodGet,
+				fmt.Sprintf("/items/top?limit=%d&maxStaleness=%s", wantLimit, wantMaxStaleness),
+				http.NoBody,
+			)
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			offsetLag := rand.Int64N(1000)
+			wantResponse := &aggregation.GetTopKItemsResponse{
+				Data: []aggregation.TopKItem{{ItemID: faker.UUIDHyphenated(), Count: rand.Int64N(100)}},
+				Metadata: aggregation.TopKItemsMetadata{
+					LastOffset:       rand.Int64N(1000),
+					OffsetLag:        &offsetLag,
+					StalenessSeconds: float64(rand.IntN(1000)),
+				},
+			}
+			mockQueries.EXPECT().GetTopKItems(
+				mock.AnythingOfType("backgroundCtx"),
+				aggregation.GetTopKItemsParams{
+					Limit:        wantLimit,
+					MaxStaleness: wantMaxStaleness,
+				},
+			).Return(wantResponse, nil)
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			var gotResponse aggregation.GetTopKItemsResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &gotResponse))
+			assert.Equal(t, *wantResponse, gotResponse)
+		})
+
+		t.Run("should fail if bad max staleness", func(t *testing.T) {
+			for _, maxStaleness := range []string{faker.Word(), "-1s"} {
+				req := httptest.NewRequest(
+					http.MethodGet,
+					"/items/top?limit=10&maxStaleness="+maxStaleness,
+					http.NoBody,
+				)
+				w := httptest.NewRecorder()
+				deps := makeDeps(t)
+
+				NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+				deps.Mux.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusBadRequest, w.Code, maxStaleness)
+			}
+		})
+
+		t.Run("should respond with service unavailable if not ready or stale", func(t *testing.T) {
+			for _, queryErr := range []error{aggregation.ErrLiveStateNotReady, aggregation.ErrLiveStateStale} {
+				req := httptest.NewRequest(http.MethodGet, "/items/top?limit=10&maxStaleness=1s", http.NoBody)
+				w := httptest.NewRecorder()
+				deps := makeDeps(t)
+
+				mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+				mockQueries.EXPECT().
+					GetTopKItems(mock.Anything, mock.Anything).
+					Return(nil, fmt.Errorf("%w: %s", queryErr, faker.Sentence()))
+
+				NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+				deps.Mux.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusServiceUnavailable, w.Code, queryErr)
+			}
+		})
+
 		t.Run("should fail if no limit", func(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/items/top", http.NoBody)
 			w := httptest.NewRecorder()
@@ -95,6 +173,51 @@ func TestItemsRoutes(t *testing.T) {
 			assert.Equal(t, http.StatusBadRequest, w.Code)
 		})
 
+		t.Run("should allow limit up to the capacity of the window", func(t *testing.T) {
+			deps := makeDeps(t)
+			req := httptest.NewRequest(
+				http.MethodGet,
+				fmt.Sprintf("/items/top?window=all-time&limit=%d", deps.AllTimeItemsCapacity),
+				http.NoBody,
+			)
+			w := httptest.NewRecorder()
+
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			mockQueries.EXPECT().
+				GetTopKItems(mock.Anything, aggregation.GetTopKItemsParams{Limit: deps.AllTimeItemsCapacity}).
+				Return(&aggregation.GetTopKItemsResponse{}, nil)
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+
+		t.Run("should fail if limit is out of range", func(t *testing.T) {
+			deps := makeDeps(t)
+			for _, limit := range []int{0, -1, -1 - rand.IntN(100), deps.AllTimeItemsCapacity + 1 + rand.IntN(100)} {
+				req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/items/top?limit=%d", limit), http.NoBody)
+				w := httptest.NewRecorder()
+				mux := http.NewServeMux()
+
+				NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(mux)
+				mux.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusBadRequest, w.Code, limit)
+			}
+		})
+
+		t.Run("should fail if unknown window", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/items/top?limit=10&window="+faker.Word(), http.NoBody)
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+
 		t.Run("should handle query error", func(t *testing.T) {
 			wantLimit := 100 + rand.IntN(100)
 			req := httptest.NewRequest(
@@ -123,6 +246,349 @@ func TestItemsRoutes(t *testing.T) {
 		})
 	})
 
+	t.Run("GET /items/{itemID}/stats", func(t *testing.T) {
+		t.Run("should return item stats", func(t *testing.T) {
+			itemID := faker.UUIDHyphenated()
+			req := httptest.NewRequest(http.MethodGet, "/items/"+itemID+"/stats", http.NoBody)
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			rank := 1 + rand.IntN(100)
+			wantResponse := &aggregation.GetItemStatsResponse{
+				ItemID:  itemID,
+				Count:   rand.Int64N(1000),
+				Rank:    &rank,
+				MaxRank: 100 + rand.IntN(100),
+			}
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			mockQueries.EXPECT().GetItemStats(
+				mock.Anything,
+				aggregation.GetItemStatsParams{ItemID: itemID},
+			).Return(wantResponse, nil)
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			var gotResponse aggregation.GetItemStatsResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &gotResponse))
+			assert.Equal(t, *wantResponse, gotResponse)
+		})
+
+		t.Run("should respond with service unavailable if not ready", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/items/"+faker.UUIDHyphenated()+"/stats", http.NoBody)
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			mockQueries.EXPECT().
+				GetItemStats(mock.Anything, mock.Anything).
+				Return(nil, fmt.Errorf("%w: %s", aggregation.ErrLiveStateNotReady, faker.Sentence()))
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		})
+
+		t.Run("should handle query error", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/items/"+faker.UUIDHyphenated()+"/stats", http.NoBody)
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			mockQueries.EXPECT().
+				GetItemStats(mock.Anything, mock.Anything).
+				Return(nil, errors.New(faker.Sentence()))
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+		})
+	})
+
+	t.Run("GET /items/{itemID}/rank", func(t *testing.T) {
+		t.Run("should return item rank", func(t *testing.T) {
+			itemID := faker.UUIDHyphenated()
+			req := httptest.NewRequest(http.MethodGet, "/items/"+itemID+"/rank", http.NoBody)
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			rank := 1 + rand.IntN(100000)
+			wantResponse := &aggregation.GetItemRankResponse{
+				ItemID:      itemID,
+				Count:       rand.Int64N(1000),
+				Rank:        &rank,
+				RankedItems: rank + rand.IntN(100000),
+			}
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			mockQueries.EXPECT().GetItemRank(
+				mock.Anything,
+				aggregation.GetItemRankParams{ItemID: itemID},
+			).Return(wantResponse, nil)
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			var gotResponse aggregation.GetItemRankResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &gotResponse))
+			assert.Equal(t, *wantResponse, gotResponse)
+		})
+
+		t.Run("should map query errors to status codes", func(t *testing.T) {
+			cases := []struct {
+				err        error
+				wantStatus int
+			}{
+				{err: aggregation.ErrRankIndexDisabled, wantStatus: http.StatusNotImplemented},
+				{
+					err:        fmt.Errorf("%w: %s", aggregation.ErrLiveStateNotReady, faker.Sentence()),
+					wantStatus: http.StatusServiceUnavailable,
+				},
+				{err: errors.New(faker.Sentence()), wantStatus: http.StatusInternalServerError},
+			}
+			for _, tc := range cases {
+				req := httptest.NewRequest(http.MethodGet, "/items/"+faker.UUIDHyphenated()+"/rank", http.NoBody)
+				w := httptest.NewRecorder()
+				deps := makeDeps(t)
+
+				mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+				mockQueries.EXPECT().GetItemRank(mock.Anything, mock.Anything).Return(nil, tc.err)
+
+				NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+				deps.Mux.ServeHTTP(w, req)
+
+				assert.Equal(t, tc.wantStatus, w.Code, tc.err)
+			}
+		})
+	})
+
+	t.Run("POST /items/counts", func(t *testing.T) {
+		t.Run("should return items counts", func(t *testing.T) {
+			wantParams := aggregation.GetItemsCountsParams{
+				ItemIDs: []string{faker.UUIDHyphenated(), faker.UUIDHyphenated()},
+			}
+			body, err := json.Marshal(itemsCountsRequest{ItemIDs: wantParams.ItemIDs})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/items/counts", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			wantResponse := &aggregation.GetItemsCountsResponse{
+				Counts: map[string]int64{
+					wantParams.ItemIDs[0]: rand.Int64N(100),
+				},
+			}
+			mockQueries.EXPECT().GetItemsCounts(
+				mock.AnythingOfType("backgroundCtx"),
+				wantParams,
+			).Return(wantResponse, nil)
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			var gotResponse aggregation.GetItemsCountsResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &gotResponse))
+			assert.Equal(t, wantResponse, &gotResponse)
+		})
+		t.Run("should fail if bad body", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/items/counts", strings.NewReader(faker.Word()))
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+		t.Run("should fail if too many items", func(t *testing.T) {
+			body, err := json.Marshal(itemsCountsRequest{ItemIDs: make([]string, maxCountsItemIDs+1)})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/items/counts", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+		t.Run("should fail if body is too large", func(t *testing.T) {
+			itemIDs := make([]string, maxCountsItemIDs)
+			for i := range itemIDs {
+				itemIDs[i] = strings.Repeat("a", maxRequestItemIDSize)
+			}
+			body, err := json.Marshal(itemsCountsRequest{ItemIDs: itemIDs})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/items/counts", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		})
+		t.Run("should respond with service unavailable if not ready", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/items/counts", strings.NewReader(`{"itemIds":[]}`))
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			mockQueries.EXPECT().
+				GetItemsCounts(mock.Anything, mock.Anything).
+				Return(nil, fmt.Errorf("%w: %s", aggregation.ErrLiveStateNotReady, faker.Sentence()))
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		})
+		t.Run("should handle query error", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/items/counts", strings.NewReader(`{"itemIds":[]}`))
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			mockQueries.EXPECT().
+				GetItemsCounts(mock.Anything, mock.Anything).
+				Return(nil, errors.New(faker.Sentence()))
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+		})
+	})
+
+	t.Run("POST /items/snapshot", func(t *testing.T) {
+		t.Run("should return state snapshot", func(t *testing.T) {
+			wantParams := aggregation.GetStateSnapshotParams{
+				Limit:   1 + rand.IntN(100),
+				ItemIDs: []string{faker.UUIDHyphenated(), faker.UUIDHyphenated()},
+			}
+			req := httptest.NewRequest(http.MethodPost, "/items/snapshot", strings.NewReader(
+				fmt.Sprintf(`{"limit":%d,"itemIds":["%s","%s"]}`,
+					wantParams.Limit, wantParams.ItemIDs[0], wantParams.ItemIDs[1],
+				),
+			))
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			wantResponse := &aggregation.GetStateSnapshotResponse{
+				LastOffset: rand.Int64(),
+				TopItems: []aggregation.TopKItem{
+					{ItemID: faker.UUIDHyphenated(), Count: rand.Int64N(100)},
+				},
+				ItemsCounters: map[string]int64{
+					wantParams.ItemIDs[0]: rand.Int64N(100),
+				},
+			}
+			mockQueries.EXPECT().GetStateSnapshot(
+				mock.AnythingOfType("backgroundCtx"),
+				wantParams,
+			).Return(wantResponse, nil)
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			var gotResponse aggregation.GetStateSnapshotResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &gotResponse))
+			assert.Equal(t, wantResponse, &gotResponse)
+		})
+		t.Run("should fail if bad body", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/items/snapshot", strings.NewReader(faker.Word()))
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+		t.Run("should fail if limit is out of range", func(t *testing.T) {
+			deps := makeDeps(t)
+			for _, limit := range []int{
+				0, -1 - rand.IntN(100), deps.AllTimeItemsCapacity + 1 + rand.IntN(100), math.MaxInt,
+			} {
+				req := httptest.NewRequest(http.MethodPost, "/items/snapshot",
+					strings.NewReader(fmt.Sprintf(`{"limit":%d}`, limit)))
+				w := httptest.NewRecorder()
+				mux := http.NewServeMux()
+
+				NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(mux)
+				mux.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusBadRequest, w.Code, limit)
+			}
+		})
+		t.Run("should fail if too many items", func(t *testing.T) {
+			body, err := json.Marshal(stateSnapshotRequest{Limit: 10, ItemIDs: make([]string, maxSnapshotItemIDs+1)})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/items/snapshot", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+		t.Run("should fail if body is too large", func(t *testing.T) {
+			itemIDs := make([]string, maxSnapshotItemIDs)
+			for i := range itemIDs {
+				itemIDs[i] = strings.Repeat("a", maxRequestItemIDSize)
+			}
+			body, err := json.Marshal(stateSnapshotRequest{ItemIDs: itemIDs})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/items/snapshot", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		})
+		t.Run("should respond with service unavailable if no live state", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/items/snapshot", strings.NewReader(`{"limit":10}`))
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			mockQueries.EXPECT().GetStateSnapshot(mock.Anything, mock.Anything).
+				Return(nil, fmt.Errorf("%w: %s", aggregation.ErrLiveStateUnavailable, faker.Sentence()))
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		})
+		t.Run("should handle query error", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/items/snapshot", strings.NewReader(`{"limit":10}`))
+			w := httptest.NewRecorder()
+			deps := makeDeps(t)
+
+			mockQueries, _ := deps.Queries.(*aggregation.MockQueries)
+			mockQueries.EXPECT().GetStateSnapshot(mock.Anything, mock.Anything).
+				Return(nil, errors.New(faker.Sentence()))
+
+			NewItemsRoutesGroup(deps.ItemsRoutesDeps).Mount(deps.Mux)
+			deps.Mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+		})
+	})
+
 	t.Run("POST /items/events", func(t *testing.T) {
 		t.Run("should ingest the event", func(t *testing.T) {
 			wantItemID := faker.UUIDHyphenated()
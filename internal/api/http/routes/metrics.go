@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/dig"
+)
+
+type MetricsDeps struct {
+	dig.In
+
+	MetricsGatherer prometheus.Gatherer
+}
+
+func NewMetricsRoutesGroup(deps MetricsDeps) Group {
+	return Group{
+		Mount: MountFunc(func(r router) {
+			r.Handle("GET /metrics", promhttp.HandlerFor(deps.MetricsGatherer, promhttp.HandlerOpts{}))
+		}),
+	}
+}
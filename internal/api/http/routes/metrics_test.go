@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsRoutes(t *testing.T) {
+	t.Run("GET /metrics", func(t *testing.T) {
+		t.Run("should respond with registered metrics", func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			metricName := "test_" + faker.Word() + "_total"
+			promauto.With(registry).NewCounter(prometheus.CounterOpts{
+				Name: metricName,
+				Help: faker.Sentence(),
+			}).Inc()
+
+			mux := http.NewServeMux()
+			NewMetricsRoutesGroup(MetricsDeps{MetricsGatherer: registry}).Mount(mux)
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), metricName+" 1")
+		})
+	})
+}
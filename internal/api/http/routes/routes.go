@@ -37,5 +37,6 @@ func Register(container *dig.Container) error {
 
 		NewHealthCheckRoutesGroup,
 		NewItemsRoutesGroup,
+		NewMetricsRoutesGroup,
 	)
 }
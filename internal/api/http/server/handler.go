@@ -6,15 +6,21 @@ import (
 
 	"github.com/gemyago/top-k-system-go/internal/api/http/middleware"
 	"github.com/gemyago/top-k-system-go/internal/api/http/routes"
+	"github.com/prometheus/client_golang/prometheus"
 	sloghttp "github.com/samber/slog-http"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/dig"
 )
 
 type RootHandlerDeps struct {
 	dig.In
 
-	RootLogger *slog.Logger
-	Groups     []routes.MountFunc `group:"server"`
+	RootLogger        *slog.Logger
+	MetricsRegisterer prometheus.Registerer
+	TracerProvider    trace.TracerProvider
+	Propagator        propagation.TextMapPropagator
+	Groups            []routes.MountFunc `group:"server"`
 }
 
 func NewRootHandler(deps RootHandlerDeps) http.Handler {
@@ -26,7 +32,11 @@ func NewRootHandler(deps RootHandlerDeps) http.Handler {
 
 	// Router wire-up
 	chain := middleware.Chain(
-		middleware.NewTracingMiddleware(middleware.NewTracingMiddlewareCfg()),
+		middleware.NewTracingMiddleware(
+			middleware.NewTracingMiddlewareCfg().
+				WithTracerProvider(deps.TracerProvider).
+				WithPropagator(deps.Propagator),
+		),
 		sloghttp.NewWithConfig(deps.RootLogger, sloghttp.Config{
 			DefaultLevel:     slog.LevelInfo,
 			ClientErrorLevel: slog.LevelWarn,
@@ -39,6 +49,8 @@ func NewRootHandler(deps RootHandlerDeps) http.Handler {
 			WithSpanID:         true,
 			WithTraceID:        true,
 		}),
+		middleware.NewMetricsMiddleware(deps.MetricsRegisterer),
+		middleware.NewTracingRouteMiddleware(),
 		middleware.NewRecovererMiddleware(deps.RootLogger),
 	)
 	return chain(mux)
@@ -2,16 +2,28 @@ package aggregation
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"maps"
 	"time"
 
 	"github.com/gemyago/top-k-system-go/internal/diag"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/dig"
 )
 
 type aggregationState struct {
 	counters     counters
 	allTimeItems topKItems
+
+	// status is tracked for the live state only (nil otherwise)
+	status *aggregationStatus
+
+	// rankIndex is optionally maintained for the live state only (nil otherwise)
+	rankIndex *rankIndex
 }
 
 type beginAggregatingOpts struct {
@@ -19,6 +31,73 @@ type beginAggregatingOpts struct {
 
 	// tillOffset indicates the offset to aggregate until
 	tillOffset int64
+
+	// checkPointInterval indicates how often the state should be passed
+	// to the onCheckPoint. Check points are disabled if 0.
+	checkPointInterval time.Duration
+
+	// onCheckPoint is invoked with the flushed state on every check point interval
+	// and once the aggregation is stopped (final is true in this case). It is invoked
+	// on the aggregation goroutine, so the state must be copied (snapshotAggregationState)
+	// if it is used after the call, unless it is final.
+	onCheckPoint func(ctx context.Context, state aggregationState, final bool)
+
+	// offsetLagInterval indicates how often the offset lag should be updated.
+	// Offset lag is not monitored if 0.
+	offsetLagInterval time.Duration
+
+	// stateReads receives functions that are invoked with the state in between
+	// processing messages, so other goroutines can read it consistently.
+	// State reads are disabled if nil.
+	stateReads liveStateReads
+}
+
+// liveStateReads is a channel to read the live aggregation state. The state
+// is consistent as of the last flush.
+type liveStateReads chan func(state aggregationState)
+
+// snapshotAggregationState will make a copy of the state so it can be written
+// while the aggregation continues. Must be called from the aggregation goroutine.
+// The aggregation is paused while counters are copied, the cost is measured by
+// BenchmarkSnapshotAggregationState.
+func snapshotAggregationState(state aggregationState) aggregationState {
+	// items are immutable (updates are replacing them) so it is safe to share.
+	// The snapshot is not updated, so it only needs to fit the current items.
+	items := state.allTimeItems.getItems(topKGetAllItemsLimit)
+	allTimeItems := newTopKBTreeItems(len(items))
+	allTimeItems.load(items)
+	return aggregationState{
+		counters: &countersImpl{
+			lastOffset:   state.counters.getLastOffset(),
+			itemCounters: maps.Clone(state.counters.getItemsCounters()),
+		},
+		allTimeItems: allTimeItems,
+	}
+}
+
+type LiveAggregationStateDeps struct {
+	// all injectable fields must be exported
+	// to let dig inject them
+
+	dig.In
+
+	// config
+	RankIndexEnabled     bool `name:"config.aggregator.rankIndex.enabled"`
+	AllTimeItemsCapacity int  `name:"config.aggregator.topK.allTime.capacity"`
+}
+
+// newLiveAggregationState will create the state that is aggregated by the server
+// and read by queries.
+func newLiveAggregationState(deps LiveAggregationStateDeps) aggregationState {
+	state := aggregationState{
+		counters:     newSynchronisedCounters(),
+		allTimeItems: newTopKItems(deps.AllTimeItemsCapacity),
+		status:       newAggregationStatus(),
+	}
+	if deps.RankIndexEnabled {
+		state.rankIndex = newRankIndex()
+	}
+	return state
 }
 
 type itemEventsAggregator interface {
@@ -39,14 +118,18 @@ type ItemEventsAggregatorDeps struct {
 	ItemEventLogRate int64         `name:"config.aggregator.itemEventLogRate"`
 
 	// service layer
-	TickerFactory func(d time.Duration) *time.Ticker
+	TickerFactory     func(d time.Duration) *time.Ticker
+	MetricsRegisterer prometheus.Registerer
+	TracerProvider    trace.TracerProvider
 
 	// package private components
 	AggregatorModel itemEventsAggregatorModel
 }
 
 type itemEventsAggregatorImpl struct {
-	logger *slog.Logger
+	logger       *slog.Logger
+	tracer       trace.Tracer
+	earlyFlushes prometheus.Counter
 	ItemEventsAggregatorDeps
 }
 
@@ -57,41 +140,118 @@ func (a *itemEventsAggregatorImpl) beginAggregating(
 ) error {
 	messagesChan := a.AggregatorModel.fetchMessages(ctx, opts.sinceOffset)
 	flushTimer := a.ItemEventsAggregatorDeps.TickerFactory(a.FlushInterval)
+
+	// nil channel (check points disabled) is never selected
+	var checkPointTicks <-chan time.Time
+	if opts.checkPointInterval > 0 {
+		checkPointTimer := a.ItemEventsAggregatorDeps.TickerFactory(opts.checkPointInterval)
+		defer checkPointTimer.Stop()
+		checkPointTicks = checkPointTimer.C
+	}
+	var offsetLagTicks <-chan time.Time
+	if opts.offsetLagInterval > 0 {
+		offsetLagTimer := a.ItemEventsAggregatorDeps.TickerFactory(opts.offsetLagInterval)
+		defer offsetLagTimer.Stop()
+		offsetLagTicks = offsetLagTimer.C
+	}
 	for {
 		select {
 		case <-flushTimer.C:
 			a.AggregatorModel.flushMessages(ctx, state)
-		case res := <-messagesChan:
-			// TODO: Potentially Better error handling here
+		case <-checkPointTicks:
+			a.checkPoint(ctx, state, opts, false)
+		case <-offsetLagTicks:
+			a.updateOffsetLag(ctx, state, opts.offsetLagInterval)
+		case read := <-opts.stateReads:
+			read(state)
+		case res, ok := <-messagesChan:
+			if !ok {
+				// fetching stopped due to cancellation, ctx.Done case will handle it
+				messagesChan = nil
+				continue
+			}
 			if res.err != nil {
-				a.logger.ErrorContext(ctx, "failed to fetch message", diag.ErrAttr(res.err))
-			} else {
-				a.AggregatorModel.aggregateItemEvent(res.offset, res.event)
-				shouldLog := a.Verbose || (a.ItemEventLogRate > 0 && res.offset%a.ItemEventLogRate == 0)
-				if shouldLog {
-					a.logger.DebugContext(ctx, "Item event aggregated",
-						slog.String("itemID", res.event.ItemID),
-						slog.Int64("offset", res.offset),
-					)
-				}
-				if opts.tillOffset > 0 && res.offset >= opts.tillOffset {
-					a.logger.InfoContext(ctx, "Target offset reached. Flushing and stopping aggregation.",
-						slog.Int64("offset", res.offset),
-						slog.Int64("tillOffset", opts.tillOffset),
-					)
+				a.logger.ErrorContext(ctx, "Failed to fetch messages. Flushing and stopping aggregation.",
+					diag.ErrAttr(res.err),
+				)
+				if checkPointTicks != nil {
+					// the final check point is flushing as well and lets the shutdown
+					// stop waiting for it
+					a.checkPoint(ctx, state, opts, true)
+				} else {
 					a.AggregatorModel.flushMessages(ctx, state)
-					return nil
 				}
+				return fmt.Errorf("aggregation stopped: %w", res.err)
+			}
+			bufferFull := a.AggregatorModel.aggregateItemEvent(res.offset, res.event, res.spanContext)
+			shouldLog := a.Verbose || (a.ItemEventLogRate > 0 && res.offset%a.ItemEventLogRate == 0)
+			if shouldLog && res.event != nil {
+				a.logger.DebugContext(withCorrelationID(ctx, res.correlationID), "Item event aggregated",
+					slog.String("itemID", res.event.ItemID),
+					slog.Int64("offset", res.offset),
+				)
+			}
+			if opts.tillOffset > 0 && res.offset >= opts.tillOffset {
+				a.logger.InfoContext(ctx, "Target offset reached. Flushing and stopping aggregation.",
+					slog.Int64("offset", res.offset),
+					slog.Int64("tillOffset", opts.tillOffset),
+				)
+				a.AggregatorModel.flushMessages(ctx, state)
+				return nil
+			}
+			if bufferFull {
+				a.logger.DebugContext(ctx, "Aggregated items buffer is full. Flushing early.",
+					slog.Int64("offset", res.offset),
+				)
+				a.earlyFlushes.Inc()
+				a.AggregatorModel.flushMessages(ctx, state)
 			}
 		case <-ctx.Done():
+			if checkPointTicks != nil {
+				a.logger.InfoContext(ctx, "Aggregation stopped. Flushing and producing final check point.")
+				a.checkPoint(ctx, state, opts, true)
+			}
 			return nil
 		}
 	}
 }
 
+// checkPoint will flush aggregated events and pass the state to the onCheckPoint.
+// The flush and the check point write are traced as a part of the same check point span.
+func (a *itemEventsAggregatorImpl) checkPoint(
+	ctx context.Context,
+	state aggregationState,
+	opts beginAggregatingOpts,
+	final bool,
+) {
+	ctx, span := a.tracer.Start(ctx, "aggregator.checkPoint",
+		trace.WithAttributes(attribute.Bool("aggregator.checkPoint.final", final)),
+	)
+	defer span.End()
+	a.AggregatorModel.flushMessages(ctx, state)
+	opts.onCheckPoint(ctx, state, final)
+}
+
+// updateOffsetLag will not let slow reads of the stream tail to block the aggregation
+// for longer than the lag update interval.
+func (a *itemEventsAggregatorImpl) updateOffsetLag(
+	ctx context.Context,
+	state aggregationState,
+	timeout time.Duration,
+) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	a.AggregatorModel.updateOffsetLag(ctx, state)
+}
+
 func newItemEventsAggregator(deps ItemEventsAggregatorDeps) itemEventsAggregator {
 	return &itemEventsAggregatorImpl{
-		logger:                   deps.RootLogger.WithGroup("item-events-aggregator"),
+		logger: deps.RootLogger.WithGroup("item-events-aggregator"),
+		tracer: deps.TracerProvider.Tracer(tracerName),
+		earlyFlushes: promauto.With(deps.MetricsRegisterer).NewCounter(prometheus.CounterOpts{
+			Name: "aggregator_early_flushes_total",
+			Help: "Number of flushes triggered by the full aggregated items buffer",
+		}),
 		ItemEventsAggregatorDeps: deps,
 	}
 }
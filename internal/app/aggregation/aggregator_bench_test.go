@@ -0,0 +1,34 @@
+package aggregation
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/samber/lo"
+)
+
+// BenchmarkSnapshotAggregationState measures the pause of the aggregation
+// while the snapshot is taken for the live check point.
+func BenchmarkSnapshotAggregationState(b *testing.B) {
+	for _, size := range []int{100000, 1000000} {
+		sizeName := lo.Ternary(size == 100000, "100k", "1m")
+		cnt := newCounters()
+		cnt.updateItemsCount(1, zipfCountersOfSize(size))
+		state := aggregationState{
+			counters:     cnt,
+			allTimeItems: newTopKItems(topKMaxItemsSize),
+		}
+		state.allTimeItems.load(randomTopKItems(topKMaxItemsSize))
+
+		b.Run(sizeName, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				snapshotAggregationState(state)
+			}
+			before := heapAllocBytes()
+			snapshot := snapshotAggregationState(state)
+			b.ReportMetric(float64(heapAllocBytes()-before), "snapshot-bytes")
+			runtime.KeepAlive(snapshot)
+		})
+	}
+}
@@ -3,17 +3,61 @@ package aggregation
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/gemyago/top-k-system-go/internal/app/models"
+	"github.com/gemyago/top-k-system-go/internal/diag"
+	"github.com/gemyago/top-k-system-go/internal/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/samber/lo"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 	"go.uber.org/dig"
 )
 
+const tracerName = "github.com/gemyago/top-k-system-go/internal/app/aggregation"
+
+// maxFlushSpanLinks limits the number of aggregated events the flush span is linked
+// with, to keep the size of the span bounded.
+const maxFlushSpanLinks = 128
+
 type fetchMessageResult struct {
+	// event is nil if the message is malformed and routed to dead letters. The result
+	// is still sent so the aggregated offset moves past skipped messages.
 	event  *models.ItemEvent
 	offset int64
 	err    error
+
+	// spanContext of the message processing span. Invalid if the message
+	// has no trace context.
+	spanContext trace.SpanContext
+
+	// correlationID of the request that produced the message. Empty if not set.
+	correlationID string
+}
+
+// withCorrelationID will restore the correlation id of the message (if any) into
+// the context, so it is included in logs.
+func withCorrelationID(ctx context.Context, correlationID string) context.Context {
+	if correlationID == "" {
+		return ctx
+	}
+	logAttributes := diag.GetLogAttributesFromContext(ctx)
+	logAttributes.CorrelationID = slog.StringValue(correlationID)
+	return diag.SetLogAttributesToContext(ctx, logAttributes)
 }
 
 type ItemEventsAggregatorModelDeps struct {
@@ -25,45 +69,195 @@ type ItemEventsAggregatorModelDeps struct {
 	RootLogger *slog.Logger
 
 	// config
-	Verbose bool `name:"config.aggregator.verbose"`
+	Verbose                  bool          `name:"config.aggregator.verbose"`
+	FetchRetryInitialBackoff time.Duration `name:"config.aggregator.fetchRetry.initialBackoff"`
+	FetchRetryMaxBackoff     time.Duration `name:"config.aggregator.fetchRetry.maxBackoff"`
+
+	// FetchRetryMaxAttempts is a number of consecutive failed fetches after which
+	// the error is considered terminal. Retries indefinitely if 0.
+	FetchRetryMaxAttempts int `name:"config.aggregator.fetchRetry.maxAttempts"`
+
+	// MaxBufferedItems is a number of distinct items aggregated in between flushes
+	// that will trigger an early flush. Unbounded if 0.
+	MaxBufferedItems int `name:"config.aggregator.maxBufferedItems"`
 
 	// service layer
-	ItemEventsReader itemEventsKafkaReader
+	ItemEventsReader  itemEventsKafkaReader
+	DeadLetterWriter  services.DeadLetterWriter
+	MetricsRegisterer prometheus.Registerer
+	Time              services.TimeProvider
+	TracerProvider    trace.TracerProvider
+	Propagator        propagation.TextMapPropagator
 }
 
 type itemEventsAggregatorModel interface {
-	aggregateItemEvent(offset int64, evt *models.ItemEvent)
+	// aggregateItemEvent returns true if the buffer of aggregated items is full and should be flushed.
+	// The span context (if valid) is linked with the span of the next flush. The event is nil for
+	// skipped messages, only the offset is advanced in this case.
+	aggregateItemEvent(offset int64, evt *models.ItemEvent, spanContext trace.SpanContext) bool
 	flushMessages(ctx context.Context, state aggregationState)
 	fetchMessages(ctx context.Context, fromOffset int64) <-chan fetchMessageResult
+
+	// updateOffsetLag will compare the tail of the stream with the aggregated offset
+	updateOffsetLag(ctx context.Context, state aggregationState)
 }
 
 type itemEventsAggregatorModelImpl struct {
 	lastAggregatedOffset int64
 	aggregatedItems      map[string]int64
 	logger               *slog.Logger
+	tracer               trace.Tracer
+
+	// ingestion time of heartbeats aggregated since the last flush
+	pendingHeartbeats []time.Time
+
+	// spans of events aggregated since the last flush
+	pendingLinks []trace.Link
+
+	// unix nanoseconds of the last flush, read by metrics collector
+	lastFlushedAt atomic.Int64
+
+	malformedMessages prometheus.Counter
+	fetchRetries      prometheus.Counter
+	bufferedItems     prometheus.Gauge
+	flushDuration     prometheus.Histogram
+	heartbeatLatency  prometheus.Histogram
+	offsetLag         prometheus.Gauge
+	aggregatedEvents  prometheus.Counter
+	flushItems        prometheus.Histogram
+	countersSize      prometheus.Gauge
+	topKMinCount      prometheus.Gauge
 
 	deps ItemEventsAggregatorModelDeps
 }
 
 // aggregateItemEvent method is not thread safe, should be only called from a same
 // goroutine as flushMessages.
-func (m *itemEventsAggregatorModelImpl) aggregateItemEvent(offset int64, evt *models.ItemEvent) {
+func (m *itemEventsAggregatorModelImpl) aggregateItemEvent(
+	offset int64,
+	evt *models.ItemEvent,
+	spanContext trace.SpanContext,
+) bool {
 	m.lastAggregatedOffset = offset
+	if evt == nil {
+		return false
+	}
+	if spanContext.IsValid() && len(m.pendingLinks) < maxFlushSpanLinks {
+		m.pendingLinks = append(m.pendingLinks, trace.Link{SpanContext: spanContext})
+	}
+	if evt.Heartbeat {
+		m.pendingHeartbeats = append(m.pendingHeartbeats, evt.IngestedAt)
+		return false
+	}
+	m.aggregatedEvents.Inc()
 	curVal := m.aggregatedItems[evt.ItemID]
 	m.aggregatedItems[evt.ItemID] = curVal + 1
+	m.bufferedItems.Set(float64(len(m.aggregatedItems)))
+	return m.deps.MaxBufferedItems > 0 && len(m.aggregatedItems) >= m.deps.MaxBufferedItems
 }
 
 // flushMessages method is not thread safe, should be only called from a same
 // goroutine as aggregateItemEvent.
 func (m *itemEventsAggregatorModelImpl) flushMessages(ctx context.Context, state aggregationState) {
+	ctx, span := m.tracer.Start(ctx, "aggregator.flush",
+		trace.WithLinks(m.pendingLinks...),
+		trace.WithAttributes(
+			attribute.Int("aggregator.flush.items", len(m.aggregatedItems)),
+			attribute.Int64("aggregator.offset", m.lastAggregatedOffset),
+		),
+	)
+	defer span.End()
+	m.pendingLinks = m.pendingLinks[:0]
+
 	m.logger.DebugContext(ctx, "Flushing aggregated messages")
+	startedAt := m.deps.Time.Now()
+	m.flushItems.Observe(float64(len(m.aggregatedItems)))
+	var prevCounts map[string]int64
+	if state.rankIndex != nil {
+		// previous counts can not be derived from increments for items with zero count
+		prevCounts = state.counters.getItemsCount(lo.Keys(m.aggregatedItems))
+	}
 	updatedItems := state.counters.updateItemsCount(m.lastAggregatedOffset, m.aggregatedItems)
 	for itemID, count := range updatedItems {
 		state.allTimeItems.updateIfGreater(topKItem{ItemID: itemID, Count: count})
 	}
+	state.rankIndex.update(prevCounts, updatedItems)
 	clear(m.aggregatedItems)
+	m.countersSize.Set(float64(len(state.counters.getItemsCounters())))
+	if minItem, ok := state.allTimeItems.getMinItem(); ok {
+		m.topKMinCount.Set(float64(minItem.Count))
+	}
+	m.bufferedItems.Set(0)
+	flushedAt := m.deps.Time.Now()
+	m.flushDuration.Observe(flushedAt.Sub(startedAt).Seconds())
+	m.lastFlushedAt.Store(flushedAt.UnixNano())
+	if state.status != nil {
+		state.status.flushed(state.counters.getLastOffset(), flushedAt)
+	}
+
+	// heartbeat is considered aggregated once it's flushed to counters
+	for _, ingestedAt := range m.pendingHeartbeats {
+		m.heartbeatLatency.Observe(flushedAt.Sub(ingestedAt).Seconds())
+	}
+	m.pendingHeartbeats = m.pendingHeartbeats[:0]
 }
 
+// updateOffsetLag method is not thread safe, should be only called from a same
+// goroutine as aggregateItemEvent.
+func (m *itemEventsAggregatorModelImpl) updateOffsetLag(ctx context.Context, state aggregationState) {
+	tailOffset, err := m.deps.ItemEventsReader.ReadLastOffset(ctx)
+	if err != nil {
+		m.logger.WarnContext(ctx, "Failed to read last offset to update lag", diag.ErrAttr(err))
+		return
+	}
+
+	// tail offset is the offset of the next message to be produced. Events that
+	// are not flushed yet are not visible to queries, so they are still lagging.
+	offsetLag := max(0, tailOffset-1-state.counters.getLastOffset())
+	m.offsetLag.Set(float64(offsetLag))
+	state.status.offsetLagUpdated(tailOffset, offsetLag)
+}
+
+type fetchErrorKind int
+
+const (
+	// fetchErrorTransient errors are retried with exponential backoff.
+	fetchErrorTransient fetchErrorKind = iota
+
+	// fetchErrorTerminal errors stop the aggregation.
+	fetchErrorTerminal
+
+	// fetchErrorCanceled indicates the context is done and fetching should stop silently.
+	fetchErrorCanceled
+)
+
+// classifyFetchError will decide how to react on reader error. Closed reader
+// and non retriable kafka errors are terminal, everything else is retried.
+func classifyFetchError(err error) fetchErrorKind {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return fetchErrorCanceled
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, net.ErrClosed) {
+		return fetchErrorTerminal
+	}
+	var kafkaErr kafka.Error
+	if errors.As(err, &kafkaErr) && !kafkaErr.Temporary() {
+		return fetchErrorTerminal
+	}
+	return fetchErrorTransient
+}
+
+// nextFetchBackoff will double the backoff up to the configured max value.
+func (m *itemEventsAggregatorModelImpl) nextFetchBackoff(backoff time.Duration) time.Duration {
+	if backoff == 0 {
+		return m.deps.FetchRetryInitialBackoff
+	}
+	return min(backoff*2, m.deps.FetchRetryMaxBackoff) //nolint:mnd // exponential
+}
+
+// fetchMessages will start fetching messages in a separate goroutine. Transient errors
+// are retried internally. Terminal error is sent to the channel as a last result.
+// The channel is closed once fetching is stopped.
 func (m *itemEventsAggregatorModelImpl) fetchMessages(ctx context.Context, fromOffset int64) <-chan fetchMessageResult {
 	resultsChan := make(chan fetchMessageResult)
 	if err := m.deps.ItemEventsReader.SetOffset(fromOffset); err != nil {
@@ -74,34 +268,192 @@ func (m *itemEventsAggregatorModelImpl) fetchMessages(ctx context.Context, fromO
 		return resultsChan
 	}
 
+	send := func(res fetchMessageResult) bool {
+		select {
+		case resultsChan <- res:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
 	go func() {
+		defer close(resultsChan)
+		var backoff time.Duration
+		failedAttempts := 0
 		for {
 			msg, err := m.deps.ItemEventsReader.FetchMessage(ctx)
 			if err != nil {
-				resultsChan <- fetchMessageResult{err: fmt.Errorf("failed to fetch messages: %w", err)}
-				// TODO: If EOF just stop the loop
-				// review usage to make sure it will not break anything
-			} else {
-				var itemEvent models.ItemEvent
-				if err = json.Unmarshal(msg.Value, &itemEvent); err != nil {
-					resultsChan <- fetchMessageResult{err: fmt.Errorf("failed to unmarshal message: %w", err)}
+				switch classifyFetchError(err) {
+				case fetchErrorCanceled:
+					return
+				case fetchErrorTerminal:
+					send(fetchMessageResult{err: fmt.Errorf("failed to fetch messages: %w", err)})
+					return
+				case fetchErrorTransient:
+				}
+				failedAttempts++
+				if m.deps.FetchRetryMaxAttempts > 0 && failedAttempts >= m.deps.FetchRetryMaxAttempts {
+					send(fetchMessageResult{err: fmt.Errorf(
+						"failed to fetch messages after %d attempts: %w", failedAttempts, err,
+					)})
+					return
 				}
-				resultsChan <- fetchMessageResult{
-					event:  &itemEvent,
-					offset: msg.Offset,
+				backoff = m.nextFetchBackoff(backoff)
+				m.fetchRetries.Inc()
+				m.logger.WarnContext(ctx, "Failed to fetch message, retrying",
+					slog.Int("attempt", failedAttempts),
+					slog.Duration("backoff", backoff),
+					diag.ErrAttr(err),
+				)
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+			backoff = 0
+			failedAttempts = 0
+
+			span := m.startProcessingSpan(ctx, msg)
+			correlationID := services.NewKafkaHeadersCarrier(&msg.Headers).Get(diag.CorrelationIDHeader)
+			var itemEvent models.ItemEvent
+			if err = json.Unmarshal(msg.Value, &itemEvent); err != nil {
+				err = fmt.Errorf("failed to unmarshal message: %w", err)
+			} else if itemEvent.ItemID == "" && !itemEvent.Heartbeat {
+				err = errors.New("message has no item id")
+			}
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, "malformed message")
+				span.End()
+				m.handleMalformedMessage(withCorrelationID(ctx, correlationID), msg, err)
+				if !send(fetchMessageResult{offset: msg.Offset, correlationID: correlationID}) {
+					return
 				}
+				continue
+			}
+			sent := send(fetchMessageResult{
+				event:         &itemEvent,
+				offset:        msg.Offset,
+				spanContext:   span.SpanContext(),
+				correlationID: correlationID,
+			})
+			span.End()
+			if !sent {
+				return
 			}
 		}
 	}()
 	return resultsChan
 }
 
+// startProcessingSpan will start the span of processing the message that continues
+// the trace of the producer. Messages produced without trace context are not traced.
+func (m *itemEventsAggregatorModelImpl) startProcessingSpan(ctx context.Context, msg kafka.Message) trace.Span {
+	producerCtx := m.deps.Propagator.Extract(ctx, services.NewKafkaHeadersCarrier(&msg.Headers))
+	if !trace.SpanContextFromContext(producerCtx).IsValid() {
+		return noop.Span{}
+	}
+	_, span := m.tracer.Start(producerCtx, "item-events process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeDeliver,
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(msg.Partition)),
+			semconv.MessagingKafkaMessageOffset(int(msg.Offset)),
+		),
+	)
+	return span
+}
+
+// handleMalformedMessage will skip the message that can not be aggregated and route
+// it to dead letters. Failure to write the dead letter is logged but does not stop
+// the aggregation.
+func (m *itemEventsAggregatorModelImpl) handleMalformedMessage(ctx context.Context, msg kafka.Message, err error) {
+	m.malformedMessages.Inc()
+	m.logger.WarnContext(ctx, "Skipping malformed message",
+		slog.String("topic", msg.Topic),
+		slog.Int("partition", msg.Partition),
+		slog.Int64("offset", msg.Offset),
+		diag.ErrAttr(err),
+	)
+	if dlErr := m.deps.DeadLetterWriter.WriteDeadLetter(ctx, services.DeadLetter{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Payload:   msg.Value,
+		Error:     err.Error(),
+		FailedAt:  m.deps.Time.Now(),
+	}); dlErr != nil {
+		m.logger.ErrorContext(ctx, "Failed to write dead letter",
+			slog.Int64("offset", msg.Offset),
+			diag.ErrAttr(dlErr),
+		)
+	}
+}
+
 func newItemEventsAggregatorModel(
 	deps ItemEventsAggregatorModelDeps,
 ) itemEventsAggregatorModel {
-	return &itemEventsAggregatorModelImpl{
+	metrics := promauto.With(deps.MetricsRegisterer)
+	model := &itemEventsAggregatorModelImpl{
 		logger:          deps.RootLogger.WithGroup("item-events-aggregator-model"),
+		tracer:          deps.TracerProvider.Tracer(tracerName),
 		aggregatedItems: make(map[string]int64),
-		deps:            deps,
+		malformedMessages: metrics.NewCounter(prometheus.CounterOpts{
+			Name: "aggregator_malformed_messages_total",
+			Help: "Number of item event messages that could not be aggregated and were routed to dead letters",
+		}),
+		fetchRetries: metrics.NewCounter(prometheus.CounterOpts{
+			Name: "aggregator_fetch_retries_total",
+			Help: "Number of retried item event fetches that failed with transient errors",
+		}),
+		bufferedItems: metrics.NewGauge(prometheus.GaugeOpts{
+			Name: "aggregator_buffered_items",
+			Help: "Number of distinct items aggregated since the last flush",
+		}),
+		flushDuration: metrics.NewHistogram(prometheus.HistogramOpts{
+			Name:    "aggregator_flush_duration_seconds",
+			Help:    "Duration of flushing aggregated items to counters and top items",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 15), //nolint:mnd // 1ms to ~16s
+		}),
+		heartbeatLatency: metrics.NewHistogram(prometheus.HistogramOpts{
+			Name:    "aggregator_heartbeat_latency_seconds",
+			Help:    "Time from ingesting the heartbeat event till it is flushed to counters",
+			Buckets: prometheus.ExponentialBuckets(0.5, 2, 12), //nolint:mnd // 0.5s to ~17m
+		}),
+		offsetLag: metrics.NewGauge(prometheus.GaugeOpts{
+			Name: "aggregator_offset_lag",
+			Help: "Number of item events in the stream that are not aggregated yet",
+		}),
+		aggregatedEvents: metrics.NewCounter(prometheus.CounterOpts{
+			Name: "aggregator_item_events_total",
+			Help: "Number of aggregated item events (excluding heartbeats)",
+		}),
+		flushItems: metrics.NewHistogram(prometheus.HistogramOpts{
+			Name:    "aggregator_flush_items",
+			Help:    "Number of distinct items flushed to counters at once",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 10), //nolint:mnd // 1 to ~262k
+		}),
+		countersSize: metrics.NewGauge(prometheus.GaugeOpts{
+			Name: "aggregator_counters_items",
+			Help: "Number of items with counters",
+		}),
+		topKMinCount: metrics.NewGauge(prometheus.GaugeOpts{
+			Name: "aggregator_top_items_min_count",
+			Help: "Count of the least popular item among all time top items",
+		}),
+		deps: deps,
 	}
+	model.lastFlushedAt.Store(deps.Time.Now().UnixNano())
+	metrics.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "aggregator_seconds_since_last_flush",
+		Help: "Time since aggregated items were last flushed to counters",
+	}, func() float64 {
+		return deps.Time.Now().Sub(time.Unix(0, model.lastFlushedAt.Load())).Seconds()
+	})
+	return model
 }
@@ -4,25 +4,45 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"math/rand"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/gemyago/top-k-system-go/internal/app/models"
 	"github.com/gemyago/top-k-system-go/internal/diag"
 	"github.com/gemyago/top-k-system-go/internal/services"
 	"github.com/go-faker/faker/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/samber/lo"
 	"github.com/segmentio/kafka-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestAggregatorModel(t *testing.T) {
 	newMockDeps := func(t *testing.T) ItemEventsAggregatorModelDeps {
 		return ItemEventsAggregatorModelDeps{
-			RootLogger:       diag.RootTestLogger(),
-			ItemEventsReader: services.NewMockKafkaReader(t),
+			RootLogger:        diag.RootTestLogger(),
+			ItemEventsReader:  services.NewMockKafkaReader(t),
+			DeadLetterWriter:  services.NewMockDeadLetterWriter(t),
+			MetricsRegisterer: prometheus.NewRegistry(),
+			Time:              services.NewMockNow(),
+			TracerProvider:    noop.NewTracerProvider(),
+			Propagator:        services.NewTextMapPropagator(),
+
+			FetchRetryInitialBackoff: time.Microsecond,
+			FetchRetryMaxBackoff:     10 * time.Microsecond,
+			FetchRetryMaxAttempts:    5 + rand.Intn(5),
 		}
 	}
 
@@ -38,7 +58,7 @@ func TestAggregatorModel(t *testing.T) {
 				models.MakeRandomItemEvent(),
 			}
 			for i, e := range itemEvents {
-				model.aggregateItemEvent(baseOffset+int64(i), &e)
+				model.aggregateItemEvent(baseOffset+int64(i), &e, trace.SpanContext{})
 			}
 
 			modelImpl, _ := model.(*itemEventsAggregatorModelImpl)
@@ -47,6 +67,19 @@ func TestAggregatorModel(t *testing.T) {
 				assert.Equal(t, int64(1), modelImpl.aggregatedItems[e.ItemID])
 			}
 		})
+		t.Run("should only advance offset of skipped message", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			evt := models.MakeRandomItemEvent()
+			offset := rand.Int63n(1000)
+			model.aggregateItemEvent(offset, &evt, trace.SpanContext{})
+			assert.False(t, model.aggregateItemEvent(offset+1, nil, trace.SpanContext{}))
+
+			modelImpl, _ := model.(*itemEventsAggregatorModelImpl)
+			assert.Equal(t, offset+1, modelImpl.lastAggregatedOffset)
+			assert.Equal(t, map[string]int64{evt.ItemID: 1}, modelImpl.aggregatedItems)
+		})
 		t.Run("should increment existing counters", func(t *testing.T) {
 			mockDeps := newMockDeps(t)
 			model := newItemEventsAggregatorModel(mockDeps)
@@ -61,7 +94,7 @@ func TestAggregatorModel(t *testing.T) {
 			modelImpl, _ := model.(*itemEventsAggregatorModelImpl)
 			for i, e := range itemEvents {
 				modelImpl.aggregatedItems[e.ItemID] = baseCounter + int64(i)
-				model.aggregateItemEvent(baseOffset+int64(i), &e)
+				model.aggregateItemEvent(baseOffset+int64(i), &e, trace.SpanContext{})
 			}
 
 			assert.Equal(t, baseOffset+int64(len(itemEvents)-1), modelImpl.lastAggregatedOffset)
@@ -69,6 +102,35 @@ func TestAggregatorModel(t *testing.T) {
 				assert.Equal(t, baseCounter+int64(i+1), modelImpl.aggregatedItems[e.ItemID])
 			}
 		})
+		t.Run("should report full buffer when max distinct items reached", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			mockDeps.MaxBufferedItems = 2 + rand.Intn(5)
+			model := newItemEventsAggregatorModel(mockDeps)
+			modelImpl, _ := model.(*itemEventsAggregatorModelImpl)
+
+			baseOffset := rand.Int63n(1000)
+			for i := range mockDeps.MaxBufferedItems - 1 {
+				evt := models.MakeRandomItemEvent()
+				assert.False(t, model.aggregateItemEvent(baseOffset+int64(i), &evt, trace.SpanContext{}))
+
+				// same item should not grow the buffer
+				assert.False(t, model.aggregateItemEvent(baseOffset+int64(i), &evt, trace.SpanContext{}))
+			}
+			assert.InDelta(t, float64(mockDeps.MaxBufferedItems-1), testutil.ToFloat64(modelImpl.bufferedItems), 0)
+
+			evt := models.MakeRandomItemEvent()
+			assert.True(t, model.aggregateItemEvent(baseOffset+int64(mockDeps.MaxBufferedItems), &evt, trace.SpanContext{}))
+		})
+		t.Run("should never report full buffer if unbounded", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			mockDeps.MaxBufferedItems = 0
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			for i := range 10 + rand.Intn(10) {
+				evt := models.MakeRandomItemEvent()
+				assert.False(t, model.aggregateItemEvent(int64(i), &evt, trace.SpanContext{}))
+			}
+		})
 	})
 
 	t.Run("fetchMessages", func(t *testing.T) {
@@ -106,20 +168,14 @@ func TestAggregatorModel(t *testing.T) {
 				},
 			)
 
-			gotResults := make([]fetchMessageResult, 0, len(itemEvents))
-			syncChan := make(chan struct{})
-			go func() {
-				for res := range model.fetchMessages(ctx, fromOffset) {
-					gotResults = append(gotResults, res)
-					if len(gotResults) == len(itemEvents) {
-						syncChan <- struct{}{}
-						break
-					}
-				}
-			}()
-			<-syncChan
+			gotResults := make([]fetchMessageResult, 0, len(itemEvents)+1)
+			for res := range model.fetchMessages(ctx, fromOffset) {
+				gotResults = append(gotResults, res)
+			}
 
-			require.Len(t, gotResults, len(itemEvents))
+			// EOF is terminal, so it is the last result
+			require.Len(t, gotResults, len(itemEvents)+1)
+			require.ErrorIs(t, gotResults[len(itemEvents)].err, io.EOF)
 			for i, wantItem := range itemEvents {
 				gotResult := gotResults[i]
 				assert.Equal(t, fetchMessageResult{
@@ -129,6 +185,282 @@ func TestAggregatorModel(t *testing.T) {
 			}
 		})
 
+		t.Run("should skip malformed messages and write them to dead letters", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			validEvent := models.MakeRandomItemEvent()
+			malformedMessages := []kafka.Message{
+				{
+					Topic:     faker.Word(),
+					Partition: rand.Intn(10),
+					Offset:    rand.Int63(),
+					Key:       []byte(faker.UUIDHyphenated()),
+					Value:     []byte(faker.Sentence()),
+				},
+				{
+					Topic:     faker.Word(),
+					Partition: rand.Intn(10),
+					Offset:    rand.Int63(),
+					Value:     lo.Must(json.Marshal(models.ItemEvent{})),
+				},
+			}
+			validMessage := kafka.Message{
+				Offset: rand.Int63(),
+				Key:    []byte(validEvent.ItemID),
+				Value:  lo.Must(json.Marshal(validEvent)),
+			}
+			messages := append(malformedMessages, validMessage) //nolint:gocritic // new slice is fine
+
+			ctx := context.Background()
+			mockReader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			fromOffset := rand.Int63n(1000)
+			mockReader.EXPECT().SetOffset(fromOffset).Return(nil)
+			fetchMessageCounter := 0
+			mockReader.EXPECT().FetchMessage(ctx).RunAndReturn(
+				func(_ context.Context) (kafka.Message, error) {
+					defer func() {
+						fetchMessageCounter++
+					}()
+					if fetchMessageCounter >= len(messages) {
+						return kafka.Message{}, io.EOF
+					}
+					return messages[fetchMessageCounter], nil
+				},
+			).Maybe()
+
+			mockDeadLetterWriter, _ := mockDeps.DeadLetterWriter.(*services.MockDeadLetterWriter)
+			gotDeadLetters := make([]services.DeadLetter, 0, len(malformedMessages))
+			mockDeadLetterWriter.EXPECT().WriteDeadLetter(ctx, mock.Anything).RunAndReturn(
+				func(_ context.Context, letter services.DeadLetter) error {
+					gotDeadLetters = append(gotDeadLetters, letter)
+					return errors.New(faker.Sentence()) // should not stop the processing
+				},
+			)
+
+			results := model.fetchMessages(ctx, fromOffset)
+			for _, msg := range malformedMessages {
+				res := <-results
+				require.NoError(t, res.err)
+				assert.Equal(t, fetchMessageResult{offset: msg.Offset}, res, "skipped message offset should be sent")
+			}
+			res := <-results
+			require.NoError(t, res.err)
+			assert.Equal(t, fetchMessageResult{
+				offset: validMessage.Offset,
+				event:  &validEvent,
+			}, res)
+
+			require.Len(t, gotDeadLetters, len(malformedMessages))
+			for i, msg := range malformedMessages {
+				got := gotDeadLetters[i]
+				assert.Equal(t, msg.Topic, got.Topic)
+				assert.Equal(t, msg.Partition, got.Partition)
+				assert.Equal(t, msg.Offset, got.Offset)
+				assert.Equal(t, msg.Key, got.Key)
+				assert.Equal(t, msg.Value, got.Payload)
+				assert.NotEmpty(t, got.Error)
+				assert.Equal(t, services.MockNowValue(mockDeps.Time), got.FailedAt)
+			}
+			modelImpl, _ := model.(*itemEventsAggregatorModelImpl)
+			assert.InDelta(t, float64(len(malformedMessages)), testutil.ToFloat64(modelImpl.malformedMessages), 0)
+		})
+
+		t.Run("should feed heartbeat events to the channel", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			heartbeat := models.NewHeartbeatEvent(time.UnixMilli(faker.RandomUnixTime()))
+			wantOffset := rand.Int63()
+			ctx := context.Background()
+			mockReader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			fromOffset := rand.Int63n(1000)
+			mockReader.EXPECT().SetOffset(fromOffset).Return(nil)
+			mockReader.EXPECT().FetchMessage(ctx).Return(kafka.Message{
+				Offset: wantOffset,
+				Value:  lo.Must(json.Marshal(heartbeat)),
+			}, nil).Once()
+			mockReader.EXPECT().FetchMessage(ctx).Return(kafka.Message{}, io.EOF).Maybe()
+
+			res := <-model.fetchMessages(ctx, fromOffset)
+			require.NoError(t, res.err)
+			assert.Equal(t, wantOffset, res.offset)
+			assert.True(t, res.event.Heartbeat)
+			assert.True(t, heartbeat.IngestedAt.Equal(res.event.IngestedAt))
+		})
+
+		t.Run("should continue trace of the producer", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			recorder := tracetest.NewSpanRecorder()
+			mockDeps.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			producerSpanContext := trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    trace.TraceID{byte(1 + rand.Intn(255))},
+				SpanID:     trace.SpanID{byte(1 + rand.Intn(255))},
+				TraceFlags: trace.FlagsSampled,
+			})
+			msg := kafka.Message{
+				Offset: rand.Int63(),
+				Value:  lo.Must(json.Marshal(models.MakeRandomItemEvent())),
+			}
+			mockDeps.Propagator.Inject(
+				trace.ContextWithSpanContext(context.Background(), producerSpanContext),
+				services.NewKafkaHeadersCarrier(&msg.Headers),
+			)
+
+			ctx := context.Background()
+			mockReader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			fromOffset := rand.Int63n(1000)
+			mockReader.EXPECT().SetOffset(fromOffset).Return(nil)
+			mockReader.EXPECT().FetchMessage(ctx).Return(msg, nil).Once()
+			mockReader.EXPECT().FetchMessage(ctx).Return(kafka.Message{}, io.EOF).Once()
+
+			results := lo.ChannelToSlice(model.fetchMessages(ctx, fromOffset))
+			res := results[0]
+			require.NoError(t, res.err)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, trace.SpanKindConsumer, spans[0].SpanKind())
+			assert.Equal(t, producerSpanContext.SpanID(), spans[0].Parent().SpanID())
+			assert.Equal(t, producerSpanContext.TraceID(), res.spanContext.TraceID())
+			assert.Equal(t, spans[0].SpanContext(), res.spanContext)
+		})
+
+		t.Run("should pass correlation id of the message", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			wantCorrelationID := faker.UUIDHyphenated()
+			ctx := context.Background()
+			mockReader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			fromOffset := rand.Int63n(1000)
+			mockReader.EXPECT().SetOffset(fromOffset).Return(nil)
+			mockReader.EXPECT().FetchMessage(ctx).Return(kafka.Message{
+				Offset: rand.Int63(),
+				Value:  lo.Must(json.Marshal(models.MakeRandomItemEvent())),
+				Headers: []kafka.Header{
+					{Key: diag.CorrelationIDHeader, Value: []byte(wantCorrelationID)},
+				},
+			}, nil).Once()
+			mockReader.EXPECT().FetchMessage(ctx).Return(kafka.Message{}, io.EOF).Once()
+
+			results := lo.ChannelToSlice(model.fetchMessages(ctx, fromOffset))
+			require.NoError(t, results[0].err)
+			assert.Equal(t, wantCorrelationID, results[0].correlationID)
+		})
+
+		t.Run("should not trace messages without trace context", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			recorder := tracetest.NewSpanRecorder()
+			mockDeps.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			ctx := context.Background()
+			mockReader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			fromOffset := rand.Int63n(1000)
+			mockReader.EXPECT().SetOffset(fromOffset).Return(nil)
+			mockReader.EXPECT().FetchMessage(ctx).Return(kafka.Message{
+				Offset: rand.Int63(),
+				Value:  lo.Must(json.Marshal(models.MakeRandomItemEvent())),
+			}, nil).Once()
+			mockReader.EXPECT().FetchMessage(ctx).Return(kafka.Message{}, io.EOF).Once()
+
+			results := lo.ChannelToSlice(model.fetchMessages(ctx, fromOffset))
+			res := results[0]
+			require.NoError(t, res.err)
+
+			assert.False(t, res.spanContext.IsValid())
+			assert.Empty(t, recorder.Ended())
+		})
+
+		t.Run("should retry transient errors with backoff", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			wantEvent := models.MakeRandomItemEvent()
+			wantOffset := rand.Int63()
+			failuresCount := mockDeps.FetchRetryMaxAttempts - 1
+			ctx := context.Background()
+			mockReader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			fromOffset := rand.Int63n(1000)
+			mockReader.EXPECT().SetOffset(fromOffset).Return(nil)
+			fetchMessageCounter := 0
+			mockReader.EXPECT().FetchMessage(ctx).RunAndReturn(
+				func(_ context.Context) (kafka.Message, error) {
+					defer func() {
+						fetchMessageCounter++
+					}()
+					if fetchMessageCounter < failuresCount {
+						return kafka.Message{}, errors.New(faker.Sentence())
+					}
+					return kafka.Message{
+						Offset: wantOffset,
+						Value:  lo.Must(json.Marshal(wantEvent)),
+					}, nil
+				},
+			)
+
+			res := <-model.fetchMessages(ctx, fromOffset)
+			require.NoError(t, res.err)
+			assert.Equal(t, fetchMessageResult{offset: wantOffset, event: &wantEvent}, res)
+			modelImpl, _ := model.(*itemEventsAggregatorModelImpl)
+			assert.InDelta(t, float64(failuresCount), testutil.ToFloat64(modelImpl.fetchRetries), 0)
+		})
+
+		t.Run("should stop with error if max attempts reached", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			wantErr := errors.New(faker.Sentence())
+			ctx := context.Background()
+			mockReader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			fromOffset := rand.Int63n(1000)
+			mockReader.EXPECT().SetOffset(fromOffset).Return(nil)
+			mockReader.EXPECT().FetchMessage(ctx).Return(kafka.Message{}, wantErr).
+				Times(mockDeps.FetchRetryMaxAttempts)
+
+			results := model.fetchMessages(ctx, fromOffset)
+			res := <-results
+			require.ErrorIs(t, res.err, wantErr)
+			_, ok := <-results
+			assert.False(t, ok)
+		})
+
+		t.Run("should stop on terminal kafka errors", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			ctx := context.Background()
+			mockReader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			fromOffset := rand.Int63n(1000)
+			mockReader.EXPECT().SetOffset(fromOffset).Return(nil)
+			mockReader.EXPECT().FetchMessage(ctx).Return(kafka.Message{}, kafka.TopicAuthorizationFailed).Once()
+
+			res := <-model.fetchMessages(ctx, fromOffset)
+			require.ErrorIs(t, res.err, kafka.TopicAuthorizationFailed)
+		})
+
+		t.Run("should close results when context is cancelled", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			mockReader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			fromOffset := rand.Int63n(1000)
+			mockReader.EXPECT().SetOffset(fromOffset).Return(nil)
+			mockReader.EXPECT().FetchMessage(ctx).RunAndReturn(
+				func(ctx context.Context) (kafka.Message, error) {
+					cancel()
+					return kafka.Message{}, ctx.Err()
+				},
+			).Once()
+
+			_, ok := <-model.fetchMessages(ctx, fromOffset)
+			assert.False(t, ok)
+		})
+
 		t.Run("should return error if failed to set offset", func(t *testing.T) {
 			mockDeps := newMockDeps(t)
 			model := newItemEventsAggregatorModel(mockDeps)
@@ -144,6 +476,39 @@ func TestAggregatorModel(t *testing.T) {
 		})
 	})
 
+	t.Run("withCorrelationID", func(t *testing.T) {
+		t.Run("should set correlation id to log attributes", func(t *testing.T) {
+			wantCorrelationID := faker.UUIDHyphenated()
+			ctx := withCorrelationID(context.Background(), wantCorrelationID)
+			assert.Equal(t, wantCorrelationID, diag.GetLogAttributesFromContext(ctx).CorrelationID.String())
+		})
+		t.Run("should keep context as is if no correlation id", func(t *testing.T) {
+			ctx := context.Background()
+			assert.Equal(t, ctx, withCorrelationID(ctx, ""))
+		})
+	})
+
+	t.Run("classifyFetchError", func(t *testing.T) {
+		cases := []struct {
+			name string
+			err  error
+			want fetchErrorKind
+		}{
+			{name: "canceled", err: fmt.Errorf("wrapped: %w", context.Canceled), want: fetchErrorCanceled},
+			{name: "deadline", err: context.DeadlineExceeded, want: fetchErrorCanceled},
+			{name: "eof", err: fmt.Errorf("wrapped: %w", io.EOF), want: fetchErrorTerminal},
+			{name: "closed", err: net.ErrClosed, want: fetchErrorTerminal},
+			{name: "kafka non temporary", err: kafka.SASLAuthenticationFailed, want: fetchErrorTerminal},
+			{name: "kafka temporary", err: kafka.LeaderNotAvailable, want: fetchErrorTransient},
+			{name: "other", err: errors.New(faker.Sentence()), want: fetchErrorTransient},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				assert.Equal(t, tc.want, classifyFetchError(tc.err))
+			})
+		}
+	})
+
 	t.Run("flushMessages", func(t *testing.T) {
 		t.Run("should update counters and reset the aggregated values", func(t *testing.T) {
 			mockDeps := newMockDeps(t)
@@ -156,7 +521,7 @@ func TestAggregatorModel(t *testing.T) {
 				models.MakeRandomItemEvent(),
 			}
 			for i, e := range itemEvents {
-				model.aggregateItemEvent(baseOffset+int64(i), &e)
+				model.aggregateItemEvent(baseOffset+int64(i), &e, trace.SpanContext{})
 			}
 
 			modelImpl, _ := model.(*itemEventsAggregatorModelImpl)
@@ -173,6 +538,11 @@ func TestAggregatorModel(t *testing.T) {
 			for k, v := range updatedValues {
 				mockAllTimeItems.EXPECT().updateIfGreater(topKItem{ItemID: k, Count: v})
 			}
+			allCounters := randomCountersValues()
+			mockCounters.EXPECT().getItemsCounters().Return(allCounters)
+			minItem := &topKItem{ItemID: faker.UUIDHyphenated(), Count: rand.Int63n(1000)}
+			mockAllTimeItems.EXPECT().getMinItem().Return(minItem, true)
+			wantFlushItems := len(modelImpl.aggregatedItems)
 
 			model.flushMessages(context.Background(), aggregationState{
 				counters:     mockCounters,
@@ -180,6 +550,271 @@ func TestAggregatorModel(t *testing.T) {
 			})
 			assert.Equal(t, baseOffset+int64(len(itemEvents)-1), modelImpl.lastAggregatedOffset)
 			assert.Empty(t, modelImpl.aggregatedItems)
+			assert.InDelta(t, 0.0, testutil.ToFloat64(modelImpl.bufferedItems), 0)
+			var flushDuration dto.Metric
+			require.NoError(t, modelImpl.flushDuration.Write(&flushDuration))
+			assert.Equal(t, uint64(1), flushDuration.GetHistogram().GetSampleCount())
+			var flushItems dto.Metric
+			require.NoError(t, modelImpl.flushItems.Write(&flushItems))
+			assert.InDelta(t, float64(wantFlushItems), flushItems.GetHistogram().GetSampleSum(), 0)
+			assert.InDelta(t, float64(len(allCounters)), testutil.ToFloat64(modelImpl.countersSize), 0)
+			assert.InDelta(t, float64(minItem.Count), testutil.ToFloat64(modelImpl.topKMinCount), 0)
+			assert.InDelta(t, float64(len(itemEvents)), testutil.ToFloat64(modelImpl.aggregatedEvents), 0)
+		})
+		t.Run("should link flush span with spans of aggregated events", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			recorder := tracetest.NewSpanRecorder()
+			mockDeps.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			eventsCount := maxFlushSpanLinks + 1 + rand.Intn(10)
+			wantSpanContexts := make([]trace.SpanContext, 0, maxFlushSpanLinks)
+			for i := range eventsCount {
+				spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: trace.TraceID{byte(i + 1)},
+					SpanID:  trace.SpanID{byte(i + 1)},
+				})
+				if i < maxFlushSpanLinks {
+					wantSpanContexts = append(wantSpanContexts, spanContext)
+				}
+				evt := models.MakeRandomItemEvent()
+				model.aggregateItemEvent(int64(i), &evt, spanContext)
+			}
+			model.aggregateItemEvent(int64(eventsCount), lo.ToPtr(models.MakeRandomItemEvent()), trace.SpanContext{})
+
+			state := aggregationState{
+				counters:     newCounters(),
+				allTimeItems: newTopKItems(topKMaxItemsSize),
+			}
+			model.flushMessages(context.Background(), state)
+			model.flushMessages(context.Background(), state)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 2)
+			assert.Equal(t, "aggregator.flush", spans[0].Name())
+			gotSpanContexts := lo.Map(spans[0].Links(), func(l sdktrace.Link, _ int) trace.SpanContext {
+				return l.SpanContext
+			})
+			assert.Equal(t, wantSpanContexts, gotSpanContexts)
+			assert.Empty(t, spans[1].Links())
+		})
+		t.Run("should observe latency of aggregated heartbeats", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+			modelImpl, _ := model.(*itemEventsAggregatorModelImpl)
+
+			now := services.MockNowValue(mockDeps.Time)
+			wantLatency := time.Duration(1+rand.Intn(60)) * time.Second
+			heartbeat := models.NewHeartbeatEvent(now.Add(-wantLatency))
+			offset := rand.Int63()
+			assert.False(t, model.aggregateItemEvent(offset, &heartbeat, trace.SpanContext{}))
+			assert.Empty(t, modelImpl.aggregatedItems)
+			assert.Equal(t, offset, modelImpl.lastAggregatedOffset)
+
+			mockCounters := newMockCounters(t)
+			mockCounters.EXPECT().updateItemsCount(offset, modelImpl.aggregatedItems).Return(map[string]int64{})
+			mockCounters.EXPECT().getItemsCounters().Return(map[string]int64{})
+			mockAllTimeItems := newMockTopKItems(t)
+			mockAllTimeItems.EXPECT().getMinItem().Return(nil, false)
+			model.flushMessages(context.Background(), aggregationState{
+				counters:     mockCounters,
+				allTimeItems: mockAllTimeItems,
+			})
+			assert.InDelta(t, 0.0, testutil.ToFloat64(modelImpl.aggregatedEvents), 0)
+
+			var heartbeatLatency dto.Metric
+			require.NoError(t, modelImpl.heartbeatLatency.Write(&heartbeatLatency))
+			assert.Equal(t, uint64(1), heartbeatLatency.GetHistogram().GetSampleCount())
+			assert.InDelta(t, wantLatency.Seconds(), heartbeatLatency.GetHistogram().GetSampleSum(), 0.001)
+			assert.Empty(t, modelImpl.pendingHeartbeats)
+		})
+		t.Run("should track time since last flush", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			mockNow, _ := mockDeps.Time.(*services.MockNow)
+			registry := prometheus.NewRegistry()
+			mockDeps.MetricsRegisterer = registry
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			sinceLastFlush := func() float64 {
+				metrics := lo.Must(registry.Gather())
+				metric, ok := lo.Find(metrics, func(m *dto.MetricFamily) bool {
+					return m.GetName() == "aggregator_seconds_since_last_flush"
+				})
+				require.True(t, ok)
+				return metric.GetMetric()[0].GetGauge().GetValue()
+			}
+
+			startedAt := mockNow.Now()
+			mockNow.SetValue(startedAt.Add(10 * time.Second))
+			assert.InDelta(t, 10.0, sinceLastFlush(), 0.001)
+
+			model.flushMessages(context.Background(), aggregationState{
+				counters:     newCounters(),
+				allTimeItems: newTopKItems(topKMaxItemsSize),
+			})
+			mockNow.SetValue(startedAt.Add(15 * time.Second))
+			assert.InDelta(t, 5.0, sinceLastFlush(), 0.001)
+		})
+		t.Run("should report flushed offset to the live state status", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			offset := rand.Int63n(1000)
+			evt := models.MakeRandomItemEvent()
+			model.aggregateItemEvent(offset, &evt, trace.SpanContext{})
+
+			status := newAggregationStatus()
+			model.flushMessages(context.Background(), aggregationState{
+				counters:     newCounters(),
+				allTimeItems: newTopKItems(topKMaxItemsSize),
+				status:       status,
+			})
+			got := status.snapshot()
+			assert.Equal(t, offset, got.LastOffset)
+			require.NotNil(t, got.LastFlushedAt)
+			assert.Equal(t, mockDeps.Time.Now(), *got.LastFlushedAt)
+		})
+		t.Run("should move flushed items in the rank index", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			state := aggregationState{
+				counters:     newCounters(),
+				allTimeItems: newTopKItems(topKMaxItemsSize),
+				rankIndex:    newRankIndex(),
+			}
+			existingItemID := faker.UUIDHyphenated()
+			existingCount := 10 + rand.Int63n(1000)
+			otherCount := existingCount + 1
+			restoredCounters := map[string]int64{
+				existingItemID:         existingCount,
+				faker.UUIDHyphenated(): otherCount,
+			}
+			state.counters.updateItemsCount(rand.Int63n(1000), restoredCounters)
+			state.rankIndex.load(restoredCounters)
+
+			offset := rand.Int63n(1000)
+			newItemEvt := models.MakeRandomItemEvent()
+			model.aggregateItemEvent(offset, &newItemEvt, trace.SpanContext{})
+			existingItemEvt := models.ItemEvent{ItemID: existingItemID}
+			model.aggregateItemEvent(offset+1, &existingItemEvt, trace.SpanContext{})
+			model.aggregateItemEvent(offset+2, &existingItemEvt, trace.SpanContext{})
+
+			model.flushMessages(context.Background(), state)
+
+			gotRank, gotRankedItems := state.rankIndex.getRank(existingCount + 2)
+			assert.Equal(t, 1, gotRank)
+			assert.Equal(t, 3, gotRankedItems)
+			gotRank, _ = state.rankIndex.getRank(otherCount)
+			assert.Equal(t, 2, gotRank)
+			gotRank, _ = state.rankIndex.getRank(1)
+			assert.Equal(t, 3, gotRank)
+		})
+		t.Run("should move flushed items with zero count in the rank index", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			state := aggregationState{
+				counters:     newCounters(),
+				allTimeItems: newTopKItems(topKMaxItemsSize),
+				rankIndex:    newRankIndex(),
+			}
+			zeroItemID := faker.UUIDHyphenated()
+			restoredCounters := map[string]int64{
+				zeroItemID:             0,
+				faker.UUIDHyphenated(): 10 + rand.Int63n(1000),
+			}
+			state.counters.updateItemsCount(rand.Int63n(1000), restoredCounters)
+			state.rankIndex.load(restoredCounters)
+
+			zeroItemEvt := models.ItemEvent{ItemID: zeroItemID}
+			model.aggregateItemEvent(rand.Int63n(1000), &zeroItemEvt, trace.SpanContext{})
+
+			model.flushMessages(context.Background(), state)
+
+			gotRank, gotRankedItems := state.rankIndex.getRank(1)
+			assert.Equal(t, 2, gotRank)
+			assert.Equal(t, 2, gotRankedItems)
+			gotRank, _ = state.rankIndex.getRank(0)
+			assert.Equal(t, 3, gotRank)
+		})
+	})
+
+	t.Run("updateOffsetLag", func(t *testing.T) {
+		t.Run("should set lag as a difference of the tail and flushed offset", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+			modelImpl, _ := model.(*itemEventsAggregatorModelImpl)
+
+			// all the events are aggregated but not flushed yet
+			flushedOffset := rand.Int63n(1000)
+			wantLag := 1 + rand.Int63n(1000)
+			evt := models.MakeRandomItemEvent()
+			model.aggregateItemEvent(flushedOffset+wantLag, &evt, trace.SpanContext{})
+
+			ctx := context.Background()
+			mockReader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			mockReader.EXPECT().ReadLastOffset(ctx).Return(flushedOffset+wantLag+1, nil)
+			mockCounters := newMockCounters(t)
+			mockCounters.EXPECT().getLastOffset().Return(flushedOffset)
+
+			model.updateOffsetLag(ctx, aggregationState{counters: mockCounters})
+			assert.InDelta(t, float64(wantLag), testutil.ToFloat64(modelImpl.offsetLag), 0)
+		})
+		t.Run("should use restored offset if nothing aggregated yet", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+			modelImpl, _ := model.(*itemEventsAggregatorModelImpl)
+
+			restoredOffset := rand.Int63n(1000)
+			wantLag := rand.Int63n(1000)
+
+			ctx := context.Background()
+			mockReader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			mockReader.EXPECT().ReadLastOffset(ctx).Return(restoredOffset+wantLag+1, nil)
+			mockCounters := newMockCounters(t)
+			mockCounters.EXPECT().getLastOffset().Return(restoredOffset)
+
+			model.updateOffsetLag(ctx, aggregationState{counters: mockCounters})
+			assert.InDelta(t, float64(wantLag), testutil.ToFloat64(modelImpl.offsetLag), 0)
+		})
+		t.Run("should report the lag to the live state status", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+
+			restoredOffset := rand.Int63n(1000)
+			wantLag := rand.Int63n(1000)
+
+			ctx := context.Background()
+			mockReader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			mockReader.EXPECT().ReadLastOffset(ctx).Return(restoredOffset+wantLag+1, nil)
+			mockCounters := newMockCounters(t)
+			mockCounters.EXPECT().getLastOffset().Return(restoredOffset)
+
+			status := newAggregationStatus()
+			status.beginCatchingUp(time.Now(), restoredOffset, wantLag, true)
+			model.updateOffsetLag(ctx, aggregationState{counters: mockCounters, status: status})
+
+			got := status.snapshot()
+			require.NotNil(t, got.OffsetLag)
+			assert.Equal(t, wantLag, *got.OffsetLag)
+			require.NotNil(t, got.TailOffset)
+			assert.Equal(t, restoredOffset+wantLag+1, *got.TailOffset)
+			assert.Equal(t, AggregationPhaseLive, got.Phase)
+		})
+		t.Run("should keep previous lag if failed to read last offset", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			model := newItemEventsAggregatorModel(mockDeps)
+			modelImpl, _ := model.(*itemEventsAggregatorModelImpl)
+			prevLag := float64(rand.Int63n(1000))
+			modelImpl.offsetLag.Set(prevLag)
+
+			ctx := context.Background()
+			mockReader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			mockReader.EXPECT().ReadLastOffset(ctx).Return(0, errors.New(faker.Sentence()))
+
+			model.updateOffsetLag(ctx, aggregationState{counters: newMockCounters(t)})
+			assert.InDelta(t, prevLag, testutil.ToFloat64(modelImpl.offsetLag), 0)
 		})
 	})
 }
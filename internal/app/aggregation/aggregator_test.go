@@ -10,27 +10,56 @@ import (
 	"github.com/gemyago/top-k-system-go/internal/app/models"
 	"github.com/gemyago/top-k-system-go/internal/diag"
 	"github.com/go-faker/faker/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestItemEventsAggregator(t *testing.T) {
 	type itemEventsAggregatorMockDeps struct {
-		deps            ItemEventsAggregatorDeps
-		flushTickerChan chan time.Time
+		deps                 ItemEventsAggregatorDeps
+		flushTickerChan      chan time.Time
+		checkPointInterval   time.Duration
+		checkPointTickerChan chan time.Time
+		offsetLagInterval    time.Duration
+		offsetLagTickerChan  chan time.Time
 	}
 
 	newMockDeps := func(t *testing.T) itemEventsAggregatorMockDeps {
 		flushTickerChan := make(chan time.Time)
 		flushTicker := &time.Ticker{C: flushTickerChan}
 		flushInterval := time.Duration(rand.Int63n(1000))
+		checkPointTickerChan := make(chan time.Time)
+		checkPointTicker := &time.Ticker{C: checkPointTickerChan}
+		checkPointInterval := flushInterval + 1 + time.Duration(rand.Int63n(1000))
+		offsetLagTickerChan := make(chan time.Time)
+		offsetLagTicker := &time.Ticker{C: offsetLagTickerChan}
+		offsetLagInterval := checkPointInterval + 1 + time.Duration(rand.Int63n(1000))
 		return itemEventsAggregatorMockDeps{
-			flushTickerChan: flushTickerChan,
+			flushTickerChan:      flushTickerChan,
+			checkPointInterval:   checkPointInterval,
+			checkPointTickerChan: checkPointTickerChan,
+			offsetLagInterval:    offsetLagInterval,
+			offsetLagTickerChan:  offsetLagTickerChan,
 			deps: ItemEventsAggregatorDeps{
-				RootLogger:      diag.RootTestLogger(),
-				AggregatorModel: newMockItemEventsAggregatorModel(t),
-				FlushInterval:   flushInterval,
+				RootLogger:        diag.RootTestLogger(),
+				AggregatorModel:   newMockItemEventsAggregatorModel(t),
+				FlushInterval:     flushInterval,
+				MetricsRegisterer: prometheus.NewRegistry(),
+				TracerProvider:    noop.NewTracerProvider(),
 				TickerFactory: func(d time.Duration) *time.Ticker {
+					if d == checkPointInterval {
+						return checkPointTicker
+					}
+					if d == offsetLagInterval {
+						return offsetLagTicker
+					}
 					assert.Equal(t, flushInterval, d)
 					return flushTicker
 				},
@@ -67,7 +96,7 @@ func TestItemEventsAggregator(t *testing.T) {
 				exit <- aggregator.beginAggregating(ctx, state, beginAggregatingOpts{})
 			}()
 			for i, v := range wantItems {
-				mockModel.EXPECT().aggregateItemEvent(int64(i)+offsetBase, &v)
+				mockModel.EXPECT().aggregateItemEvent(int64(i)+offsetBase, &v, trace.SpanContext{}).Return(false)
 				fetchResultChan <- fetchMessageResult{offset: int64(i) + offsetBase, event: &v}
 			}
 
@@ -108,13 +137,113 @@ func TestItemEventsAggregator(t *testing.T) {
 				})
 			}()
 			for i, v := range wantItems {
-				mockModel.EXPECT().aggregateItemEvent(int64(i)+offsetBase, &v)
+				mockModel.EXPECT().aggregateItemEvent(int64(i)+offsetBase, &v, trace.SpanContext{}).Return(false)
 				fetchResultChan <- fetchMessageResult{offset: int64(i) + offsetBase, event: &v}
 			}
 			gotErr := <-exit
 			require.NoError(t, gotErr)
 		})
-		t.Run("should handle errors when fetch messages", func(t *testing.T) {
+		t.Run("should stop at given offset if the last message is malformed", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.deps.Verbose = true
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			aggregator := newItemEventsAggregator(deps.deps)
+
+			mockModel, _ := deps.deps.AggregatorModel.(*mockItemEventsAggregatorModel)
+
+			offsetBase := rand.Int63n(1000)
+			evt := models.MakeRandomItemEvent()
+			state := aggregationState{
+				counters: newCounters(),
+			}
+
+			fetchResultChan := make(chan fetchMessageResult)
+			mockModel.EXPECT().fetchMessages(ctx, offsetBase).Return(fetchResultChan)
+			mockModel.EXPECT().flushMessages(ctx, state)
+
+			exit := make(chan error)
+			go func() {
+				exit <- aggregator.beginAggregating(ctx, state, beginAggregatingOpts{
+					sinceOffset: offsetBase,
+					tillOffset:  offsetBase + 1,
+				})
+			}()
+			mockModel.EXPECT().aggregateItemEvent(offsetBase, &evt, trace.SpanContext{}).Return(false)
+			fetchResultChan <- fetchMessageResult{offset: offsetBase, event: &evt}
+			mockModel.EXPECT().aggregateItemEvent(offsetBase+1, (*models.ItemEvent)(nil), trace.SpanContext{}).Return(false)
+			fetchResultChan <- fetchMessageResult{offset: offsetBase + 1}
+
+			select {
+			case gotErr := <-exit:
+				require.NoError(t, gotErr)
+			case <-time.After(time.Second):
+				require.Fail(t, "aggregation should stop at the target offset")
+			}
+		})
+		t.Run("should flush and stop with error when fetch messages failed", func(t *testing.T) {
+			deps := newMockDeps(t)
+			ctx := context.Background()
+			aggregator := newItemEventsAggregator(deps.deps)
+
+			mockModel, _ := deps.deps.AggregatorModel.(*mockItemEventsAggregatorModel)
+
+			fetchResultChan := make(chan fetchMessageResult)
+			mockModel.EXPECT().fetchMessages(ctx, int64(0)).Return(fetchResultChan)
+			cnt := newCounters()
+			state := aggregationState{
+				counters: cnt,
+			}
+			mockModel.EXPECT().flushMessages(ctx, state)
+
+			exit := make(chan error)
+			go func() {
+				exit <- aggregator.beginAggregating(ctx, state, beginAggregatingOpts{})
+			}()
+			wantErr := errors.New(faker.Word())
+			fetchResultChan <- fetchMessageResult{err: wantErr}
+
+			gotErr := <-exit
+			require.ErrorIs(t, gotErr, wantErr)
+		})
+		t.Run("should pass final snapshot when fetch messages failed", func(t *testing.T) {
+			deps := newMockDeps(t)
+			ctx := context.Background()
+			aggregator := newItemEventsAggregator(deps.deps)
+
+			mockModel, _ := deps.deps.AggregatorModel.(*mockItemEventsAggregatorModel)
+			cnt, _ := newCounters().(*countersImpl)
+			cnt.updateItemsCount(rand.Int63n(1000), randomCountersValues())
+			state := aggregationState{
+				counters:     cnt,
+				allTimeItems: newTopKItems(topKMaxItemsSize),
+			}
+
+			fetchResultChan := make(chan fetchMessageResult)
+			mockModel.EXPECT().fetchMessages(ctx, int64(0)).Return(fetchResultChan)
+			mockModel.EXPECT().flushMessages(mock.Anything, state).Once()
+
+			var gotSnapshot aggregationState
+			var gotFinal bool
+			exit := make(chan error)
+			go func() {
+				exit <- aggregator.beginAggregating(ctx, state, beginAggregatingOpts{
+					checkPointInterval: deps.checkPointInterval,
+					onCheckPoint: func(_ context.Context, snapshot aggregationState, final bool) {
+						gotSnapshot = snapshot
+						gotFinal = final
+					},
+				})
+			}()
+			wantErr := errors.New(faker.Word())
+			fetchResultChan <- fetchMessageResult{err: wantErr}
+
+			gotErr := <-exit
+			require.ErrorIs(t, gotErr, wantErr)
+			assert.True(t, gotFinal)
+			assert.Equal(t, cnt.getLastOffset(), gotSnapshot.counters.getLastOffset())
+		})
+		t.Run("should wait for context when fetch messages stopped", func(t *testing.T) {
 			deps := newMockDeps(t)
 			ctx, cancel := context.WithCancel(context.Background())
 			aggregator := newItemEventsAggregator(deps.deps)
@@ -132,8 +261,13 @@ func TestItemEventsAggregator(t *testing.T) {
 			go func() {
 				exit <- aggregator.beginAggregating(ctx, state, beginAggregatingOpts{})
 			}()
-			fetchResultChan <- fetchMessageResult{err: errors.New(faker.Word())}
+			close(fetchResultChan)
 
+			select {
+			case <-exit:
+				require.FailNow(t, "aggregation should not stop before context is done")
+			case <-time.After(10 * time.Millisecond):
+			}
 			cancel()
 			gotErr := <-exit
 			require.NoError(t, gotErr)
@@ -160,6 +294,41 @@ func TestItemEventsAggregator(t *testing.T) {
 			gotErr := <-exit
 			assert.NoError(t, gotErr)
 		})
+		t.Run("should flush early when buffer is full", func(t *testing.T) {
+			deps := newMockDeps(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			aggregator := newItemEventsAggregator(deps.deps)
+
+			mockModel, _ := deps.deps.AggregatorModel.(*mockItemEventsAggregatorModel)
+			cnt := newCounters()
+			state := aggregationState{
+				counters: cnt,
+			}
+
+			fetchResultChan := make(chan fetchMessageResult)
+			mockModel.EXPECT().fetchMessages(ctx, int64(0)).Return(fetchResultChan)
+
+			exit := make(chan error)
+			go func() {
+				exit <- aggregator.beginAggregating(ctx, state, beginAggregatingOpts{})
+			}()
+
+			offset := rand.Int63n(1000)
+			evt := models.MakeRandomItemEvent()
+			mockModel.EXPECT().aggregateItemEvent(offset, &evt, trace.SpanContext{}).Return(true)
+			flushed := make(chan struct{})
+			mockModel.EXPECT().flushMessages(ctx, state).Run(func(_ context.Context, _ aggregationState) {
+				close(flushed)
+			}).Once()
+			fetchResultChan <- fetchMessageResult{offset: offset, event: &evt}
+			<-flushed
+
+			cancel()
+			gotErr := <-exit
+			require.NoError(t, gotErr)
+			aggregatorImpl, _ := aggregator.(*itemEventsAggregatorImpl)
+			assert.InDelta(t, 1.0, testutil.ToFloat64(aggregatorImpl.earlyFlushes), 0)
+		})
 		t.Run("should flush messages on timer", func(t *testing.T) {
 			deps := newMockDeps(t)
 			ctx, cancel := context.WithCancel(context.Background())
@@ -185,5 +354,227 @@ func TestItemEventsAggregator(t *testing.T) {
 			gotErr := <-exit
 			require.NoError(t, gotErr)
 		})
+		t.Run("should update offset lag on timer", func(t *testing.T) {
+			deps := newMockDeps(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			aggregator := newItemEventsAggregator(deps.deps)
+
+			mockModel, _ := deps.deps.AggregatorModel.(*mockItemEventsAggregatorModel)
+			state := aggregationState{
+				counters: newCounters(),
+			}
+
+			fetchResultChan := make(chan fetchMessageResult)
+			mockModel.EXPECT().fetchMessages(ctx, int64(0)).Return(fetchResultChan)
+			mockModel.EXPECT().updateOffsetLag(mock.Anything, state).Run(func(lagCtx context.Context, _ aggregationState) {
+				_, hasDeadline := lagCtx.Deadline()
+				assert.True(t, hasDeadline)
+			}).Times(2)
+
+			exit := make(chan error)
+			go func() {
+				exit <- aggregator.beginAggregating(ctx, state, beginAggregatingOpts{
+					offsetLagInterval: deps.offsetLagInterval,
+				})
+			}()
+			deps.offsetLagTickerChan <- time.Now()
+			deps.offsetLagTickerChan <- time.Now()
+
+			cancel()
+			gotErr := <-exit
+			require.NoError(t, gotErr)
+		})
+		t.Run("should invoke state reads with the state", func(t *testing.T) {
+			deps := newMockDeps(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			aggregator := newItemEventsAggregator(deps.deps)
+
+			mockModel, _ := deps.deps.AggregatorModel.(*mockItemEventsAggregatorModel)
+			cnt := newCounters()
+			state := aggregationState{
+				counters: cnt,
+			}
+
+			fetchResultChan := make(chan fetchMessageResult)
+			mockModel.EXPECT().fetchMessages(ctx, int64(0)).Return(fetchResultChan)
+
+			stateReads := make(liveStateReads)
+			exit := make(chan error)
+			go func() {
+				exit <- aggregator.beginAggregating(ctx, state, beginAggregatingOpts{
+					stateReads: stateReads,
+				})
+			}()
+			gotState := make(chan aggregationState, 1)
+			stateReads <- func(state aggregationState) {
+				gotState <- state
+			}
+			assert.Equal(t, state, <-gotState)
+
+			cancel()
+			gotErr := <-exit
+			require.NoError(t, gotErr)
+		})
+		t.Run("should flush and pass the state on check point timer", func(t *testing.T) {
+			deps := newMockDeps(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			aggregator := newItemEventsAggregator(deps.deps)
+
+			mockModel, _ := deps.deps.AggregatorModel.(*mockItemEventsAggregatorModel)
+			cnt, _ := newCounters().(*countersImpl)
+			cnt.updateItemsCount(rand.Int63n(1000), randomCountersValues())
+			allTimeItems := newTopKItems(topKMaxItemsSize)
+			allTimeItems.load(randomTopKItems(10))
+			state := aggregationState{
+				counters:     cnt,
+				allTimeItems: allTimeItems,
+			}
+
+			fetchResultChan := make(chan fetchMessageResult)
+			mockModel.EXPECT().fetchMessages(ctx, int64(0)).Return(fetchResultChan)
+			mockModel.EXPECT().flushMessages(mock.Anything, state)
+
+			type checkPoint struct {
+				state aggregationState
+				final bool
+			}
+			checkPoints := make(chan checkPoint, 1)
+			exit := make(chan error)
+			go func() {
+				exit <- aggregator.beginAggregating(ctx, state, beginAggregatingOpts{
+					checkPointInterval: deps.checkPointInterval,
+					onCheckPoint: func(_ context.Context, state aggregationState, final bool) {
+						checkPoints <- checkPoint{state: state, final: final}
+					},
+				})
+			}()
+			deps.checkPointTickerChan <- time.Now()
+			got := <-checkPoints
+			assert.False(t, got.final)
+			assert.Same(t, cnt, got.state.counters)
+			assert.Same(t, allTimeItems, got.state.allTimeItems)
+
+			mockModel.EXPECT().flushMessages(mock.Anything, state)
+			cancel()
+			assert.True(t, (<-checkPoints).final)
+			gotErr := <-exit
+			require.NoError(t, gotErr)
+		})
+		t.Run("should flush and pass final snapshot when stopped", func(t *testing.T) {
+			deps := newMockDeps(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			aggregator := newItemEventsAggregator(deps.deps)
+
+			mockModel, _ := deps.deps.AggregatorModel.(*mockItemEventsAggregatorModel)
+			cnt, _ := newCounters().(*countersImpl)
+			cnt.updateItemsCount(rand.Int63n(1000), randomCountersValues())
+			state := aggregationState{
+				counters:     cnt,
+				allTimeItems: newTopKItems(topKMaxItemsSize),
+			}
+
+			fetchResultChan := make(chan fetchMessageResult)
+			mockModel.EXPECT().fetchMessages(ctx, int64(0)).Return(fetchResultChan)
+			mockModel.EXPECT().flushMessages(mock.Anything, state)
+
+			var gotSnapshot aggregationState
+			var gotFinal bool
+			exit := make(chan error)
+			go func() {
+				exit <- aggregator.beginAggregating(ctx, state, beginAggregatingOpts{
+					checkPointInterval: deps.checkPointInterval,
+					onCheckPoint: func(_ context.Context, snapshot aggregationState, final bool) {
+						gotSnapshot = snapshot
+						gotFinal = final
+					},
+				})
+			}()
+			cancel()
+			gotErr := <-exit
+			require.NoError(t, gotErr)
+			assert.True(t, gotFinal)
+			assert.Equal(t, cnt.getLastOffset(), gotSnapshot.counters.getLastOffset())
+			assert.Equal(t, cnt.getItemsCounters(), gotSnapshot.counters.getItemsCounters())
+		})
+		t.Run("should trace flush and check point write within check point span", func(t *testing.T) {
+			deps := newMockDeps(t)
+			recorder := tracetest.NewSpanRecorder()
+			deps.deps.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			ctx, cancel := context.WithCancel(context.Background())
+			aggregator := newItemEventsAggregator(deps.deps)
+
+			mockModel, _ := deps.deps.AggregatorModel.(*mockItemEventsAggregatorModel)
+			state := aggregationState{
+				counters:     newCounters(),
+				allTimeItems: newTopKItems(topKMaxItemsSize),
+			}
+
+			fetchResultChan := make(chan fetchMessageResult)
+			mockModel.EXPECT().fetchMessages(ctx, int64(0)).Return(fetchResultChan)
+			var flushSpanContext trace.SpanContext
+			mockModel.EXPECT().flushMessages(mock.Anything, state).Run(func(flushCtx context.Context, _ aggregationState) {
+				flushSpanContext = trace.SpanContextFromContext(flushCtx)
+			})
+
+			checkPointSpanContexts := make(chan trace.SpanContext, 1)
+			exit := make(chan error)
+			go func() {
+				exit <- aggregator.beginAggregating(ctx, state, beginAggregatingOpts{
+					checkPointInterval: deps.checkPointInterval,
+					onCheckPoint: func(cpCtx context.Context, _ aggregationState, final bool) {
+						if !final {
+							checkPointSpanContexts <- trace.SpanContextFromContext(cpCtx)
+						}
+					},
+				})
+			}()
+			deps.checkPointTickerChan <- time.Now()
+			gotSpanContext := <-checkPointSpanContexts
+			cancel()
+			require.NoError(t, <-exit)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 2)
+			assert.Equal(t, "aggregator.checkPoint", spans[0].Name())
+			assert.Equal(t, spans[0].SpanContext(), gotSpanContext)
+			assert.Equal(t, spans[1].SpanContext(), flushSpanContext)
+		})
+	})
+}
+
+func TestNewLiveAggregationState(t *testing.T) {
+	t.Run("should create the state with rank index if enabled", func(t *testing.T) {
+		state := newLiveAggregationState(LiveAggregationStateDeps{
+			RankIndexEnabled:     true,
+			AllTimeItemsCapacity: topKMaxItemsSize,
+		})
+		assert.NotNil(t, state.counters)
+		assert.NotNil(t, state.allTimeItems)
+		assert.NotNil(t, state.status)
+		assert.NotNil(t, state.rankIndex)
+	})
+	t.Run("should create the state without rank index if disabled", func(t *testing.T) {
+		state := newLiveAggregationState(LiveAggregationStateDeps{})
+		assert.Nil(t, state.rankIndex)
+	})
+}
+
+func TestSnapshotAggregationState(t *testing.T) {
+	t.Run("should copy counters and all time items", func(t *testing.T) {
+		capacity := topKMaxItemsSize + 1 + rand.Intn(100)
+		state := aggregationState{
+			counters:     newCounters(),
+			allTimeItems: newTopKItems(capacity),
+		}
+		state.counters.updateItemsCount(rand.Int63n(1000), randomCountersValues())
+		state.allTimeItems.load(randomTopKItems(capacity))
+
+		snapshot := snapshotAggregationState(state)
+		assert.Equal(t, state.counters.getLastOffset(), snapshot.counters.getLastOffset())
+		assert.Equal(t, state.counters.getItemsCounters(), snapshot.counters.getItemsCounters())
+		assert.Equal(t,
+			state.allTimeItems.getItems(topKGetAllItemsLimit),
+			snapshot.allTimeItems.getItems(topKGetAllItemsLimit),
+		)
 	})
 }
@@ -0,0 +1,215 @@
+package aggregation
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	blobCompressionNone = "none"
+	blobCodecGzip       = "gzip"
+	blobCodecZstd       = "zstd"
+	blobCodecAESGCM     = "aes-gcm"
+
+	blobCodecSeparator = "+"
+)
+
+// blobTransform is a single step of the blob encoding (e.g compression or encryption).
+type blobTransform interface {
+	newWriter(w io.Writer) (io.WriteCloser, error)
+	newReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// blobCodec is a chain of transforms applied to blobs in order when writing and in
+// reverse order when reading. The name of the codec is recorded in the manifest
+// (e.g "zstd+aes-gcm"). Empty codec means raw blobs.
+type blobCodec struct {
+	name       string
+	transforms []blobTransform
+}
+
+type multiCloser []io.Closer
+
+func (c multiCloser) Close() error {
+	for _, closer := range c {
+		if err := closer.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type blobCodecWriter struct {
+	io.Writer
+	multiCloser
+}
+
+type blobCodecReader struct {
+	io.Reader
+	multiCloser
+}
+
+// newWriter returns a writer that will encode the data written to it. The writer
+// must be closed to flush all the data.
+func (c blobCodec) newWriter(w io.Writer) (io.WriteCloser, error) {
+	var closers multiCloser
+	for i := len(c.transforms) - 1; i >= 0; i-- {
+		tw, err := c.transforms[i].newWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		w = tw
+		closers = append(multiCloser{tw}, closers...)
+	}
+	return blobCodecWriter{Writer: w, multiCloser: closers}, nil
+}
+
+func (c blobCodec) newReader(r io.Reader) (io.ReadCloser, error) {
+	var closers multiCloser
+	for i := len(c.transforms) - 1; i >= 0; i-- {
+		tr, err := c.transforms[i].newReader(r)
+		if err != nil {
+			return nil, err
+		}
+		r = tr
+		closers = append(multiCloser{tr}, closers...)
+	}
+	return blobCodecReader{Reader: r, multiCloser: closers}, nil
+}
+
+type gzipTransform struct{}
+
+func (gzipTransform) newWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipTransform) newReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+type zstdTransform struct{}
+
+func (zstdTransform) newWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w)
+}
+
+func (zstdTransform) newReader(r io.Reader) (io.ReadCloser, error) {
+	dec, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return dec.IOReadCloser(), nil
+}
+
+// aesGCMTransform will encrypt the whole blob with a random nonce prepended.
+// GCM can not authenticate partial data so the blob is buffered in memory.
+type aesGCMTransform struct {
+	aead cipher.AEAD
+}
+
+type aesGCMWriter struct {
+	bytes.Buffer
+	aead cipher.AEAD
+	out  io.Writer
+}
+
+func (w *aesGCMWriter) Close() error {
+	nonce := make([]byte, w.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	_, err := w.out.Write(w.aead.Seal(nonce, nonce, w.Bytes(), nil))
+	return err
+}
+
+func (t aesGCMTransform) newWriter(w io.Writer) (io.WriteCloser, error) {
+	return &aesGCMWriter{aead: t.aead, out: w}, nil
+}
+
+func (t aesGCMTransform) newReader(r io.Reader) (io.ReadCloser, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := t.aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errors.New("encrypted blob is too short")
+	}
+	plain, err := t.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt blob: %w", err)
+	}
+	return io.NopCloser(bytes.NewReader(plain)), nil
+}
+
+func newAESGCMTransform(encryptionKey string) (aesGCMTransform, error) {
+	if encryptionKey == "" {
+		return aesGCMTransform{}, errors.New("encryption key is not configured")
+	}
+	key, err := base64.StdEncoding.DecodeString(encryptionKey)
+	if err != nil {
+		return aesGCMTransform{}, fmt.Errorf("failed to decode encryption key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return aesGCMTransform{}, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return aesGCMTransform{}, err
+	}
+	return aesGCMTransform{aead: aead}, nil
+}
+
+// parseBlobCodec will build the codec by its name. The encryption key (base64 encoded
+// AES key) is only required if the codec includes encryption.
+func parseBlobCodec(name string, encryptionKey string) (blobCodec, error) {
+	codec := blobCodec{name: name}
+	if name == "" {
+		return codec, nil
+	}
+	for _, transformName := range strings.Split(name, blobCodecSeparator) {
+		switch transformName {
+		case blobCodecGzip:
+			codec.transforms = append(codec.transforms, gzipTransform{})
+		case blobCodecZstd:
+			codec.transforms = append(codec.transforms, zstdTransform{})
+		case blobCodecAESGCM:
+			transform, err := newAESGCMTransform(encryptionKey)
+			if err != nil {
+				return blobCodec{}, err
+			}
+			codec.transforms = append(codec.transforms, transform)
+		default:
+			return blobCodec{}, fmt.Errorf("unsupported blob codec: %s", name)
+		}
+	}
+	return codec, nil
+}
+
+// blobCodecName will produce the codec name for a given compression
+// and optional encryption.
+func blobCodecName(compression string, encrypt bool) (string, error) {
+	var transforms []string
+	switch compression {
+	case "", blobCompressionNone:
+	case blobCodecGzip, blobCodecZstd:
+		transforms = append(transforms, compression)
+	default:
+		return "", fmt.Errorf("unsupported blob compression: %s", compression)
+	}
+	if encrypt {
+		transforms = append(transforms, blobCodecAESGCM)
+	}
+	return strings.Join(transforms, blobCodecSeparator), nil
+}
@@ -0,0 +1,140 @@
+package aggregation
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func randomEncryptionKey() string {
+	key := make([]byte, 32)
+	lo.Must(rand.Read(key))
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestBlobCodec(t *testing.T) {
+	encode := func(t *testing.T, codec blobCodec, data []byte) []byte {
+		var out bytes.Buffer
+		w, err := codec.newWriter(&out)
+		require.NoError(t, err)
+		lo.Must(w.Write(data))
+		require.NoError(t, w.Close())
+		return out.Bytes()
+	}
+
+	decode := func(t *testing.T, codec blobCodec, data []byte) ([]byte, error) {
+		r, err := codec.newReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+
+	t.Run("should encode and decode data", func(t *testing.T) {
+		encryptionKey := randomEncryptionKey()
+		data := []byte(strings.Repeat(faker.UUIDHyphenated(), 100))
+		for _, name := range []string{
+			"",
+			blobCodecGzip,
+			blobCodecZstd,
+			blobCodecAESGCM,
+			blobCodecGzip + blobCodecSeparator + blobCodecAESGCM,
+			blobCodecZstd + blobCodecSeparator + blobCodecAESGCM,
+		} {
+			t.Run(name, func(t *testing.T) {
+				codec, err := parseBlobCodec(name, encryptionKey)
+				require.NoError(t, err)
+				assert.Equal(t, name, codec.name)
+
+				encoded := encode(t, codec, data)
+				if name != "" {
+					assert.NotEqual(t, data, encoded)
+				}
+				decoded, err := decode(t, codec, encoded)
+				require.NoError(t, err)
+				assert.Equal(t, data, decoded)
+			})
+		}
+	})
+
+	t.Run("should compress data", func(t *testing.T) {
+		data := []byte(strings.Repeat(faker.UUIDHyphenated(), 100))
+		for _, name := range []string{blobCodecGzip, blobCodecZstd} {
+			codec := lo.Must(parseBlobCodec(name, ""))
+			assert.Less(t, len(encode(t, codec, data)), len(data)/10, name)
+		}
+	})
+
+	t.Run("should fail to decrypt with a different key", func(t *testing.T) {
+		data := []byte(faker.Sentence())
+		encoded := encode(t, lo.Must(parseBlobCodec(blobCodecAESGCM, randomEncryptionKey())), data)
+
+		_, err := decode(t, lo.Must(parseBlobCodec(blobCodecAESGCM, randomEncryptionKey())), encoded)
+		require.Error(t, err)
+	})
+
+	t.Run("should fail to decrypt tampered data", func(t *testing.T) {
+		codec := lo.Must(parseBlobCodec(blobCodecAESGCM, randomEncryptionKey()))
+		encoded := encode(t, codec, []byte(faker.Sentence()))
+		encoded[len(encoded)-1] ^= 0xff
+
+		_, err := decode(t, codec, encoded)
+		require.Error(t, err)
+	})
+
+	t.Run("should fail to decrypt too short data", func(t *testing.T) {
+		codec := lo.Must(parseBlobCodec(blobCodecAESGCM, randomEncryptionKey()))
+
+		_, err := decode(t, codec, []byte{1, 2, 3})
+		require.Error(t, err)
+	})
+
+	t.Run("should fail to decode not compressed data", func(t *testing.T) {
+		for _, name := range []string{blobCodecGzip, blobCodecZstd} {
+			_, err := decode(t, lo.Must(parseBlobCodec(name, "")), []byte(faker.Sentence()))
+			require.Error(t, err, name)
+		}
+	})
+
+	t.Run("parseBlobCodec", func(t *testing.T) {
+		t.Run("should fail if unknown codec", func(t *testing.T) {
+			_, err := parseBlobCodec(blobCodecZstd+blobCodecSeparator+faker.Word(), "")
+			require.Error(t, err)
+		})
+		t.Run("should fail if no encryption key", func(t *testing.T) {
+			_, err := parseBlobCodec(blobCodecAESGCM, "")
+			require.Error(t, err)
+		})
+		t.Run("should fail if encryption key is not base64", func(t *testing.T) {
+			_, err := parseBlobCodec(blobCodecAESGCM, "%"+faker.Word())
+			require.Error(t, err)
+		})
+		t.Run("should fail if encryption key has invalid size", func(t *testing.T) {
+			_, err := parseBlobCodec(blobCodecAESGCM, base64.StdEncoding.EncodeToString([]byte(faker.Word())))
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("blobCodecName", func(t *testing.T) {
+		t.Run("should produce codec name", func(t *testing.T) {
+			assert.Equal(t, "", lo.Must(blobCodecName("", false)))
+			assert.Equal(t, "", lo.Must(blobCodecName(blobCompressionNone, false)))
+			assert.Equal(t, blobCodecZstd, lo.Must(blobCodecName(blobCodecZstd, false)))
+			assert.Equal(t, blobCodecAESGCM, lo.Must(blobCodecName(blobCompressionNone, true)))
+			assert.Equal(t, "gzip+aes-gcm", lo.Must(blobCodecName(blobCodecGzip, true)))
+		})
+		t.Run("should fail if unknown compression", func(t *testing.T) {
+			_, err := blobCodecName(faker.Word(), false)
+			require.Error(t, err)
+		})
+	})
+}
@@ -0,0 +1,342 @@
+package aggregation
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Blobs format versions recorded in the manifest
+const (
+	// blobsFormatGob is used by check points produced before the binary format was introduced
+	blobsFormatGob = 0
+
+	// blobsFormatBinaryV1 is a compact binary format. Blob layout:
+	//
+	//	magic (4 bytes) | records count (uvarint) | records...
+	//
+	// Record layout:
+	//
+	//	key kind (1 byte) | key | count (varint)
+	//
+	// Key is either 16 bytes of the packed UUID (if the key is a canonical
+	// lowercase UUID string) or uvarint length prefixed string. Counters records
+	// are sorted by key (UUID keys first), items records are kept in the original order.
+	blobsFormatBinaryV1 = 1
+)
+
+const (
+	blobsFormatGobName    = "gob"
+	blobsFormatBinaryName = "binary"
+)
+
+const (
+	countersBlobMagic = "TKC1"
+	itemsBlobMagic    = "TKI1"
+
+	binaryKeyKindString = 0
+	binaryKeyKindUUID   = 1
+
+	uuidStringLen = 36
+	uuidBytesLen  = 16
+
+	maxBinaryKeyLen = 1 << 16
+
+	// maxBinaryPreallocRecords limits the memory allocated upfront for records since
+	// the records count is read from the blob. Bigger collections grow as records are read.
+	maxBinaryPreallocRecords = 1 << 20
+)
+
+var errInvalidBinaryBlob = errors.New("invalid binary blob")
+
+// uuidHexOffsets are the positions of hex pairs in the canonical UUID string.
+var uuidHexOffsets = [uuidBytesLen]int{0, 2, 4, 6, 9, 11, 14, 16, 19, 21, 24, 26, 28, 30, 32, 34} //nolint:gochecknoglobals // constant
+
+// packUUID will pack a canonical lowercase UUID string (e.g 320d87f0-2a9c-4e66-a28d-34ef4cbaa937)
+// into 16 bytes. Other strings are not packed so they can be restored exactly.
+func packUUID(key string, dst *[uuidBytesLen]byte) bool {
+	if len(key) != uuidStringLen || key[8] != '-' || key[13] != '-' || key[18] != '-' || key[23] != '-' {
+		return false
+	}
+	for i, offset := range uuidHexOffsets {
+		hi, okHi := lowerHexValue(key[offset])
+		lo, okLo := lowerHexValue(key[offset+1])
+		if !okHi || !okLo {
+			return false
+		}
+		dst[i] = hi<<4 | lo
+	}
+	return true
+}
+
+func lowerHexValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	default:
+		return 0, false
+	}
+}
+
+func unpackUUID(src []byte) string {
+	var buf [uuidStringLen]byte
+	hex.Encode(buf[0:8], src[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], src[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], src[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], src[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], src[10:])
+	return string(buf[:])
+}
+
+type binaryRecordsWriter struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+func newBinaryRecordsWriter(w io.Writer, magic string, count int) (*binaryRecordsWriter, error) {
+	rw := &binaryRecordsWriter{w: bufio.NewWriter(w)}
+	rw.buf = append(rw.buf, magic...)
+	rw.buf = binary.AppendUvarint(rw.buf, uint64(count))
+	if _, err := rw.w.Write(rw.buf); err != nil {
+		return nil, err
+	}
+	return rw, nil
+}
+
+func (rw *binaryRecordsWriter) write(key string, count int64) error {
+	var packed [uuidBytesLen]byte
+	if packUUID(key, &packed) {
+		return rw.writePacked(&packed, count)
+	}
+	rw.buf = append(rw.buf[:0], binaryKeyKindString)
+	rw.buf = binary.AppendUvarint(rw.buf, uint64(len(key)))
+	rw.buf = append(rw.buf, key...)
+	rw.buf = binary.AppendVarint(rw.buf, count)
+	_, err := rw.w.Write(rw.buf)
+	return err
+}
+
+func (rw *binaryRecordsWriter) writePacked(packed *[uuidBytesLen]byte, count int64) error {
+	rw.buf = append(rw.buf[:0], binaryKeyKindUUID)
+	rw.buf = append(rw.buf, packed[:]...)
+	rw.buf = binary.AppendVarint(rw.buf, count)
+	_, err := rw.w.Write(rw.buf)
+	return err
+}
+
+func (rw *binaryRecordsWriter) flush() error {
+	return rw.w.Flush()
+}
+
+type binaryRecordsReader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// newBinaryRecordsReader will validate the header and return the reader
+// along with the number of records.
+func newBinaryRecordsReader(r io.Reader, magic string) (*binaryRecordsReader, int, error) {
+	rr := &binaryRecordsReader{r: bufio.NewReader(r)}
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(rr.r, header); err != nil {
+		return nil, 0, fmt.Errorf("failed to read header: %w", err)
+	}
+	if string(header) != magic {
+		return nil, 0, fmt.Errorf("unexpected header %q: %w", header, errInvalidBinaryBlob)
+	}
+	count, err := binary.ReadUvarint(rr.r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read records count: %w", err)
+	}
+	if count > math.MaxInt {
+		return nil, 0, fmt.Errorf("records count %d is too large: %w", count, errInvalidBinaryBlob)
+	}
+	return rr, int(count), nil
+}
+
+// preallocRecords returns the capacity to allocate for a given records count.
+func preallocRecords(count int) int {
+	return min(count, maxBinaryPreallocRecords)
+}
+
+func (rr *binaryRecordsReader) read() (string, int64, error) {
+	kind, err := rr.r.ReadByte()
+	if err != nil {
+		return "", 0, err
+	}
+	var key string
+	switch kind {
+	case binaryKeyKindUUID:
+		rr.buf = slices.Grow(rr.buf[:0], uuidBytesLen)[:uuidBytesLen]
+		if _, err = io.ReadFull(rr.r, rr.buf); err != nil {
+			return "", 0, err
+		}
+		key = unpackUUID(rr.buf)
+	case binaryKeyKindString:
+		var keyLen uint64
+		if keyLen, err = binary.ReadUvarint(rr.r); err != nil {
+			return "", 0, err
+		}
+		if keyLen > maxBinaryKeyLen {
+			return "", 0, fmt.Errorf("key length %d is too large: %w", keyLen, errInvalidBinaryBlob)
+		}
+		rr.buf = slices.Grow(rr.buf[:0], int(keyLen))[:keyLen]
+		if _, err = io.ReadFull(rr.r, rr.buf); err != nil {
+			return "", 0, err
+		}
+		key = string(rr.buf)
+	default:
+		return "", 0, fmt.Errorf("unexpected key kind %d: %w", kind, errInvalidBinaryBlob)
+	}
+	count, err := binary.ReadVarint(rr.r)
+	if err != nil {
+		return "", 0, err
+	}
+	return key, count, nil
+}
+
+// readAll will call fn for each record. It ensures that there is no trailing data.
+func (rr *binaryRecordsReader) readAll(count int, fn func(key string, val int64)) error {
+	for i := range count {
+		key, val, err := rr.read()
+		if err != nil {
+			return fmt.Errorf("failed to read record %d: %w", i, unexpectedEOF(err))
+		}
+		fn(key, val)
+	}
+	if _, err := rr.r.ReadByte(); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("unexpected data after %d records: %w", count, errInvalidBinaryBlob)
+	}
+	return nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// packedUUIDRecord is a counter with the packed UUID key. Big endian halves
+// of the packed UUID are ordered the same way as canonical UUID strings.
+type packedUUIDRecord struct {
+	hi    uint64
+	lo    uint64
+	count int64
+}
+
+func comparePackedUUIDRecords(a, b packedUUIDRecord) int {
+	if c := cmp.Compare(a.hi, b.hi); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.lo, b.lo)
+}
+
+// encodeCountersBinary will write the counters to a given writer. Records with UUID
+// keys go first sorted by key followed by other records sorted by key, so the blob
+// is the same for the same counters. Sorting requires a copy of all the records,
+// UUIDs are packed before sorting since comparing them is much faster than comparing strings.
+func encodeCountersBinary(w io.Writer, counters map[string]int64) error {
+	uuidRecords := make([]packedUUIDRecord, 0, len(counters))
+	var otherRecords []topKItem
+	var packed [uuidBytesLen]byte
+	for key, val := range counters {
+		if packUUID(key, &packed) {
+			uuidRecords = append(uuidRecords, packedUUIDRecord{
+				hi:    binary.BigEndian.Uint64(packed[:8]),
+				lo:    binary.BigEndian.Uint64(packed[8:]),
+				count: val,
+			})
+		} else {
+			otherRecords = append(otherRecords, topKItem{ItemID: key, Count: val})
+		}
+	}
+	slices.SortFunc(uuidRecords, comparePackedUUIDRecords)
+	slices.SortFunc(otherRecords, func(a, b topKItem) int {
+		return strings.Compare(a.ItemID, b.ItemID)
+	})
+
+	rw, err := newBinaryRecordsWriter(w, countersBlobMagic, len(counters))
+	if err != nil {
+		return err
+	}
+	for _, record := range uuidRecords {
+		binary.BigEndian.PutUint64(packed[:8], record.hi)
+		binary.BigEndian.PutUint64(packed[8:], record.lo)
+		if err = rw.writePacked(&packed, record.count); err != nil {
+			return err
+		}
+	}
+	for _, record := range otherRecords {
+		if err = rw.write(record.ItemID, record.Count); err != nil {
+			return err
+		}
+	}
+	return rw.flush()
+}
+
+func decodeCountersBinary(r io.Reader) (map[string]int64, error) {
+	rr, count, err := newBinaryRecordsReader(r, countersBlobMagic)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]int64, preallocRecords(count))
+	if err = rr.readAll(count, func(key string, val int64) {
+		result[key] = val
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func encodeItemsBinary(w io.Writer, items []*topKItem) error {
+	rw, err := newBinaryRecordsWriter(w, itemsBlobMagic, len(items))
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err = rw.write(item.ItemID, item.Count); err != nil {
+			return err
+		}
+	}
+	return rw.flush()
+}
+
+func decodeItemsBinary(r io.Reader) ([]*topKItem, error) {
+	rr, count, err := newBinaryRecordsReader(r, itemsBlobMagic)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*topKItem, 0, preallocRecords(count))
+	if err = rr.readAll(count, func(key string, val int64) {
+		result = append(result, &topKItem{ItemID: key, Count: val})
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// parseBlobsFormat will return the format version by its config name.
+func parseBlobsFormat(name string) (int, error) {
+	switch name {
+	case blobsFormatGobName:
+		return blobsFormatGob, nil
+	case blobsFormatBinaryName:
+		return blobsFormatBinaryV1, nil
+	default:
+		return 0, fmt.Errorf("unsupported blobs format: %s", name)
+	}
+}
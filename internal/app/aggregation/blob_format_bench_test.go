@@ -0,0 +1,70 @@
+package aggregation
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/samber/lo"
+)
+
+func randomCountersOfSize(size int) map[string]int64 {
+	counters := make(map[string]int64, size)
+	for range size {
+		counters[faker.UUIDHyphenated()] = rand.Int64N(1000000)
+	}
+	return counters
+}
+
+func BenchmarkBlobFormat(b *testing.B) {
+	type format struct {
+		name   string
+		encode func(w io.Writer, counters map[string]int64) error
+		decode func(r io.Reader) (map[string]int64, error)
+	}
+	formats := []format{
+		{
+			name: blobsFormatGobName,
+			encode: func(w io.Writer, counters map[string]int64) error {
+				return gob.NewEncoder(w).Encode(counters)
+			},
+			decode: func(r io.Reader) (map[string]int64, error) {
+				var result map[string]int64
+				err := gob.NewDecoder(r).Decode(&result)
+				return result, err
+			},
+		},
+		{
+			name:   blobsFormatBinaryName,
+			encode: encodeCountersBinary,
+			decode: decodeCountersBinary,
+		},
+	}
+
+	for _, size := range []int{10000, 100000} {
+		counters := randomCountersOfSize(size)
+		for _, f := range formats {
+			var encoded bytes.Buffer
+			lo.Must0(f.encode(&encoded, counters))
+
+			b.Run(f.name+"/encode/"+lo.Ternary(size == 10000, "10k", "100k"), func(b *testing.B) {
+				var buf bytes.Buffer
+				for i := 0; i < b.N; i++ {
+					buf.Reset()
+					lo.Must0(f.encode(&buf, counters))
+				}
+				b.ReportMetric(float64(encoded.Len()), "blob-bytes")
+			})
+
+			b.Run(f.name+"/decode/"+lo.Ternary(size == 10000, "10k", "100k"), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					lo.Must(f.decode(bytes.NewReader(encoded.Bytes())))
+				}
+				b.ReportMetric(float64(encoded.Len()), "blob-bytes")
+			})
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package aggregation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobFormat(t *testing.T) {
+	t.Run("packUUID", func(t *testing.T) {
+		t.Run("should pack canonical uuid", func(t *testing.T) {
+			key := faker.UUIDHyphenated()
+			var packed [uuidBytesLen]byte
+			require.True(t, packUUID(key, &packed))
+			assert.Equal(t, key, unpackUUID(packed[:]))
+		})
+		t.Run("should not pack other strings", func(t *testing.T) {
+			uuid := faker.UUIDHyphenated()
+			for _, key := range []string{
+				"",
+				faker.Word(),
+				strings.ToUpper(uuid),
+				faker.UUIDDigit(),
+				"{" + uuid[1:35] + "}",
+				strings.ReplaceAll(uuid, "-", "_"),
+				uuid[:35] + "g",
+			} {
+				var packed [uuidBytesLen]byte
+				assert.False(t, packUUID(key, &packed), key)
+			}
+		})
+	})
+
+	t.Run("counters", func(t *testing.T) {
+		t.Run("should encode and decode counters", func(t *testing.T) {
+			counters := randomCountersValues()
+			counters[faker.Word()] = rand.Int64()
+			counters[strings.ToUpper(faker.UUIDHyphenated())] = rand.Int64()
+			counters[""] = 0
+			counters[faker.UUIDHyphenated()] = -rand.Int64()
+			counters[faker.UUIDHyphenated()] = math.MaxInt64
+
+			var buf bytes.Buffer
+			require.NoError(t, encodeCountersBinary(&buf, counters))
+			got, err := decodeCountersBinary(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, counters, got)
+		})
+		t.Run("should write records sorted by key", func(t *testing.T) {
+			counters := randomCountersValues()
+			var buf bytes.Buffer
+			require.NoError(t, encodeCountersBinary(&buf, counters))
+
+			rr, count, err := newBinaryRecordsReader(&buf, countersBlobMagic)
+			require.NoError(t, err)
+			require.Equal(t, len(counters), count)
+			var keys []string
+			require.NoError(t, rr.readAll(count, func(key string, _ int64) {
+				keys = append(keys, key)
+			}))
+			assert.IsIncreasing(t, keys)
+		})
+		t.Run("should write uuid keys before other keys", func(t *testing.T) {
+			counters := randomCountersValues()
+			otherKeys := []string{"b-" + faker.Word(), "a-" + faker.Word(), strings.ToUpper(faker.UUIDHyphenated())}
+			for _, key := range otherKeys {
+				counters[key] = rand.Int64()
+			}
+			var buf bytes.Buffer
+			require.NoError(t, encodeCountersBinary(&buf, counters))
+
+			rr, count, err := newBinaryRecordsReader(&buf, countersBlobMagic)
+			require.NoError(t, err)
+			var keys []string
+			require.NoError(t, rr.readAll(count, func(key string, _ int64) {
+				keys = append(keys, key)
+			}))
+			uuidKeys := keys[:len(keys)-len(otherKeys)]
+			assert.IsIncreasing(t, uuidKeys)
+			for _, key := range uuidKeys {
+				assert.NotContains(t, otherKeys, key)
+			}
+			slices.Sort(otherKeys)
+			assert.Equal(t, otherKeys, keys[len(uuidKeys):])
+		})
+		t.Run("should pack uuid keys", func(t *testing.T) {
+			counters := map[string]int64{faker.UUIDHyphenated(): 1}
+			var buf bytes.Buffer
+			require.NoError(t, encodeCountersBinary(&buf, counters))
+
+			// magic, count, kind, uuid, count
+			assert.Equal(t, len(countersBlobMagic)+1+1+uuidBytesLen+1, buf.Len())
+		})
+		t.Run("should encode empty counters", func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, encodeCountersBinary(&buf, map[string]int64{}))
+			got, err := decodeCountersBinary(&buf)
+			require.NoError(t, err)
+			assert.Empty(t, got)
+		})
+		t.Run("should return write errors", func(t *testing.T) {
+			wantErr := errors.New(faker.Sentence())
+			counters := make(map[string]int64, 1000)
+			for range 1000 {
+				counters[faker.UUIDHyphenated()] = rand.Int64()
+			}
+			err := encodeCountersBinary(errWriter{err: wantErr}, counters)
+			require.ErrorIs(t, err, wantErr)
+		})
+		t.Run("should fail if unexpected header", func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, encodeItemsBinary(&buf, randomTopKItems(3)))
+			_, err := decodeCountersBinary(&buf)
+			require.ErrorIs(t, err, errInvalidBinaryBlob)
+		})
+		t.Run("should fail if no header", func(t *testing.T) {
+			_, err := decodeCountersBinary(bytes.NewReader([]byte(countersBlobMagic[:2])))
+			require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		})
+		t.Run("should fail if no records count", func(t *testing.T) {
+			_, err := decodeCountersBinary(bytes.NewReader([]byte(countersBlobMagic)))
+			require.ErrorIs(t, err, io.EOF)
+		})
+		t.Run("should fail if truncated", func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, encodeCountersBinary(&buf, randomCountersValues()))
+			data := buf.Bytes()
+			_, err := decodeCountersBinary(bytes.NewReader(data[:len(data)-1-rand.IntN(10)]))
+			require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		})
+		t.Run("should fail if trailing data", func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, encodeCountersBinary(&buf, randomCountersValues()))
+			buf.WriteByte(byte(rand.IntN(256)))
+			_, err := decodeCountersBinary(&buf)
+			require.ErrorIs(t, err, errInvalidBinaryBlob)
+		})
+		t.Run("should fail if unknown key kind", func(t *testing.T) {
+			data := binary.AppendUvarint([]byte(countersBlobMagic), 1)
+			data = append(data, 2+byte(rand.IntN(100)))
+			_, err := decodeCountersBinary(bytes.NewReader(data))
+			require.ErrorIs(t, err, errInvalidBinaryBlob)
+		})
+		t.Run("should fail if key is too long", func(t *testing.T) {
+			data := binary.AppendUvarint([]byte(countersBlobMagic), 1)
+			data = append(data, binaryKeyKindString)
+			data = binary.AppendUvarint(data, maxBinaryKeyLen+1)
+			_, err := decodeCountersBinary(bytes.NewReader(data))
+			require.ErrorIs(t, err, errInvalidBinaryBlob)
+		})
+		t.Run("should not preallocate for untrusted records count", func(t *testing.T) {
+			data := binary.AppendUvarint([]byte(countersBlobMagic), math.MaxInt-uint64(rand.IntN(1000)))
+			_, err := decodeCountersBinary(bytes.NewReader(data))
+			require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		})
+		t.Run("should fail if records count overflows", func(t *testing.T) {
+			data := binary.AppendUvarint([]byte(countersBlobMagic), math.MaxInt+1+uint64(rand.IntN(1000)))
+			_, err := decodeCountersBinary(bytes.NewReader(data))
+			require.ErrorIs(t, err, errInvalidBinaryBlob)
+		})
+	})
+
+	t.Run("items", func(t *testing.T) {
+		t.Run("should encode and decode items preserving order", func(t *testing.T) {
+			items := randomTopKItems(10)
+			items = append(items, &topKItem{ItemID: faker.Word(), Count: rand.Int64()})
+
+			var buf bytes.Buffer
+			require.NoError(t, encodeItemsBinary(&buf, items))
+			got, err := decodeItemsBinary(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, items, got)
+		})
+		t.Run("should not preallocate for untrusted records count", func(t *testing.T) {
+			data := binary.AppendUvarint([]byte(itemsBlobMagic), math.MaxInt-uint64(rand.IntN(1000)))
+			data = append(data, binaryKeyKindUUID)
+			_, err := decodeItemsBinary(bytes.NewReader(data))
+			require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		})
+		t.Run("should fail if unexpected header", func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, encodeCountersBinary(&buf, randomCountersValues()))
+			_, err := decodeItemsBinary(&buf)
+			require.ErrorIs(t, err, errInvalidBinaryBlob)
+		})
+		t.Run("should fail if truncated", func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, encodeItemsBinary(&buf, randomTopKItems(3)))
+			data := buf.Bytes()
+			_, err := decodeItemsBinary(bytes.NewReader(data[:len(data)-1]))
+			require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		})
+		t.Run("should return write errors", func(t *testing.T) {
+			wantErr := errors.New(faker.Sentence())
+			err := encodeItemsBinary(errWriter{err: wantErr}, randomTopKItems(1000))
+			require.ErrorIs(t, err, wantErr)
+		})
+	})
+
+	t.Run("parseBlobsFormat", func(t *testing.T) {
+		assert.Equal(t, blobsFormatGob, lo.Must(parseBlobsFormat(blobsFormatGobName)))
+		assert.Equal(t, blobsFormatBinaryV1, lo.Must(parseBlobsFormat(blobsFormatBinaryName)))
+		_, err := parseBlobsFormat(faker.Word())
+		require.Error(t, err)
+	})
+}
+
+type errWriter struct {
+	err error
+}
+
+func (w errWriter) Write(_ []byte) (int, error) {
+	return 0, w.err
+}
@@ -1,18 +1,65 @@
 package aggregation
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io/fs"
 	"log/slog"
+	"os"
+	"slices"
+	"time"
 
+	"github.com/gemyago/top-k-system-go/internal/diag"
+	"github.com/gemyago/top-k-system-go/internal/services"
+	"github.com/gemyago/top-k-system-go/internal/services/blobstorage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/dig"
 )
 
+// ErrStaleCheckPoint indicates an attempt to write the check point with the offset
+// not above the current one.
+var ErrStaleCheckPoint = errors.New("check point is not newer than the current one")
+
+// ErrCheckPointNotFound indicates that there is no check point with a given offset
+// in the check points history.
+var ErrCheckPointNotFound = errors.New("check point not found")
+
+// ErrCheckPointsLeaseLost indicates that the check points lease has expired and has
+// been taken by another owner while the check points were being modified.
+var ErrCheckPointsLeaseLost = errors.New("check points lease is lost")
+
+// leaseRenewalsPerTTL is a number of times the lease is renewed within its TTL, so
+// a single failed renewal does not let the lease expire.
+const leaseRenewalsPerTTL = 3
+
+type checkPointListItem struct {
+	checkPointManifest
+	current bool
+}
+
 type checkPointer interface {
 	restoreState(ctx context.Context, state aggregationState) error
+
+	// restoreStateAt will restore the state from the historical check point with a given offset.
+	restoreStateAt(ctx context.Context, state aggregationState, offset int64) error
+
 	dumpState(ctx context.Context, state aggregationState) error
+
+	// listCheckPoints returns all known check points ordered by offset (oldest first).
+	listCheckPoints(ctx context.Context) ([]checkPointListItem, error)
+
+	// rollbackTo will make the historical check point with a given offset current.
+	rollbackTo(ctx context.Context, offset int64) error
+
+	// pruneCheckPoints will apply the retention policy to the check points history
+	// and delete blobs that are no longer referenced by retained check points.
+	pruneCheckPoints(ctx context.Context) error
 }
 
 type CheckPointerDeps struct {
@@ -23,16 +70,118 @@ type CheckPointerDeps struct {
 
 	RootLogger *slog.Logger
 
+	// config
+	RetentionKeepLast    int           `name:"config.checkpointer.retention.keepLast"`
+	RetentionMaxAge      time.Duration `name:"config.checkpointer.retention.maxAge"`
+	LeaseTTL             time.Duration `name:"config.checkpointer.lease.ttl"`
+	AllTimeItemsCapacity int           `name:"config.aggregator.topK.allTime.capacity"`
+
+	// service layer
+	Time              services.TimeProvider
+	UUIDGenerator     services.UUIDGenerator
+	MetricsRegisterer prometheus.Registerer
+	TracerProvider    trace.TracerProvider
+
 	// package private components
 	CheckPointerModel checkPointerModel
 }
 
 type checkPointerImpl struct {
-	logger *slog.Logger
-	deps   CheckPointerDeps
+	logger        *slog.Logger
+	tracer        trace.Tracer
+	deps          CheckPointerDeps
+	writeDuration prometheus.Histogram
+
+	// leaseOwner uniquely identifies this process when acquiring the lease
+	leaseOwner string
 }
 
-func (cp *checkPointerImpl) restoreState(ctx context.Context, state aggregationState) error {
+// withLease will run the function holding the check points lease so concurrent
+// writers (e.g overlapping jobs) will not interfere. The lease is renewed while the
+// function is running. If the lease is lost, the context of the function is canceled.
+func (cp *checkPointerImpl) withLease(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := cp.deps.CheckPointerModel.acquireLease(ctx, cp.leaseOwner, cp.deps.LeaseTTL); err != nil {
+		return fmt.Errorf("failed to acquire check points lease: %w", err)
+	}
+	leaseCtx, cancel := context.WithCancelCause(ctx)
+	renewalDone := make(chan struct{})
+	go func() {
+		defer close(renewalDone)
+		cp.renewLease(leaseCtx, cancel)
+	}()
+	fnErr := fn(leaseCtx)
+	leaseLost := context.Cause(leaseCtx)
+	cancel(nil)
+	<-renewalDone
+	if err := cp.deps.CheckPointerModel.releaseLease(ctx, cp.leaseOwner); err != nil {
+		cp.logger.WarnContext(ctx, "Failed to release check points lease", diag.ErrAttr(err))
+	}
+	if fnErr != nil && errors.Is(leaseLost, ErrCheckPointsLeaseLost) {
+		return fmt.Errorf("%w: %w", leaseLost, fnErr)
+	}
+	return fnErr
+}
+
+// renewLease will periodically extend the lease until the context is done. The context
+// is canceled with ErrCheckPointsLeaseLost if the lease is taken by another owner.
+// Other renewal errors are logged, the lease is still valid till the next attempt.
+func (cp *checkPointerImpl) renewLease(ctx context.Context, loseLease context.CancelCauseFunc) {
+	if cp.deps.LeaseTTL <= 0 {
+		return
+	}
+	ticker := time.NewTicker(cp.deps.LeaseTTL / leaseRenewalsPerTTL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cp.ensureLease(ctx); err != nil {
+				if errors.Is(err, ErrCheckPointsLeaseLost) {
+					cp.logger.ErrorContext(ctx, "Check points lease is lost", diag.ErrAttr(err))
+					loseLease(err)
+					return
+				}
+				cp.logger.WarnContext(ctx, "Failed to renew check points lease", diag.ErrAttr(err))
+			}
+		}
+	}
+}
+
+// ensureLease will extend the lease held by this process. The error wrapping
+// ErrCheckPointsLeaseLost is returned if the lease is held by another owner.
+func (cp *checkPointerImpl) ensureLease(ctx context.Context) error {
+	err := cp.deps.CheckPointerModel.acquireLease(ctx, cp.leaseOwner, cp.deps.LeaseTTL)
+	if errors.Is(err, blobstorage.ErrLeaseHeld) {
+		return fmt.Errorf("%w: %w", ErrCheckPointsLeaseLost, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to renew check points lease: %w", err)
+	}
+	return nil
+}
+
+// startSpan will start the span of the check point operation. The span is ended
+// (and marked as failed if err is not nil) by the returned function. Blob storage
+// is not traced, so the context of the operation is not replaced.
+func (cp *checkPointerImpl) startSpan(
+	ctx context.Context,
+	name string,
+	attributes ...attribute.KeyValue,
+) func(err error) {
+	_, span := cp.tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "check point operation failed")
+		}
+		span.End()
+	}
+}
+
+func (cp *checkPointerImpl) restoreState(ctx context.Context, state aggregationState) (err error) {
+	endSpan := cp.startSpan(ctx, "checkpointer.restoreState")
+	defer func() { endSpan(err) }()
 	manifest, err := cp.deps.CheckPointerModel.readManifest(ctx)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
@@ -41,31 +190,125 @@ func (cp *checkPointerImpl) restoreState(ctx context.Context, state aggregationS
 		}
 		return err
 	}
+	return cp.restoreManifestState(ctx, state, manifest)
+}
+
+func (cp *checkPointerImpl) restoreStateAt(
+	ctx context.Context,
+	state aggregationState,
+	offset int64,
+) (err error) {
+	endSpan := cp.startSpan(ctx, "checkpointer.restoreStateAt", attribute.Int64("checkpoint.offset", offset))
+	defer func() { endSpan(err) }()
+	manifest, err := cp.findCheckPoint(ctx, offset)
+	if err != nil {
+		return err
+	}
+	return cp.restoreManifestState(ctx, state, manifest)
+}
 
+func (cp *checkPointerImpl) restoreManifestState(
+	ctx context.Context,
+	state aggregationState,
+	manifest checkPointManifest,
+) error {
 	// TODO: read in parallel
 
-	counterValues, err := cp.deps.CheckPointerModel.readCounters(ctx, manifest.CountersBlobFileName)
+	state.status.beginRestoreCheckPoint(manifest.LastOffset, manifest.CreatedAt, checkPointBlobsCount)
+
+	counterValues, err := cp.deps.CheckPointerModel.readCounters(ctx, manifest.CountersBlobFileName, manifest.encoding())
 	if err != nil {
 		return fmt.Errorf("failed to read counters: %w", err)
 	}
 	state.counters.updateItemsCount(manifest.LastOffset, counterValues)
+	state.rankIndex.load(counterValues)
+	state.status.blobRestored(len(counterValues))
 
-	allTimeItems, err := cp.deps.CheckPointerModel.readItems(ctx, manifest.AllTimeItemsFileName)
+	allTimeItems, err := cp.deps.CheckPointerModel.readItems(ctx, manifest.AllTimeItemsFileName, manifest.encoding())
 	if err != nil {
 		return fmt.Errorf("failed to read all time items: %w", err)
 	}
-	state.allTimeItems.load(allTimeItems)
+	cp.restoreAllTimeItems(ctx, state, manifest, allTimeItems, counterValues)
+	state.status.blobRestored(0)
+
+	return nil
+}
+
+// restoreAllTimeItems will load all time items of the check point. Extra items are
+// dropped if the capacity has shrunk. If the capacity has grown, items beyond the
+// capacity of the check point are not stored, so top items are selected from counters.
+func (cp *checkPointerImpl) restoreAllTimeItems(
+	ctx context.Context,
+	state aggregationState,
+	manifest checkPointManifest,
+	allTimeItems []*topKItem,
+	counterValues map[string]int64,
+) {
+	checkPointCapacity := manifest.allTimeItemsCapacity()
+	if cp.deps.AllTimeItemsCapacity <= checkPointCapacity || len(allTimeItems) < checkPointCapacity {
+		state.allTimeItems.load(allTimeItems)
+		return
+	}
+	cp.logger.InfoContext(ctx, "All time items capacity has grown. Selecting top items from counters.",
+		slog.Int("checkPointCapacity", checkPointCapacity),
+		slog.Int("capacity", cp.deps.AllTimeItemsCapacity),
+	)
+	for itemID, count := range counterValues {
+		state.allTimeItems.updateIfGreater(topKItem{ItemID: itemID, Count: count})
+	}
+}
+
+func (cp *checkPointerImpl) dumpState(ctx context.Context, state aggregationState) (err error) {
+	endSpan := cp.startSpan(ctx, "checkpointer.dumpState",
+		attribute.Int64("checkpoint.offset", state.counters.getLastOffset()),
+	)
+	defer func() { endSpan(err) }()
+	return cp.withLease(ctx, func(ctx context.Context) error {
+		startedAt := cp.deps.Time.Now()
+		if err := cp.writeState(ctx, state); err != nil {
+			return err
+		}
+		cp.writeDuration.Observe(cp.deps.Time.Now().Sub(startedAt).Seconds())
+		return nil
+	})
+}
+
+// checkNotStale will fail if the current check point is at or after a given offset.
+func (cp *checkPointerImpl) checkNotStale(ctx context.Context, lastOffset int64) error {
+	currentManifest, err := cp.deps.CheckPointerModel.readManifest(ctx)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
 
+	// blobs are named by offset, so the check point with the same offset
+	// would overwrite blobs of the current one
+	if lastOffset <= currentManifest.LastOffset {
+		return fmt.Errorf("offset %d, current offset %d: %w",
+			lastOffset, currentManifest.LastOffset, ErrStaleCheckPoint,
+		)
+	}
 	return nil
 }
 
-func (cp *checkPointerImpl) dumpState(ctx context.Context, state aggregationState) error {
+func (cp *checkPointerImpl) writeState(ctx context.Context, state aggregationState) error {
+	if err := cp.checkNotStale(ctx, state.counters.getLastOffset()); err != nil {
+		return err
+	}
+
+	encoding := cp.deps.CheckPointerModel.blobsEncoding()
 	countersFileName := fmt.Sprintf("counters-%d", state.counters.getLastOffset())
 	allTimeItemsFileName := fmt.Sprintf("all-time-items-%d", state.counters.getLastOffset())
 	newManifest := checkPointManifest{
 		LastOffset:           state.counters.getLastOffset(),
 		CountersBlobFileName: countersFileName,
 		AllTimeItemsFileName: allTimeItemsFileName,
+		CreatedAt:            cp.deps.Time.Now(),
+		FormatVersion:        encoding.FormatVersion,
+		Codec:                encoding.Codec,
+		AllTimeItemsCapacity: cp.deps.AllTimeItemsCapacity,
 	}
 	// TODO: write in parallel (except the manifest)
 
@@ -85,17 +328,217 @@ func (cp *checkPointerImpl) dumpState(ctx context.Context, state aggregationStat
 		return fmt.Errorf("failed to write all time items: %w", err)
 	}
 
+	// Writing blobs may take long, so the lease and the current check point are checked
+	// again. Otherwise other writer that took over the expired lease may be overwritten.
+	if err := cp.ensureLease(ctx); err != nil {
+		return err
+	}
+	if err := cp.checkNotStale(ctx, newManifest.LastOffset); err != nil {
+		return err
+	}
+
+	history, err := cp.readHistory(ctx)
+	if err != nil {
+		return err
+	}
+	history.Manifests = slices.DeleteFunc(history.Manifests, func(m checkPointManifest) bool {
+		return m.LastOffset == newManifest.LastOffset
+	})
+	history.Manifests = append(history.Manifests, newManifest)
+	slices.SortFunc(history.Manifests, compareManifestsByOffset)
+	if err = cp.deps.CheckPointerModel.writeManifestHistory(ctx, history); err != nil {
+		return fmt.Errorf("failed to write manifest history: %w", err)
+	}
+
 	// We write manifest last so if counters fail, the manifest will point on the last
 	// counters
-	if err := cp.deps.CheckPointerModel.writeManifest(ctx, newManifest); err != nil {
+	if err = cp.deps.CheckPointerModel.writeManifest(ctx, newManifest); err != nil {
 		return fmt.Errorf("failed to write manifest: %w", err)
 	}
 	return nil
 }
 
+// readHistory will read the manifest history. If there is no history yet, it will
+// be initialized with the current manifest (if any) so blobs produced before the history
+// was introduced are also subject to retention.
+func (cp *checkPointerImpl) readHistory(ctx context.Context) (checkPointManifestHistory, error) {
+	history, err := cp.deps.CheckPointerModel.readManifestHistory(ctx)
+	if err == nil {
+		return history, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return history, fmt.Errorf("failed to read manifest history: %w", err)
+	}
+	manifest, err := cp.deps.CheckPointerModel.readManifest(ctx)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return checkPointManifestHistory{}, nil
+		}
+		return history, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return checkPointManifestHistory{Manifests: []checkPointManifest{manifest}}, nil
+}
+
+func (cp *checkPointerImpl) findCheckPoint(ctx context.Context, offset int64) (checkPointManifest, error) {
+	history, err := cp.readHistory(ctx)
+	if err != nil {
+		return checkPointManifest{}, err
+	}
+	index, found := slices.BinarySearchFunc(
+		history.Manifests,
+		offset,
+		func(m checkPointManifest, offset int64) int { return cmp.Compare(m.LastOffset, offset) },
+	)
+	if !found {
+		return checkPointManifest{}, fmt.Errorf("offset %d: %w", offset, ErrCheckPointNotFound)
+	}
+	return history.Manifests[index], nil
+}
+
+func (cp *checkPointerImpl) listCheckPoints(ctx context.Context) ([]checkPointListItem, error) {
+	currentManifest, err := cp.deps.CheckPointerModel.readManifest(ctx)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []checkPointListItem{}, nil
+		}
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	history, err := cp.readHistory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]checkPointListItem, len(history.Manifests))
+	for i, manifest := range history.Manifests {
+		result[i] = checkPointListItem{
+			checkPointManifest: manifest,
+			current:            manifest.LastOffset == currentManifest.LastOffset,
+		}
+	}
+	return result, nil
+}
+
+func (cp *checkPointerImpl) rollbackTo(ctx context.Context, offset int64) error {
+	return cp.withLease(ctx, func(ctx context.Context) error {
+		manifest, err := cp.findCheckPoint(ctx, offset)
+		if err != nil {
+			return err
+		}
+		if err = cp.deps.CheckPointerModel.writeManifest(ctx, manifest); err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+		cp.logger.InfoContext(ctx, "Current check point changed",
+			slog.Int64("lastOffset", manifest.LastOffset),
+			slog.Time("createdAt", manifest.CreatedAt),
+		)
+		return nil
+	})
+}
+
+// isRetained indicates if the check point should be kept. The index is a position
+// of the check point in the history starting from the most recent one.
+func (cp *checkPointerImpl) isRetained(manifest checkPointManifest, index int, now time.Time) bool {
+	if cp.deps.RetentionKeepLast > 0 && index >= cp.deps.RetentionKeepLast {
+		return false
+	}
+	if cp.deps.RetentionMaxAge > 0 && now.Sub(manifest.CreatedAt) > cp.deps.RetentionMaxAge {
+		return false
+	}
+	return true
+}
+
+func (cp *checkPointerImpl) pruneCheckPoints(ctx context.Context) error {
+	return cp.withLease(ctx, func(ctx context.Context) error {
+		return cp.pruneHistory(ctx)
+	})
+}
+
+func (cp *checkPointerImpl) pruneHistory(ctx context.Context) error {
+	currentManifest, err := cp.deps.CheckPointerModel.readManifest(ctx)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			cp.logger.InfoContext(ctx, "Manifest not found. Nothing to prune.")
+			return nil
+		}
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+	history, err := cp.readHistory(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := cp.deps.Time.Now()
+	retained := make([]checkPointManifest, 0, len(history.Manifests))
+	pruned := make([]checkPointManifest, 0, len(history.Manifests))
+	for i, manifest := range slices.Backward(history.Manifests) {
+		isCurrent := manifest.LastOffset == currentManifest.LastOffset
+		if isCurrent || cp.isRetained(manifest, len(history.Manifests)-i-1, now) {
+			retained = append(retained, manifest)
+		} else {
+			pruned = append(pruned, manifest)
+		}
+	}
+	if len(pruned) == 0 {
+		cp.logger.InfoContext(ctx, "No check points to prune", slog.Int("retainedCount", len(retained)))
+		return nil
+	}
+
+	slices.SortFunc(retained, compareManifestsByOffset)
+	referencedBlobs := map[string]struct{}{
+		currentManifest.CountersBlobFileName: {},
+		currentManifest.AllTimeItemsFileName: {},
+	}
+	for _, manifest := range retained {
+		referencedBlobs[manifest.CountersBlobFileName] = struct{}{}
+		referencedBlobs[manifest.AllTimeItemsFileName] = struct{}{}
+	}
+
+	// History is written first so it never points to deleted blobs. If the deletion
+	// fails, the blobs are just left behind.
+	if err = cp.deps.CheckPointerModel.writeManifestHistory(ctx, checkPointManifestHistory{
+		Manifests: retained,
+	}); err != nil {
+		return fmt.Errorf("failed to write manifest history: %w", err)
+	}
+
+	deletedBlobsCount := 0
+	for _, manifest := range pruned {
+		for _, blobFileName := range []string{manifest.CountersBlobFileName, manifest.AllTimeItemsFileName} {
+			if _, ok := referencedBlobs[blobFileName]; ok {
+				continue
+			}
+			if err = cp.deps.CheckPointerModel.deleteBlob(ctx, blobFileName); err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				return fmt.Errorf("failed to delete blob %s: %w", blobFileName, err)
+			}
+			deletedBlobsCount++
+		}
+	}
+
+	cp.logger.InfoContext(ctx, "Check points pruned",
+		slog.Int("prunedCount", len(pruned)),
+		slog.Int("retainedCount", len(retained)),
+		slog.Int("deletedBlobsCount", deletedBlobsCount),
+	)
+	return nil
+}
+
+func compareManifestsByOffset(a, b checkPointManifest) int {
+	return cmp.Compare(a.LastOffset, b.LastOffset)
+}
+
 func newCheckPointer(deps CheckPointerDeps) checkPointer {
+	hostname, _ := os.Hostname()
 	return &checkPointerImpl{
 		logger: deps.RootLogger.WithGroup("check-pointer"),
+		tracer: deps.TracerProvider.Tracer(tracerName),
 		deps:   deps,
+		writeDuration: promauto.With(deps.MetricsRegisterer).NewHistogram(prometheus.HistogramOpts{
+			Name:    "checkpoint_write_duration_seconds",
+			Help:    "Duration of successfully written check points",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 14),
+		}),
+		leaseOwner: hostname + "-" + deps.UUIDGenerator(),
 	}
 }
@@ -5,25 +5,94 @@ import (
 	"context"
 	"encoding/gob"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"time"
 
+	"github.com/gemyago/top-k-system-go/internal/services"
 	"github.com/gemyago/top-k-system-go/internal/services/blobstorage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"go.uber.org/dig"
 )
 
+const (
+	manifestFileName        = "manifest.json"
+	manifestHistoryFileName = "manifest-history.json"
+	checkPointsLeaseKey     = "check-points.lease"
+)
+
+// Kinds of check point blobs reported in metrics
+const (
+	checkPointBlobCounters     = "counters"
+	checkPointBlobAllTimeItems = "all_time_items"
+)
+
+// checkPointBlobsCount is a number of blobs every check point consists of
+const checkPointBlobsCount = 2
+
 type checkPointManifest struct {
 	LastOffset           int64  `json:"lastOffset"`
 	CountersBlobFileName string `json:"countersBlobFileName"`
 	AllTimeItemsFileName string `json:"allTimeItemsFileName"`
+
+	// CreatedAt is zero for manifests produced before the history was introduced
+	CreatedAt time.Time `json:"createdAt"`
+
+	// FormatVersion of the counters and items blobs. Zero (gob) for manifests
+	// produced before the binary format was introduced
+	FormatVersion int `json:"formatVersion,omitempty"`
+
+	// Codec of the counters and items blobs. Empty for raw blobs
+	Codec string `json:"codec,omitempty"`
+
+	// AllTimeItemsCapacity is the capacity of all time items the check point is
+	// produced with. Zero for manifests produced before the capacity was configurable
+	AllTimeItemsCapacity int `json:"allTimeItemsCapacity,omitempty"`
+}
+
+// blobsEncoding describes how the counters and items blobs are encoded.
+type blobsEncoding struct {
+	FormatVersion int
+	Codec         string
+}
+
+func (m checkPointManifest) encoding() blobsEncoding {
+	return blobsEncoding{FormatVersion: m.FormatVersion, Codec: m.Codec}
+}
+
+func (m checkPointManifest) allTimeItemsCapacity() int {
+	if m.AllTimeItemsCapacity == 0 {
+		return topKMaxItemsSize
+	}
+	return m.AllTimeItemsCapacity
+}
+
+// checkPointManifestHistory holds all known check points ordered by LastOffset
+// (oldest first). The current manifest is always one of them.
+type checkPointManifestHistory struct {
+	Manifests []checkPointManifest `json:"manifests"`
 }
 
 type checkPointerModel interface {
 	readManifest(ctx context.Context) (checkPointManifest, error)
 	writeManifest(ctx context.Context, manifest checkPointManifest) error
-	readCounters(ctx context.Context, blobFileName string) (map[string]int64, error)
+	readManifestHistory(ctx context.Context) (checkPointManifestHistory, error)
+	writeManifestHistory(ctx context.Context, history checkPointManifestHistory) error
+
+	// blobsEncoding returns the encoding that is used to write counters and items blobs
+	blobsEncoding() blobsEncoding
+	readCounters(ctx context.Context, blobFileName string, encoding blobsEncoding) (map[string]int64, error)
 	writeCounters(ctx context.Context, blobFileName string, val map[string]int64) error
-	readItems(ctx context.Context, blobFileName string) ([]*topKItem, error)
+	readItems(ctx context.Context, blobFileName string, encoding blobsEncoding) ([]*topKItem, error)
 	writeItems(ctx context.Context, blobFileName string, val []*topKItem) error
+	deleteBlob(ctx context.Context, blobFileName string) error
+
+	// acquireLease will acquire the exclusive lease to modify check points. The error
+	// wrapping blobstorage.ErrLeaseHeld is returned if the lease is held by other owner.
+	acquireLease(ctx context.Context, owner string, ttl time.Duration) error
+	releaseLease(ctx context.Context, owner string) error
 }
 
 type CheckPointerModelDeps struct {
@@ -32,17 +101,39 @@ type CheckPointerModelDeps struct {
 
 	dig.In
 
+	// config
+	BlobsFormat        string `name:"config.checkpointer.blobs.format"`
+	BlobsCompression   string `name:"config.checkpointer.blobs.compression"`
+	BlobsEncryptionKey string `name:"config.checkpointer.blobs.encryptionKey"`
+
 	// services
 	blobstorage.Storage
+	Time              services.TimeProvider
+	MetricsRegisterer prometheus.Registerer
 }
 
 type checkPointerModelImpl struct {
 	CheckPointerModelDeps
+	writeFormatVersion int
+	writeCodec         blobCodec
+	blobSizeBytes      *prometheus.GaugeVec
+}
+
+// countingWriter will count bytes written to the underlying writer.
+type countingWriter struct {
+	io.Writer
+	written int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.written += int64(n)
+	return n, err
 }
 
 func (m checkPointerModelImpl) readManifest(ctx context.Context) (checkPointManifest, error) {
 	var manifestBytes bytes.Buffer
-	if err := m.Storage.Download(ctx, "manifest.json", &manifestBytes); err != nil {
+	if err := m.Storage.Download(ctx, manifestFileName, &manifestBytes); err != nil {
 		return checkPointManifest{}, fmt.Errorf("failed to read the manifest: %w", err)
 	}
 	var manifest checkPointManifest
@@ -57,59 +148,204 @@ func (m checkPointerModelImpl) writeManifest(ctx context.Context, manifest check
 	if err := json.NewEncoder(&manifestBytes).Encode(manifest); err != nil {
 		return fmt.Errorf("failed to encode manifest: %w", err)
 	}
-	return m.Storage.Upload(ctx, "manifest.json", &manifestBytes)
+	return m.Storage.Upload(ctx, manifestFileName, &manifestBytes)
 }
 
-// TODO: blobs are going to be very large (5GB), we may need to consider chunking approach
-// but this is a caller level refactoring very likely
-// both read and write sides will need to be updated
+func (m checkPointerModelImpl) readManifestHistory(ctx context.Context) (checkPointManifestHistory, error) {
+	var historyBytes bytes.Buffer
+	if err := m.Storage.Download(ctx, manifestHistoryFileName, &historyBytes); err != nil {
+		return checkPointManifestHistory{}, fmt.Errorf("failed to read the manifest history: %w", err)
+	}
+	var history checkPointManifestHistory
+	if err := json.NewDecoder(&historyBytes).Decode(&history); err != nil {
+		return checkPointManifestHistory{}, fmt.Errorf("failed to decode manifest history: %w", err)
+	}
+	return history, nil
+}
 
-func (m checkPointerModelImpl) readCounters(ctx context.Context, blobFileName string) (map[string]int64, error) {
-	var contents bytes.Buffer
-	if err := m.Storage.Download(ctx, blobFileName, &contents); err != nil {
-		return nil, fmt.Errorf("failed to download file: %w", err)
+func (m checkPointerModelImpl) writeManifestHistory(ctx context.Context, history checkPointManifestHistory) error {
+	var historyBytes bytes.Buffer
+	if err := json.NewEncoder(&historyBytes).Encode(history); err != nil {
+		return fmt.Errorf("failed to encode manifest history: %w", err)
 	}
-	var result map[string]int64
-	if err := gob.NewDecoder(&contents).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode counters: %w", err)
+	return m.Storage.Upload(ctx, manifestHistoryFileName, &historyBytes)
+}
+
+func (m checkPointerModelImpl) blobsEncoding() blobsEncoding {
+	return blobsEncoding{FormatVersion: m.writeFormatVersion, Codec: m.writeCodec.name}
+}
+
+// readBlob will stream the blob from the storage to the decode func. Blobs can be
+// very large (GBs) so they are never fully buffered (unless encrypted).
+func (m checkPointerModelImpl) readBlob(
+	ctx context.Context,
+	blobFileName string,
+	encoding blobsEncoding,
+	decode func(r io.Reader) error,
+) error {
+	codec, err := parseBlobCodec(encoding.Codec, m.BlobsEncryptionKey)
+	if err != nil {
+		return err
 	}
-	return result, nil
+	contents, contentsWriter := io.Pipe()
+	downloadErr := make(chan error, 1)
+	go func() {
+		err := m.Storage.Download(ctx, blobFileName, contentsWriter)
+		contentsWriter.CloseWithError(err)
+		downloadErr <- err
+	}()
+
+	err = func() error {
+		reader, err := codec.newReader(contents)
+		if err != nil {
+			return fmt.Errorf("failed to decode blob file %s: %w", blobFileName, err)
+		}
+		defer reader.Close()
+		return decode(reader)
+	}()
+
+	// Will abort the download if the decoding has stopped early
+	contents.Close()
+	if err := <-downloadErr; err != nil && !errors.Is(err, io.ErrClosedPipe) {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+	return err
 }
 
-func (m checkPointerModelImpl) writeCounters(ctx context.Context, blobFileName string, val map[string]int64) error {
-	var contents bytes.Buffer
-	if err := gob.NewEncoder(&contents).Encode(val); err != nil {
-		return fmt.Errorf("failed to encode value: %w", err)
+// writeBlob will stream the encoded blob to the storage. The size of the
+// encoded blob is reported per blob kind.
+func (m checkPointerModelImpl) writeBlob(
+	ctx context.Context,
+	blobKind string,
+	blobFileName string,
+	encode func(w io.Writer) error,
+) error {
+	contents, contentsWriter := io.Pipe()
+	counter := &countingWriter{Writer: contentsWriter}
+	encodeErr := make(chan error, 1)
+	go func() {
+		err := func() error {
+			writer, err := m.writeCodec.newWriter(counter)
+			if err != nil {
+				return err
+			}
+			if err = encode(writer); err != nil {
+				return err
+			}
+			return writer.Close()
+		}()
+		contentsWriter.CloseWithError(err)
+		encodeErr <- err
+	}()
+
+	err := m.Storage.Upload(ctx, blobFileName, contents)
+
+	// Will abort the encoding if the upload has stopped early
+	contents.Close()
+	if encErr := <-encodeErr; err == nil && encErr != nil {
+		return fmt.Errorf("failed to encode value: %w", encErr)
 	}
-	if err := m.Storage.Upload(ctx, blobFileName, &contents); err != nil {
+	if err != nil {
 		return fmt.Errorf("failed to upload blob file %s: %w", blobFileName, err)
 	}
+
+	// encoding goroutine has finished at this point so it is safe to read the counter
+	m.blobSizeBytes.WithLabelValues(blobKind).Set(float64(counter.written))
 	return nil
 }
 
-func (m checkPointerModelImpl) readItems(ctx context.Context, blobFileName string) ([]*topKItem, error) {
-	var contents bytes.Buffer
-	if err := m.Storage.Download(ctx, blobFileName, &contents); err != nil {
-		return nil, fmt.Errorf("failed to download file: %w", err)
+func (m checkPointerModelImpl) readCounters(
+	ctx context.Context, blobFileName string, encoding blobsEncoding,
+) (map[string]int64, error) {
+	var result map[string]int64
+	if err := m.readBlob(ctx, blobFileName, encoding, func(r io.Reader) error {
+		var err error
+		if encoding.FormatVersion == blobsFormatBinaryV1 {
+			result, err = decodeCountersBinary(r)
+			return err
+		}
+		return gob.NewDecoder(r).Decode(&result)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to decode counters: %w", err)
 	}
+	return result, nil
+}
+
+func (m checkPointerModelImpl) writeCounters(ctx context.Context, blobFileName string, val map[string]int64) error {
+	return m.writeBlob(ctx, checkPointBlobCounters, blobFileName, func(w io.Writer) error {
+		if m.writeFormatVersion == blobsFormatBinaryV1 {
+			return encodeCountersBinary(w, val)
+		}
+		return gob.NewEncoder(w).Encode(val)
+	})
+}
+
+func (m checkPointerModelImpl) readItems(
+	ctx context.Context, blobFileName string, encoding blobsEncoding,
+) ([]*topKItem, error) {
 	var result []*topKItem
-	if err := gob.NewDecoder(&contents).Decode(&result); err != nil {
+	if err := m.readBlob(ctx, blobFileName, encoding, func(r io.Reader) error {
+		var err error
+		if encoding.FormatVersion == blobsFormatBinaryV1 {
+			result, err = decodeItemsBinary(r)
+			return err
+		}
+		return gob.NewDecoder(r).Decode(&result)
+	}); err != nil {
 		return nil, fmt.Errorf("failed to decode items: %w", err)
 	}
 	return result, nil
 }
 
 func (m checkPointerModelImpl) writeItems(ctx context.Context, blobFileName string, val []*topKItem) error {
-	var contents bytes.Buffer
-	if err := gob.NewEncoder(&contents).Encode(val); err != nil {
-		return fmt.Errorf("failed to encode value: %w", err)
-	}
-	if err := m.Storage.Upload(ctx, blobFileName, &contents); err != nil {
-		return fmt.Errorf("failed to upload blob file %s: %w", blobFileName, err)
+	return m.writeBlob(ctx, checkPointBlobAllTimeItems, blobFileName, func(w io.Writer) error {
+		if m.writeFormatVersion == blobsFormatBinaryV1 {
+			return encodeItemsBinary(w, val)
+		}
+		return gob.NewEncoder(w).Encode(val)
+	})
+}
+
+func (m checkPointerModelImpl) deleteBlob(ctx context.Context, blobFileName string) error {
+	if err := m.Storage.Delete(ctx, blobFileName); err != nil {
+		return fmt.Errorf("failed to delete blob file %s: %w", blobFileName, err)
 	}
 	return nil
 }
 
-func newCheckPointerModel(deps CheckPointerModelDeps) checkPointerModel {
-	return &checkPointerModelImpl{CheckPointerModelDeps: deps}
+func (m checkPointerModelImpl) acquireLease(ctx context.Context, owner string, ttl time.Duration) error {
+	return blobstorage.AcquireLease(ctx, m.Storage, blobstorage.LeaseParams{
+		Key:   checkPointsLeaseKey,
+		Owner: owner,
+		TTL:   ttl,
+		Now:   m.Time.Now(),
+	})
+}
+
+func (m checkPointerModelImpl) releaseLease(ctx context.Context, owner string) error {
+	return blobstorage.ReleaseLease(ctx, m.Storage, checkPointsLeaseKey, owner)
+}
+
+func newCheckPointerModel(deps CheckPointerModelDeps) (checkPointerModel, error) {
+	formatVersion, err := parseBlobsFormat(deps.BlobsFormat)
+	if err != nil {
+		return nil, err
+	}
+	codecName, err := blobCodecName(deps.BlobsCompression, deps.BlobsEncryptionKey != "")
+	if err != nil {
+		return nil, err
+	}
+	writeCodec, err := parseBlobCodec(codecName, deps.BlobsEncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return &checkPointerModelImpl{
+		CheckPointerModelDeps: deps,
+		writeFormatVersion:    formatVersion,
+		writeCodec:            writeCodec,
+		blobSizeBytes: promauto.With(deps.MetricsRegisterer).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "checkpoint_blob_size_bytes",
+			Help: "Size of the last written check point blob after encoding",
+		}, []string{"blob"}),
+	}, nil
 }
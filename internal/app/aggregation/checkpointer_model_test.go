@@ -1,6 +1,7 @@
 package aggregation
 
 import (
+	"bytes"
 	"context"
 	"encoding/gob"
 	"encoding/json"
@@ -8,30 +9,52 @@ import (
 	"io"
 	"math/rand/v2"
 	"testing"
+	"time"
 
+	"github.com/gemyago/top-k-system-go/internal/services"
 	"github.com/gemyago/top-k-system-go/internal/services/blobstorage"
 	"github.com/go-faker/faker/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
+// pipeSafeStorage hides the pipe ends from the mock. The mock formats all the
+// arguments when matching the call, which races with the other side of the pipe.
+type pipeSafeStorage struct {
+	*blobstorage.MockStorage
+}
+
+func (s pipeSafeStorage) Upload(ctx context.Context, key string, r io.Reader) error {
+	return s.MockStorage.Upload(ctx, key, struct{ io.Reader }{r})
+}
+
+func (s pipeSafeStorage) Download(ctx context.Context, key string, w io.Writer) error {
+	return s.MockStorage.Download(ctx, key, struct{ io.Writer }{w})
+}
+
 func TestCheckPointerModel(t *testing.T) {
 	newMockDeps := func(t *testing.T) CheckPointerModelDeps {
 		return CheckPointerModelDeps{
-			Storage: blobstorage.NewMockStorage(t),
+			Storage:           pipeSafeStorage{blobstorage.NewMockStorage(t)},
+			Time:              services.NewMockNow(),
+			BlobsFormat:       blobsFormatGobName,
+			MetricsRegisterer: prometheus.NewRegistry(),
 		}
 	}
 
 	t.Run("readManifest", func(t *testing.T) {
 		t.Run("should load the manifest from blob storage", func(t *testing.T) {
 			deps := newMockDeps(t)
-			model := newCheckPointerModel(deps)
+			model := lo.Must(newCheckPointerModel(deps))
 
 			ctx := context.Background()
 
 			wantManifest := randomManifest()
-			storage, _ := deps.Storage.(*blobstorage.MockStorage)
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
 			storage.EXPECT().Download(
 				ctx, "manifest.json", mock.Anything,
 			).RunAndReturn(func(_ context.Context, _ string, w io.Writer) error {
@@ -44,12 +67,12 @@ func TestCheckPointerModel(t *testing.T) {
 		})
 		t.Run("should return error if failed to read manifest", func(t *testing.T) {
 			deps := newMockDeps(t)
-			model := newCheckPointerModel(deps)
+			model := lo.Must(newCheckPointerModel(deps))
 
 			ctx := context.Background()
 			wantErr := errors.New(faker.Sentence())
 
-			storage, _ := deps.Storage.(*blobstorage.MockStorage)
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
 			storage.EXPECT().Download(
 				ctx, "manifest.json", mock.Anything,
 			).Return(wantErr)
@@ -59,11 +82,11 @@ func TestCheckPointerModel(t *testing.T) {
 		})
 		t.Run("should return error if failed to decode manifest", func(t *testing.T) {
 			deps := newMockDeps(t)
-			model := newCheckPointerModel(deps)
+			model := lo.Must(newCheckPointerModel(deps))
 
 			ctx := context.Background()
 
-			storage, _ := deps.Storage.(*blobstorage.MockStorage)
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
 			storage.EXPECT().Download(
 				ctx, "manifest.json", mock.Anything,
 			).RunAndReturn(func(_ context.Context, _ string, w io.Writer) error {
@@ -79,12 +102,12 @@ func TestCheckPointerModel(t *testing.T) {
 	t.Run("writeManifest", func(t *testing.T) {
 		t.Run("should upload manifest to blob storage", func(t *testing.T) {
 			deps := newMockDeps(t)
-			model := newCheckPointerModel(deps)
+			model := lo.Must(newCheckPointerModel(deps))
 
 			ctx := context.Background()
 
 			wantManifest := randomManifest()
-			storage, _ := deps.Storage.(*blobstorage.MockStorage)
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
 			storage.EXPECT().Upload(
 				ctx, "manifest.json", mock.Anything,
 			).RunAndReturn(func(_ context.Context, _ string, r io.Reader) error {
@@ -98,10 +121,113 @@ func TestCheckPointerModel(t *testing.T) {
 		})
 	})
 
+	t.Run("readManifestHistory", func(t *testing.T) {
+		t.Run("should load the manifest history from blob storage", func(t *testing.T) {
+			deps := newMockDeps(t)
+			model := lo.Must(newCheckPointerModel(deps))
+
+			ctx := context.Background()
+
+			wantHistory := randomManifestHistory(3)
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
+			storage.EXPECT().Download(
+				ctx, "manifest-history.json", mock.Anything,
+			).RunAndReturn(func(_ context.Context, _ string, w io.Writer) error {
+				return json.NewEncoder(w).Encode(&wantHistory)
+			})
+
+			gotHistory, err := model.readManifestHistory(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, wantHistory, gotHistory)
+		})
+		t.Run("should return error if failed to read manifest history", func(t *testing.T) {
+			deps := newMockDeps(t)
+			model := lo.Must(newCheckPointerModel(deps))
+
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
+			storage.EXPECT().Download(
+				ctx, "manifest-history.json", mock.Anything,
+			).Return(wantErr)
+
+			_, err := model.readManifestHistory(ctx)
+			require.ErrorIs(t, err, wantErr)
+		})
+		t.Run("should return error if failed to decode manifest history", func(t *testing.T) {
+			deps := newMockDeps(t)
+			model := lo.Must(newCheckPointerModel(deps))
+
+			ctx := context.Background()
+
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
+			storage.EXPECT().Download(
+				ctx, "manifest-history.json", mock.Anything,
+			).RunAndReturn(func(_ context.Context, _ string, w io.Writer) error {
+				_, err := w.Write([]byte(faker.Sentence()))
+				return err
+			})
+
+			_, err := model.readManifestHistory(ctx)
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("writeManifestHistory", func(t *testing.T) {
+		t.Run("should upload manifest history to blob storage", func(t *testing.T) {
+			deps := newMockDeps(t)
+			model := lo.Must(newCheckPointerModel(deps))
+
+			ctx := context.Background()
+
+			wantHistory := randomManifestHistory(3)
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
+			storage.EXPECT().Upload(
+				ctx, "manifest-history.json", mock.Anything,
+			).RunAndReturn(func(_ context.Context, _ string, r io.Reader) error {
+				var got checkPointManifestHistory
+				require.NoError(t, json.NewDecoder(r).Decode(&got))
+				assert.Equal(t, wantHistory, got)
+				return nil
+			})
+
+			require.NoError(t, model.writeManifestHistory(ctx, wantHistory))
+		})
+	})
+
+	t.Run("deleteBlob", func(t *testing.T) {
+		t.Run("should delete given blob", func(t *testing.T) {
+			deps := newMockDeps(t)
+			model := lo.Must(newCheckPointerModel(deps))
+
+			ctx := context.Background()
+			wantFile := faker.Word()
+
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
+			storage.EXPECT().Delete(ctx, wantFile).Return(nil)
+
+			require.NoError(t, model.deleteBlob(ctx, wantFile))
+		})
+		t.Run("should return error if failed to delete", func(t *testing.T) {
+			deps := newMockDeps(t)
+			model := lo.Must(newCheckPointerModel(deps))
+
+			ctx := context.Background()
+			wantFile := faker.Word()
+			wantErr := errors.New(faker.Sentence())
+
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
+			storage.EXPECT().Delete(ctx, wantFile).Return(wantErr)
+
+			require.ErrorIs(t, model.deleteBlob(ctx, wantFile), wantErr)
+		})
+	})
+
 	t.Run("readCounters", func(t *testing.T) {
 		t.Run("should read counters from a given file", func(t *testing.T) {
 			deps := newMockDeps(t)
-			model := newCheckPointerModel(deps)
+			model := lo.Must(newCheckPointerModel(deps))
 
 			wantCounters := map[string]int64{
 				faker.UUIDHyphenated(): rand.Int64(),
@@ -112,43 +238,43 @@ func TestCheckPointerModel(t *testing.T) {
 
 			ctx := context.Background()
 
-			storage, _ := deps.Storage.(*blobstorage.MockStorage)
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
 			storage.EXPECT().Download(
 				ctx, wantFile, mock.Anything,
 			).RunAndReturn(func(_ context.Context, _ string, w io.Writer) error {
 				return gob.NewEncoder(w).Encode(wantCounters)
 			})
 
-			got, err := model.readCounters(ctx, wantFile)
+			got, err := model.readCounters(ctx, wantFile, blobsEncoding{})
 			require.NoError(t, err)
 			assert.Equal(t, wantCounters, got)
 		})
 		t.Run("should return error if failed to read counters", func(t *testing.T) {
 			deps := newMockDeps(t)
-			model := newCheckPointerModel(deps)
+			model := lo.Must(newCheckPointerModel(deps))
 
 			wantFile := faker.Word()
 			wantErr := errors.New(faker.Sentence())
 
 			ctx := context.Background()
 
-			storage, _ := deps.Storage.(*blobstorage.MockStorage)
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
 			storage.EXPECT().Download(
 				ctx, wantFile, mock.Anything,
 			).Return(wantErr)
 
-			_, err := model.readCounters(ctx, wantFile)
+			_, err := model.readCounters(ctx, wantFile, blobsEncoding{})
 			require.ErrorIs(t, err, wantErr)
 		})
 		t.Run("should return error if failed to decode counters", func(t *testing.T) {
 			deps := newMockDeps(t)
-			model := newCheckPointerModel(deps)
+			model := lo.Must(newCheckPointerModel(deps))
 
 			wantFile := faker.Word()
 
 			ctx := context.Background()
 
-			storage, _ := deps.Storage.(*blobstorage.MockStorage)
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
 			storage.EXPECT().Download(
 				ctx, wantFile, mock.Anything,
 			).RunAndReturn(func(_ context.Context, _ string, w io.Writer) error {
@@ -156,38 +282,156 @@ func TestCheckPointerModel(t *testing.T) {
 				return err
 			})
 
-			_, err := model.readCounters(ctx, wantFile)
+			_, err := model.readCounters(ctx, wantFile, blobsEncoding{})
+			require.Error(t, err)
+		})
+		t.Run("should return error if unknown codec", func(t *testing.T) {
+			deps := newMockDeps(t)
+			model := lo.Must(newCheckPointerModel(deps))
+
+			_, err := model.readCounters(context.Background(), faker.Word(), blobsEncoding{Codec: faker.Word()})
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("newCheckPointerModel", func(t *testing.T) {
+		t.Run("should use raw blobs if no compression and encryption", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.BlobsCompression = blobCompressionNone
+			model := lo.Must(newCheckPointerModel(deps))
+			assert.Empty(t, model.blobsEncoding().Codec)
+		})
+		t.Run("should use compression and encryption codec", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.BlobsCompression = blobCodecZstd
+			deps.BlobsEncryptionKey = randomEncryptionKey()
+			model := lo.Must(newCheckPointerModel(deps))
+			assert.Equal(t, "zstd+aes-gcm", model.blobsEncoding().Codec)
+		})
+		t.Run("should fail if unknown format", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.BlobsFormat = faker.Word()
+			_, err := newCheckPointerModel(deps)
+			require.Error(t, err)
+		})
+		t.Run("should fail if unknown compression", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.BlobsCompression = faker.Word()
+			_, err := newCheckPointerModel(deps)
+			require.Error(t, err)
+		})
+		t.Run("should fail if invalid encryption key", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.BlobsEncryptionKey = faker.Word()
+			_, err := newCheckPointerModel(deps)
 			require.Error(t, err)
 		})
 	})
 
+	t.Run("blobs encoding", func(t *testing.T) {
+		t.Run("should read blobs written with configured format and codec", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.BlobsFormat = []string{blobsFormatGobName, blobsFormatBinaryName}[rand.IntN(2)]
+			deps.BlobsCompression = []string{blobCodecGzip, blobCodecZstd}[rand.IntN(2)]
+			deps.BlobsEncryptionKey = randomEncryptionKey()
+			model := lo.Must(newCheckPointerModel(deps))
+
+			ctx := context.Background()
+			wantCounters := randomCountersValues()
+			wantItems := randomTopKItems(5)
+			countersFile := "counters-" + faker.Word()
+			itemsFile := "items-" + faker.Word()
+			blobs := map[string][]byte{}
+
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
+			storage.EXPECT().Upload(ctx, mock.Anything, mock.Anything).RunAndReturn(
+				func(_ context.Context, key string, r io.Reader) error {
+					blobs[key] = lo.Must(io.ReadAll(r))
+					return nil
+				},
+			)
+			storage.EXPECT().Download(ctx, mock.Anything, mock.Anything).RunAndReturn(
+				func(_ context.Context, key string, w io.Writer) error {
+					_, err := w.Write(blobs[key])
+					return err
+				},
+			)
+
+			require.NoError(t, model.writeCounters(ctx, countersFile, wantCounters))
+			require.NoError(t, model.writeItems(ctx, itemsFile, wantItems))
+
+			var rawCounters map[string]int64
+			require.Error(t, gob.NewDecoder(bytes.NewReader(blobs[countersFile])).Decode(&rawCounters))
+
+			gotCounters, err := model.readCounters(ctx, countersFile, model.blobsEncoding())
+			require.NoError(t, err)
+			assert.Equal(t, wantCounters, gotCounters)
+
+			gotItems, err := model.readItems(ctx, itemsFile, model.blobsEncoding())
+			require.NoError(t, err)
+			assert.Equal(t, wantItems, gotItems)
+		})
+		t.Run("should stop downloading if failed to decode counters", func(t *testing.T) {
+			deps := newMockDeps(t)
+			model := lo.Must(newCheckPointerModel(deps))
+
+			wantFile := faker.Word()
+			ctx := context.Background()
+
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
+			storage.EXPECT().Download(
+				ctx, wantFile, mock.Anything,
+			).RunAndReturn(func(_ context.Context, _ string, w io.Writer) error {
+				for {
+					if _, err := w.Write([]byte(faker.Sentence())); err != nil {
+						return err
+					}
+				}
+			})
+
+			_, err := model.readCounters(ctx, wantFile, blobsEncoding{FormatVersion: blobsFormatBinaryV1})
+			require.ErrorIs(t, err, errInvalidBinaryBlob)
+		})
+	})
+
 	t.Run("writeCounters", func(t *testing.T) {
 		t.Run("should write counters to a given file", func(t *testing.T) {
 			deps := newMockDeps(t)
-			model := newCheckPointerModel(deps)
+			model := lo.Must(newCheckPointerModel(deps))
 
 			wantCounters := randomCountersValues()
 			wantFile := faker.Word()
 
 			ctx := context.Background()
 
-			storage, _ := deps.Storage.(*blobstorage.MockStorage)
+			var wantSize int
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
 			storage.EXPECT().Upload(
 				ctx, wantFile, mock.Anything,
 			).RunAndReturn(func(_ context.Context, _ string, r io.Reader) error {
+				data, err := io.ReadAll(r)
+				require.NoError(t, err)
+				wantSize = len(data)
 				var got map[string]int64
-				require.NoError(t, gob.NewDecoder(r).Decode(&got))
+				require.NoError(t, gob.NewDecoder(bytes.NewReader(data)).Decode(&got))
 				assert.Equal(t, wantCounters, got)
 				return nil
 			})
 
 			err := model.writeCounters(ctx, wantFile, wantCounters)
 			require.NoError(t, err)
+
+			modelImpl, _ := model.(*checkPointerModelImpl)
+			assert.InDelta(t,
+				float64(wantSize),
+				testutil.ToFloat64(modelImpl.blobSizeBytes.WithLabelValues(checkPointBlobCounters)),
+				0,
+			)
 		})
 
 		t.Run("should return error if failed to upload counters", func(t *testing.T) {
 			deps := newMockDeps(t)
-			model := newCheckPointerModel(deps)
+			model := lo.Must(newCheckPointerModel(deps))
 
 			wantCounters := randomCountersValues()
 			wantFile := faker.Word()
@@ -195,7 +439,7 @@ func TestCheckPointerModel(t *testing.T) {
 
 			ctx := context.Background()
 
-			storage, _ := deps.Storage.(*blobstorage.MockStorage)
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
 			storage.EXPECT().Upload(
 				ctx, wantFile, mock.Anything,
 			).Return(wantErr)
@@ -208,52 +452,52 @@ func TestCheckPointerModel(t *testing.T) {
 	t.Run("readItems", func(t *testing.T) {
 		t.Run("should read items from a given file", func(t *testing.T) {
 			deps := newMockDeps(t)
-			model := newCheckPointerModel(deps)
+			model := lo.Must(newCheckPointerModel(deps))
 
 			wantItems := randomTopKItems(10)
 			wantFile := faker.Word()
 
 			ctx := context.Background()
 
-			storage, _ := deps.Storage.(*blobstorage.MockStorage)
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
 			storage.EXPECT().Download(
 				ctx, wantFile, mock.Anything,
 			).RunAndReturn(func(_ context.Context, _ string, w io.Writer) error {
 				return gob.NewEncoder(w).Encode(wantItems)
 			})
 
-			got, err := model.readItems(ctx, wantFile)
+			got, err := model.readItems(ctx, wantFile, blobsEncoding{})
 			require.NoError(t, err)
 			assert.Equal(t, wantItems, got)
 		})
 
 		t.Run("should return error if failed to read items", func(t *testing.T) {
 			deps := newMockDeps(t)
-			model := newCheckPointerModel(deps)
+			model := lo.Must(newCheckPointerModel(deps))
 
 			wantFile := faker.Word()
 			wantErr := errors.New(faker.Sentence())
 
 			ctx := context.Background()
 
-			storage, _ := deps.Storage.(*blobstorage.MockStorage)
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
 			storage.EXPECT().Download(
 				ctx, wantFile, mock.Anything,
 			).Return(wantErr)
 
-			_, err := model.readItems(ctx, wantFile)
+			_, err := model.readItems(ctx, wantFile, blobsEncoding{})
 			require.ErrorIs(t, err, wantErr)
 		})
 
 		t.Run("should return error if failed to decode items", func(t *testing.T) {
 			deps := newMockDeps(t)
-			model := newCheckPointerModel(deps)
+			model := lo.Must(newCheckPointerModel(deps))
 
 			wantFile := faker.Word()
 
 			ctx := context.Background()
 
-			storage, _ := deps.Storage.(*blobstorage.MockStorage)
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
 			storage.EXPECT().Download(
 				ctx, wantFile, mock.Anything,
 			).RunAndReturn(func(_ context.Context, _ string, w io.Writer) error {
@@ -261,7 +505,7 @@ func TestCheckPointerModel(t *testing.T) {
 				return err
 			})
 
-			_, err := model.readItems(ctx, wantFile)
+			_, err := model.readItems(ctx, wantFile, blobsEncoding{})
 			require.Error(t, err)
 		})
 	})
@@ -269,30 +513,41 @@ func TestCheckPointerModel(t *testing.T) {
 	t.Run("writeItems", func(t *testing.T) {
 		t.Run("should write items to a given file", func(t *testing.T) {
 			deps := newMockDeps(t)
-			model := newCheckPointerModel(deps)
+			model := lo.Must(newCheckPointerModel(deps))
 
 			wantItems := randomTopKItems(10)
 			wantFile := faker.Word()
 
 			ctx := context.Background()
 
-			storage, _ := deps.Storage.(*blobstorage.MockStorage)
+			var wantSize int
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
 			storage.EXPECT().Upload(
 				ctx, wantFile, mock.Anything,
 			).RunAndReturn(func(_ context.Context, _ string, r io.Reader) error {
+				data, err := io.ReadAll(r)
+				require.NoError(t, err)
+				wantSize = len(data)
 				var got []*topKItem
-				require.NoError(t, gob.NewDecoder(r).Decode(&got))
+				require.NoError(t, gob.NewDecoder(bytes.NewReader(data)).Decode(&got))
 				assert.Equal(t, wantItems, got)
 				return nil
 			})
 
 			err := model.writeItems(ctx, wantFile, wantItems)
 			require.NoError(t, err)
+
+			modelImpl, _ := model.(*checkPointerModelImpl)
+			assert.InDelta(t,
+				float64(wantSize),
+				testutil.ToFloat64(modelImpl.blobSizeBytes.WithLabelValues(checkPointBlobAllTimeItems)),
+				0,
+			)
 		})
 
 		t.Run("should return error if failed to upload items", func(t *testing.T) {
 			deps := newMockDeps(t)
-			model := newCheckPointerModel(deps)
+			model := lo.Must(newCheckPointerModel(deps))
 
 			wantItems := randomTopKItems(10)
 			wantFile := faker.Word()
@@ -300,7 +555,7 @@ func TestCheckPointerModel(t *testing.T) {
 
 			ctx := context.Background()
 
-			storage, _ := deps.Storage.(*blobstorage.MockStorage)
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
 			storage.EXPECT().Upload(
 				ctx, wantFile, mock.Anything,
 			).Return(wantErr)
@@ -309,4 +564,51 @@ func TestCheckPointerModel(t *testing.T) {
 			require.ErrorIs(t, err, wantErr)
 		})
 	})
+
+	t.Run("acquireLease", func(t *testing.T) {
+		t.Run("should create the lease blob", func(t *testing.T) {
+			deps := newMockDeps(t)
+			model := lo.Must(newCheckPointerModel(deps))
+
+			ctx := context.Background()
+			owner := faker.UUIDHyphenated()
+			ttl := time.Duration(10+rand.IntN(100)) * time.Minute
+
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
+			storage.EXPECT().UploadIfNotExists(ctx, checkPointsLeaseKey, mock.Anything).RunAndReturn(
+				func(_ context.Context, _ string, r io.Reader) error {
+					var record struct {
+						Owner     string    `json:"owner"`
+						ExpiresAt time.Time `json:"expiresAt"`
+					}
+					require.NoError(t, json.NewDecoder(r).Decode(&record))
+					assert.Equal(t, owner, record.Owner)
+					assert.True(t, services.MockNowValue(deps.Time).Add(ttl).Equal(record.ExpiresAt))
+					return nil
+				},
+			)
+
+			require.NoError(t, model.acquireLease(ctx, owner, ttl))
+		})
+	})
+
+	t.Run("releaseLease", func(t *testing.T) {
+		t.Run("should delete the lease blob held by the owner", func(t *testing.T) {
+			deps := newMockDeps(t)
+			model := lo.Must(newCheckPointerModel(deps))
+
+			ctx := context.Background()
+			owner := faker.UUIDHyphenated()
+
+			storage := deps.Storage.(pipeSafeStorage).MockStorage
+			storage.EXPECT().Download(ctx, checkPointsLeaseKey, mock.Anything).RunAndReturn(
+				func(_ context.Context, _ string, w io.Writer) error {
+					return json.NewEncoder(w).Encode(map[string]any{"owner": owner})
+				},
+			)
+			storage.EXPECT().Delete(ctx, checkPointsLeaseKey).Return(nil)
+
+			require.NoError(t, model.releaseLease(ctx, owner))
+		})
+	})
 }
@@ -7,21 +7,47 @@ import (
 	"io/fs"
 	"math/rand/v2"
 	"testing"
+	"time"
 
 	"github.com/gemyago/top-k-system-go/internal/diag"
+	"github.com/gemyago/top-k-system-go/internal/services"
+	"github.com/gemyago/top-k-system-go/internal/services/blobstorage"
 	"github.com/go-faker/faker/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestCheckPointer(t *testing.T) {
 	newMockDeps := func(t *testing.T) CheckPointerDeps {
 		return CheckPointerDeps{
-			RootLogger:        diag.RootTestLogger(),
-			CheckPointerModel: newMockCheckPointerModel(t),
+			RootLogger:           diag.RootTestLogger(),
+			LeaseTTL:             time.Duration(1+rand.IntN(1000)) * time.Second,
+			AllTimeItemsCapacity: topKMaxItemsSize,
+			Time:                 services.NewMockNow(),
+			UUIDGenerator:        services.NewUUIDGenerator(),
+			MetricsRegisterer:    prometheus.NewRegistry(),
+			TracerProvider:       noop.NewTracerProvider(),
+			CheckPointerModel:    newMockCheckPointerModel(t),
 		}
 	}
 
+	// newLeasedCheckPointer will create the check pointer that expects the lease
+	// to be acquired and released
+	newLeasedCheckPointer := func(deps CheckPointerDeps) checkPointer {
+		cp, _ := newCheckPointer(deps).(*checkPointerImpl)
+		mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+		mockModel.EXPECT().acquireLease(mock.Anything, cp.leaseOwner, deps.LeaseTTL).Return(nil)
+		mockModel.EXPECT().releaseLease(mock.Anything, cp.leaseOwner).Return(nil)
+		return cp
+	}
+
 	t.Run("restoreState", func(t *testing.T) {
 		t.Run("should read the the manifest and values", func(t *testing.T) {
 			deps := newMockDeps(t)
@@ -34,8 +60,8 @@ func TestCheckPointer(t *testing.T) {
 
 			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
 			mockModel.EXPECT().readManifest(ctx).Return(manifest, nil)
-			mockModel.EXPECT().readCounters(ctx, manifest.CountersBlobFileName).Return(values, nil)
-			mockModel.EXPECT().readItems(ctx, manifest.AllTimeItemsFileName).Return(allTimeRawItems, nil)
+			mockModel.EXPECT().readCounters(ctx, manifest.CountersBlobFileName, manifest.encoding()).Return(values, nil)
+			mockModel.EXPECT().readItems(ctx, manifest.AllTimeItemsFileName, manifest.encoding()).Return(allTimeRawItems, nil)
 
 			counters, _ := newCounters().(*countersImpl)
 			allTimeItems := newTopKItems(topKMaxItemsSize)
@@ -54,6 +80,158 @@ func TestCheckPointer(t *testing.T) {
 				allTimeItems.getItems(topKGetAllItemsLimit),
 			)
 		})
+		t.Run("should report restore progress of the live state", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newCheckPointer(deps)
+
+			ctx := context.Background()
+			manifest := randomManifest()
+			values := randomCountersValues()
+			status := newAggregationStatus()
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(ctx).Return(manifest, nil)
+			mockModel.EXPECT().
+				readCounters(ctx, manifest.CountersBlobFileName, manifest.encoding()).
+				RunAndReturn(func(_ context.Context, _ string, _ blobsEncoding) (map[string]int64, error) {
+					got := status.snapshot().Restore
+					assert.Equal(t, manifest.LastOffset, got.CheckPointOffset)
+					assert.Equal(t, checkPointBlobsCount, got.TotalBlobs)
+					assert.Equal(t, 0, got.RestoredBlobs)
+					return values, nil
+				})
+			mockModel.EXPECT().
+				readItems(ctx, manifest.AllTimeItemsFileName, manifest.encoding()).
+				RunAndReturn(func(_ context.Context, _ string, _ blobsEncoding) ([]*topKItem, error) {
+					got := status.snapshot().Restore
+					assert.Equal(t, 1, got.RestoredBlobs)
+					assert.Equal(t, len(values), got.RestoredItems)
+					return randomTopKItems(10), nil
+				})
+
+			require.NoError(t, cp.restoreState(ctx, aggregationState{
+				counters:     newCounters(),
+				allTimeItems: newTopKItems(topKMaxItemsSize),
+				status:       status,
+			}))
+
+			got := status.snapshot()
+			assert.Equal(t, checkPointBlobsCount, got.Restore.RestoredBlobs)
+			assert.Equal(t, len(values), got.Restore.RestoredItems)
+			require.NotNil(t, got.CheckPointCreatedAt)
+			assert.Equal(t, manifest.CreatedAt, *got.CheckPointCreatedAt)
+		})
+		t.Run("should load restored counters to the rank index", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newCheckPointer(deps)
+
+			ctx := context.Background()
+			manifest := randomManifest()
+			values := randomCountersValues()
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(ctx).Return(manifest, nil)
+			mockModel.EXPECT().
+				readCounters(ctx, manifest.CountersBlobFileName, manifest.encoding()).
+				Return(values, nil)
+			mockModel.EXPECT().
+				readItems(ctx, manifest.AllTimeItemsFileName, manifest.encoding()).
+				Return(randomTopKItems(10), nil)
+
+			index := newRankIndex()
+			require.NoError(t, cp.restoreState(ctx, aggregationState{
+				counters:     newCounters(),
+				allTimeItems: newTopKItems(topKMaxItemsSize),
+				rankIndex:    index,
+			}))
+
+			_, gotRankedItems := index.getRank(0)
+			assert.Equal(t, len(values), gotRankedItems)
+		})
+		t.Run("should restore all time items if capacity has changed", func(t *testing.T) {
+			// topItemsOf will return top items of counters with distinct counts
+			topItemsOf := func(values map[string]int64, limit int) []*topKItem {
+				items := newTopKItems(limit)
+				for itemID, count := range values {
+					items.updateIfGreater(topKItem{ItemID: itemID, Count: count})
+				}
+				return items.getItems(topKGetAllItemsLimit)
+			}
+			distinctCountersValues := func(size int) map[string]int64 {
+				values := make(map[string]int64, size)
+				for i := range size {
+					values[faker.UUIDHyphenated()] = int64(i+1)*10 + rand.Int64N(10)
+				}
+				return values
+			}
+
+			type testCase struct {
+				name               string
+				checkPointCapacity int
+				capacity           int
+				counters           int
+				storedItems        int
+				wantItems          int
+			}
+			for _, tc := range []testCase{
+				{name: "grown", checkPointCapacity: 5, capacity: 10, counters: 20, storedItems: 5, wantItems: 10},
+				{name: "shrunk", checkPointCapacity: 10, capacity: 5, counters: 20, storedItems: 10, wantItems: 5},
+				{name: "grown but not full", checkPointCapacity: 50, capacity: 100, counters: 20, storedItems: 20, wantItems: 20},
+			} {
+				t.Run(tc.name, func(t *testing.T) {
+					deps := newMockDeps(t)
+					deps.AllTimeItemsCapacity = tc.capacity
+					cp := newCheckPointer(deps)
+
+					ctx := context.Background()
+					manifest := randomManifest()
+					manifest.AllTimeItemsCapacity = tc.checkPointCapacity
+					values := distinctCountersValues(tc.counters)
+
+					mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+					mockModel.EXPECT().readManifest(ctx).Return(manifest, nil)
+					mockModel.EXPECT().
+						readCounters(ctx, manifest.CountersBlobFileName, manifest.encoding()).
+						Return(values, nil)
+					mockModel.EXPECT().
+						readItems(ctx, manifest.AllTimeItemsFileName, manifest.encoding()).
+						Return(topItemsOf(values, tc.storedItems), nil)
+
+					allTimeItems := newTopKItems(tc.capacity)
+					require.NoError(t, cp.restoreState(ctx, aggregationState{
+						counters:     newCounters(),
+						allTimeItems: allTimeItems,
+					}))
+					assert.Equal(t, topItemsOf(values, tc.wantItems), allTimeItems.getItems(topKGetAllItemsLimit))
+				})
+			}
+		})
+		t.Run("should treat check points without capacity as default capacity", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.AllTimeItemsCapacity = topKMaxItemsSize + 1 + rand.IntN(1000)
+			cp := newCheckPointer(deps)
+
+			ctx := context.Background()
+			manifest := randomManifest()
+			wantItems := newTopKItems(topKMaxItemsSize)
+			wantItems.load(randomTopKItems(10))
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(ctx).Return(manifest, nil)
+			mockModel.EXPECT().
+				readCounters(ctx, manifest.CountersBlobFileName, manifest.encoding()).
+				Return(randomCountersValues(), nil)
+			mockModel.EXPECT().
+				readItems(ctx, manifest.AllTimeItemsFileName, manifest.encoding()).
+				Return(wantItems.getItems(topKGetAllItemsLimit), nil)
+
+			allTimeItems := newTopKItems(deps.AllTimeItemsCapacity)
+			require.NoError(t, cp.restoreState(ctx, aggregationState{
+				counters:     newCounters(),
+				allTimeItems: allTimeItems,
+			}))
+			assert.Equal(t, wantItems.getItems(topKGetAllItemsLimit), allTimeItems.getItems(topKGetAllItemsLimit))
+		})
 		t.Run("should handle initial blank state", func(t *testing.T) {
 			deps := newMockDeps(t)
 			cp := newCheckPointer(deps)
@@ -82,13 +260,32 @@ func TestCheckPointer(t *testing.T) {
 			manifest := randomManifest()
 
 			mockModel.EXPECT().readManifest(ctx).Return(manifest, nil)
-			mockModel.EXPECT().readCounters(ctx, manifest.CountersBlobFileName).Return(nil, wantErr)
+			mockModel.EXPECT().readCounters(ctx, manifest.CountersBlobFileName, manifest.encoding()).Return(nil, wantErr)
 
 			counters, _ := newCounters().(*countersImpl)
 			require.ErrorIs(t, cp.restoreState(ctx, aggregationState{
 				counters: counters,
 			}), wantErr)
 		})
+		t.Run("should trace restore and record failures", func(t *testing.T) {
+			deps := newMockDeps(t)
+			recorder := tracetest.NewSpanRecorder()
+			deps.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			cp := newCheckPointer(deps)
+
+			ctx := context.Background()
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			wantErr := errors.New(faker.Sentence())
+			mockModel.EXPECT().readManifest(ctx).Return(checkPointManifest{}, wantErr)
+
+			require.ErrorIs(t, cp.restoreState(ctx, aggregationState{}), wantErr)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, "checkpointer.restoreState", spans[0].Name())
+			assert.Equal(t, codes.Error, spans[0].Status().Code)
+		})
 		t.Run("should fail on counters reading errors", func(t *testing.T) {
 			deps := newMockDeps(t)
 			cp := newCheckPointer(deps)
@@ -115,9 +312,9 @@ func TestCheckPointer(t *testing.T) {
 
 			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
 			mockModel.EXPECT().readManifest(ctx).Return(manifest, nil)
-			mockModel.EXPECT().readCounters(ctx, manifest.CountersBlobFileName).Return(values, nil)
+			mockModel.EXPECT().readCounters(ctx, manifest.CountersBlobFileName, manifest.encoding()).Return(values, nil)
 			wantErr := errors.New(faker.Sentence())
-			mockModel.EXPECT().readItems(ctx, manifest.AllTimeItemsFileName).Return(nil, wantErr)
+			mockModel.EXPECT().readItems(ctx, manifest.AllTimeItemsFileName, manifest.encoding()).Return(nil, wantErr)
 
 			counters, _ := newCounters().(*countersImpl)
 			allTimeItems := newTopKItems(topKMaxItemsSize)
@@ -129,45 +326,164 @@ func TestCheckPointer(t *testing.T) {
 	})
 
 	t.Run("dumpState", func(t *testing.T) {
-		t.Run("should write values and manifest", func(t *testing.T) {
+		t.Run("should write values, history and manifest", func(t *testing.T) {
 			deps := newMockDeps(t)
-			cp := newCheckPointer(deps)
+			cp := newLeasedCheckPointer(deps)
 
 			ctx := context.Background()
 			values := randomCountersValues()
+			existingHistory := randomManifestHistory(3)
 			cnt := newCounters()
-			cnt.updateItemsCount(rand.Int64(), values)
+			cnt.updateItemsCount(existingHistory.Manifests[2].LastOffset+1+rand.Int64N(1000), values)
 			wantAllTimeItems := newTopKItems(topKMaxItemsSize)
 			wantAllTimeItems.load(randomTopKItems(10))
 
+			wantManifest := checkPointManifest{
+				LastOffset:           cnt.getLastOffset(),
+				CountersBlobFileName: fmt.Sprintf("counters-%d", cnt.getLastOffset()),
+				AllTimeItemsFileName: fmt.Sprintf("all-time-items-%d", cnt.getLastOffset()),
+				CreatedAt:            services.MockNowValue(deps.Time),
+				FormatVersion:        rand.IntN(2),
+				Codec:                faker.Word(),
+				AllTimeItemsCapacity: deps.AllTimeItemsCapacity,
+			}
+
 			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
-			mockModel.EXPECT().writeCounters(
-				ctx,
-				fmt.Sprintf("counters-%d", cnt.getLastOffset()),
-				values,
-			).Return(nil)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(existingHistory.Manifests[2], nil)
+			mockModel.EXPECT().blobsEncoding().Return(wantManifest.encoding())
+			mockModel.EXPECT().writeCounters(mock.Anything, wantManifest.CountersBlobFileName, values).Return(nil)
 			mockModel.EXPECT().writeItems(
-				ctx,
-				fmt.Sprintf("all-time-items-%d", cnt.getLastOffset()),
+				mock.Anything,
+				wantManifest.AllTimeItemsFileName,
 				wantAllTimeItems.getItems(topKMaxItemsSize),
 			).Return(nil)
-			mockModel.EXPECT().writeManifest(
-				ctx,
-				checkPointManifest{
-					LastOffset:           cnt.getLastOffset(),
-					CountersBlobFileName: fmt.Sprintf("counters-%d", cnt.getLastOffset()),
-					AllTimeItemsFileName: fmt.Sprintf("all-time-items-%d", cnt.getLastOffset()),
-				},
-			).Return(nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(existingHistory, nil)
+			mockModel.EXPECT().writeManifestHistory(mock.Anything, checkPointManifestHistory{
+				Manifests: append(existingHistory.Manifests, wantManifest),
+			}).Return(nil)
+			mockModel.EXPECT().writeManifest(mock.Anything, wantManifest).Return(nil)
 
 			require.NoError(t, cp.dumpState(ctx, aggregationState{
 				counters:     cnt,
 				allTimeItems: wantAllTimeItems,
 			}))
+
+			cpImpl, _ := cp.(*checkPointerImpl)
+			var writeDuration dto.Metric
+			require.NoError(t, cpImpl.writeDuration.Write(&writeDuration))
+			assert.Equal(t, uint64(1), writeDuration.GetHistogram().GetSampleCount())
+		})
+		t.Run("should replace history entry with the same offset after roll back", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			values := randomCountersValues()
+			cnt := newCounters()
+			existingHistory := randomManifestHistory(3)
+			cnt.updateItemsCount(existingHistory.Manifests[1].LastOffset, values)
+			allTimeItems := newTopKItems(topKMaxItemsSize)
+
+			wantManifest := checkPointManifest{
+				LastOffset:           cnt.getLastOffset(),
+				CountersBlobFileName: fmt.Sprintf("counters-%d", cnt.getLastOffset()),
+				AllTimeItemsFileName: fmt.Sprintf("all-time-items-%d", cnt.getLastOffset()),
+				CreatedAt:            services.MockNowValue(deps.Time),
+				FormatVersion:        rand.IntN(2),
+				Codec:                faker.Word(),
+				AllTimeItemsCapacity: deps.AllTimeItemsCapacity,
+			}
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			// rolled back to the previous check point
+			mockModel.EXPECT().readManifest(mock.Anything).Return(existingHistory.Manifests[0], nil)
+			mockModel.EXPECT().blobsEncoding().Return(wantManifest.encoding())
+			mockModel.EXPECT().writeCounters(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().writeItems(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(existingHistory, nil)
+			mockModel.EXPECT().writeManifestHistory(mock.Anything, checkPointManifestHistory{
+				Manifests: []checkPointManifest{
+					existingHistory.Manifests[0],
+					wantManifest,
+					existingHistory.Manifests[2],
+				},
+			}).Return(nil)
+			mockModel.EXPECT().writeManifest(mock.Anything, wantManifest).Return(nil)
+
+			require.NoError(t, cp.dumpState(ctx, aggregationState{
+				counters:     cnt,
+				allTimeItems: allTimeItems,
+			}))
+		})
+		t.Run("should initialize history with the current manifest", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			cnt := newCounters()
+			currentManifest := randomManifest()
+			cnt.updateItemsCount(currentManifest.LastOffset+1+rand.Int64N(1000), randomCountersValues())
+			allTimeItems := newTopKItems(topKMaxItemsSize)
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().blobsEncoding().Return(randomBlobsEncoding())
+			mockModel.EXPECT().writeCounters(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().writeItems(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(
+				checkPointManifestHistory{}, fmt.Errorf("no history: %w", fs.ErrNotExist),
+			)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(currentManifest, nil)
+			mockModel.EXPECT().writeManifestHistory(mock.Anything, mock.Anything).RunAndReturn(
+				func(_ context.Context, history checkPointManifestHistory) error {
+					require.Len(t, history.Manifests, 2)
+					assert.Equal(t, currentManifest, history.Manifests[0])
+					assert.Equal(t, cnt.getLastOffset(), history.Manifests[1].LastOffset)
+					return nil
+				},
+			)
+			mockModel.EXPECT().writeManifest(mock.Anything, mock.Anything).Return(nil)
+
+			require.NoError(t, cp.dumpState(ctx, aggregationState{
+				counters:     cnt,
+				allTimeItems: allTimeItems,
+			}))
+		})
+		t.Run("should start new history if no manifest", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			cnt := newCounters()
+			cnt.updateItemsCount(rand.Int64N(1000), randomCountersValues())
+			allTimeItems := newTopKItems(topKMaxItemsSize)
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().blobsEncoding().Return(randomBlobsEncoding())
+			mockModel.EXPECT().writeCounters(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().writeItems(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(
+				checkPointManifestHistory{}, fmt.Errorf("no history: %w", fs.ErrNotExist),
+			)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(
+				checkPointManifest{}, fmt.Errorf("no manifest: %w", fs.ErrNotExist),
+			)
+			mockModel.EXPECT().writeManifestHistory(mock.Anything, mock.Anything).RunAndReturn(
+				func(_ context.Context, history checkPointManifestHistory) error {
+					require.Len(t, history.Manifests, 1)
+					assert.Equal(t, cnt.getLastOffset(), history.Manifests[0].LastOffset)
+					return nil
+				},
+			)
+			mockModel.EXPECT().writeManifest(mock.Anything, mock.Anything).Return(nil)
+
+			require.NoError(t, cp.dumpState(ctx, aggregationState{
+				counters:     cnt,
+				allTimeItems: allTimeItems,
+			}))
 		})
 		t.Run("should handle write counters errors", func(t *testing.T) {
 			deps := newMockDeps(t)
-			cp := newCheckPointer(deps)
+			cp := newLeasedCheckPointer(deps)
 
 			ctx := context.Background()
 			values := randomCountersValues()
@@ -175,9 +491,13 @@ func TestCheckPointer(t *testing.T) {
 			cnt.updateItemsCount(rand.Int64(), values)
 
 			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(
+				checkPointManifest{}, fmt.Errorf("no manifest: %w", fs.ErrNotExist),
+			)
 			wantErr := errors.New(faker.Sentence())
+			mockModel.EXPECT().blobsEncoding().Return(randomBlobsEncoding())
 			mockModel.EXPECT().writeCounters(
-				ctx,
+				mock.Anything,
 				fmt.Sprintf("counters-%d", cnt.getLastOffset()),
 				values,
 			).Return(wantErr)
@@ -185,10 +505,15 @@ func TestCheckPointer(t *testing.T) {
 			require.ErrorIs(t, cp.dumpState(ctx, aggregationState{
 				counters: cnt,
 			}), wantErr)
+
+			cpImpl, _ := cp.(*checkPointerImpl)
+			var writeDuration dto.Metric
+			require.NoError(t, cpImpl.writeDuration.Write(&writeDuration))
+			assert.Equal(t, uint64(0), writeDuration.GetHistogram().GetSampleCount())
 		})
 		t.Run("should handle write items errors", func(t *testing.T) {
 			deps := newMockDeps(t)
-			cp := newCheckPointer(deps)
+			cp := newLeasedCheckPointer(deps)
 
 			ctx := context.Background()
 			values := randomCountersValues()
@@ -198,14 +523,18 @@ func TestCheckPointer(t *testing.T) {
 			allTimeItems.load(randomTopKItems(10))
 
 			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(
+				checkPointManifest{}, fmt.Errorf("no manifest: %w", fs.ErrNotExist),
+			)
+			mockModel.EXPECT().blobsEncoding().Return(randomBlobsEncoding())
 			mockModel.EXPECT().writeCounters(
-				ctx,
+				mock.Anything,
 				fmt.Sprintf("counters-%d", cnt.getLastOffset()),
 				values,
 			).Return(nil)
 			wantErr := errors.New(faker.Sentence())
 			mockModel.EXPECT().writeItems(
-				ctx,
+				mock.Anything,
 				fmt.Sprintf("all-time-items-%d", cnt.getLastOffset()),
 				allTimeItems.getItems(topKMaxItemsSize),
 			).Return(wantErr)
@@ -215,9 +544,58 @@ func TestCheckPointer(t *testing.T) {
 				allTimeItems: allTimeItems,
 			}), wantErr)
 		})
+		t.Run("should handle read history errors", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			cnt := newCounters()
+			cnt.updateItemsCount(rand.Int64(), randomCountersValues())
+			allTimeItems := newTopKItems(topKMaxItemsSize)
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(
+				checkPointManifest{}, fmt.Errorf("no manifest: %w", fs.ErrNotExist),
+			)
+			mockModel.EXPECT().blobsEncoding().Return(randomBlobsEncoding())
+			mockModel.EXPECT().writeCounters(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().writeItems(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			wantErr := errors.New(faker.Sentence())
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(checkPointManifestHistory{}, wantErr)
+
+			require.ErrorIs(t, cp.dumpState(ctx, aggregationState{
+				counters:     cnt,
+				allTimeItems: allTimeItems,
+			}), wantErr)
+		})
+		t.Run("should handle write history errors", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			cnt := newCounters()
+			cnt.updateItemsCount(rand.Int64(), randomCountersValues())
+			allTimeItems := newTopKItems(topKMaxItemsSize)
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(
+				checkPointManifest{}, fmt.Errorf("no manifest: %w", fs.ErrNotExist),
+			)
+			mockModel.EXPECT().blobsEncoding().Return(randomBlobsEncoding())
+			mockModel.EXPECT().writeCounters(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().writeItems(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(randomManifestHistory(2), nil)
+			wantErr := errors.New(faker.Sentence())
+			mockModel.EXPECT().writeManifestHistory(mock.Anything, mock.Anything).Return(wantErr)
+
+			require.ErrorIs(t, cp.dumpState(ctx, aggregationState{
+				counters:     cnt,
+				allTimeItems: allTimeItems,
+			}), wantErr)
+		})
 		t.Run("should handle write manifest errors", func(t *testing.T) {
 			deps := newMockDeps(t)
-			cp := newCheckPointer(deps)
+			cp := newLeasedCheckPointer(deps)
 
 			ctx := context.Background()
 			values := randomCountersValues()
@@ -227,23 +605,34 @@ func TestCheckPointer(t *testing.T) {
 			allTimeItems.load(randomTopKItems(10))
 
 			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(
+				checkPointManifest{}, fmt.Errorf("no manifest: %w", fs.ErrNotExist),
+			)
 			wantErr := errors.New(faker.Sentence())
+			wantEncoding := randomBlobsEncoding()
+			mockModel.EXPECT().blobsEncoding().Return(wantEncoding)
 			mockModel.EXPECT().writeCounters(
-				ctx,
+				mock.Anything,
 				fmt.Sprintf("counters-%d", cnt.getLastOffset()),
 				values,
 			).Return(nil)
 			mockModel.EXPECT().writeItems(
-				ctx,
+				mock.Anything,
 				fmt.Sprintf("all-time-items-%d", cnt.getLastOffset()),
 				allTimeItems.getItems(topKMaxItemsSize),
 			).Return(nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(checkPointManifestHistory{}, nil)
+			mockModel.EXPECT().writeManifestHistory(mock.Anything, mock.Anything).Return(nil)
 			mockModel.EXPECT().writeManifest(
-				ctx,
+				mock.Anything,
 				checkPointManifest{
 					LastOffset:           cnt.getLastOffset(),
 					CountersBlobFileName: fmt.Sprintf("counters-%d", cnt.getLastOffset()),
 					AllTimeItemsFileName: fmt.Sprintf("all-time-items-%d", cnt.getLastOffset()),
+					CreatedAt:            services.MockNowValue(deps.Time),
+					FormatVersion:        wantEncoding.FormatVersion,
+					Codec:                wantEncoding.Codec,
+					AllTimeItemsCapacity: deps.AllTimeItemsCapacity,
 				},
 			).Return(wantErr)
 
@@ -252,5 +641,571 @@ func TestCheckPointer(t *testing.T) {
 				allTimeItems: allTimeItems,
 			}), wantErr)
 		})
+		t.Run("should refuse to write check point older than the current one", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			currentManifest := randomManifest()
+			cnt := newCounters()
+			cnt.updateItemsCount(currentManifest.LastOffset-1-rand.Int64N(currentManifest.LastOffset), randomCountersValues())
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(currentManifest, nil)
+
+			require.ErrorIs(t, cp.dumpState(ctx, aggregationState{
+				counters:     cnt,
+				allTimeItems: newTopKItems(topKMaxItemsSize),
+			}), ErrStaleCheckPoint)
+		})
+		t.Run("should refuse to write check point at the offset of the current one", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			currentManifest := randomManifest()
+			cnt := newCounters()
+			cnt.updateItemsCount(currentManifest.LastOffset, randomCountersValues())
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(currentManifest, nil)
+
+			require.ErrorIs(t, cp.dumpState(ctx, aggregationState{
+				counters:     cnt,
+				allTimeItems: newTopKItems(topKMaxItemsSize),
+			}), ErrStaleCheckPoint)
+		})
+		t.Run("should handle read manifest errors", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(checkPointManifest{}, wantErr)
+
+			require.ErrorIs(t, cp.dumpState(ctx, aggregationState{
+				counters: newCounters(),
+			}), wantErr)
+		})
+		t.Run("should not write manifest if lease is lost while writing blobs", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp, _ := newCheckPointer(deps).(*checkPointerImpl)
+
+			ctx := context.Background()
+			cnt := newCounters()
+			cnt.updateItemsCount(rand.Int64N(1000), randomCountersValues())
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().acquireLease(mock.Anything, cp.leaseOwner, deps.LeaseTTL).Return(nil).Once()
+			mockModel.EXPECT().readManifest(mock.Anything).Return(checkPointManifest{}, fs.ErrNotExist)
+			mockModel.EXPECT().blobsEncoding().Return(blobsEncoding{})
+			mockModel.EXPECT().writeCounters(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().writeItems(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().
+				acquireLease(mock.Anything, cp.leaseOwner, deps.LeaseTTL).
+				Return(fmt.Errorf("%w: %s", blobstorage.ErrLeaseHeld, faker.Sentence()))
+			mockModel.EXPECT().releaseLease(mock.Anything, cp.leaseOwner).Return(nil)
+
+			require.ErrorIs(t, cp.dumpState(ctx, aggregationState{
+				counters:     cnt,
+				allTimeItems: newTopKItems(topKMaxItemsSize),
+			}), ErrCheckPointsLeaseLost)
+		})
+		t.Run("should not write manifest if newer check point is written while writing blobs", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			cnt := newCounters()
+			cnt.updateItemsCount(rand.Int64N(1000), randomCountersValues())
+			newerManifest := randomManifest()
+			newerManifest.LastOffset = cnt.getLastOffset() + 1 + rand.Int64N(1000)
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(checkPointManifest{}, fs.ErrNotExist).Once()
+			mockModel.EXPECT().blobsEncoding().Return(blobsEncoding{})
+			mockModel.EXPECT().writeCounters(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().writeItems(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(newerManifest, nil)
+
+			require.ErrorIs(t, cp.dumpState(ctx, aggregationState{
+				counters:     cnt,
+				allTimeItems: newTopKItems(topKMaxItemsSize),
+			}), ErrStaleCheckPoint)
+		})
+		t.Run("should fail if lease is held by other owner", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp, _ := newCheckPointer(deps).(*checkPointerImpl)
+
+			ctx := context.Background()
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().acquireLease(mock.Anything, cp.leaseOwner, deps.LeaseTTL).Return(blobstorage.ErrLeaseHeld)
+
+			require.ErrorIs(t, cp.dumpState(ctx, aggregationState{
+				counters: newCounters(),
+			}), blobstorage.ErrLeaseHeld)
+		})
+		t.Run("should ignore lease release errors", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp, _ := newCheckPointer(deps).(*checkPointerImpl)
+
+			ctx := context.Background()
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().acquireLease(mock.Anything, cp.leaseOwner, deps.LeaseTTL).Return(nil)
+			mockModel.EXPECT().releaseLease(mock.Anything, cp.leaseOwner).Return(errors.New(faker.Sentence()))
+			mockModel.EXPECT().readManifest(mock.Anything).Return(checkPointManifest{}, fs.ErrNotExist)
+			mockModel.EXPECT().blobsEncoding().Return(randomBlobsEncoding())
+			mockModel.EXPECT().writeCounters(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().writeItems(mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(checkPointManifestHistory{}, nil)
+			mockModel.EXPECT().writeManifestHistory(mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().writeManifest(mock.Anything, mock.Anything).Return(nil)
+
+			require.NoError(t, cp.dumpState(ctx, aggregationState{
+				counters:     newCounters(),
+				allTimeItems: newTopKItems(topKMaxItemsSize),
+			}))
+		})
+	})
+
+	t.Run("withLease", func(t *testing.T) {
+		t.Run("should renew the lease while running", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.LeaseTTL = 3 * time.Millisecond
+			cp, _ := newCheckPointer(deps).(*checkPointerImpl)
+
+			renewed := make(chan struct{})
+			acquiredCount := 0
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().acquireLease(mock.Anything, cp.leaseOwner, deps.LeaseTTL).RunAndReturn(
+				func(_ context.Context, _ string, _ time.Duration) error {
+					acquiredCount++
+					if acquiredCount == 2 {
+						close(renewed)
+					}
+					return nil
+				},
+			)
+			mockModel.EXPECT().releaseLease(mock.Anything, cp.leaseOwner).Return(nil)
+
+			require.NoError(t, cp.withLease(context.Background(), func(_ context.Context) error {
+				select {
+				case <-renewed:
+					return nil
+				case <-time.After(time.Second):
+					return errors.New("lease is not renewed")
+				}
+			}))
+		})
+		t.Run("should cancel the function if the lease is lost", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.LeaseTTL = 3 * time.Millisecond
+			cp, _ := newCheckPointer(deps).(*checkPointerImpl)
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().acquireLease(mock.Anything, cp.leaseOwner, deps.LeaseTTL).Return(nil).Once()
+			mockModel.EXPECT().acquireLease(mock.Anything, cp.leaseOwner, deps.LeaseTTL).Return(blobstorage.ErrLeaseHeld)
+			mockModel.EXPECT().releaseLease(mock.Anything, cp.leaseOwner).Return(nil)
+
+			err := cp.withLease(context.Background(), func(ctx context.Context) error {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Second):
+					return nil
+				}
+			})
+			require.ErrorIs(t, err, ErrCheckPointsLeaseLost)
+			require.ErrorIs(t, err, context.Canceled)
+		})
+	})
+
+	t.Run("restoreStateAt", func(t *testing.T) {
+		t.Run("should restore the state from the historical check point", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newCheckPointer(deps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(3 + rand.IntN(5))
+			manifest := history.Manifests[rand.IntN(len(history.Manifests))]
+			values := randomCountersValues()
+			allTimeRawItems := randomTopKItems(10)
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifestHistory(ctx).Return(history, nil)
+			mockModel.EXPECT().readCounters(ctx, manifest.CountersBlobFileName, manifest.encoding()).Return(values, nil)
+			mockModel.EXPECT().readItems(ctx, manifest.AllTimeItemsFileName, manifest.encoding()).Return(allTimeRawItems, nil)
+
+			counters, _ := newCounters().(*countersImpl)
+			allTimeItems := newTopKItems(topKMaxItemsSize)
+			require.NoError(t, cp.restoreStateAt(ctx, aggregationState{
+				counters:     counters,
+				allTimeItems: allTimeItems,
+			}, manifest.LastOffset))
+
+			wantAllTimeItems := newTopKItems(topKMaxItemsSize)
+			wantAllTimeItems.load(allTimeRawItems)
+
+			assert.Equal(t, manifest.LastOffset, counters.lastOffset)
+			assert.Equal(t, values, counters.itemCounters)
+			assert.Equal(t,
+				wantAllTimeItems.getItems(topKGetAllItemsLimit),
+				allTimeItems.getItems(topKGetAllItemsLimit),
+			)
+		})
+		t.Run("should fail if no check point with a given offset", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newCheckPointer(deps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(3)
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifestHistory(ctx).Return(history, nil)
+
+			counters, _ := newCounters().(*countersImpl)
+			require.ErrorIs(t, cp.restoreStateAt(ctx, aggregationState{
+				counters: counters,
+			}, history.Manifests[2].LastOffset+1), ErrCheckPointNotFound)
+		})
+		t.Run("should fail on history reading errors", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newCheckPointer(deps)
+
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifestHistory(ctx).Return(checkPointManifestHistory{}, wantErr)
+
+			counters, _ := newCounters().(*countersImpl)
+			require.ErrorIs(t, cp.restoreStateAt(ctx, aggregationState{
+				counters: counters,
+			}, rand.Int64()), wantErr)
+		})
+	})
+
+	t.Run("listCheckPoints", func(t *testing.T) {
+		t.Run("should list check points and mark the current one", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newCheckPointer(deps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(3)
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(ctx).Return(history.Manifests[1], nil)
+			mockModel.EXPECT().readManifestHistory(ctx).Return(history, nil)
+
+			got, err := cp.listCheckPoints(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []checkPointListItem{
+				{checkPointManifest: history.Manifests[0]},
+				{checkPointManifest: history.Manifests[1], current: true},
+				{checkPointManifest: history.Manifests[2]},
+			}, got)
+		})
+		t.Run("should list current check point if no history", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newCheckPointer(deps)
+
+			ctx := context.Background()
+			manifest := randomManifest()
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(ctx).Return(manifest, nil)
+			mockModel.EXPECT().readManifestHistory(ctx).Return(
+				checkPointManifestHistory{}, fmt.Errorf("no history: %w", fs.ErrNotExist),
+			)
+
+			got, err := cp.listCheckPoints(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []checkPointListItem{
+				{checkPointManifest: manifest, current: true},
+			}, got)
+		})
+		t.Run("should return empty list if no manifest", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newCheckPointer(deps)
+
+			ctx := context.Background()
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(ctx).Return(
+				checkPointManifest{}, fmt.Errorf("no manifest: %w", fs.ErrNotExist),
+			)
+
+			got, err := cp.listCheckPoints(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, got)
+		})
+		t.Run("should fail on manifest reading errors", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newCheckPointer(deps)
+
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(ctx).Return(checkPointManifest{}, wantErr)
+
+			_, err := cp.listCheckPoints(ctx)
+			require.ErrorIs(t, err, wantErr)
+		})
+		t.Run("should fail on history reading errors", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newCheckPointer(deps)
+
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(ctx).Return(randomManifest(), nil)
+			mockModel.EXPECT().readManifestHistory(ctx).Return(checkPointManifestHistory{}, wantErr)
+
+			_, err := cp.listCheckPoints(ctx)
+			require.ErrorIs(t, err, wantErr)
+		})
+	})
+
+	t.Run("rollbackTo", func(t *testing.T) {
+		t.Run("should make the historical check point current", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(3 + rand.IntN(5))
+			manifest := history.Manifests[rand.IntN(len(history.Manifests))]
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(history, nil)
+			mockModel.EXPECT().writeManifest(mock.Anything, manifest).Return(nil)
+
+			require.NoError(t, cp.rollbackTo(ctx, manifest.LastOffset))
+		})
+		t.Run("should fail if no check point with a given offset", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(3)
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(history, nil)
+
+			require.ErrorIs(t, cp.rollbackTo(ctx, history.Manifests[0].LastOffset-1), ErrCheckPointNotFound)
+		})
+		t.Run("should fail on manifest writing errors", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(3)
+			wantErr := errors.New(faker.Sentence())
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(history, nil)
+			mockModel.EXPECT().writeManifest(mock.Anything, history.Manifests[1]).Return(wantErr)
+
+			require.ErrorIs(t, cp.rollbackTo(ctx, history.Manifests[1].LastOffset), wantErr)
+		})
+	})
+
+	t.Run("pruneCheckPoints", func(t *testing.T) {
+		t.Run("should keep last N check points and delete blobs of others", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.RetentionKeepLast = 2
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(5)
+			currentManifest := history.Manifests[4]
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(currentManifest, nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(history, nil)
+			mockModel.EXPECT().writeManifestHistory(mock.Anything, checkPointManifestHistory{
+				Manifests: history.Manifests[3:],
+			}).Return(nil)
+			for _, manifest := range history.Manifests[:3] {
+				mockModel.EXPECT().deleteBlob(mock.Anything, manifest.CountersBlobFileName).Return(nil)
+				mockModel.EXPECT().deleteBlob(mock.Anything, manifest.AllTimeItemsFileName).Return(nil)
+			}
+
+			require.NoError(t, cp.pruneCheckPoints(ctx))
+		})
+		t.Run("should prune check points older than max age", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.RetentionMaxAge = time.Duration(1+rand.IntN(100)) * time.Hour
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			now := services.MockNowValue(deps.Time)
+			history := randomManifestHistory(4)
+			history.Manifests[0].CreatedAt = now.Add(-deps.RetentionMaxAge - time.Second)
+			history.Manifests[1].CreatedAt = now.Add(-deps.RetentionMaxAge - time.Second)
+			history.Manifests[2].CreatedAt = now.Add(-deps.RetentionMaxAge)
+			history.Manifests[3].CreatedAt = now
+			currentManifest := history.Manifests[3]
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(currentManifest, nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(history, nil)
+			mockModel.EXPECT().writeManifestHistory(mock.Anything, checkPointManifestHistory{
+				Manifests: history.Manifests[2:],
+			}).Return(nil)
+			for _, manifest := range history.Manifests[:2] {
+				mockModel.EXPECT().deleteBlob(mock.Anything, manifest.CountersBlobFileName).Return(nil)
+				mockModel.EXPECT().deleteBlob(mock.Anything, manifest.AllTimeItemsFileName).Return(nil)
+			}
+
+			require.NoError(t, cp.pruneCheckPoints(ctx))
+		})
+		t.Run("should always keep the current check point", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.RetentionKeepLast = 1
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(3)
+			currentManifest := history.Manifests[0]
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(currentManifest, nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(history, nil)
+			mockModel.EXPECT().writeManifestHistory(mock.Anything, checkPointManifestHistory{
+				Manifests: []checkPointManifest{history.Manifests[0], history.Manifests[2]},
+			}).Return(nil)
+			mockModel.EXPECT().deleteBlob(mock.Anything, history.Manifests[1].CountersBlobFileName).Return(nil)
+			mockModel.EXPECT().deleteBlob(mock.Anything, history.Manifests[1].AllTimeItemsFileName).Return(nil)
+
+			require.NoError(t, cp.pruneCheckPoints(ctx))
+		})
+		t.Run("should not delete blobs referenced by retained check points", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.RetentionKeepLast = 1
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(2)
+			history.Manifests[0].AllTimeItemsFileName = history.Manifests[1].AllTimeItemsFileName
+			currentManifest := history.Manifests[1]
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(currentManifest, nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(history, nil)
+			mockModel.EXPECT().writeManifestHistory(mock.Anything, checkPointManifestHistory{
+				Manifests: history.Manifests[1:],
+			}).Return(nil)
+			mockModel.EXPECT().deleteBlob(mock.Anything, history.Manifests[0].CountersBlobFileName).Return(nil)
+
+			require.NoError(t, cp.pruneCheckPoints(ctx))
+		})
+		t.Run("should ignore already deleted blobs", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.RetentionKeepLast = 1
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(2)
+			currentManifest := history.Manifests[1]
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(currentManifest, nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(history, nil)
+			mockModel.EXPECT().writeManifestHistory(mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().deleteBlob(mock.Anything, history.Manifests[0].CountersBlobFileName).Return(
+				fmt.Errorf("deleted: %w", fs.ErrNotExist),
+			)
+			mockModel.EXPECT().deleteBlob(mock.Anything, history.Manifests[0].AllTimeItemsFileName).Return(nil)
+
+			require.NoError(t, cp.pruneCheckPoints(ctx))
+		})
+		t.Run("should do nothing if all check points are retained", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.RetentionKeepLast = 5
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(5)
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(history.Manifests[4], nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(history, nil)
+
+			require.NoError(t, cp.pruneCheckPoints(ctx))
+		})
+		t.Run("should do nothing if no manifest", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(
+				checkPointManifest{}, fmt.Errorf("no manifest: %w", fs.ErrNotExist),
+			)
+
+			require.NoError(t, cp.pruneCheckPoints(ctx))
+		})
+		t.Run("should fail on manifest reading errors", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(checkPointManifest{}, wantErr)
+
+			require.ErrorIs(t, cp.pruneCheckPoints(ctx), wantErr)
+		})
+		t.Run("should fail on history reading errors", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(randomManifest(), nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(checkPointManifestHistory{}, wantErr)
+
+			require.ErrorIs(t, cp.pruneCheckPoints(ctx), wantErr)
+		})
+		t.Run("should fail on history writing errors", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.RetentionKeepLast = 1
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(2)
+			wantErr := errors.New(faker.Sentence())
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(history.Manifests[1], nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(history, nil)
+			mockModel.EXPECT().writeManifestHistory(mock.Anything, mock.Anything).Return(wantErr)
+
+			require.ErrorIs(t, cp.pruneCheckPoints(ctx), wantErr)
+		})
+		t.Run("should fail on blob deletion errors", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.RetentionKeepLast = 1
+			cp := newLeasedCheckPointer(deps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(2)
+			wantErr := errors.New(faker.Sentence())
+
+			mockModel, _ := deps.CheckPointerModel.(*mockCheckPointerModel)
+			mockModel.EXPECT().readManifest(mock.Anything).Return(history.Manifests[1], nil)
+			mockModel.EXPECT().readManifestHistory(mock.Anything).Return(history, nil)
+			mockModel.EXPECT().writeManifestHistory(mock.Anything, mock.Anything).Return(nil)
+			mockModel.EXPECT().deleteBlob(mock.Anything, history.Manifests[0].CountersBlobFileName).Return(wantErr)
+
+			require.ErrorIs(t, cp.pruneCheckPoints(ctx), wantErr)
+		})
 	})
 }
@@ -1,9 +1,13 @@
 package aggregation
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/samber/lo"
@@ -11,6 +15,16 @@ import (
 	"go.uber.org/dig"
 )
 
+// ErrCheckPointsExist indicates that the operation requires no check points to exist.
+var ErrCheckPointsExist = errors.New("check points already exist")
+
+// ErrLiveStateBehind indicates that the live state is aggregated till the offset
+// lower than the offset of the check point it is verified against.
+var ErrLiveStateBehind = errors.New("live state is behind the check point")
+
+// ErrNothingToRebuild indicates that there are no messages in the stream to rebuild the state from.
+var ErrNothingToRebuild = errors.New("no messages to rebuild from")
+
 type itemEventsKafkaReader interface {
 	FetchMessage(ctx context.Context) (kafka.Message, error)
 	SetOffset(offset int64) error
@@ -25,15 +39,25 @@ type CommandsDeps struct {
 
 	RootLogger *slog.Logger
 
+	// config
+	RestoreCheckPointOffset int64         `name:"config.aggregator.restoreCheckPointOffset"`
+	CheckPointsEnabled      bool          `name:"config.aggregator.checkPoints.enabled"`
+	CheckPointsInterval     time.Duration `name:"config.aggregator.checkPoints.interval"`
+	OffsetLagInterval       time.Duration `name:"config.aggregator.offsetLagInterval"`
+	LiveMaxOffsetLag        int64         `name:"config.aggregator.liveMaxOffsetLag"`
+	AllTimeItemsCapacity    int           `name:"config.aggregator.topK.allTime.capacity"`
+
 	// service layer
 	ItemEventsReader itemEventsKafkaReader
 
 	// package private components
 	ItemEventsAggregator itemEventsAggregator
 	CheckPointer         checkPointer
+	LiveCheckPointer     liveCheckPointer
 	CountersFactory      countersFactory
 	TopKItemsFactory     topKItemsFactory
 	AggregationState     aggregationState
+	LiveStateReads       liveStateReads
 }
 
 type Commands struct {
@@ -41,10 +65,34 @@ type Commands struct {
 	deps   CommandsDeps
 }
 
-func (c *Commands) StartAggregator(ctx context.Context) error {
+// CheckPoint describes a check point from the check points history.
+type CheckPoint struct {
+	LastOffset           int64
+	CreatedAt            time.Time
+	CountersBlobFileName string
+	AllTimeItemsFileName string
+	Codec                string
+
+	// Current indicates if the check point is the one that will be restored
+	Current bool
+}
+
+func (c *Commands) restoreAggregationState(ctx context.Context) error {
+	if c.deps.RestoreCheckPointOffset > 0 {
+		c.logger.InfoContext(ctx, "Restoring counters state from explicitly chosen check point",
+			slog.Int64("checkPointOffset", c.deps.RestoreCheckPointOffset),
+		)
+		return c.deps.CheckPointer.restoreStateAt(ctx, c.deps.AggregationState, c.deps.RestoreCheckPointOffset)
+	}
 	c.logger.DebugContext(ctx, "Restoring counters state")
+	return c.deps.CheckPointer.restoreState(ctx, c.deps.AggregationState)
+}
+
+func (c *Commands) StartAggregator(ctx context.Context) error {
 	startedAt := time.Now()
-	if err := c.deps.CheckPointer.restoreState(ctx, c.deps.AggregationState); err != nil {
+	status := c.deps.AggregationState.status
+	status.beginRestore(startedAt)
+	if err := c.restoreAggregationState(ctx); err != nil {
 		return fmt.Errorf("failed to restore state while starting aggregator: %w", err)
 	}
 
@@ -55,19 +103,34 @@ func (c *Commands) StartAggregator(ctx context.Context) error {
 		slog.Duration("restorationDuration", time.Since(startedAt)),
 	)
 	lastOffset := counters.getLastOffset()
+
+	// without the offset lag monitoring the catch up progress is unknown
+	// so the aggregator is considered live right away
+	status.beginCatchingUp(time.Now(), lastOffset, c.deps.LiveMaxOffsetLag, c.deps.OffsetLagInterval > 0)
 	sinceOffset := lo.If(lastOffset == 0, int64(0)).Else(lastOffset + 1)
 	c.logger.InfoContext(ctx,
 		"Starting aggregation",
 		slog.Int64("sinceOffset", sinceOffset),
 	)
-	return c.deps.ItemEventsAggregator.beginAggregating(ctx, c.deps.AggregationState, beginAggregatingOpts{
-		sinceOffset: sinceOffset,
-	})
+	opts := beginAggregatingOpts{
+		sinceOffset:       sinceOffset,
+		stateReads:        c.deps.LiveStateReads,
+		offsetLagInterval: c.deps.OffsetLagInterval,
+	}
+	if c.deps.CheckPointsEnabled {
+		c.logger.InfoContext(ctx, "Check points of the live state enabled",
+			slog.Duration("interval", c.deps.CheckPointsInterval),
+		)
+		c.deps.LiveCheckPointer.begin(lastOffset)
+		opts.checkPointInterval = c.deps.CheckPointsInterval
+		opts.onCheckPoint = c.deps.LiveCheckPointer.writeCheckPoint
+	}
+	return c.deps.ItemEventsAggregator.beginAggregating(ctx, c.deps.AggregationState, opts)
 }
 
 func (c *Commands) CreateCheckPoint(ctx context.Context) error {
 	ctn := c.deps.CountersFactory.newCounters()
-	allTimesItems := c.deps.TopKItemsFactory.newTopKItems(topKMaxItemsSize)
+	allTimesItems := c.deps.TopKItemsFactory.newTopKItems(c.deps.AllTimeItemsCapacity)
 	state := aggregationState{
 		counters:     ctn,
 		allTimeItems: allTimesItems,
@@ -118,6 +181,487 @@ func (c *Commands) CreateCheckPoint(ctx context.Context) error {
 	return nil
 }
 
+func (c *Commands) PruneCheckPoints(ctx context.Context) error {
+	c.logger.InfoContext(ctx, "Pruning check points")
+	if err := c.deps.CheckPointer.pruneCheckPoints(ctx); err != nil {
+		return fmt.Errorf("failed to prune check points: %w", err)
+	}
+	return nil
+}
+
+func (c *Commands) ListCheckPoints(ctx context.Context) ([]CheckPoint, error) {
+	items, err := c.deps.CheckPointer.listCheckPoints(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list check points: %w", err)
+	}
+	result := make([]CheckPoint, len(items))
+	for i, item := range items {
+		result[i] = CheckPoint{
+			LastOffset:           item.LastOffset,
+			CreatedAt:            item.CreatedAt,
+			CountersBlobFileName: item.CountersBlobFileName,
+			AllTimeItemsFileName: item.AllTimeItemsFileName,
+			Codec:                item.Codec,
+			Current:              item.current,
+		}
+	}
+	return result, nil
+}
+
+// RollbackCheckPoint will make the historical check point current. The aggregation
+// will be restored from it and replayed from its offset on a next start. Servers
+// producing live check points must be stopped first, otherwise the next live check
+// point will replace the rolled back one.
+func (c *Commands) RollbackCheckPoint(ctx context.Context, offset int64) error {
+	c.logger.InfoContext(ctx, "Rolling back check point", slog.Int64("offset", offset))
+	if err := c.deps.CheckPointer.rollbackTo(ctx, offset); err != nil {
+		return fmt.Errorf("failed to rollback check point: %w", err)
+	}
+	return nil
+}
+
+// findCheckPoint returns the check point with a given offset or the current one
+// if the offset is 0.
+func (c *Commands) findCheckPoint(ctx context.Context, offset int64) (CheckPoint, error) {
+	checkPoints, err := c.ListCheckPoints(ctx)
+	if err != nil {
+		return CheckPoint{}, err
+	}
+	checkPoint, found := lo.Find(checkPoints, func(item CheckPoint) bool {
+		return lo.If(offset == 0, item.Current).Else(item.LastOffset == offset)
+	})
+	if !found {
+		return CheckPoint{}, fmt.Errorf("offset %d: %w", offset, ErrCheckPointNotFound)
+	}
+	return checkPoint, nil
+}
+
+// loadCheckPoint will restore the state of the check point with a given offset
+// (or the current one if the offset is 0) into a new aggregation state.
+func (c *Commands) loadCheckPoint(ctx context.Context, offset int64) (CheckPoint, aggregationState, error) {
+	checkPoint, err := c.findCheckPoint(ctx, offset)
+	if err != nil {
+		return CheckPoint{}, aggregationState{}, err
+	}
+	state := aggregationState{
+		counters:     c.deps.CountersFactory.newCounters(),
+		allTimeItems: c.deps.TopKItemsFactory.newTopKItems(c.deps.AllTimeItemsCapacity),
+	}
+	if err = c.deps.CheckPointer.restoreStateAt(ctx, state, checkPoint.LastOffset); err != nil {
+		return CheckPoint{}, aggregationState{}, fmt.Errorf("failed to restore check point state: %w", err)
+	}
+	return checkPoint, state, nil
+}
+
+type InspectCheckPointParams struct {
+	// Offset of the check point to inspect. Current check point is used if 0.
+	Offset int64
+
+	// TopN is a number of top items to include
+	TopN int
+}
+
+type CheckPointDetails struct {
+	CheckPoint
+
+	// ItemsCount is a number of distinct items in the check point
+	ItemsCount int
+
+	// TotalEvents is a sum of all counters
+	TotalEvents int64
+
+	TopItems []TopKItem
+}
+
+func (c *Commands) InspectCheckPoint(
+	ctx context.Context,
+	params InspectCheckPointParams,
+) (*CheckPointDetails, error) {
+	checkPoint, state, err := c.loadCheckPoint(ctx, params.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load check point: %w", err)
+	}
+	itemsCounters := state.counters.getItemsCounters()
+	var totalEvents int64
+	for _, count := range itemsCounters {
+		totalEvents += count
+	}
+	topItems := state.allTimeItems.getItems(params.TopN)
+	return &CheckPointDetails{
+		CheckPoint:  checkPoint,
+		ItemsCount:  len(itemsCounters),
+		TotalEvents: totalEvents,
+		TopItems: lo.Map(topItems, func(item *topKItem, _ int) TopKItem {
+			return TopKItem{ItemID: item.ItemID, Count: item.Count}
+		}),
+	}, nil
+}
+
+// ExportCheckPoint will invoke the write function for each counter of the check point
+// with a given offset (or the current one if the offset is 0). Counters are ordered by item id.
+func (c *Commands) ExportCheckPoint(
+	ctx context.Context,
+	offset int64,
+	write func(itemID string, count int64) error,
+) error {
+	checkPoint, state, err := c.loadCheckPoint(ctx, offset)
+	if err != nil {
+		return fmt.Errorf("failed to load check point: %w", err)
+	}
+	itemsCounters := state.counters.getItemsCounters()
+	for _, itemID := range slices.Sorted(maps.Keys(itemsCounters)) {
+		if err = write(itemID, itemsCounters[itemID]); err != nil {
+			return fmt.Errorf("failed to write counter: %w", err)
+		}
+	}
+	c.logger.InfoContext(ctx, "Check point exported",
+		slog.Int64("lastOffset", checkPoint.LastOffset),
+		slog.Int("itemsCount", len(itemsCounters)),
+	)
+	return nil
+}
+
+type ImportCheckPointParams struct {
+	// LastOffset is an offset the check point is anchored at. The aggregator
+	// will continue from the next offset (or from the beginning if 0).
+	LastOffset int64
+
+	// Force allows importing when there are existing check points. The imported
+	// one will become current, so it must be anchored after the offset of the
+	// current check point (roll back first to go back).
+	Force bool
+
+	// ReadCounters must invoke the add function for each imported counter.
+	// Counts of the same item are summed up.
+	ReadCounters func(add func(itemID string, count int64)) error
+}
+
+// ImportCheckPoint will create a new check point (counters, all time items and manifest)
+// from the counters produced by a given source.
+func (c *Commands) ImportCheckPoint(ctx context.Context, params ImportCheckPointParams) error {
+	checkPoints, err := c.ListCheckPoints(ctx)
+	if err != nil {
+		return err
+	}
+	if len(checkPoints) > 0 && !params.Force {
+		return fmt.Errorf("failed to import check point: %w", ErrCheckPointsExist)
+	}
+
+	// checked upfront so the import is not refused after reading all the counters
+	current, hasCurrent := lo.Find(checkPoints, func(checkPoint CheckPoint) bool { return checkPoint.Current })
+	if hasCurrent && params.LastOffset <= current.LastOffset {
+		return fmt.Errorf(
+			"failed to import check point: offset %d is not above the current check point offset %d, roll back first: %w",
+			params.LastOffset, current.LastOffset, ErrStaleCheckPoint,
+		)
+	}
+
+	importedCounters := make(map[string]int64)
+	if err := params.ReadCounters(func(itemID string, count int64) {
+		importedCounters[itemID] += count
+	}); err != nil {
+		return fmt.Errorf("failed to read imported counters: %w", err)
+	}
+
+	state := aggregationState{
+		counters:     c.deps.CountersFactory.newCounters(),
+		allTimeItems: c.deps.TopKItemsFactory.newTopKItems(c.deps.AllTimeItemsCapacity),
+	}
+	updatedItems := state.counters.updateItemsCount(params.LastOffset, importedCounters)
+	for itemID, count := range updatedItems {
+		state.allTimeItems.updateIfGreater(topKItem{ItemID: itemID, Count: count})
+	}
+
+	if err := c.deps.CheckPointer.dumpState(ctx, state); err != nil {
+		return fmt.Errorf("failed to dump state: %w", err)
+	}
+
+	c.logger.InfoContext(ctx, "Check point imported",
+		slog.Int64("lastOffset", params.LastOffset),
+		slog.Int("itemsCount", len(importedCounters)),
+	)
+	return nil
+}
+
+type RebuildCheckPointParams struct {
+	// SinceOffset is an offset to start aggregating from. The stream
+	// is aggregated from the beginning if 0.
+	SinceOffset int64
+
+	// DryRun will compare the rebuilt state with the current check point
+	// instead of writing a new one.
+	DryRun bool
+}
+
+// CounterDiff is a difference of the item counter between the current
+// and the rebuilt state. The count is 0 if the item is missing.
+type CounterDiff struct {
+	ItemID       string
+	CurrentCount int64
+	RebuiltCount int64
+}
+
+type RebuildCheckPointResult struct {
+	// LastOffset is an offset the rebuilt state is anchored at
+	LastOffset int64
+
+	// ItemsCount is a number of distinct items in the rebuilt state
+	ItemsCount int
+
+	// TotalEvents is a sum of all rebuilt counters
+	TotalEvents int64
+
+	// Current is a check point the rebuilt state was compared with. It is only
+	// set in the dry run mode if there is a current check point.
+	Current *CheckPoint
+
+	// Diffs are counters that differ from the current check point ordered
+	// by item id. Only populated in the dry run mode.
+	Diffs []CounterDiff
+}
+
+// diffCounters will return the counters that differ ordered by item id.
+func diffCounters(current, rebuilt map[string]int64) []CounterDiff {
+	diffs := []CounterDiff{}
+	for itemID, count := range rebuilt {
+		if current[itemID] != count {
+			diffs = append(diffs, CounterDiff{ItemID: itemID, CurrentCount: current[itemID], RebuiltCount: count})
+		}
+	}
+	for itemID, count := range current {
+		if _, ok := rebuilt[itemID]; !ok {
+			diffs = append(diffs, CounterDiff{ItemID: itemID, CurrentCount: count})
+		}
+	}
+	slices.SortFunc(diffs, func(a, b CounterDiff) int {
+		return cmp.Compare(a.ItemID, b.ItemID)
+	})
+	return diffs
+}
+
+// RebuildCheckPoint will ignore the current check point and aggregate the stream
+// from a given offset till its tail into a fresh state. The state is written as
+// a new check point unless it is a dry run, in which case it is compared with
+// the current check point.
+func (c *Commands) RebuildCheckPoint(
+	ctx context.Context,
+	params RebuildCheckPointParams,
+) (*RebuildCheckPointResult, error) {
+	streamTail, err := c.deps.ItemEventsReader.ReadLastOffset(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the lag: %w", err)
+	}
+
+	// the streamTail will have a next offset. The tillOffset of 0 means no
+	// limit so the stream with a single message can not be rebuilt.
+	tillOffset := streamTail - 1
+	if tillOffset <= 0 || tillOffset < params.SinceOffset {
+		return nil, fmt.Errorf("since offset %d, stream tail %d: %w",
+			params.SinceOffset, streamTail, ErrNothingToRebuild,
+		)
+	}
+
+	var currentCheckPoint *CheckPoint
+	var currentCounters map[string]int64
+	if params.DryRun {
+		checkPoint, currentState, loadErr := c.loadCheckPoint(ctx, 0)
+		switch {
+		case loadErr == nil:
+			currentCheckPoint = &checkPoint
+			currentCounters = currentState.counters.getItemsCounters()
+		case errors.Is(loadErr, ErrCheckPointNotFound):
+			c.logger.InfoContext(ctx, "No current check point. Rebuilt state will be compared with empty state.")
+		default:
+			return nil, fmt.Errorf("failed to load current check point: %w", loadErr)
+		}
+	}
+
+	state := aggregationState{
+		counters:     c.deps.CountersFactory.newCounters(),
+		allTimeItems: c.deps.TopKItemsFactory.newTopKItems(c.deps.AllTimeItemsCapacity),
+	}
+	c.logger.InfoContext(ctx,
+		"Rebuilding state",
+		slog.Int64("sinceOffset", params.SinceOffset),
+		slog.Int64("streamTail", streamTail),
+		slog.Bool("dryRun", params.DryRun),
+	)
+	if err = c.deps.ItemEventsAggregator.beginAggregating(ctx, state, beginAggregatingOpts{
+		sinceOffset: params.SinceOffset,
+		tillOffset:  tillOffset,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to aggregate till offset: %w", err)
+	}
+
+	itemsCounters := state.counters.getItemsCounters()
+	result := &RebuildCheckPointResult{
+		LastOffset: state.counters.getLastOffset(),
+		ItemsCount: len(itemsCounters),
+	}
+	for _, count := range itemsCounters {
+		result.TotalEvents += count
+	}
+
+	if params.DryRun {
+		result.Current = currentCheckPoint
+		result.Diffs = diffCounters(currentCounters, itemsCounters)
+		c.logger.InfoContext(ctx, "Dry run completed. Check point not written.",
+			slog.Int64("lastOffset", result.LastOffset),
+			slog.Int("diffsCount", len(result.Diffs)),
+		)
+		return result, nil
+	}
+
+	if err = c.deps.CheckPointer.dumpState(ctx, state); err != nil {
+		return nil, fmt.Errorf("failed to dump state: %w", err)
+	}
+	c.logger.InfoContext(ctx, "Check point rebuilt",
+		slog.Int64("lastOffset", result.LastOffset),
+		slog.Int("itemsCount", result.ItemsCount),
+	)
+	return result, nil
+}
+
+type VerifyCheckPointParams struct {
+	// Offset of the check point to verify with. Current check point is used if 0.
+	Offset int64
+
+	// TopN is a number of top items to compare
+	TopN int
+
+	// SampleSize is a number of random items of the check point to compare counters of
+	SampleSize int
+
+	// FetchLiveState must return the snapshot of the live state (e.g from the running server).
+	FetchLiveState func(ctx context.Context, params GetStateSnapshotParams) (*GetStateSnapshotResponse, error)
+}
+
+// TopItemMismatch is a difference of top items at a given rank (starting from 1).
+// The item is nil if there is no item at this rank.
+type TopItemMismatch struct {
+	Rank           int
+	CheckPointItem *TopKItem
+	LiveItem       *TopKItem
+}
+
+// CounterMismatch is a difference of the item counter. The count is 0 if the item is missing.
+type CounterMismatch struct {
+	ItemID          string
+	CheckPointCount int64
+	LiveCount       int64
+}
+
+type VerifyCheckPointResult struct {
+	CheckPoint CheckPoint
+
+	// LastOffset is an offset both states are compared at
+	LastOffset int64
+
+	// TopItemsCount is a number of compared top items
+	TopItemsCount int
+
+	// SampledItemsCount is a number of items counters were compared of
+	SampledItemsCount int
+
+	TopItemsMismatches []TopItemMismatch
+	CountersMismatches []CounterMismatch
+}
+
+// Matches indicates that no mismatches were found.
+func (r *VerifyCheckPointResult) Matches() bool {
+	return len(r.TopItemsMismatches) == 0 && len(r.CountersMismatches) == 0
+}
+
+func diffTopItems(checkPointItems []*topKItem, liveItems []TopKItem) []TopItemMismatch {
+	mismatches := []TopItemMismatch{}
+	for i := range max(len(checkPointItems), len(liveItems)) {
+		var checkPointItem, liveItem *TopKItem
+		if i < len(checkPointItems) {
+			checkPointItem = &TopKItem{ItemID: checkPointItems[i].ItemID, Count: checkPointItems[i].Count}
+		}
+		if i < len(liveItems) {
+			liveItem = &liveItems[i]
+		}
+		if checkPointItem == nil || liveItem == nil || *checkPointItem != *liveItem {
+			mismatches = append(mismatches, TopItemMismatch{
+				Rank:           i + 1,
+				CheckPointItem: checkPointItem,
+				LiveItem:       liveItem,
+			})
+		}
+	}
+	return mismatches
+}
+
+// VerifyCheckPoint will load the check point, aggregate it till the offset of the live state
+// and compare top items and counters of randomly sampled items with the live state.
+func (c *Commands) VerifyCheckPoint(
+	ctx context.Context,
+	params VerifyCheckPointParams,
+) (*VerifyCheckPointResult, error) {
+	checkPoint, state, err := c.loadCheckPoint(ctx, params.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load check point: %w", err)
+	}
+
+	sampledItemIDs := lo.Samples(slices.Collect(maps.Keys(state.counters.getItemsCounters())), params.SampleSize)
+	slices.Sort(sampledItemIDs)
+	liveState, err := params.FetchLiveState(ctx, GetStateSnapshotParams{
+		Limit:   params.TopN,
+		ItemIDs: sampledItemIDs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch live state: %w", err)
+	}
+
+	if liveState.LastOffset < checkPoint.LastOffset {
+		return nil, fmt.Errorf("live offset %d, check point offset %d: %w",
+			liveState.LastOffset, checkPoint.LastOffset, ErrLiveStateBehind,
+		)
+	}
+	if liveState.LastOffset > checkPoint.LastOffset {
+		sinceOffset := lo.If(checkPoint.LastOffset == 0, int64(0)).Else(checkPoint.LastOffset + 1)
+		c.logger.InfoContext(ctx,
+			"Aggregating check point till the live offset",
+			slog.Int64("sinceOffset", sinceOffset),
+			slog.Int64("liveOffset", liveState.LastOffset),
+		)
+		if err = c.deps.ItemEventsAggregator.beginAggregating(ctx, state, beginAggregatingOpts{
+			sinceOffset: sinceOffset,
+			tillOffset:  liveState.LastOffset,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to aggregate till offset: %w", err)
+		}
+	}
+
+	itemsCounters := state.counters.getItemsCounters()
+	topItems := state.allTimeItems.getItems(params.TopN)
+	result := &VerifyCheckPointResult{
+		CheckPoint:         checkPoint,
+		LastOffset:         liveState.LastOffset,
+		TopItemsCount:      max(len(topItems), len(liveState.TopItems)),
+		SampledItemsCount:  len(sampledItemIDs),
+		TopItemsMismatches: diffTopItems(topItems, liveState.TopItems),
+		CountersMismatches: []CounterMismatch{},
+	}
+	for _, itemID := range sampledItemIDs {
+		if itemsCounters[itemID] != liveState.ItemsCounters[itemID] {
+			result.CountersMismatches = append(result.CountersMismatches, CounterMismatch{
+				ItemID:          itemID,
+				CheckPointCount: itemsCounters[itemID],
+				LiveCount:       liveState.ItemsCounters[itemID],
+			})
+		}
+	}
+
+	c.logger.InfoContext(ctx, "Check point verified",
+		slog.Int64("lastOffset", result.LastOffset),
+		slog.Int("topItemsMismatches", len(result.TopItemsMismatches)),
+		slog.Int("countersMismatches", len(result.CountersMismatches)),
+	)
+	return result, nil
+}
+
 func NewCommands(deps CommandsDeps) *Commands {
 	return &Commands{
 		logger: deps.RootLogger.WithGroup("aggregator.commands"),
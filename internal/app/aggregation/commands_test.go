@@ -3,12 +3,18 @@ package aggregation
 import (
 	"context"
 	"errors"
+	"maps"
 	"math/rand/v2"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gemyago/top-k-system-go/internal/diag"
 	"github.com/gemyago/top-k-system-go/internal/services"
 	"github.com/go-faker/faker/v4"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -18,6 +24,7 @@ func TestCommands(t *testing.T) {
 		return CommandsDeps{
 			RootLogger:           diag.RootTestLogger(),
 			CheckPointer:         newMockCheckPointer(t),
+			LiveCheckPointer:     newMockLiveCheckPointer(t),
 			ItemEventsAggregator: newMockItemEventsAggregator(t),
 			ItemEventsReader:     services.NewMockKafkaReader(t),
 			CountersFactory:      newMockCountersFactory(t),
@@ -25,7 +32,12 @@ func TestCommands(t *testing.T) {
 			AggregationState: aggregationState{
 				counters:     newMockCounters(t),
 				allTimeItems: newMockTopKItems(t),
+				status:       newAggregationStatus(),
 			},
+			LiveStateReads:       make(liveStateReads),
+			OffsetLagInterval:    time.Duration(1+rand.IntN(1000)) * time.Second,
+			LiveMaxOffsetLag:     rand.Int64N(1000),
+			AllTimeItemsCapacity: topKMaxItemsSize + rand.IntN(1000),
 		}
 	}
 
@@ -46,13 +58,80 @@ func TestCommands(t *testing.T) {
 			aggregator, _ := mockDeps.ItemEventsAggregator.(*mockItemEventsAggregator)
 			aggregator.EXPECT().
 				beginAggregating(ctx, mockDeps.AggregationState, beginAggregatingOpts{
-					sinceOffset: lastOffset + 1,
+					sinceOffset:       lastOffset + 1,
+					stateReads:        mockDeps.LiveStateReads,
+					offsetLagInterval: mockDeps.OffsetLagInterval,
 				}).
 				Return(nil)
 
 			require.NoError(t, commands.StartAggregator(ctx))
 		})
 
+		t.Run("should track restore and catch up phases", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			status := mockDeps.AggregationState.status
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().
+				restoreState(ctx, mockDeps.AggregationState).
+				RunAndReturn(func(_ context.Context, _ aggregationState) error {
+					got := status.snapshot()
+					assert.Equal(t, AggregationPhaseRestoring, got.Phase)
+					assert.False(t, got.Restore.StartedAt.IsZero())
+					assert.Nil(t, got.Restore.CompletedAt)
+					return nil
+				})
+
+			lastOffset := rand.Int64N(100)
+			mockCounters, _ := mockDeps.AggregationState.counters.(*mockCounters)
+			mockCounters.EXPECT().getItemsCounters().Return(map[string]int64{})
+			mockCounters.EXPECT().getLastOffset().Return(lastOffset)
+
+			aggregator, _ := mockDeps.ItemEventsAggregator.(*mockItemEventsAggregator)
+			aggregator.EXPECT().
+				beginAggregating(ctx, mockDeps.AggregationState, mock.Anything).
+				RunAndReturn(func(_ context.Context, _ aggregationState, _ beginAggregatingOpts) error {
+					got := status.snapshot()
+					assert.Equal(t, AggregationPhaseCatchingUp, got.Phase)
+					assert.NotNil(t, got.Restore.CompletedAt)
+					assert.Equal(t, lastOffset, got.LastOffset)
+
+					status.offsetLagUpdated(rand.Int64N(1000), mockDeps.LiveMaxOffsetLag+1)
+					assert.Equal(t, AggregationPhaseCatchingUp, status.snapshot().Phase)
+					status.offsetLagUpdated(rand.Int64N(1000), mockDeps.LiveMaxOffsetLag)
+					assert.Equal(t, AggregationPhaseLive, status.snapshot().Phase)
+					return nil
+				})
+
+			require.NoError(t, commands.StartAggregator(ctx))
+		})
+
+		t.Run("should become live once restored if offset lag is not monitored", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			mockDeps.OffsetLagInterval = 0
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().restoreState(ctx, mockDeps.AggregationState).Return(nil)
+
+			mockCounters, _ := mockDeps.AggregationState.counters.(*mockCounters)
+			mockCounters.EXPECT().getItemsCounters().Return(map[string]int64{})
+			mockCounters.EXPECT().getLastOffset().Return(rand.Int64N(100))
+
+			aggregator, _ := mockDeps.ItemEventsAggregator.(*mockItemEventsAggregator)
+			aggregator.EXPECT().
+				beginAggregating(ctx, mockDeps.AggregationState, mock.Anything).
+				RunAndReturn(func(_ context.Context, _ aggregationState, _ beginAggregatingOpts) error {
+					assert.Equal(t, AggregationPhaseLive, mockDeps.AggregationState.status.snapshot().Phase)
+					return nil
+				})
+
+			require.NoError(t, commands.StartAggregator(ctx))
+		})
+
 		t.Run("should start aggregating from the beginning if no state", func(t *testing.T) {
 			mockDeps := newMockDeps(t)
 			commands := NewCommands(mockDeps)
@@ -69,13 +148,77 @@ func TestCommands(t *testing.T) {
 			aggregator, _ := mockDeps.ItemEventsAggregator.(*mockItemEventsAggregator)
 			aggregator.EXPECT().
 				beginAggregating(ctx, mockDeps.AggregationState, beginAggregatingOpts{
-					sinceOffset: 0,
+					sinceOffset:       0,
+					stateReads:        mockDeps.LiveStateReads,
+					offsetLagInterval: mockDeps.OffsetLagInterval,
+				}).
+				Return(nil)
+
+			require.NoError(t, commands.StartAggregator(ctx))
+		})
+
+		t.Run("should restore state from explicitly chosen check point", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			mockDeps.RestoreCheckPointOffset = 1 + rand.Int64N(1000)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().
+				restoreStateAt(ctx, mockDeps.AggregationState, mockDeps.RestoreCheckPointOffset).
+				Return(nil)
+
+			mockCounters, _ := mockDeps.AggregationState.counters.(*mockCounters)
+			mockCounters.EXPECT().getItemsCounters().Return(map[string]int64{})
+			mockCounters.EXPECT().getLastOffset().Return(mockDeps.RestoreCheckPointOffset)
+
+			aggregator, _ := mockDeps.ItemEventsAggregator.(*mockItemEventsAggregator)
+			aggregator.EXPECT().
+				beginAggregating(ctx, mockDeps.AggregationState, beginAggregatingOpts{
+					sinceOffset:       mockDeps.RestoreCheckPointOffset + 1,
+					stateReads:        mockDeps.LiveStateReads,
+					offsetLagInterval: mockDeps.OffsetLagInterval,
 				}).
 				Return(nil)
 
 			require.NoError(t, commands.StartAggregator(ctx))
 		})
 
+		t.Run("should start aggregating with live check points", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			mockDeps.CheckPointsEnabled = true
+			mockDeps.CheckPointsInterval = time.Duration(1+rand.IntN(1000)) * time.Second
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().restoreState(ctx, mockDeps.AggregationState).Return(nil)
+
+			lastOffset := 1 + rand.Int64N(100)
+			mockCounters, _ := mockDeps.AggregationState.counters.(*mockCounters)
+			mockCounters.EXPECT().getItemsCounters().Return(map[string]int64{})
+			mockCounters.EXPECT().getLastOffset().Return(lastOffset)
+
+			liveCheckPointer, _ := mockDeps.LiveCheckPointer.(*mockLiveCheckPointer)
+			liveCheckPointer.EXPECT().begin(lastOffset)
+
+			aggregator, _ := mockDeps.ItemEventsAggregator.(*mockItemEventsAggregator)
+			aggregator.EXPECT().
+				beginAggregating(ctx, mockDeps.AggregationState, mock.Anything).
+				RunAndReturn(func(ctx context.Context, _ aggregationState, opts beginAggregatingOpts) error {
+					assert.Equal(t, lastOffset+1, opts.sinceOffset)
+					assert.Equal(t, mockDeps.CheckPointsInterval, opts.checkPointInterval)
+
+					snapshot := aggregationState{counters: newCounters()}
+					final := rand.IntN(2) == 1
+					liveCheckPointer.EXPECT().writeCheckPoint(ctx, snapshot, final)
+					opts.onCheckPoint(ctx, snapshot, final)
+					return nil
+				})
+
+			require.NoError(t, commands.StartAggregator(ctx))
+		})
+
 		t.Run("should return error if restore state failed", func(t *testing.T) {
 			mockDeps := newMockDeps(t)
 			commands := NewCommands(mockDeps)
@@ -105,7 +248,7 @@ func TestCommands(t *testing.T) {
 
 			mockAllTimesItems := newMockTopKItems(t)
 			topKItemsFactory, _ := mockDeps.TopKItemsFactory.(*mockTopKItemsFactory)
-			topKItemsFactory.EXPECT().newTopKItems(topKMaxItemsSize).Return(mockAllTimesItems)
+			topKItemsFactory.EXPECT().newTopKItems(mockDeps.AllTimeItemsCapacity).Return(mockAllTimesItems)
 
 			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
 			checkPointer.EXPECT().restoreState(ctx, aggregationState{
@@ -150,7 +293,7 @@ func TestCommands(t *testing.T) {
 
 			mockAllTimesItems := newMockTopKItems(t)
 			topKItemsFactory, _ := mockDeps.TopKItemsFactory.(*mockTopKItemsFactory)
-			topKItemsFactory.EXPECT().newTopKItems(topKMaxItemsSize).Return(mockAllTimesItems)
+			topKItemsFactory.EXPECT().newTopKItems(mockDeps.AllTimeItemsCapacity).Return(mockAllTimesItems)
 
 			state := aggregationState{
 				counters:     wantCounters,
@@ -192,7 +335,7 @@ func TestCommands(t *testing.T) {
 
 			mockAllTimesItems := newMockTopKItems(t)
 			topKItemsFactory, _ := mockDeps.TopKItemsFactory.(*mockTopKItemsFactory)
-			topKItemsFactory.EXPECT().newTopKItems(topKMaxItemsSize).Return(mockAllTimesItems)
+			topKItemsFactory.EXPECT().newTopKItems(mockDeps.AllTimeItemsCapacity).Return(mockAllTimesItems)
 
 			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
 			checkPointer.EXPECT().restoreState(ctx, mock.Anything).Return(nil)
@@ -215,7 +358,7 @@ func TestCommands(t *testing.T) {
 
 			mockAllTimesItems := newMockTopKItems(t)
 			topKItemsFactory, _ := mockDeps.TopKItemsFactory.(*mockTopKItemsFactory)
-			topKItemsFactory.EXPECT().newTopKItems(topKMaxItemsSize).Return(mockAllTimesItems)
+			topKItemsFactory.EXPECT().newTopKItems(mockDeps.AllTimeItemsCapacity).Return(mockAllTimesItems)
 
 			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
 			wantErr := errors.New(faker.Sentence())
@@ -236,7 +379,7 @@ func TestCommands(t *testing.T) {
 
 			mockAllTimesItems := newMockTopKItems(t)
 			topKItemsFactory, _ := mockDeps.TopKItemsFactory.(*mockTopKItemsFactory)
-			topKItemsFactory.EXPECT().newTopKItems(topKMaxItemsSize).Return(mockAllTimesItems)
+			topKItemsFactory.EXPECT().newTopKItems(mockDeps.AllTimeItemsCapacity).Return(mockAllTimesItems)
 
 			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
 			checkPointer.EXPECT().restoreState(ctx, mock.Anything).Return(nil)
@@ -248,4 +391,942 @@ func TestCommands(t *testing.T) {
 			require.ErrorIs(t, commands.CreateCheckPoint(ctx), wantErr)
 		})
 	})
+	t.Run("PruneCheckPoints", func(t *testing.T) {
+		t.Run("should prune check points", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().pruneCheckPoints(ctx).Return(nil)
+
+			require.NoError(t, commands.PruneCheckPoints(ctx))
+		})
+		t.Run("should return error if failed to prune check points", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().pruneCheckPoints(ctx).Return(wantErr)
+
+			require.ErrorIs(t, commands.PruneCheckPoints(ctx), wantErr)
+		})
+	})
+	t.Run("ListCheckPoints", func(t *testing.T) {
+		t.Run("should list check points", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(3)
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{
+				{checkPointManifest: history.Manifests[0]},
+				{checkPointManifest: history.Manifests[1]},
+				{checkPointManifest: history.Manifests[2], current: true},
+			}, nil)
+
+			got, err := commands.ListCheckPoints(ctx)
+			require.NoError(t, err)
+			want := make([]CheckPoint, len(history.Manifests))
+			for i, manifest := range history.Manifests {
+				want[i] = CheckPoint{
+					LastOffset:           manifest.LastOffset,
+					CreatedAt:            manifest.CreatedAt,
+					CountersBlobFileName: manifest.CountersBlobFileName,
+					AllTimeItemsFileName: manifest.AllTimeItemsFileName,
+					Codec:                manifest.Codec,
+					Current:              i == 2,
+				}
+			}
+			assert.Equal(t, want, got)
+		})
+		t.Run("should return error if failed to list check points", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return(nil, wantErr)
+
+			_, err := commands.ListCheckPoints(ctx)
+			require.ErrorIs(t, err, wantErr)
+		})
+	})
+	t.Run("RollbackCheckPoint", func(t *testing.T) {
+		t.Run("should rollback to a given check point", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			offset := rand.Int64()
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().rollbackTo(ctx, offset).Return(nil)
+
+			require.NoError(t, commands.RollbackCheckPoint(ctx, offset))
+		})
+		t.Run("should return error if failed to rollback", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			offset := rand.Int64()
+			wantErr := errors.New(faker.Sentence())
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().rollbackTo(ctx, offset).Return(wantErr)
+
+			require.ErrorIs(t, commands.RollbackCheckPoint(ctx, offset), wantErr)
+		})
+	})
+	t.Run("InspectCheckPoint", func(t *testing.T) {
+		setupCheckPointState := func(
+			t *testing.T,
+			mockDeps CommandsDeps,
+			manifest checkPointManifest,
+		) (*mockCounters, *mockTopKItems) {
+			wantCounters := newMockCounters(t)
+			countersFactory, _ := mockDeps.CountersFactory.(*mockCountersFactory)
+			countersFactory.EXPECT().newCounters().Return(wantCounters)
+
+			wantAllTimeItems := newMockTopKItems(t)
+			topKItemsFactory, _ := mockDeps.TopKItemsFactory.(*mockTopKItemsFactory)
+			topKItemsFactory.EXPECT().newTopKItems(mockDeps.AllTimeItemsCapacity).Return(wantAllTimeItems)
+
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().restoreStateAt(mock.Anything, aggregationState{
+				counters:     wantCounters,
+				allTimeItems: wantAllTimeItems,
+			}, manifest.LastOffset).Return(nil)
+			return wantCounters, wantAllTimeItems
+		}
+
+		t.Run("should return details of the current check point", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(3)
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{
+				{checkPointManifest: history.Manifests[0]},
+				{checkPointManifest: history.Manifests[1], current: true},
+				{checkPointManifest: history.Manifests[2]},
+			}, nil)
+			wantCounters, wantAllTimeItems := setupCheckPointState(t, mockDeps, history.Manifests[1])
+
+			itemsCounters := randomCountersValues()
+			var wantTotalEvents int64
+			for itemID := range itemsCounters {
+				itemsCounters[itemID] = rand.Int64N(1000)
+				wantTotalEvents += itemsCounters[itemID]
+			}
+			wantCounters.EXPECT().getItemsCounters().Return(itemsCounters)
+
+			topN := 1 + rand.IntN(10)
+			topItems := randomTopKItems(topN)
+			wantAllTimeItems.EXPECT().getItems(topN).Return(topItems)
+
+			got, err := commands.InspectCheckPoint(ctx, InspectCheckPointParams{TopN: topN})
+			require.NoError(t, err)
+			assert.Equal(t, &CheckPointDetails{
+				CheckPoint: CheckPoint{
+					LastOffset:           history.Manifests[1].LastOffset,
+					CreatedAt:            history.Manifests[1].CreatedAt,
+					CountersBlobFileName: history.Manifests[1].CountersBlobFileName,
+					AllTimeItemsFileName: history.Manifests[1].AllTimeItemsFileName,
+					Codec:                history.Manifests[1].Codec,
+					Current:              true,
+				},
+				ItemsCount:  len(itemsCounters),
+				TotalEvents: wantTotalEvents,
+				TopItems: lo.Map(topItems, func(item *topKItem, _ int) TopKItem {
+					return TopKItem{ItemID: item.ItemID, Count: item.Count}
+				}),
+			}, got)
+		})
+		t.Run("should return details of the check point with a given offset", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(3)
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{
+				{checkPointManifest: history.Manifests[0]},
+				{checkPointManifest: history.Manifests[1]},
+				{checkPointManifest: history.Manifests[2], current: true},
+			}, nil)
+			wantCounters, wantAllTimeItems := setupCheckPointState(t, mockDeps, history.Manifests[0])
+			wantCounters.EXPECT().getItemsCounters().Return(map[string]int64{})
+			wantAllTimeItems.EXPECT().getItems(mock.Anything).Return([]*topKItem{})
+
+			got, err := commands.InspectCheckPoint(ctx, InspectCheckPointParams{
+				Offset: history.Manifests[0].LastOffset,
+				TopN:   10,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, history.Manifests[0].LastOffset, got.LastOffset)
+			assert.False(t, got.Current)
+		})
+		t.Run("should return error if no check point with a given offset", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			history := randomManifestHistory(2)
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{
+				{checkPointManifest: history.Manifests[0]},
+				{checkPointManifest: history.Manifests[1], current: true},
+			}, nil)
+
+			_, err := commands.InspectCheckPoint(ctx, InspectCheckPointParams{
+				Offset: history.Manifests[1].LastOffset + 1,
+			})
+			require.ErrorIs(t, err, ErrCheckPointNotFound)
+		})
+		t.Run("should return error if no current check point", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{}, nil)
+
+			_, err := commands.InspectCheckPoint(ctx, InspectCheckPointParams{})
+			require.ErrorIs(t, err, ErrCheckPointNotFound)
+		})
+		t.Run("should return error if failed to list check points", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return(nil, wantErr)
+
+			_, err := commands.InspectCheckPoint(ctx, InspectCheckPointParams{})
+			require.ErrorIs(t, err, wantErr)
+		})
+		t.Run("should return error if failed to restore state", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			manifest := randomManifest()
+			wantErr := errors.New(faker.Sentence())
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{
+				{checkPointManifest: manifest, current: true},
+			}, nil)
+			countersFactory, _ := mockDeps.CountersFactory.(*mockCountersFactory)
+			countersFactory.EXPECT().newCounters().Return(newMockCounters(t))
+			topKItemsFactory, _ := mockDeps.TopKItemsFactory.(*mockTopKItemsFactory)
+			topKItemsFactory.EXPECT().newTopKItems(mockDeps.AllTimeItemsCapacity).Return(newMockTopKItems(t))
+			checkPointer.EXPECT().restoreStateAt(ctx, mock.Anything, manifest.LastOffset).Return(wantErr)
+
+			_, err := commands.InspectCheckPoint(ctx, InspectCheckPointParams{})
+			require.ErrorIs(t, err, wantErr)
+		})
+	})
+	t.Run("ExportCheckPoint", func(t *testing.T) {
+		setupCheckPointState := func(t *testing.T, mockDeps CommandsDeps) (checkPointManifest, *mockCounters) {
+			manifest := randomManifest()
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(mock.Anything).Return([]checkPointListItem{
+				{checkPointManifest: manifest, current: true},
+			}, nil)
+
+			wantCounters := newMockCounters(t)
+			countersFactory, _ := mockDeps.CountersFactory.(*mockCountersFactory)
+			countersFactory.EXPECT().newCounters().Return(wantCounters)
+			topKItemsFactory, _ := mockDeps.TopKItemsFactory.(*mockTopKItemsFactory)
+			topKItemsFactory.EXPECT().newTopKItems(mockDeps.AllTimeItemsCapacity).Return(newMockTopKItems(t))
+			checkPointer.EXPECT().restoreStateAt(mock.Anything, mock.Anything, manifest.LastOffset).Return(nil)
+			return manifest, wantCounters
+		}
+
+		t.Run("should write all counters ordered by item id", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			_, wantCounters := setupCheckPointState(t, mockDeps)
+			itemsCounters := randomCountersValues()
+			wantCounters.EXPECT().getItemsCounters().Return(itemsCounters)
+
+			gotItemIDs := []string{}
+			gotCounters := map[string]int64{}
+			require.NoError(t, commands.ExportCheckPoint(ctx, 0, func(itemID string, count int64) error {
+				gotItemIDs = append(gotItemIDs, itemID)
+				gotCounters[itemID] = count
+				return nil
+			}))
+			assert.Equal(t, itemsCounters, gotCounters)
+			assert.True(t, slices.IsSorted(gotItemIDs))
+		})
+		t.Run("should return error if failed to write", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			_, wantCounters := setupCheckPointState(t, mockDeps)
+			wantCounters.EXPECT().getItemsCounters().Return(randomCountersValues())
+
+			wantErr := errors.New(faker.Sentence())
+			require.ErrorIs(t, commands.ExportCheckPoint(ctx, 0, func(_ string, _ int64) error {
+				return wantErr
+			}), wantErr)
+		})
+		t.Run("should return error if failed to load check point", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return(nil, wantErr)
+
+			require.ErrorIs(t, commands.ExportCheckPoint(ctx, rand.Int64(), func(_ string, _ int64) error {
+				return nil
+			}), wantErr)
+		})
+	})
+	t.Run("ImportCheckPoint", func(t *testing.T) {
+		t.Run("should create check point from imported counters", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			mockDeps.CountersFactory = countersFactoryFunc(newCounters)
+			mockDeps.TopKItemsFactory = topKItemsFactoryFunc(newTopKItems)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			lastOffset := rand.Int64N(100000)
+			records := randomCountersValues()
+			duplicateItemID := faker.UUIDHyphenated()
+
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{}, nil)
+
+			var dumpedState aggregationState
+			checkPointer.EXPECT().dumpState(ctx, mock.Anything).RunAndReturn(
+				func(_ context.Context, state aggregationState) error {
+					dumpedState = state
+					return nil
+				},
+			)
+
+			require.NoError(t, commands.ImportCheckPoint(ctx, ImportCheckPointParams{
+				LastOffset: lastOffset,
+				ReadCounters: func(add func(itemID string, count int64)) error {
+					for itemID, count := range records {
+						add(itemID, count)
+					}
+					add(duplicateItemID, 10)
+					add(duplicateItemID, 20)
+					return nil
+				},
+			}))
+
+			wantCounters := maps.Clone(records)
+			wantCounters[duplicateItemID] = 30
+			assert.Equal(t, lastOffset, dumpedState.counters.getLastOffset())
+			assert.Equal(t, wantCounters, dumpedState.counters.getItemsCounters())
+
+			wantAllTimeItems := newTopKItems(topKMaxItemsSize)
+			for itemID, count := range wantCounters {
+				wantAllTimeItems.updateIfGreater(topKItem{ItemID: itemID, Count: count})
+			}
+			assert.Equal(t,
+				wantAllTimeItems.getItems(topKGetAllItemsLimit),
+				dumpedState.allTimeItems.getItems(topKGetAllItemsLimit),
+			)
+		})
+		t.Run("should fail if check points exist", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{
+				{checkPointManifest: randomManifest(), current: true},
+			}, nil)
+
+			require.ErrorIs(t, commands.ImportCheckPoint(ctx, ImportCheckPointParams{
+				ReadCounters: func(_ func(itemID string, count int64)) error {
+					return nil
+				},
+			}), ErrCheckPointsExist)
+		})
+		t.Run("should import if check points exist and forced", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			mockDeps.CountersFactory = countersFactoryFunc(newCounters)
+			mockDeps.TopKItemsFactory = topKItemsFactoryFunc(newTopKItems)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			currentManifest := randomManifest()
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{
+				{checkPointManifest: currentManifest, current: true},
+			}, nil)
+			checkPointer.EXPECT().dumpState(ctx, mock.Anything).Return(nil)
+
+			require.NoError(t, commands.ImportCheckPoint(ctx, ImportCheckPointParams{
+				LastOffset: currentManifest.LastOffset + 1 + rand.Int64N(1000),
+				Force:      true,
+				ReadCounters: func(_ func(itemID string, count int64)) error {
+					return nil
+				},
+			}))
+		})
+		t.Run("should refuse forced import not above the current check point", func(t *testing.T) {
+			currentManifest := randomManifest()
+			currentManifest.LastOffset = 1 + rand.Int64N(10000)
+			for _, lastOffset := range []int64{
+				currentManifest.LastOffset,
+				currentManifest.LastOffset - 1 - rand.Int64N(currentManifest.LastOffset),
+			} {
+				mockDeps := newMockDeps(t)
+				commands := NewCommands(mockDeps)
+
+				ctx := context.Background()
+				checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+				checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{
+					{checkPointManifest: currentManifest, current: true},
+				}, nil)
+
+				err := commands.ImportCheckPoint(ctx, ImportCheckPointParams{
+					LastOffset: lastOffset,
+					Force:      true,
+					ReadCounters: func(_ func(itemID string, count int64)) error {
+						require.Fail(t, "counters should not be read")
+						return nil
+					},
+				})
+				require.ErrorIs(t, err, ErrStaleCheckPoint, lastOffset)
+				assert.Contains(t, err.Error(), "roll back first")
+			}
+		})
+		t.Run("should fail if failed to list check points", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return(nil, wantErr)
+
+			require.ErrorIs(t, commands.ImportCheckPoint(ctx, ImportCheckPointParams{}), wantErr)
+		})
+		t.Run("should fail if failed to read counters", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{}, nil)
+
+			require.ErrorIs(t, commands.ImportCheckPoint(ctx, ImportCheckPointParams{
+				Force: true,
+				ReadCounters: func(_ func(itemID string, count int64)) error {
+					return wantErr
+				},
+			}), wantErr)
+		})
+		t.Run("should fail if failed to dump state", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			mockDeps.CountersFactory = countersFactoryFunc(newCounters)
+			mockDeps.TopKItemsFactory = topKItemsFactoryFunc(newTopKItems)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{}, nil)
+			checkPointer.EXPECT().dumpState(ctx, mock.Anything).Return(wantErr)
+
+			require.ErrorIs(t, commands.ImportCheckPoint(ctx, ImportCheckPointParams{
+				Force: true,
+				ReadCounters: func(_ func(itemID string, count int64)) error {
+					return nil
+				},
+			}), wantErr)
+		})
+	})
+	t.Run("RebuildCheckPoint", func(t *testing.T) {
+		newRebuildDeps := func(t *testing.T) CommandsDeps {
+			mockDeps := newMockDeps(t)
+			mockDeps.CountersFactory = countersFactoryFunc(newCounters)
+			mockDeps.TopKItemsFactory = topKItemsFactoryFunc(newTopKItems)
+			return mockDeps
+		}
+
+		setupAggregation := func(
+			mockDeps CommandsDeps,
+			sinceOffset, streamTail int64,
+			counters map[string]int64,
+		) {
+			reader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			reader.EXPECT().ReadLastOffset(mock.Anything).Return(streamTail, nil)
+
+			aggregator, _ := mockDeps.ItemEventsAggregator.(*mockItemEventsAggregator)
+			aggregator.EXPECT().
+				beginAggregating(mock.Anything, mock.Anything, beginAggregatingOpts{
+					sinceOffset: sinceOffset,
+					tillOffset:  streamTail - 1,
+				}).
+				RunAndReturn(func(_ context.Context, state aggregationState, opts beginAggregatingOpts) error {
+					state.counters.updateItemsCount(opts.tillOffset, counters)
+					return nil
+				})
+		}
+
+		t.Run("should aggregate from a given offset and write check point", func(t *testing.T) {
+			mockDeps := newRebuildDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			sinceOffset := rand.Int64N(1000)
+			streamTail := sinceOffset + 1 + rand.Int64N(1000)
+			rebuiltCounters := randomCountersValues()
+			var wantTotalEvents int64
+			for itemID := range rebuiltCounters {
+				rebuiltCounters[itemID] = 1 + rand.Int64N(1000)
+				wantTotalEvents += rebuiltCounters[itemID]
+			}
+			setupAggregation(mockDeps, sinceOffset, streamTail, rebuiltCounters)
+
+			var dumpedState aggregationState
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().dumpState(ctx, mock.Anything).RunAndReturn(
+				func(_ context.Context, state aggregationState) error {
+					dumpedState = state
+					return nil
+				},
+			)
+
+			got, err := commands.RebuildCheckPoint(ctx, RebuildCheckPointParams{SinceOffset: sinceOffset})
+			require.NoError(t, err)
+			assert.Equal(t, &RebuildCheckPointResult{
+				LastOffset:  streamTail - 1,
+				ItemsCount:  len(rebuiltCounters),
+				TotalEvents: wantTotalEvents,
+			}, got)
+			assert.Equal(t, streamTail-1, dumpedState.counters.getLastOffset())
+			assert.Equal(t, rebuiltCounters, dumpedState.counters.getItemsCounters())
+		})
+		t.Run("should diff with current check point in dry run mode", func(t *testing.T) {
+			mockDeps := newRebuildDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			streamTail := 2 + rand.Int64N(1000)
+			unchangedItemID := faker.UUIDHyphenated()
+			changedItemID := faker.UUIDHyphenated()
+			addedItemID := faker.UUIDHyphenated()
+			removedItemID := faker.UUIDHyphenated()
+			currentCounters := map[string]int64{
+				unchangedItemID: 10,
+				changedItemID:   20,
+				removedItemID:   30,
+			}
+			rebuiltCounters := map[string]int64{
+				unchangedItemID: 10,
+				changedItemID:   25,
+				addedItemID:     5,
+			}
+			setupAggregation(mockDeps, 0, streamTail, rebuiltCounters)
+
+			manifest := randomManifest()
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{
+				{checkPointManifest: manifest, current: true},
+			}, nil)
+			checkPointer.EXPECT().restoreStateAt(ctx, mock.Anything, manifest.LastOffset).RunAndReturn(
+				func(_ context.Context, state aggregationState, offset int64) error {
+					state.counters.updateItemsCount(offset, currentCounters)
+					return nil
+				},
+			)
+
+			got, err := commands.RebuildCheckPoint(ctx, RebuildCheckPointParams{DryRun: true})
+			require.NoError(t, err)
+			assert.Equal(t, streamTail-1, got.LastOffset)
+			assert.Equal(t, int64(40), got.TotalEvents)
+			assert.Equal(t, &CheckPoint{
+				LastOffset:           manifest.LastOffset,
+				CreatedAt:            manifest.CreatedAt,
+				CountersBlobFileName: manifest.CountersBlobFileName,
+				AllTimeItemsFileName: manifest.AllTimeItemsFileName,
+				Codec:                manifest.Codec,
+				Current:              true,
+			}, got.Current)
+			wantDiffs := []CounterDiff{
+				{ItemID: changedItemID, CurrentCount: 20, RebuiltCount: 25},
+				{ItemID: addedItemID, RebuiltCount: 5},
+				{ItemID: removedItemID, CurrentCount: 30},
+			}
+			slices.SortFunc(wantDiffs, func(a, b CounterDiff) int {
+				return strings.Compare(a.ItemID, b.ItemID)
+			})
+			assert.Equal(t, wantDiffs, got.Diffs)
+		})
+		t.Run("should diff with empty state in dry run mode if no check points", func(t *testing.T) {
+			mockDeps := newRebuildDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			streamTail := 2 + rand.Int64N(1000)
+			itemID := faker.UUIDHyphenated()
+			setupAggregation(mockDeps, 0, streamTail, map[string]int64{itemID: 7})
+
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{}, nil)
+
+			got, err := commands.RebuildCheckPoint(ctx, RebuildCheckPointParams{DryRun: true})
+			require.NoError(t, err)
+			assert.Nil(t, got.Current)
+			assert.Equal(t, []CounterDiff{{ItemID: itemID, RebuiltCount: 7}}, got.Diffs)
+		})
+		t.Run("should return empty diffs if nothing changed", func(t *testing.T) {
+			mockDeps := newRebuildDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			streamTail := 2 + rand.Int64N(1000)
+			counters := randomCountersValues()
+			setupAggregation(mockDeps, 0, streamTail, counters)
+
+			manifest := randomManifest()
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{
+				{checkPointManifest: manifest, current: true},
+			}, nil)
+			checkPointer.EXPECT().restoreStateAt(ctx, mock.Anything, manifest.LastOffset).RunAndReturn(
+				func(_ context.Context, state aggregationState, offset int64) error {
+					state.counters.updateItemsCount(offset, counters)
+					return nil
+				},
+			)
+
+			got, err := commands.RebuildCheckPoint(ctx, RebuildCheckPointParams{DryRun: true})
+			require.NoError(t, err)
+			assert.Equal(t, []CounterDiff{}, got.Diffs)
+		})
+		t.Run("should fail if no messages since offset", func(t *testing.T) {
+			mockDeps := newRebuildDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			streamTail := 1 + rand.Int64N(1000)
+			reader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			reader.EXPECT().ReadLastOffset(ctx).Return(streamTail, nil)
+
+			_, err := commands.RebuildCheckPoint(ctx, RebuildCheckPointParams{SinceOffset: streamTail})
+			require.ErrorIs(t, err, ErrNothingToRebuild)
+		})
+		t.Run("should fail if stream is empty", func(t *testing.T) {
+			mockDeps := newRebuildDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			reader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			reader.EXPECT().ReadLastOffset(ctx).Return(0, nil)
+
+			_, err := commands.RebuildCheckPoint(ctx, RebuildCheckPointParams{})
+			require.ErrorIs(t, err, ErrNothingToRebuild)
+		})
+		t.Run("should fail if failed to read lag", func(t *testing.T) {
+			mockDeps := newRebuildDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+			reader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			reader.EXPECT().ReadLastOffset(ctx).Return(0, wantErr)
+
+			_, err := commands.RebuildCheckPoint(ctx, RebuildCheckPointParams{})
+			require.ErrorIs(t, err, wantErr)
+		})
+		t.Run("should fail if failed to load current check point", func(t *testing.T) {
+			mockDeps := newRebuildDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			reader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			reader.EXPECT().ReadLastOffset(ctx).Return(2+rand.Int64N(1000), nil)
+
+			wantErr := errors.New(faker.Sentence())
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return(nil, wantErr)
+
+			_, err := commands.RebuildCheckPoint(ctx, RebuildCheckPointParams{DryRun: true})
+			require.ErrorIs(t, err, wantErr)
+		})
+		t.Run("should fail if failed to aggregate", func(t *testing.T) {
+			mockDeps := newRebuildDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			reader, _ := mockDeps.ItemEventsReader.(*services.MockKafkaReader)
+			reader.EXPECT().ReadLastOffset(ctx).Return(2+rand.Int64N(1000), nil)
+
+			wantErr := errors.New(faker.Sentence())
+			aggregator, _ := mockDeps.ItemEventsAggregator.(*mockItemEventsAggregator)
+			aggregator.EXPECT().beginAggregating(ctx, mock.Anything, mock.Anything).Return(wantErr)
+
+			_, err := commands.RebuildCheckPoint(ctx, RebuildCheckPointParams{})
+			require.ErrorIs(t, err, wantErr)
+		})
+		t.Run("should fail if failed to dump state", func(t *testing.T) {
+			mockDeps := newRebuildDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			setupAggregation(mockDeps, 0, 2+rand.Int64N(1000), randomCountersValues())
+
+			wantErr := errors.New(faker.Sentence())
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().dumpState(ctx, mock.Anything).Return(wantErr)
+
+			_, err := commands.RebuildCheckPoint(ctx, RebuildCheckPointParams{})
+			require.ErrorIs(t, err, wantErr)
+		})
+	})
+	t.Run("VerifyCheckPoint", func(t *testing.T) {
+		newVerifyDeps := func(t *testing.T) CommandsDeps {
+			mockDeps := newMockDeps(t)
+			mockDeps.CountersFactory = countersFactoryFunc(newCounters)
+			mockDeps.TopKItemsFactory = topKItemsFactoryFunc(newTopKItems)
+			return mockDeps
+		}
+
+		// applyCounters will update the state the same way as the aggregator flush does
+		applyCounters := func(state aggregationState, lastOffset int64, increments map[string]int64) {
+			updatedItems := state.counters.updateItemsCount(lastOffset, increments)
+			for itemID, count := range updatedItems {
+				state.allTimeItems.updateIfGreater(topKItem{ItemID: itemID, Count: count})
+			}
+		}
+
+		randomIncrements := func() map[string]int64 {
+			increments := randomCountersValues()
+			for itemID := range increments {
+				increments[itemID] = 1 + rand.Int64N(1000)
+			}
+			return increments
+		}
+
+		setupCheckPoint := func(
+			mockDeps CommandsDeps,
+			manifest checkPointManifest,
+			counters map[string]int64,
+		) {
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(mock.Anything).Return([]checkPointListItem{
+				{checkPointManifest: manifest, current: true},
+			}, nil)
+			checkPointer.EXPECT().restoreStateAt(mock.Anything, mock.Anything, manifest.LastOffset).RunAndReturn(
+				func(_ context.Context, state aggregationState, offset int64) error {
+					applyCounters(state, offset, counters)
+					return nil
+				},
+			)
+		}
+
+		// liveStateOf will produce the snapshot of the state with given counters
+		liveStateOf := func(
+			lastOffset int64,
+			topN int,
+			counters map[string]int64,
+			itemIDs []string,
+		) *GetStateSnapshotResponse {
+			state := aggregationState{counters: newCounters(), allTimeItems: newTopKItems(topKMaxItemsSize)}
+			applyCounters(state, lastOffset, counters)
+			return &GetStateSnapshotResponse{
+				LastOffset:    lastOffset,
+				TopItems:      toTopKItems(state.allTimeItems.getItems(topN)),
+				ItemsCounters: lo.PickByKeys(counters, itemIDs),
+			}
+		}
+
+		t.Run("should aggregate check point till live offset and compare", func(t *testing.T) {
+			mockDeps := newVerifyDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			manifest := randomManifest()
+			manifest.LastOffset = 1 + rand.Int64N(1000)
+			checkPointCounters := randomIncrements()
+			setupCheckPoint(mockDeps, manifest, checkPointCounters)
+
+			liveOffset := manifest.LastOffset + 1 + rand.Int64N(1000)
+			newIncrements := randomIncrements()
+			aggregator, _ := mockDeps.ItemEventsAggregator.(*mockItemEventsAggregator)
+			aggregator.EXPECT().
+				beginAggregating(ctx, mock.Anything, beginAggregatingOpts{
+					sinceOffset: manifest.LastOffset + 1,
+					tillOffset:  liveOffset,
+				}).
+				RunAndReturn(func(_ context.Context, state aggregationState, opts beginAggregatingOpts) error {
+					applyCounters(state, opts.tillOffset, newIncrements)
+					return nil
+				})
+
+			liveCounters := maps.Clone(checkPointCounters)
+			maps.Copy(liveCounters, newIncrements)
+			topN := 1 + rand.IntN(len(liveCounters))
+			sampleSize := 1 + rand.IntN(len(checkPointCounters))
+
+			got, err := commands.VerifyCheckPoint(ctx, VerifyCheckPointParams{
+				TopN:       topN,
+				SampleSize: sampleSize,
+				FetchLiveState: func(_ context.Context, params GetStateSnapshotParams) (*GetStateSnapshotResponse, error) {
+					assert.Equal(t, topN, params.Limit)
+					assert.Len(t, params.ItemIDs, sampleSize)
+					assert.IsIncreasing(t, params.ItemIDs)
+					for _, itemID := range params.ItemIDs {
+						assert.Contains(t, checkPointCounters, itemID)
+					}
+					return liveStateOf(liveOffset, params.Limit, liveCounters, params.ItemIDs), nil
+				},
+			})
+			require.NoError(t, err)
+			assert.True(t, got.Matches())
+			assert.Equal(t, liveOffset, got.LastOffset)
+			assert.Equal(t, manifest.LastOffset, got.CheckPoint.LastOffset)
+			assert.Equal(t, topN, got.TopItemsCount)
+			assert.Equal(t, sampleSize, got.SampledItemsCount)
+		})
+		t.Run("should not aggregate if live offset is the same", func(t *testing.T) {
+			mockDeps := newVerifyDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			manifest := randomManifest()
+			counters := randomIncrements()
+			setupCheckPoint(mockDeps, manifest, counters)
+
+			got, err := commands.VerifyCheckPoint(ctx, VerifyCheckPointParams{
+				TopN:       len(counters),
+				SampleSize: len(counters),
+				FetchLiveState: func(_ context.Context, params GetStateSnapshotParams) (*GetStateSnapshotResponse, error) {
+					return liveStateOf(manifest.LastOffset, params.Limit, counters, params.ItemIDs), nil
+				},
+			})
+			require.NoError(t, err)
+			assert.True(t, got.Matches())
+		})
+		t.Run("should report mismatches", func(t *testing.T) {
+			mockDeps := newVerifyDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			manifest := randomManifest()
+			topItemID := faker.UUIDHyphenated()
+			otherItemID := faker.UUIDHyphenated()
+			setupCheckPoint(mockDeps, manifest, map[string]int64{topItemID: 100, otherItemID: 10})
+
+			got, err := commands.VerifyCheckPoint(ctx, VerifyCheckPointParams{
+				TopN:       10,
+				SampleSize: 10,
+				FetchLiveState: func(_ context.Context, _ GetStateSnapshotParams) (*GetStateSnapshotResponse, error) {
+					return &GetStateSnapshotResponse{
+						LastOffset: manifest.LastOffset,
+						TopItems:   []TopKItem{{ItemID: topItemID, Count: 90}},
+						ItemsCounters: map[string]int64{
+							topItemID: 90,
+						},
+					}, nil
+				},
+			})
+			require.NoError(t, err)
+			assert.False(t, got.Matches())
+			assert.Equal(t, 2, got.TopItemsCount)
+			assert.Equal(t, []TopItemMismatch{
+				{
+					Rank:           1,
+					CheckPointItem: &TopKItem{ItemID: topItemID, Count: 100},
+					LiveItem:       &TopKItem{ItemID: topItemID, Count: 90},
+				},
+				{
+					Rank:           2,
+					CheckPointItem: &TopKItem{ItemID: otherItemID, Count: 10},
+				},
+			}, got.TopItemsMismatches)
+			wantCountersMismatches := []CounterMismatch{
+				{ItemID: topItemID, CheckPointCount: 100, LiveCount: 90},
+				{ItemID: otherItemID, CheckPointCount: 10},
+			}
+			slices.SortFunc(wantCountersMismatches, func(a, b CounterMismatch) int {
+				return strings.Compare(a.ItemID, b.ItemID)
+			})
+			assert.Equal(t, wantCountersMismatches, got.CountersMismatches)
+		})
+		t.Run("should fail if live state is behind", func(t *testing.T) {
+			mockDeps := newVerifyDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			manifest := randomManifest()
+			manifest.LastOffset = 1 + rand.Int64N(1000)
+			setupCheckPoint(mockDeps, manifest, randomIncrements())
+
+			_, err := commands.VerifyCheckPoint(ctx, VerifyCheckPointParams{
+				FetchLiveState: func(_ context.Context, _ GetStateSnapshotParams) (*GetStateSnapshotResponse, error) {
+					return &GetStateSnapshotResponse{LastOffset: manifest.LastOffset - 1}, nil
+				},
+			})
+			require.ErrorIs(t, err, ErrLiveStateBehind)
+		})
+		t.Run("should fail if failed to load check point", func(t *testing.T) {
+			mockDeps := newVerifyDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			checkPointer, _ := mockDeps.CheckPointer.(*mockCheckPointer)
+			checkPointer.EXPECT().listCheckPoints(ctx).Return([]checkPointListItem{}, nil)
+
+			_, err := commands.VerifyCheckPoint(ctx, VerifyCheckPointParams{})
+			require.ErrorIs(t, err, ErrCheckPointNotFound)
+		})
+		t.Run("should fail if failed to fetch live state", func(t *testing.T) {
+			mockDeps := newVerifyDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			setupCheckPoint(mockDeps, randomManifest(), randomIncrements())
+
+			wantErr := errors.New(faker.Sentence())
+			_, err := commands.VerifyCheckPoint(ctx, VerifyCheckPointParams{
+				FetchLiveState: func(_ context.Context, _ GetStateSnapshotParams) (*GetStateSnapshotResponse, error) {
+					return nil, wantErr
+				},
+			})
+			require.ErrorIs(t, err, wantErr)
+		})
+		t.Run("should fail if failed to aggregate", func(t *testing.T) {
+			mockDeps := newVerifyDeps(t)
+			commands := NewCommands(mockDeps)
+
+			ctx := context.Background()
+			manifest := randomManifest()
+			setupCheckPoint(mockDeps, manifest, randomIncrements())
+
+			wantErr := errors.New(faker.Sentence())
+			aggregator, _ := mockDeps.ItemEventsAggregator.(*mockItemEventsAggregator)
+			aggregator.EXPECT().beginAggregating(ctx, mock.Anything, mock.Anything).Return(wantErr)
+
+			_, err := commands.VerifyCheckPoint(ctx, VerifyCheckPointParams{
+				FetchLiveState: func(_ context.Context, _ GetStateSnapshotParams) (*GetStateSnapshotResponse, error) {
+					return &GetStateSnapshotResponse{LastOffset: manifest.LastOffset + 1}, nil
+				},
+			})
+			require.ErrorIs(t, err, wantErr)
+		})
+	})
 }
@@ -1,8 +1,18 @@
 package aggregation
 
+import "sync"
+
 type counters interface {
+	// getItemsCounters returns the underlying counters, so it must be only called
+	// from a goroutine that updates the counters
 	getItemsCounters() map[string]int64
 
+	// getItemCount returns the count of a given item and false if the item is unknown
+	getItemCount(itemID string) (int64, bool)
+
+	// getItemsCount returns counts of given items. Unknown items are omitted.
+	getItemsCount(itemIDs []string) map[string]int64
+
 	getLastOffset() int64
 
 	// updateItemsCount will update the counts and return the result with
@@ -21,6 +31,21 @@ func (c *countersImpl) getItemsCounters() map[string]int64 {
 	return c.itemCounters
 }
 
+func (c *countersImpl) getItemCount(itemID string) (int64, bool) {
+	count, ok := c.itemCounters[itemID]
+	return count, ok
+}
+
+func (c *countersImpl) getItemsCount(itemIDs []string) map[string]int64 {
+	result := make(map[string]int64, len(itemIDs))
+	for _, itemID := range itemIDs {
+		if count, ok := c.itemCounters[itemID]; ok {
+			result[itemID] = count
+		}
+	}
+	return result
+}
+
 func (c *countersImpl) getLastOffset() int64 {
 	return c.lastOffset
 }
@@ -37,6 +62,40 @@ func (c *countersImpl) updateItemsCount(lastOffset int64, increments map[string]
 	return result
 }
 
+// synchronisedCounters lets other goroutines read the counters of the live
+// state while they are updated by the aggregation goroutine.
+type synchronisedCounters struct {
+	counters
+	rwLock sync.RWMutex
+}
+
+func (c *synchronisedCounters) getItemCount(itemID string) (int64, bool) {
+	c.rwLock.RLock()
+	defer c.rwLock.RUnlock()
+	return c.counters.getItemCount(itemID)
+}
+
+// getItemsCount will read all counts at once, so they are consistent as of the same flush.
+func (c *synchronisedCounters) getItemsCount(itemIDs []string) map[string]int64 {
+	c.rwLock.RLock()
+	defer c.rwLock.RUnlock()
+	return c.counters.getItemsCount(itemIDs)
+}
+
+func (c *synchronisedCounters) getLastOffset() int64 {
+	c.rwLock.RLock()
+	defer c.rwLock.RUnlock()
+	return c.counters.getLastOffset()
+}
+
+func (c *synchronisedCounters) updateItemsCount(lastOffset int64, increments map[string]int64) map[string]int64 {
+	c.rwLock.Lock()
+	defer c.rwLock.Unlock()
+	return c.counters.updateItemsCount(lastOffset, increments)
+}
+
+var _ counters = (*synchronisedCounters)(nil)
+
 type countersFactory interface {
 	newCounters() counters
 }
@@ -54,3 +113,9 @@ func newCounters() counters {
 		itemCounters: make(map[string]int64),
 	}
 }
+
+func newSynchronisedCounters() counters {
+	return &synchronisedCounters{
+		counters: newCounters(),
+	}
+}
@@ -3,6 +3,7 @@ package aggregation
 import (
 	"maps"
 	"math/rand"
+	"slices"
 	"testing"
 
 	"github.com/go-faker/faker/v4"
@@ -81,4 +82,71 @@ func TestCounters(t *testing.T) {
 			assert.Equal(t, wantResult, gotUpdated)
 		})
 	})
+
+	t.Run("getItemCount", func(t *testing.T) {
+		t.Run("should return count of known item", func(t *testing.T) {
+			c := newCounters()
+			itemID := faker.UUIDHyphenated()
+			wantCount := rand.Int63n(1000)
+			c.updateItemsCount(rand.Int63n(1000), map[string]int64{itemID: wantCount})
+
+			gotCount, ok := c.getItemCount(itemID)
+			assert.True(t, ok)
+			assert.Equal(t, wantCount, gotCount)
+		})
+		t.Run("should indicate unknown item", func(t *testing.T) {
+			c := newCounters()
+			_, ok := c.getItemCount(faker.UUIDHyphenated())
+			assert.False(t, ok)
+		})
+	})
+
+	t.Run("getItemsCount", func(t *testing.T) {
+		t.Run("should return counts of known items only", func(t *testing.T) {
+			c := newCounters()
+			wantCounts := map[string]int64{
+				faker.UUIDHyphenated(): rand.Int63n(1000),
+				faker.UUIDHyphenated(): rand.Int63n(1000),
+			}
+			c.updateItemsCount(rand.Int63n(1000), wantCounts)
+			c.updateItemsCount(rand.Int63n(1000), map[string]int64{faker.UUIDHyphenated(): rand.Int63n(1000)})
+
+			itemIDs := append(slices.Collect(maps.Keys(wantCounts)), faker.UUIDHyphenated())
+			assert.Equal(t, wantCounts, c.getItemsCount(itemIDs))
+		})
+	})
+
+	t.Run("synchronisedCounters", func(t *testing.T) {
+		t.Run("should allow reading counters while updating", func(t *testing.T) {
+			c := newSynchronisedCounters()
+			itemID := faker.UUIDHyphenated()
+			updatesCount := 100 + rand.Intn(100)
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := range updatesCount {
+					c.updateItemsCount(int64(i), map[string]int64{itemID: 1})
+				}
+			}()
+
+			var prevCount int64
+			for running := true; running; {
+				select {
+				case <-done:
+					running = false
+				default:
+				}
+				count, _ := c.getItemCount(itemID)
+				assert.GreaterOrEqual(t, count, prevCount)
+				assert.GreaterOrEqual(t, c.getLastOffset()+1, count)
+				prevCount = count
+			}
+
+			gotCount, ok := c.getItemCount(itemID)
+			assert.True(t, ok)
+			assert.Equal(t, int64(updatesCount), gotCount)
+			assert.Equal(t, int64(updatesCount-1), c.getLastOffset())
+		})
+	})
 }
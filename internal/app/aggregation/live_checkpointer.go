@@ -0,0 +1,169 @@
+package aggregation
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gemyago/top-k-system-go/internal/diag"
+	"github.com/gemyago/top-k-system-go/internal/services"
+	"go.uber.org/dig"
+)
+
+// liveCheckPointer writes check points of the live aggregation state. Check points
+// are written in background so the aggregation is only paused to take the snapshot.
+type liveCheckPointer interface {
+	// begin must be called before the aggregation is started with the offset
+	// of the restored state
+	begin(lastOffset int64)
+
+	// writeCheckPoint must be called from the aggregation goroutine. The snapshot of
+	// the state is taken and written in background only if the check point is not
+	// skipped (previous check point is still being written or no new messages).
+	// Final check point is written after the previous one is completed. The final
+	// state is not aggregated anymore, so it is written as is.
+	writeCheckPoint(ctx context.Context, state aggregationState, final bool)
+}
+
+type LiveCheckPointerDeps struct {
+	// all injectable fields must be exported
+	// to let dig inject them
+
+	dig.In
+
+	RootLogger *slog.Logger
+
+	// config
+	Enabled bool `name:"config.aggregator.checkPoints.enabled"`
+
+	// service layer
+	*services.ShutdownHooks
+
+	// package private components
+	CheckPointer     checkPointer
+	AggregationState aggregationState
+}
+
+type liveCheckPointerImpl struct {
+	logger *slog.Logger
+	deps   LiveCheckPointerDeps
+
+	mu         sync.Mutex
+	started    bool
+	inProgress bool
+	lastOffset int64
+
+	pending   sync.WaitGroup
+	finalDone chan struct{}
+	finalErr  error
+}
+
+func (c *liveCheckPointerImpl) begin(lastOffset int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started = true
+	c.lastOffset = lastOffset
+}
+
+func (c *liveCheckPointerImpl) writeCheckPoint(ctx context.Context, state aggregationState, final bool) {
+	// check point should be completed even if the aggregation is stopped
+	ctx = context.WithoutCancel(ctx)
+	offset := state.counters.getLastOffset()
+
+	if final {
+		defer close(c.finalDone)
+		c.pending.Wait()
+		if !c.hasChanges(offset) {
+			c.logger.InfoContext(ctx, "No new messages aggregated. Final check point skipped.",
+				slog.Int64("lastOffset", offset),
+			)
+			return
+		}
+		c.finalErr = c.dumpState(ctx, state)
+		return
+	}
+
+	c.mu.Lock()
+	if c.inProgress {
+		c.mu.Unlock()
+		c.logger.WarnContext(ctx, "Previous check point is still being written. Check point skipped.",
+			slog.Int64("lastOffset", offset),
+		)
+		return
+	}
+	if offset == c.lastOffset {
+		c.mu.Unlock()
+		c.logger.DebugContext(ctx, "No new messages aggregated. Check point skipped.",
+			slog.Int64("lastOffset", offset),
+		)
+		return
+	}
+	c.inProgress = true
+	c.mu.Unlock()
+
+	snapshot := snapshotAggregationState(state)
+	c.pending.Add(1)
+	go func() {
+		defer c.pending.Done()
+		_ = c.dumpState(ctx, snapshot)
+		c.mu.Lock()
+		c.inProgress = false
+		c.mu.Unlock()
+	}()
+}
+
+func (c *liveCheckPointerImpl) hasChanges(offset int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return offset != c.lastOffset
+}
+
+func (c *liveCheckPointerImpl) dumpState(ctx context.Context, snapshot aggregationState) error {
+	startedAt := time.Now()
+	offset := snapshot.counters.getLastOffset()
+	if err := c.deps.CheckPointer.dumpState(ctx, snapshot); err != nil {
+		c.logger.ErrorContext(ctx, "Failed to write check point",
+			slog.Int64("lastOffset", offset),
+			diag.ErrAttr(err),
+		)
+		return err
+	}
+	c.mu.Lock()
+	c.lastOffset = offset
+	c.mu.Unlock()
+	c.deps.AggregationState.status.checkPointWritten(time.Now())
+	c.logger.InfoContext(ctx, "Check point written",
+		slog.Int64("lastOffset", offset),
+		slog.Duration("duration", time.Since(startedAt)),
+	)
+	return nil
+}
+
+// shutdown will wait for the final check point if the aggregation has been started.
+func (c *liveCheckPointerImpl) shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	started := c.started
+	c.mu.Unlock()
+	if !started {
+		return nil
+	}
+	select {
+	case <-c.finalDone:
+		return c.finalErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newLiveCheckPointer(deps LiveCheckPointerDeps) liveCheckPointer {
+	c := &liveCheckPointerImpl{
+		logger:    deps.RootLogger.WithGroup("live-check-pointer"),
+		deps:      deps,
+		finalDone: make(chan struct{}),
+	}
+	if deps.Enabled {
+		deps.ShutdownHooks.Register("live-check-pointer", c.shutdown)
+	}
+	return c
+}
@@ -0,0 +1,221 @@
+package aggregation
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/gemyago/top-k-system-go/internal/diag"
+	"github.com/gemyago/top-k-system-go/internal/services"
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveCheckPointer(t *testing.T) {
+	newMockDeps := func(t *testing.T) LiveCheckPointerDeps {
+		return LiveCheckPointerDeps{
+			RootLogger: diag.RootTestLogger(),
+			Enabled:    true,
+			ShutdownHooks: services.NewShutdownHooks(services.ShutdownHooksRegistryDeps{
+				RootLogger:              diag.RootTestLogger(),
+				GracefulShutdownTimeout: 10 * time.Second,
+			}),
+			CheckPointer: newMockCheckPointer(t),
+			AggregationState: aggregationState{
+				status: newAggregationStatus(),
+			},
+		}
+	}
+
+	randomSnapshot := func(lastOffset int64) aggregationState {
+		cnt := newCounters()
+		cnt.updateItemsCount(lastOffset, randomCountersValues())
+		return aggregationState{
+			counters:     cnt,
+			allTimeItems: newTopKItems(topKMaxItemsSize),
+		}
+	}
+
+	t.Run("newLiveCheckPointer", func(t *testing.T) {
+		t.Run("should register shutdown hook if enabled", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp, _ := newLiveCheckPointer(deps).(*liveCheckPointerImpl)
+			assert.True(t, deps.ShutdownHooks.HasHook("live-check-pointer", cp.shutdown))
+		})
+		t.Run("should not register shutdown hook if disabled", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.Enabled = false
+			cp, _ := newLiveCheckPointer(deps).(*liveCheckPointerImpl)
+			assert.False(t, deps.ShutdownHooks.HasHook("live-check-pointer", cp.shutdown))
+		})
+	})
+
+	t.Run("writeCheckPoint", func(t *testing.T) {
+		t.Run("should write check point in background", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp, _ := newLiveCheckPointer(deps).(*liveCheckPointerImpl)
+			lastOffset := rand.Int64N(1000)
+			cp.begin(lastOffset)
+
+			ctx := context.Background()
+			state := randomSnapshot(lastOffset + 1 + rand.Int64N(1000))
+			wantCounters := maps.Clone(state.counters.getItemsCounters())
+			written := make(chan struct{})
+			mockCheckPointer, _ := deps.CheckPointer.(*mockCheckPointer)
+			mockCheckPointer.EXPECT().dumpState(mock.Anything, mock.Anything).RunAndReturn(
+				func(_ context.Context, snapshot aggregationState) error {
+					<-written
+					assert.Equal(t, state.counters.getLastOffset()-1, snapshot.counters.getLastOffset())
+					assert.Equal(t, wantCounters, snapshot.counters.getItemsCounters())
+					return nil
+				},
+			)
+
+			cp.writeCheckPoint(ctx, state, false)
+
+			// snapshot should not be affected by further aggregation
+			state.counters.updateItemsCount(state.counters.getLastOffset()+1, map[string]int64{faker.UUIDHyphenated(): 1})
+			close(written)
+			cp.pending.Wait()
+			assert.Equal(t, state.counters.getLastOffset()-1, cp.lastOffset)
+			assert.False(t, cp.inProgress)
+			assert.NotNil(t, deps.AggregationState.status.snapshot().CheckPointCreatedAt)
+		})
+		t.Run("should skip check point if previous one is in progress", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp, _ := newLiveCheckPointer(deps).(*liveCheckPointerImpl)
+			cp.begin(0)
+
+			ctx := context.Background()
+			state1 := randomSnapshot(1 + rand.Int64N(1000))
+			written := make(chan struct{})
+			mockCheckPointer, _ := deps.CheckPointer.(*mockCheckPointer)
+			mockCheckPointer.EXPECT().dumpState(mock.Anything, mock.Anything).RunAndReturn(
+				func(_ context.Context, _ aggregationState) error {
+					<-written
+					return nil
+				},
+			).Once()
+
+			// snapshot of the skipped check point should not be taken
+			counters2 := newMockCounters(t)
+			counters2.EXPECT().getLastOffset().Return(state1.counters.getLastOffset() + 1)
+
+			cp.writeCheckPoint(ctx, state1, false)
+			cp.writeCheckPoint(ctx, aggregationState{counters: counters2}, false)
+			close(written)
+			cp.pending.Wait()
+			assert.Equal(t, state1.counters.getLastOffset(), cp.lastOffset)
+		})
+		t.Run("should skip check point if no new messages", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp, _ := newLiveCheckPointer(deps).(*liveCheckPointerImpl)
+			lastOffset := rand.Int64N(1000)
+			cp.begin(lastOffset)
+
+			// snapshot of the skipped check point should not be taken
+			counters := newMockCounters(t)
+			counters.EXPECT().getLastOffset().Return(lastOffset)
+
+			cp.writeCheckPoint(context.Background(), aggregationState{counters: counters}, false)
+			cp.pending.Wait()
+		})
+		t.Run("should keep last offset if failed to write", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp, _ := newLiveCheckPointer(deps).(*liveCheckPointerImpl)
+			lastOffset := rand.Int64N(1000)
+			cp.begin(lastOffset)
+
+			state := randomSnapshot(lastOffset + 1)
+			mockCheckPointer, _ := deps.CheckPointer.(*mockCheckPointer)
+			mockCheckPointer.EXPECT().dumpState(mock.Anything, mock.Anything).Return(errors.New(faker.Sentence()))
+
+			cp.writeCheckPoint(context.Background(), state, false)
+			cp.pending.Wait()
+			assert.Equal(t, lastOffset, cp.lastOffset)
+			assert.False(t, cp.inProgress)
+			assert.Nil(t, deps.AggregationState.status.snapshot().CheckPointCreatedAt)
+		})
+		t.Run("should write final check point after the pending one", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp, _ := newLiveCheckPointer(deps).(*liveCheckPointerImpl)
+			cp.begin(0)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			snapshot1 := randomSnapshot(1 + rand.Int64N(1000))
+			snapshot2 := randomSnapshot(snapshot1.counters.getLastOffset() + 1)
+			written := make(chan struct{})
+			var writtenOffsets []int64
+			mockCheckPointer, _ := deps.CheckPointer.(*mockCheckPointer)
+			mockCheckPointer.EXPECT().dumpState(mock.Anything, mock.Anything).RunAndReturn(
+				func(ctx context.Context, snapshot aggregationState) error {
+					require.NoError(t, ctx.Err())
+					if snapshot.counters.getLastOffset() == snapshot1.counters.getLastOffset() {
+						<-written
+					}
+					writtenOffsets = append(writtenOffsets, snapshot.counters.getLastOffset())
+					return nil
+				},
+			)
+
+			cp.writeCheckPoint(ctx, snapshot1, false)
+			finalDone := make(chan struct{})
+			go func() {
+				cp.writeCheckPoint(ctx, snapshot2, true)
+				close(finalDone)
+			}()
+			close(written)
+			<-finalDone
+			assert.Equal(t, []int64{
+				snapshot1.counters.getLastOffset(),
+				snapshot2.counters.getLastOffset(),
+			}, writtenOffsets)
+			require.NoError(t, cp.shutdown(context.Background()))
+		})
+		t.Run("should skip final check point if no new messages", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp, _ := newLiveCheckPointer(deps).(*liveCheckPointerImpl)
+			lastOffset := rand.Int64N(1000)
+			cp.begin(lastOffset)
+
+			cp.writeCheckPoint(context.Background(), randomSnapshot(lastOffset), true)
+			require.NoError(t, cp.shutdown(context.Background()))
+		})
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		t.Run("should do nothing if not started", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp, _ := newLiveCheckPointer(deps).(*liveCheckPointerImpl)
+			require.NoError(t, cp.shutdown(context.Background()))
+		})
+		t.Run("should return final check point error", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp, _ := newLiveCheckPointer(deps).(*liveCheckPointerImpl)
+			cp.begin(0)
+
+			wantErr := errors.New(faker.Sentence())
+			snapshot := randomSnapshot(1 + rand.Int64N(1000))
+			mockCheckPointer, _ := deps.CheckPointer.(*mockCheckPointer)
+			mockCheckPointer.EXPECT().dumpState(mock.Anything, snapshot).Return(wantErr)
+
+			cp.writeCheckPoint(context.Background(), snapshot, true)
+			require.ErrorIs(t, cp.shutdown(context.Background()), wantErr)
+		})
+		t.Run("should stop waiting for final check point when context is done", func(t *testing.T) {
+			deps := newMockDeps(t)
+			cp, _ := newLiveCheckPointer(deps).(*liveCheckPointerImpl)
+			cp.begin(0)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			require.ErrorIs(t, cp.shutdown(ctx), context.Canceled)
+		})
+	})
+}
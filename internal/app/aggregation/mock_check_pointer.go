@@ -70,6 +70,110 @@ func (_c *mockCheckPointer_dumpState_Call) RunAndReturn(run func(context.Context
 	return _c
 }
 
+// listCheckPoints provides a mock function with given fields: ctx
+func (_m *mockCheckPointer) listCheckPoints(ctx context.Context) ([]checkPointListItem, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for listCheckPoints")
+	}
+
+	var r0 []checkPointListItem
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]checkPointListItem, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []checkPointListItem); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]checkPointListItem)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// mockCheckPointer_listCheckPoints_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'listCheckPoints'
+type mockCheckPointer_listCheckPoints_Call struct {
+	*mock.Call
+}
+
+// listCheckPoints is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *mockCheckPointer_Expecter) listCheckPoints(ctx interface{}) *mockCheckPointer_listCheckPoints_Call {
+	return &mockCheckPointer_listCheckPoints_Call{Call: _e.mock.On("listCheckPoints", ctx)}
+}
+
+func (_c *mockCheckPointer_listCheckPoints_Call) Run(run func(ctx context.Context)) *mockCheckPointer_listCheckPoints_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *mockCheckPointer_listCheckPoints_Call) Return(_a0 []checkPointListItem, _a1 error) *mockCheckPointer_listCheckPoints_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *mockCheckPointer_listCheckPoints_Call) RunAndReturn(run func(context.Context) ([]checkPointListItem, error)) *mockCheckPointer_listCheckPoints_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// pruneCheckPoints provides a mock function with given fields: ctx
+func (_m *mockCheckPointer) pruneCheckPoints(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for pruneCheckPoints")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// mockCheckPointer_pruneCheckPoints_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'pruneCheckPoints'
+type mockCheckPointer_pruneCheckPoints_Call struct {
+	*mock.Call
+}
+
+// pruneCheckPoints is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *mockCheckPointer_Expecter) pruneCheckPoints(ctx interface{}) *mockCheckPointer_pruneCheckPoints_Call {
+	return &mockCheckPointer_pruneCheckPoints_Call{Call: _e.mock.On("pruneCheckPoints", ctx)}
+}
+
+func (_c *mockCheckPointer_pruneCheckPoints_Call) Run(run func(ctx context.Context)) *mockCheckPointer_pruneCheckPoints_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *mockCheckPointer_pruneCheckPoints_Call) Return(_a0 error) *mockCheckPointer_pruneCheckPoints_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *mockCheckPointer_pruneCheckPoints_Call) RunAndReturn(run func(context.Context) error) *mockCheckPointer_pruneCheckPoints_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // restoreState provides a mock function with given fields: ctx, state
 func (_m *mockCheckPointer) restoreState(ctx context.Context, state aggregationState) error {
 	ret := _m.Called(ctx, state)
@@ -117,6 +221,101 @@ func (_c *mockCheckPointer_restoreState_Call) RunAndReturn(run func(context.Cont
 	return _c
 }
 
+// restoreStateAt provides a mock function with given fields: ctx, state, offset
+func (_m *mockCheckPointer) restoreStateAt(ctx context.Context, state aggregationState, offset int64) error {
+	ret := _m.Called(ctx, state, offset)
+
+	if len(ret) == 0 {
+		panic("no return value specified for restoreStateAt")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, aggregationState, int64) error); ok {
+		r0 = rf(ctx, state, offset)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// mockCheckPointer_restoreStateAt_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'restoreStateAt'
+type mockCheckPointer_restoreStateAt_Call struct {
+	*mock.Call
+}
+
+// restoreStateAt is a helper method to define mock.On call
+//   - ctx context.Context
+//   - state aggregationState
+//   - offset int64
+func (_e *mockCheckPointer_Expecter) restoreStateAt(ctx interface{}, state interface{}, offset interface{}) *mockCheckPointer_restoreStateAt_Call {
+	return &mockCheckPointer_restoreStateAt_Call{Call: _e.mock.On("restoreStateAt", ctx, state, offset)}
+}
+
+func (_c *mockCheckPointer_restoreStateAt_Call) Run(run func(ctx context.Context, state aggregationState, offset int64)) *mockCheckPointer_restoreStateAt_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(aggregationState), args[2].(int64))
+	})
+	return _c
+}
+
+func (_c *mockCheckPointer_restoreStateAt_Call) Return(_a0 error) *mockCheckPointer_restoreStateAt_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *mockCheckPointer_restoreStateAt_Call) RunAndReturn(run func(context.Context, aggregationState, int64) error) *mockCheckPointer_restoreStateAt_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// rollbackTo provides a mock function with given fields: ctx, offset
+func (_m *mockCheckPointer) rollbackTo(ctx context.Context, offset int64) error {
+	ret := _m.Called(ctx, offset)
+
+	if len(ret) == 0 {
+		panic("no return value specified for rollbackTo")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, offset)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// mockCheckPointer_rollbackTo_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'rollbackTo'
+type mockCheckPointer_rollbackTo_Call struct {
+	*mock.Call
+}
+
+// rollbackTo is a helper method to define mock.On call
+//   - ctx context.Context
+//   - offset int64
+func (_e *mockCheckPointer_Expecter) rollbackTo(ctx interface{}, offset interface{}) *mockCheckPointer_rollbackTo_Call {
+	return &mockCheckPointer_rollbackTo_Call{Call: _e.mock.On("rollbackTo", ctx, offset)}
+}
+
+func (_c *mockCheckPointer_rollbackTo_Call) Run(run func(ctx context.Context, offset int64)) *mockCheckPointer_rollbackTo_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int64))
+	})
+	return _c
+}
+
+func (_c *mockCheckPointer_rollbackTo_Call) Return(_a0 error) *mockCheckPointer_rollbackTo_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *mockCheckPointer_rollbackTo_Call) RunAndReturn(run func(context.Context, int64) error) *mockCheckPointer_rollbackTo_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // newMockCheckPointer creates a new instance of mockCheckPointer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func newMockCheckPointer(t interface {
@@ -6,6 +6,7 @@ package aggregation
 
 import (
 	context "context"
+	time "time"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -23,29 +24,169 @@ func (_m *mockCheckPointerModel) EXPECT() *mockCheckPointerModel_Expecter {
 	return &mockCheckPointerModel_Expecter{mock: &_m.Mock}
 }
 
-// readCounters provides a mock function with given fields: ctx, blobFileName
-func (_m *mockCheckPointerModel) readCounters(ctx context.Context, blobFileName string) (map[string]int64, error) {
+// acquireLease provides a mock function with given fields: ctx, owner, ttl
+func (_m *mockCheckPointerModel) acquireLease(ctx context.Context, owner string, ttl time.Duration) error {
+	ret := _m.Called(ctx, owner, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for acquireLease")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) error); ok {
+		r0 = rf(ctx, owner, ttl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// mockCheckPointerModel_acquireLease_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'acquireLease'
+type mockCheckPointerModel_acquireLease_Call struct {
+	*mock.Call
+}
+
+// acquireLease is a helper method to define mock.On call
+//   - ctx context.Context
+//   - owner string
+//   - ttl time.Duration
+func (_e *mockCheckPointerModel_Expecter) acquireLease(ctx interface{}, owner interface{}, ttl interface{}) *mockCheckPointerModel_acquireLease_Call {
+	return &mockCheckPointerModel_acquireLease_Call{Call: _e.mock.On("acquireLease", ctx, owner, ttl)}
+}
+
+func (_c *mockCheckPointerModel_acquireLease_Call) Run(run func(ctx context.Context, owner string, ttl time.Duration)) *mockCheckPointerModel_acquireLease_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(time.Duration))
+	})
+	return _c
+}
+
+func (_c *mockCheckPointerModel_acquireLease_Call) Return(_a0 error) *mockCheckPointerModel_acquireLease_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *mockCheckPointerModel_acquireLease_Call) RunAndReturn(run func(context.Context, string, time.Duration) error) *mockCheckPointerModel_acquireLease_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// blobsEncoding provides a mock function with given fields:
+func (_m *mockCheckPointerModel) blobsEncoding() blobsEncoding {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for blobsEncoding")
+	}
+
+	var r0 blobsEncoding
+	if rf, ok := ret.Get(0).(func() blobsEncoding); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(blobsEncoding)
+	}
+
+	return r0
+}
+
+// mockCheckPointerModel_blobsEncoding_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'blobsEncoding'
+type mockCheckPointerModel_blobsEncoding_Call struct {
+	*mock.Call
+}
+
+// blobsEncoding is a helper method to define mock.On call
+func (_e *mockCheckPointerModel_Expecter) blobsEncoding() *mockCheckPointerModel_blobsEncoding_Call {
+	return &mockCheckPointerModel_blobsEncoding_Call{Call: _e.mock.On("blobsEncoding")}
+}
+
+func (_c *mockCheckPointerModel_blobsEncoding_Call) Run(run func()) *mockCheckPointerModel_blobsEncoding_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *mockCheckPointerModel_blobsEncoding_Call) Return(_a0 blobsEncoding) *mockCheckPointerModel_blobsEncoding_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *mockCheckPointerModel_blobsEncoding_Call) RunAndReturn(run func() blobsEncoding) *mockCheckPointerModel_blobsEncoding_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// deleteBlob provides a mock function with given fields: ctx, blobFileName
+func (_m *mockCheckPointerModel) deleteBlob(ctx context.Context, blobFileName string) error {
 	ret := _m.Called(ctx, blobFileName)
 
+	if len(ret) == 0 {
+		panic("no return value specified for deleteBlob")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, blobFileName)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// mockCheckPointerModel_deleteBlob_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'deleteBlob'
+type mockCheckPointerModel_deleteBlob_Call struct {
+	*mock.Call
+}
+
+// deleteBlob is a helper method to define mock.On call
+//   - ctx context.Context
+//   - blobFileName string
+func (_e *mockCheckPointerModel_Expecter) deleteBlob(ctx interface{}, blobFileName interface{}) *mockCheckPointerModel_deleteBlob_Call {
+	return &mockCheckPointerModel_deleteBlob_Call{Call: _e.mock.On("deleteBlob", ctx, blobFileName)}
+}
+
+func (_c *mockCheckPointerModel_deleteBlob_Call) Run(run func(ctx context.Context, blobFileName string)) *mockCheckPointerModel_deleteBlob_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *mockCheckPointerModel_deleteBlob_Call) Return(_a0 error) *mockCheckPointerModel_deleteBlob_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *mockCheckPointerModel_deleteBlob_Call) RunAndReturn(run func(context.Context, string) error) *mockCheckPointerModel_deleteBlob_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// readCounters provides a mock function with given fields: ctx, blobFileName, encoding
+func (_m *mockCheckPointerModel) readCounters(ctx context.Context, blobFileName string, encoding blobsEncoding) (map[string]int64, error) {
+	ret := _m.Called(ctx, blobFileName, encoding)
+
 	if len(ret) == 0 {
 		panic("no return value specified for readCounters")
 	}
 
 	var r0 map[string]int64
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) (map[string]int64, error)); ok {
-		return rf(ctx, blobFileName)
+	if rf, ok := ret.Get(0).(func(context.Context, string, blobsEncoding) (map[string]int64, error)); ok {
+		return rf(ctx, blobFileName, encoding)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) map[string]int64); ok {
-		r0 = rf(ctx, blobFileName)
+	if rf, ok := ret.Get(0).(func(context.Context, string, blobsEncoding) map[string]int64); ok {
+		r0 = rf(ctx, blobFileName, encoding)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(map[string]int64)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, blobFileName)
+	if rf, ok := ret.Get(1).(func(context.Context, string, blobsEncoding) error); ok {
+		r1 = rf(ctx, blobFileName, encoding)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -61,13 +202,14 @@ type mockCheckPointerModel_readCounters_Call struct {
 // readCounters is a helper method to define mock.On call
 //   - ctx context.Context
 //   - blobFileName string
-func (_e *mockCheckPointerModel_Expecter) readCounters(ctx interface{}, blobFileName interface{}) *mockCheckPointerModel_readCounters_Call {
-	return &mockCheckPointerModel_readCounters_Call{Call: _e.mock.On("readCounters", ctx, blobFileName)}
+//   - encoding blobsEncoding
+func (_e *mockCheckPointerModel_Expecter) readCounters(ctx interface{}, blobFileName interface{}, encoding interface{}) *mockCheckPointerModel_readCounters_Call {
+	return &mockCheckPointerModel_readCounters_Call{Call: _e.mock.On("readCounters", ctx, blobFileName, encoding)}
 }
 
-func (_c *mockCheckPointerModel_readCounters_Call) Run(run func(ctx context.Context, blobFileName string)) *mockCheckPointerModel_readCounters_Call {
+func (_c *mockCheckPointerModel_readCounters_Call) Run(run func(ctx context.Context, blobFileName string, encoding blobsEncoding)) *mockCheckPointerModel_readCounters_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(string))
+		run(args[0].(context.Context), args[1].(string), args[2].(blobsEncoding))
 	})
 	return _c
 }
@@ -77,14 +219,14 @@ func (_c *mockCheckPointerModel_readCounters_Call) Return(_a0 map[string]int64,
 	return _c
 }
 
-func (_c *mockCheckPointerModel_readCounters_Call) RunAndReturn(run func(context.Context, string) (map[string]int64, error)) *mockCheckPointerModel_readCounters_Call {
+func (_c *mockCheckPointerModel_readCounters_Call) RunAndReturn(run func(context.Context, string, blobsEncoding) (map[string]int64, error)) *mockCheckPointerModel_readCounters_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// readItems provides a mock function with given fields: ctx, blobFileName
-func (_m *mockCheckPointerModel) readItems(ctx context.Context, blobFileName string) ([]*topKItem, error) {
-	ret := _m.Called(ctx, blobFileName)
+// readItems provides a mock function with given fields: ctx, blobFileName, encoding
+func (_m *mockCheckPointerModel) readItems(ctx context.Context, blobFileName string, encoding blobsEncoding) ([]*topKItem, error) {
+	ret := _m.Called(ctx, blobFileName, encoding)
 
 	if len(ret) == 0 {
 		panic("no return value specified for readItems")
@@ -92,19 +234,19 @@ func (_m *mockCheckPointerModel) readItems(ctx context.Context, blobFileName str
 
 	var r0 []*topKItem
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*topKItem, error)); ok {
-		return rf(ctx, blobFileName)
+	if rf, ok := ret.Get(0).(func(context.Context, string, blobsEncoding) ([]*topKItem, error)); ok {
+		return rf(ctx, blobFileName, encoding)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) []*topKItem); ok {
-		r0 = rf(ctx, blobFileName)
+	if rf, ok := ret.Get(0).(func(context.Context, string, blobsEncoding) []*topKItem); ok {
+		r0 = rf(ctx, blobFileName, encoding)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*topKItem)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, blobFileName)
+	if rf, ok := ret.Get(1).(func(context.Context, string, blobsEncoding) error); ok {
+		r1 = rf(ctx, blobFileName, encoding)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -120,13 +262,14 @@ type mockCheckPointerModel_readItems_Call struct {
 // readItems is a helper method to define mock.On call
 //   - ctx context.Context
 //   - blobFileName string
-func (_e *mockCheckPointerModel_Expecter) readItems(ctx interface{}, blobFileName interface{}) *mockCheckPointerModel_readItems_Call {
-	return &mockCheckPointerModel_readItems_Call{Call: _e.mock.On("readItems", ctx, blobFileName)}
+//   - encoding blobsEncoding
+func (_e *mockCheckPointerModel_Expecter) readItems(ctx interface{}, blobFileName interface{}, encoding interface{}) *mockCheckPointerModel_readItems_Call {
+	return &mockCheckPointerModel_readItems_Call{Call: _e.mock.On("readItems", ctx, blobFileName, encoding)}
 }
 
-func (_c *mockCheckPointerModel_readItems_Call) Run(run func(ctx context.Context, blobFileName string)) *mockCheckPointerModel_readItems_Call {
+func (_c *mockCheckPointerModel_readItems_Call) Run(run func(ctx context.Context, blobFileName string, encoding blobsEncoding)) *mockCheckPointerModel_readItems_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(string))
+		run(args[0].(context.Context), args[1].(string), args[2].(blobsEncoding))
 	})
 	return _c
 }
@@ -136,7 +279,7 @@ func (_c *mockCheckPointerModel_readItems_Call) Return(_a0 []*topKItem, _a1 erro
 	return _c
 }
 
-func (_c *mockCheckPointerModel_readItems_Call) RunAndReturn(run func(context.Context, string) ([]*topKItem, error)) *mockCheckPointerModel_readItems_Call {
+func (_c *mockCheckPointerModel_readItems_Call) RunAndReturn(run func(context.Context, string, blobsEncoding) ([]*topKItem, error)) *mockCheckPointerModel_readItems_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -197,6 +340,109 @@ func (_c *mockCheckPointerModel_readManifest_Call) RunAndReturn(run func(context
 	return _c
 }
 
+// readManifestHistory provides a mock function with given fields: ctx
+func (_m *mockCheckPointerModel) readManifestHistory(ctx context.Context) (checkPointManifestHistory, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for readManifestHistory")
+	}
+
+	var r0 checkPointManifestHistory
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (checkPointManifestHistory, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) checkPointManifestHistory); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(checkPointManifestHistory)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// mockCheckPointerModel_readManifestHistory_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'readManifestHistory'
+type mockCheckPointerModel_readManifestHistory_Call struct {
+	*mock.Call
+}
+
+// readManifestHistory is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *mockCheckPointerModel_Expecter) readManifestHistory(ctx interface{}) *mockCheckPointerModel_readManifestHistory_Call {
+	return &mockCheckPointerModel_readManifestHistory_Call{Call: _e.mock.On("readManifestHistory", ctx)}
+}
+
+func (_c *mockCheckPointerModel_readManifestHistory_Call) Run(run func(ctx context.Context)) *mockCheckPointerModel_readManifestHistory_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *mockCheckPointerModel_readManifestHistory_Call) Return(_a0 checkPointManifestHistory, _a1 error) *mockCheckPointerModel_readManifestHistory_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *mockCheckPointerModel_readManifestHistory_Call) RunAndReturn(run func(context.Context) (checkPointManifestHistory, error)) *mockCheckPointerModel_readManifestHistory_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// releaseLease provides a mock function with given fields: ctx, owner
+func (_m *mockCheckPointerModel) releaseLease(ctx context.Context, owner string) error {
+	ret := _m.Called(ctx, owner)
+
+	if len(ret) == 0 {
+		panic("no return value specified for releaseLease")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, owner)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// mockCheckPointerModel_releaseLease_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'releaseLease'
+type mockCheckPointerModel_releaseLease_Call struct {
+	*mock.Call
+}
+
+// releaseLease is a helper method to define mock.On call
+//   - ctx context.Context
+//   - owner string
+func (_e *mockCheckPointerModel_Expecter) releaseLease(ctx interface{}, owner interface{}) *mockCheckPointerModel_releaseLease_Call {
+	return &mockCheckPointerModel_releaseLease_Call{Call: _e.mock.On("releaseLease", ctx, owner)}
+}
+
+func (_c *mockCheckPointerModel_releaseLease_Call) Run(run func(ctx context.Context, owner string)) *mockCheckPointerModel_releaseLease_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *mockCheckPointerModel_releaseLease_Call) Return(_a0 error) *mockCheckPointerModel_releaseLease_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *mockCheckPointerModel_releaseLease_Call) RunAndReturn(run func(context.Context, string) error) *mockCheckPointerModel_releaseLease_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // writeCounters provides a mock function with given fields: ctx, blobFileName, val
 func (_m *mockCheckPointerModel) writeCounters(ctx context.Context, blobFileName string, val map[string]int64) error {
 	ret := _m.Called(ctx, blobFileName, val)
@@ -340,6 +586,53 @@ func (_c *mockCheckPointerModel_writeManifest_Call) RunAndReturn(run func(contex
 	return _c
 }
 
+// writeManifestHistory provides a mock function with given fields: ctx, history
+func (_m *mockCheckPointerModel) writeManifestHistory(ctx context.Context, history checkPointManifestHistory) error {
+	ret := _m.Called(ctx, history)
+
+	if len(ret) == 0 {
+		panic("no return value specified for writeManifestHistory")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, checkPointManifestHistory) error); ok {
+		r0 = rf(ctx, history)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// mockCheckPointerModel_writeManifestHistory_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'writeManifestHistory'
+type mockCheckPointerModel_writeManifestHistory_Call struct {
+	*mock.Call
+}
+
+// writeManifestHistory is a helper method to define mock.On call
+//   - ctx context.Context
+//   - history checkPointManifestHistory
+func (_e *mockCheckPointerModel_Expecter) writeManifestHistory(ctx interface{}, history interface{}) *mockCheckPointerModel_writeManifestHistory_Call {
+	return &mockCheckPointerModel_writeManifestHistory_Call{Call: _e.mock.On("writeManifestHistory", ctx, history)}
+}
+
+func (_c *mockCheckPointerModel_writeManifestHistory_Call) Run(run func(ctx context.Context, history checkPointManifestHistory)) *mockCheckPointerModel_writeManifestHistory_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(checkPointManifestHistory))
+	})
+	return _c
+}
+
+func (_c *mockCheckPointerModel_writeManifestHistory_Call) Return(_a0 error) *mockCheckPointerModel_writeManifestHistory_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *mockCheckPointerModel_writeManifestHistory_Call) RunAndReturn(run func(context.Context, checkPointManifestHistory) error) *mockCheckPointerModel_writeManifestHistory_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // newMockCheckPointerModel creates a new instance of mockCheckPointerModel. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func newMockCheckPointerModel(t interface {
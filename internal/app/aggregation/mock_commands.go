@@ -69,6 +69,370 @@ func (_c *MockCommands_CreateCheckPoint_Call) RunAndReturn(run func(context.Cont
 	return _c
 }
 
+// ExportCheckPoint provides a mock function with given fields: ctx, offset, write
+func (_m *MockCommands) ExportCheckPoint(ctx context.Context, offset int64, write func(string, int64) error) error {
+	ret := _m.Called(ctx, offset, write)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExportCheckPoint")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, func(string, int64) error) error); ok {
+		r0 = rf(ctx, offset, write)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockCommands_ExportCheckPoint_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ExportCheckPoint'
+type MockCommands_ExportCheckPoint_Call struct {
+	*mock.Call
+}
+
+// ExportCheckPoint is a helper method to define mock.On call
+//   - ctx context.Context
+//   - offset int64
+//   - write func(string , int64) error
+func (_e *MockCommands_Expecter) ExportCheckPoint(ctx interface{}, offset interface{}, write interface{}) *MockCommands_ExportCheckPoint_Call {
+	return &MockCommands_ExportCheckPoint_Call{Call: _e.mock.On("ExportCheckPoint", ctx, offset, write)}
+}
+
+func (_c *MockCommands_ExportCheckPoint_Call) Run(run func(ctx context.Context, offset int64, write func(string, int64) error)) *MockCommands_ExportCheckPoint_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int64), args[2].(func(string, int64) error))
+	})
+	return _c
+}
+
+func (_c *MockCommands_ExportCheckPoint_Call) Return(_a0 error) *MockCommands_ExportCheckPoint_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockCommands_ExportCheckPoint_Call) RunAndReturn(run func(context.Context, int64, func(string, int64) error) error) *MockCommands_ExportCheckPoint_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ImportCheckPoint provides a mock function with given fields: ctx, params
+func (_m *MockCommands) ImportCheckPoint(ctx context.Context, params ImportCheckPointParams) error {
+	ret := _m.Called(ctx, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ImportCheckPoint")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, ImportCheckPointParams) error); ok {
+		r0 = rf(ctx, params)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockCommands_ImportCheckPoint_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ImportCheckPoint'
+type MockCommands_ImportCheckPoint_Call struct {
+	*mock.Call
+}
+
+// ImportCheckPoint is a helper method to define mock.On call
+//   - ctx context.Context
+//   - params ImportCheckPointParams
+func (_e *MockCommands_Expecter) ImportCheckPoint(ctx interface{}, params interface{}) *MockCommands_ImportCheckPoint_Call {
+	return &MockCommands_ImportCheckPoint_Call{Call: _e.mock.On("ImportCheckPoint", ctx, params)}
+}
+
+func (_c *MockCommands_ImportCheckPoint_Call) Run(run func(ctx context.Context, params ImportCheckPointParams)) *MockCommands_ImportCheckPoint_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(ImportCheckPointParams))
+	})
+	return _c
+}
+
+func (_c *MockCommands_ImportCheckPoint_Call) Return(_a0 error) *MockCommands_ImportCheckPoint_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockCommands_ImportCheckPoint_Call) RunAndReturn(run func(context.Context, ImportCheckPointParams) error) *MockCommands_ImportCheckPoint_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// InspectCheckPoint provides a mock function with given fields: ctx, params
+func (_m *MockCommands) InspectCheckPoint(ctx context.Context, params InspectCheckPointParams) (*CheckPointDetails, error) {
+	ret := _m.Called(ctx, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for InspectCheckPoint")
+	}
+
+	var r0 *CheckPointDetails
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, InspectCheckPointParams) (*CheckPointDetails, error)); ok {
+		return rf(ctx, params)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, InspectCheckPointParams) *CheckPointDetails); ok {
+		r0 = rf(ctx, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*CheckPointDetails)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, InspectCheckPointParams) error); ok {
+		r1 = rf(ctx, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockCommands_InspectCheckPoint_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'InspectCheckPoint'
+type MockCommands_InspectCheckPoint_Call struct {
+	*mock.Call
+}
+
+// InspectCheckPoint is a helper method to define mock.On call
+//   - ctx context.Context
+//   - params InspectCheckPointParams
+func (_e *MockCommands_Expecter) InspectCheckPoint(ctx interface{}, params interface{}) *MockCommands_InspectCheckPoint_Call {
+	return &MockCommands_InspectCheckPoint_Call{Call: _e.mock.On("InspectCheckPoint", ctx, params)}
+}
+
+func (_c *MockCommands_InspectCheckPoint_Call) Run(run func(ctx context.Context, params InspectCheckPointParams)) *MockCommands_InspectCheckPoint_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(InspectCheckPointParams))
+	})
+	return _c
+}
+
+func (_c *MockCommands_InspectCheckPoint_Call) Return(_a0 *CheckPointDetails, _a1 error) *MockCommands_InspectCheckPoint_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockCommands_InspectCheckPoint_Call) RunAndReturn(run func(context.Context, InspectCheckPointParams) (*CheckPointDetails, error)) *MockCommands_InspectCheckPoint_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListCheckPoints provides a mock function with given fields: ctx
+func (_m *MockCommands) ListCheckPoints(ctx context.Context) ([]CheckPoint, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListCheckPoints")
+	}
+
+	var r0 []CheckPoint
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]CheckPoint, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []CheckPoint); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]CheckPoint)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockCommands_ListCheckPoints_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListCheckPoints'
+type MockCommands_ListCheckPoints_Call struct {
+	*mock.Call
+}
+
+// ListCheckPoints is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockCommands_Expecter) ListCheckPoints(ctx interface{}) *MockCommands_ListCheckPoints_Call {
+	return &MockCommands_ListCheckPoints_Call{Call: _e.mock.On("ListCheckPoints", ctx)}
+}
+
+func (_c *MockCommands_ListCheckPoints_Call) Run(run func(ctx context.Context)) *MockCommands_ListCheckPoints_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockCommands_ListCheckPoints_Call) Return(_a0 []CheckPoint, _a1 error) *MockCommands_ListCheckPoints_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockCommands_ListCheckPoints_Call) RunAndReturn(run func(context.Context) ([]CheckPoint, error)) *MockCommands_ListCheckPoints_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PruneCheckPoints provides a mock function with given fields: ctx
+func (_m *MockCommands) PruneCheckPoints(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PruneCheckPoints")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockCommands_PruneCheckPoints_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PruneCheckPoints'
+type MockCommands_PruneCheckPoints_Call struct {
+	*mock.Call
+}
+
+// PruneCheckPoints is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockCommands_Expecter) PruneCheckPoints(ctx interface{}) *MockCommands_PruneCheckPoints_Call {
+	return &MockCommands_PruneCheckPoints_Call{Call: _e.mock.On("PruneCheckPoints", ctx)}
+}
+
+func (_c *MockCommands_PruneCheckPoints_Call) Run(run func(ctx context.Context)) *MockCommands_PruneCheckPoints_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockCommands_PruneCheckPoints_Call) Return(_a0 error) *MockCommands_PruneCheckPoints_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockCommands_PruneCheckPoints_Call) RunAndReturn(run func(context.Context) error) *MockCommands_PruneCheckPoints_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RebuildCheckPoint provides a mock function with given fields: ctx, params
+func (_m *MockCommands) RebuildCheckPoint(ctx context.Context, params RebuildCheckPointParams) (*RebuildCheckPointResult, error) {
+	ret := _m.Called(ctx, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RebuildCheckPoint")
+	}
+
+	var r0 *RebuildCheckPointResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, RebuildCheckPointParams) (*RebuildCheckPointResult, error)); ok {
+		return rf(ctx, params)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, RebuildCheckPointParams) *RebuildCheckPointResult); ok {
+		r0 = rf(ctx, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*RebuildCheckPointResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, RebuildCheckPointParams) error); ok {
+		r1 = rf(ctx, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockCommands_RebuildCheckPoint_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RebuildCheckPoint'
+type MockCommands_RebuildCheckPoint_Call struct {
+	*mock.Call
+}
+
+// RebuildCheckPoint is a helper method to define mock.On call
+//   - ctx context.Context
+//   - params RebuildCheckPointParams
+func (_e *MockCommands_Expecter) RebuildCheckPoint(ctx interface{}, params interface{}) *MockCommands_RebuildCheckPoint_Call {
+	return &MockCommands_RebuildCheckPoint_Call{Call: _e.mock.On("RebuildCheckPoint", ctx, params)}
+}
+
+func (_c *MockCommands_RebuildCheckPoint_Call) Run(run func(ctx context.Context, params RebuildCheckPointParams)) *MockCommands_RebuildCheckPoint_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(RebuildCheckPointParams))
+	})
+	return _c
+}
+
+func (_c *MockCommands_RebuildCheckPoint_Call) Return(_a0 *RebuildCheckPointResult, _a1 error) *MockCommands_RebuildCheckPoint_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockCommands_RebuildCheckPoint_Call) RunAndReturn(run func(context.Context, RebuildCheckPointParams) (*RebuildCheckPointResult, error)) *MockCommands_RebuildCheckPoint_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RollbackCheckPoint provides a mock function with given fields: ctx, offset
+func (_m *MockCommands) RollbackCheckPoint(ctx context.Context, offset int64) error {
+	ret := _m.Called(ctx, offset)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RollbackCheckPoint")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, offset)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockCommands_RollbackCheckPoint_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RollbackCheckPoint'
+type MockCommands_RollbackCheckPoint_Call struct {
+	*mock.Call
+}
+
+// RollbackCheckPoint is a helper method to define mock.On call
+//   - ctx context.Context
+//   - offset int64
+func (_e *MockCommands_Expecter) RollbackCheckPoint(ctx interface{}, offset interface{}) *MockCommands_RollbackCheckPoint_Call {
+	return &MockCommands_RollbackCheckPoint_Call{Call: _e.mock.On("RollbackCheckPoint", ctx, offset)}
+}
+
+func (_c *MockCommands_RollbackCheckPoint_Call) Run(run func(ctx context.Context, offset int64)) *MockCommands_RollbackCheckPoint_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int64))
+	})
+	return _c
+}
+
+func (_c *MockCommands_RollbackCheckPoint_Call) Return(_a0 error) *MockCommands_RollbackCheckPoint_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockCommands_RollbackCheckPoint_Call) RunAndReturn(run func(context.Context, int64) error) *MockCommands_RollbackCheckPoint_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // StartAggregator provides a mock function with given fields: ctx
 func (_m *MockCommands) StartAggregator(ctx context.Context) error {
 	ret := _m.Called(ctx)
@@ -115,6 +479,65 @@ func (_c *MockCommands_StartAggregator_Call) RunAndReturn(run func(context.Conte
 	return _c
 }
 
+// VerifyCheckPoint provides a mock function with given fields: ctx, params
+func (_m *MockCommands) VerifyCheckPoint(ctx context.Context, params VerifyCheckPointParams) (*VerifyCheckPointResult, error) {
+	ret := _m.Called(ctx, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for VerifyCheckPoint")
+	}
+
+	var r0 *VerifyCheckPointResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, VerifyCheckPointParams) (*VerifyCheckPointResult, error)); ok {
+		return rf(ctx, params)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, VerifyCheckPointParams) *VerifyCheckPointResult); ok {
+		r0 = rf(ctx, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*VerifyCheckPointResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, VerifyCheckPointParams) error); ok {
+		r1 = rf(ctx, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockCommands_VerifyCheckPoint_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'VerifyCheckPoint'
+type MockCommands_VerifyCheckPoint_Call struct {
+	*mock.Call
+}
+
+// VerifyCheckPoint is a helper method to define mock.On call
+//   - ctx context.Context
+//   - params VerifyCheckPointParams
+func (_e *MockCommands_Expecter) VerifyCheckPoint(ctx interface{}, params interface{}) *MockCommands_VerifyCheckPoint_Call {
+	return &MockCommands_VerifyCheckPoint_Call{Call: _e.mock.On("VerifyCheckPoint", ctx, params)}
+}
+
+func (_c *MockCommands_VerifyCheckPoint_Call) Run(run func(ctx context.Context, params VerifyCheckPointParams)) *MockCommands_VerifyCheckPoint_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(VerifyCheckPointParams))
+	})
+	return _c
+}
+
+func (_c *MockCommands_VerifyCheckPoint_Call) Return(_a0 *VerifyCheckPointResult, _a1 error) *MockCommands_VerifyCheckPoint_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockCommands_VerifyCheckPoint_Call) RunAndReturn(run func(context.Context, VerifyCheckPointParams) (*VerifyCheckPointResult, error)) *MockCommands_VerifyCheckPoint_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockCommands creates a new instance of MockCommands. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockCommands(t interface {
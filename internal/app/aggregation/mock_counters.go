@@ -19,6 +19,110 @@ func (_m *mockCounters) EXPECT() *mockCounters_Expecter {
 	return &mockCounters_Expecter{mock: &_m.Mock}
 }
 
+// getItemCount provides a mock function with given fields: itemID
+func (_m *mockCounters) getItemCount(itemID string) (int64, bool) {
+	ret := _m.Called(itemID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for getItemCount")
+	}
+
+	var r0 int64
+	var r1 bool
+	if rf, ok := ret.Get(0).(func(string) (int64, bool)); ok {
+		return rf(itemID)
+	}
+	if rf, ok := ret.Get(0).(func(string) int64); ok {
+		r0 = rf(itemID)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(itemID)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// mockCounters_getItemCount_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'getItemCount'
+type mockCounters_getItemCount_Call struct {
+	*mock.Call
+}
+
+// getItemCount is a helper method to define mock.On call
+//   - itemID string
+func (_e *mockCounters_Expecter) getItemCount(itemID interface{}) *mockCounters_getItemCount_Call {
+	return &mockCounters_getItemCount_Call{Call: _e.mock.On("getItemCount", itemID)}
+}
+
+func (_c *mockCounters_getItemCount_Call) Run(run func(itemID string)) *mockCounters_getItemCount_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *mockCounters_getItemCount_Call) Return(_a0 int64, _a1 bool) *mockCounters_getItemCount_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *mockCounters_getItemCount_Call) RunAndReturn(run func(string) (int64, bool)) *mockCounters_getItemCount_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// getItemsCount provides a mock function with given fields: itemIDs
+func (_m *mockCounters) getItemsCount(itemIDs []string) map[string]int64 {
+	ret := _m.Called(itemIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for getItemsCount")
+	}
+
+	var r0 map[string]int64
+	if rf, ok := ret.Get(0).(func([]string) map[string]int64); ok {
+		r0 = rf(itemIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]int64)
+		}
+	}
+
+	return r0
+}
+
+// mockCounters_getItemsCount_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'getItemsCount'
+type mockCounters_getItemsCount_Call struct {
+	*mock.Call
+}
+
+// getItemsCount is a helper method to define mock.On call
+//   - itemIDs []string
+func (_e *mockCounters_Expecter) getItemsCount(itemIDs interface{}) *mockCounters_getItemsCount_Call {
+	return &mockCounters_getItemsCount_Call{Call: _e.mock.On("getItemsCount", itemIDs)}
+}
+
+func (_c *mockCounters_getItemsCount_Call) Run(run func(itemIDs []string)) *mockCounters_getItemsCount_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].([]string))
+	})
+	return _c
+}
+
+func (_c *mockCounters_getItemsCount_Call) Return(_a0 map[string]int64) *mockCounters_getItemsCount_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *mockCounters_getItemsCount_Call) RunAndReturn(run func([]string) map[string]int64) *mockCounters_getItemsCount_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // getItemsCounters provides a mock function with given fields:
 func (_m *mockCounters) getItemsCounters() map[string]int64 {
 	ret := _m.Called()
@@ -9,6 +9,8 @@ import (
 
 	models "github.com/gemyago/top-k-system-go/internal/app/models"
 	mock "github.com/stretchr/testify/mock"
+
+	trace "go.opentelemetry.io/otel/trace"
 )
 
 // mockItemEventsAggregatorModel is an autogenerated mock type for the itemEventsAggregatorModel type
@@ -24,9 +26,22 @@ func (_m *mockItemEventsAggregatorModel) EXPECT() *mockItemEventsAggregatorModel
 	return &mockItemEventsAggregatorModel_Expecter{mock: &_m.Mock}
 }
 
-// aggregateItemEvent provides a mock function with given fields: offset, evt
-func (_m *mockItemEventsAggregatorModel) aggregateItemEvent(offset int64, evt *models.ItemEvent) {
-	_m.Called(offset, evt)
+// aggregateItemEvent provides a mock function with given fields: offset, evt, spanContext
+func (_m *mockItemEventsAggregatorModel) aggregateItemEvent(offset int64, evt *models.ItemEvent, spanContext trace.SpanContext) bool {
+	ret := _m.Called(offset, evt, spanContext)
+
+	if len(ret) == 0 {
+		panic("no return value specified for aggregateItemEvent")
+	}
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(int64, *models.ItemEvent, trace.SpanContext) bool); ok {
+		r0 = rf(offset, evt, spanContext)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
 }
 
 // mockItemEventsAggregatorModel_aggregateItemEvent_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'aggregateItemEvent'
@@ -37,23 +52,24 @@ type mockItemEventsAggregatorModel_aggregateItemEvent_Call struct {
 // aggregateItemEvent is a helper method to define mock.On call
 //   - offset int64
 //   - evt *models.ItemEvent
-func (_e *mockItemEventsAggregatorModel_Expecter) aggregateItemEvent(offset interface{}, evt interface{}) *mockItemEventsAggregatorModel_aggregateItemEvent_Call {
-	return &mockItemEventsAggregatorModel_aggregateItemEvent_Call{Call: _e.mock.On("aggregateItemEvent", offset, evt)}
+//   - spanContext trace.SpanContext
+func (_e *mockItemEventsAggregatorModel_Expecter) aggregateItemEvent(offset interface{}, evt interface{}, spanContext interface{}) *mockItemEventsAggregatorModel_aggregateItemEvent_Call {
+	return &mockItemEventsAggregatorModel_aggregateItemEvent_Call{Call: _e.mock.On("aggregateItemEvent", offset, evt, spanContext)}
 }
 
-func (_c *mockItemEventsAggregatorModel_aggregateItemEvent_Call) Run(run func(offset int64, evt *models.ItemEvent)) *mockItemEventsAggregatorModel_aggregateItemEvent_Call {
+func (_c *mockItemEventsAggregatorModel_aggregateItemEvent_Call) Run(run func(offset int64, evt *models.ItemEvent, spanContext trace.SpanContext)) *mockItemEventsAggregatorModel_aggregateItemEvent_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(int64), args[1].(*models.ItemEvent))
+		run(args[0].(int64), args[1].(*models.ItemEvent), args[2].(trace.SpanContext))
 	})
 	return _c
 }
 
-func (_c *mockItemEventsAggregatorModel_aggregateItemEvent_Call) Return() *mockItemEventsAggregatorModel_aggregateItemEvent_Call {
-	_c.Call.Return()
+func (_c *mockItemEventsAggregatorModel_aggregateItemEvent_Call) Return(_a0 bool) *mockItemEventsAggregatorModel_aggregateItemEvent_Call {
+	_c.Call.Return(_a0)
 	return _c
 }
 
-func (_c *mockItemEventsAggregatorModel_aggregateItemEvent_Call) RunAndReturn(run func(int64, *models.ItemEvent)) *mockItemEventsAggregatorModel_aggregateItemEvent_Call {
+func (_c *mockItemEventsAggregatorModel_aggregateItemEvent_Call) RunAndReturn(run func(int64, *models.ItemEvent, trace.SpanContext) bool) *mockItemEventsAggregatorModel_aggregateItemEvent_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -141,6 +157,40 @@ func (_c *mockItemEventsAggregatorModel_flushMessages_Call) RunAndReturn(run fun
 	return _c
 }
 
+// updateOffsetLag provides a mock function with given fields: ctx, state
+func (_m *mockItemEventsAggregatorModel) updateOffsetLag(ctx context.Context, state aggregationState) {
+	_m.Called(ctx, state)
+}
+
+// mockItemEventsAggregatorModel_updateOffsetLag_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'updateOffsetLag'
+type mockItemEventsAggregatorModel_updateOffsetLag_Call struct {
+	*mock.Call
+}
+
+// updateOffsetLag is a helper method to define mock.On call
+//   - ctx context.Context
+//   - state aggregationState
+func (_e *mockItemEventsAggregatorModel_Expecter) updateOffsetLag(ctx interface{}, state interface{}) *mockItemEventsAggregatorModel_updateOffsetLag_Call {
+	return &mockItemEventsAggregatorModel_updateOffsetLag_Call{Call: _e.mock.On("updateOffsetLag", ctx, state)}
+}
+
+func (_c *mockItemEventsAggregatorModel_updateOffsetLag_Call) Run(run func(ctx context.Context, state aggregationState)) *mockItemEventsAggregatorModel_updateOffsetLag_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(aggregationState))
+	})
+	return _c
+}
+
+func (_c *mockItemEventsAggregatorModel_updateOffsetLag_Call) Return() *mockItemEventsAggregatorModel_updateOffsetLag_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *mockItemEventsAggregatorModel_updateOffsetLag_Call) RunAndReturn(run func(context.Context, aggregationState)) *mockItemEventsAggregatorModel_updateOffsetLag_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // newMockItemEventsAggregatorModel creates a new instance of mockItemEventsAggregatorModel. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func newMockItemEventsAggregatorModel(t interface {
@@ -0,0 +1,106 @@
+// Code generated by mockery. DO NOT EDIT.
+
+//go:build !release
+
+package aggregation
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// mockLiveCheckPointer is an autogenerated mock type for the liveCheckPointer type
+type mockLiveCheckPointer struct {
+	mock.Mock
+}
+
+type mockLiveCheckPointer_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *mockLiveCheckPointer) EXPECT() *mockLiveCheckPointer_Expecter {
+	return &mockLiveCheckPointer_Expecter{mock: &_m.Mock}
+}
+
+// begin provides a mock function with given fields: lastOffset
+func (_m *mockLiveCheckPointer) begin(lastOffset int64) {
+	_m.Called(lastOffset)
+}
+
+// mockLiveCheckPointer_begin_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'begin'
+type mockLiveCheckPointer_begin_Call struct {
+	*mock.Call
+}
+
+// begin is a helper method to define mock.On call
+//   - lastOffset int64
+func (_e *mockLiveCheckPointer_Expecter) begin(lastOffset interface{}) *mockLiveCheckPointer_begin_Call {
+	return &mockLiveCheckPointer_begin_Call{Call: _e.mock.On("begin", lastOffset)}
+}
+
+func (_c *mockLiveCheckPointer_begin_Call) Run(run func(lastOffset int64)) *mockLiveCheckPointer_begin_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(int64))
+	})
+	return _c
+}
+
+func (_c *mockLiveCheckPointer_begin_Call) Return() *mockLiveCheckPointer_begin_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *mockLiveCheckPointer_begin_Call) RunAndReturn(run func(int64)) *mockLiveCheckPointer_begin_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// writeCheckPoint provides a mock function with given fields: ctx, state, final
+func (_m *mockLiveCheckPointer) writeCheckPoint(ctx context.Context, state aggregationState, final bool) {
+	_m.Called(ctx, state, final)
+}
+
+// mockLiveCheckPointer_writeCheckPoint_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'writeCheckPoint'
+type mockLiveCheckPointer_writeCheckPoint_Call struct {
+	*mock.Call
+}
+
+// writeCheckPoint is a helper method to define mock.On call
+//   - ctx context.Context
+//   - state aggregationState
+//   - final bool
+func (_e *mockLiveCheckPointer_Expecter) writeCheckPoint(ctx interface{}, state interface{}, final interface{}) *mockLiveCheckPointer_writeCheckPoint_Call {
+	return &mockLiveCheckPointer_writeCheckPoint_Call{Call: _e.mock.On("writeCheckPoint", ctx, state, final)}
+}
+
+func (_c *mockLiveCheckPointer_writeCheckPoint_Call) Run(run func(ctx context.Context, state aggregationState, final bool)) *mockLiveCheckPointer_writeCheckPoint_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(aggregationState), args[2].(bool))
+	})
+	return _c
+}
+
+func (_c *mockLiveCheckPointer_writeCheckPoint_Call) Return() *mockLiveCheckPointer_writeCheckPoint_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *mockLiveCheckPointer_writeCheckPoint_Call) RunAndReturn(run func(context.Context, aggregationState, bool)) *mockLiveCheckPointer_writeCheckPoint_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// newMockLiveCheckPointer creates a new instance of mockLiveCheckPointer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func newMockLiveCheckPointer(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *mockLiveCheckPointer {
+	mock := &mockLiveCheckPointer{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -23,6 +23,300 @@ func (_m *MockQueries) EXPECT() *MockQueries_Expecter {
 	return &MockQueries_Expecter{mock: &_m.Mock}
 }
 
+// GetAggregationStatus provides a mock function with given fields: _a0
+func (_m *MockQueries) GetAggregationStatus(_a0 context.Context) (*AggregationStatus, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAggregationStatus")
+	}
+
+	var r0 *AggregationStatus
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (*AggregationStatus, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) *AggregationStatus); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*AggregationStatus)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockQueries_GetAggregationStatus_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetAggregationStatus'
+type MockQueries_GetAggregationStatus_Call struct {
+	*mock.Call
+}
+
+// GetAggregationStatus is a helper method to define mock.On call
+//   - _a0 context.Context
+func (_e *MockQueries_Expecter) GetAggregationStatus(_a0 interface{}) *MockQueries_GetAggregationStatus_Call {
+	return &MockQueries_GetAggregationStatus_Call{Call: _e.mock.On("GetAggregationStatus", _a0)}
+}
+
+func (_c *MockQueries_GetAggregationStatus_Call) Run(run func(_a0 context.Context)) *MockQueries_GetAggregationStatus_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockQueries_GetAggregationStatus_Call) Return(_a0 *AggregationStatus, _a1 error) *MockQueries_GetAggregationStatus_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockQueries_GetAggregationStatus_Call) RunAndReturn(run func(context.Context) (*AggregationStatus, error)) *MockQueries_GetAggregationStatus_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetItemRank provides a mock function with given fields: _a0, params
+func (_m *MockQueries) GetItemRank(_a0 context.Context, params GetItemRankParams) (*GetItemRankResponse, error) {
+	ret := _m.Called(_a0, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetItemRank")
+	}
+
+	var r0 *GetItemRankResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, GetItemRankParams) (*GetItemRankResponse, error)); ok {
+		return rf(_a0, params)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, GetItemRankParams) *GetItemRankResponse); ok {
+		r0 = rf(_a0, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*GetItemRankResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, GetItemRankParams) error); ok {
+		r1 = rf(_a0, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockQueries_GetItemRank_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetItemRank'
+type MockQueries_GetItemRank_Call struct {
+	*mock.Call
+}
+
+// GetItemRank is a helper method to define mock.On call
+//   - _a0 context.Context
+//   - params GetItemRankParams
+func (_e *MockQueries_Expecter) GetItemRank(_a0 interface{}, params interface{}) *MockQueries_GetItemRank_Call {
+	return &MockQueries_GetItemRank_Call{Call: _e.mock.On("GetItemRank", _a0, params)}
+}
+
+func (_c *MockQueries_GetItemRank_Call) Run(run func(_a0 context.Context, params GetItemRankParams)) *MockQueries_GetItemRank_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(GetItemRankParams))
+	})
+	return _c
+}
+
+func (_c *MockQueries_GetItemRank_Call) Return(_a0 *GetItemRankResponse, _a1 error) *MockQueries_GetItemRank_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockQueries_GetItemRank_Call) RunAndReturn(run func(context.Context, GetItemRankParams) (*GetItemRankResponse, error)) *MockQueries_GetItemRank_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetItemStats provides a mock function with given fields: _a0, params
+func (_m *MockQueries) GetItemStats(_a0 context.Context, params GetItemStatsParams) (*GetItemStatsResponse, error) {
+	ret := _m.Called(_a0, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetItemStats")
+	}
+
+	var r0 *GetItemStatsResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, GetItemStatsParams) (*GetItemStatsResponse, error)); ok {
+		return rf(_a0, params)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, GetItemStatsParams) *GetItemStatsResponse); ok {
+		r0 = rf(_a0, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*GetItemStatsResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, GetItemStatsParams) error); ok {
+		r1 = rf(_a0, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockQueries_GetItemStats_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetItemStats'
+type MockQueries_GetItemStats_Call struct {
+	*mock.Call
+}
+
+// GetItemStats is a helper method to define mock.On call
+//   - _a0 context.Context
+//   - params GetItemStatsParams
+func (_e *MockQueries_Expecter) GetItemStats(_a0 interface{}, params interface{}) *MockQueries_GetItemStats_Call {
+	return &MockQueries_GetItemStats_Call{Call: _e.mock.On("GetItemStats", _a0, params)}
+}
+
+func (_c *MockQueries_GetItemStats_Call) Run(run func(_a0 context.Context, params GetItemStatsParams)) *MockQueries_GetItemStats_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(GetItemStatsParams))
+	})
+	return _c
+}
+
+func (_c *MockQueries_GetItemStats_Call) Return(_a0 *GetItemStatsResponse, _a1 error) *MockQueries_GetItemStats_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockQueries_GetItemStats_Call) RunAndReturn(run func(context.Context, GetItemStatsParams) (*GetItemStatsResponse, error)) *MockQueries_GetItemStats_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetItemsCounts provides a mock function with given fields: _a0, params
+func (_m *MockQueries) GetItemsCounts(_a0 context.Context, params GetItemsCountsParams) (*GetItemsCountsResponse, error) {
+	ret := _m.Called(_a0, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetItemsCounts")
+	}
+
+	var r0 *GetItemsCountsResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, GetItemsCountsParams) (*GetItemsCountsResponse, error)); ok {
+		return rf(_a0, params)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, GetItemsCountsParams) *GetItemsCountsResponse); ok {
+		r0 = rf(_a0, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*GetItemsCountsResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, GetItemsCountsParams) error); ok {
+		r1 = rf(_a0, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockQueries_GetItemsCounts_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetItemsCounts'
+type MockQueries_GetItemsCounts_Call struct {
+	*mock.Call
+}
+
+// GetItemsCounts is a helper method to define mock.On call
+//   - _a0 context.Context
+//   - params GetItemsCountsParams
+func (_e *MockQueries_Expecter) GetItemsCounts(_a0 interface{}, params interface{}) *MockQueries_GetItemsCounts_Call {
+	return &MockQueries_GetItemsCounts_Call{Call: _e.mock.On("GetItemsCounts", _a0, params)}
+}
+
+func (_c *MockQueries_GetItemsCounts_Call) Run(run func(_a0 context.Context, params GetItemsCountsParams)) *MockQueries_GetItemsCounts_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(GetItemsCountsParams))
+	})
+	return _c
+}
+
+func (_c *MockQueries_GetItemsCounts_Call) Return(_a0 *GetItemsCountsResponse, _a1 error) *MockQueries_GetItemsCounts_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockQueries_GetItemsCounts_Call) RunAndReturn(run func(context.Context, GetItemsCountsParams) (*GetItemsCountsResponse, error)) *MockQueries_GetItemsCounts_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetStateSnapshot provides a mock function with given fields: ctx, params
+func (_m *MockQueries) GetStateSnapshot(ctx context.Context, params GetStateSnapshotParams) (*GetStateSnapshotResponse, error) {
+	ret := _m.Called(ctx, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetStateSnapshot")
+	}
+
+	var r0 *GetStateSnapshotResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, GetStateSnapshotParams) (*GetStateSnapshotResponse, error)); ok {
+		return rf(ctx, params)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, GetStateSnapshotParams) *GetStateSnapshotResponse); ok {
+		r0 = rf(ctx, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*GetStateSnapshotResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, GetStateSnapshotParams) error); ok {
+		r1 = rf(ctx, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockQueries_GetStateSnapshot_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetStateSnapshot'
+type MockQueries_GetStateSnapshot_Call struct {
+	*mock.Call
+}
+
+// GetStateSnapshot is a helper method to define mock.On call
+//   - ctx context.Context
+//   - params GetStateSnapshotParams
+func (_e *MockQueries_Expecter) GetStateSnapshot(ctx interface{}, params interface{}) *MockQueries_GetStateSnapshot_Call {
+	return &MockQueries_GetStateSnapshot_Call{Call: _e.mock.On("GetStateSnapshot", ctx, params)}
+}
+
+func (_c *MockQueries_GetStateSnapshot_Call) Run(run func(ctx context.Context, params GetStateSnapshotParams)) *MockQueries_GetStateSnapshot_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(GetStateSnapshotParams))
+	})
+	return _c
+}
+
+func (_c *MockQueries_GetStateSnapshot_Call) Return(_a0 *GetStateSnapshotResponse, _a1 error) *MockQueries_GetStateSnapshot_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockQueries_GetStateSnapshot_Call) RunAndReturn(run func(context.Context, GetStateSnapshotParams) (*GetStateSnapshotResponse, error)) *MockQueries_GetStateSnapshot_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetTopKItems provides a mock function with given fields: _a0, params
 func (_m *MockQueries) GetTopKItems(_a0 context.Context, params GetTopKItemsParams) (*GetTopKItemsResponse, error) {
 	ret := _m.Called(_a0, params)
@@ -19,6 +19,62 @@ func (_m *mockTopKItems) EXPECT() *mockTopKItems_Expecter {
 	return &mockTopKItems_Expecter{mock: &_m.Mock}
 }
 
+// getItemRank provides a mock function with given fields: itemID
+func (_m *mockTopKItems) getItemRank(itemID string) (int, bool) {
+	ret := _m.Called(itemID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for getItemRank")
+	}
+
+	var r0 int
+	var r1 bool
+	if rf, ok := ret.Get(0).(func(string) (int, bool)); ok {
+		return rf(itemID)
+	}
+	if rf, ok := ret.Get(0).(func(string) int); ok {
+		r0 = rf(itemID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(itemID)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// mockTopKItems_getItemRank_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'getItemRank'
+type mockTopKItems_getItemRank_Call struct {
+	*mock.Call
+}
+
+// getItemRank is a helper method to define mock.On call
+//   - itemID string
+func (_e *mockTopKItems_Expecter) getItemRank(itemID interface{}) *mockTopKItems_getItemRank_Call {
+	return &mockTopKItems_getItemRank_Call{Call: _e.mock.On("getItemRank", itemID)}
+}
+
+func (_c *mockTopKItems_getItemRank_Call) Run(run func(itemID string)) *mockTopKItems_getItemRank_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *mockTopKItems_getItemRank_Call) Return(_a0 int, _a1 bool) *mockTopKItems_getItemRank_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *mockTopKItems_getItemRank_Call) RunAndReturn(run func(string) (int, bool)) *mockTopKItems_getItemRank_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // getItems provides a mock function with given fields: limit
 func (_m *mockTopKItems) getItems(limit int) []*topKItem {
 	ret := _m.Called(limit)
@@ -67,6 +123,63 @@ func (_c *mockTopKItems_getItems_Call) RunAndReturn(run func(int) []*topKItem) *
 	return _c
 }
 
+// getMinItem provides a mock function with given fields:
+func (_m *mockTopKItems) getMinItem() (*topKItem, bool) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for getMinItem")
+	}
+
+	var r0 *topKItem
+	var r1 bool
+	if rf, ok := ret.Get(0).(func() (*topKItem, bool)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() *topKItem); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*topKItem)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() bool); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// mockTopKItems_getMinItem_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'getMinItem'
+type mockTopKItems_getMinItem_Call struct {
+	*mock.Call
+}
+
+// getMinItem is a helper method to define mock.On call
+func (_e *mockTopKItems_Expecter) getMinItem() *mockTopKItems_getMinItem_Call {
+	return &mockTopKItems_getMinItem_Call{Call: _e.mock.On("getMinItem")}
+}
+
+func (_c *mockTopKItems_getMinItem_Call) Run(run func()) *mockTopKItems_getMinItem_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *mockTopKItems_getMinItem_Call) Return(_a0 *topKItem, _a1 bool) *mockTopKItems_getMinItem_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *mockTopKItems_getMinItem_Call) RunAndReturn(run func() (*topKItem, bool)) *mockTopKItems_getMinItem_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // load provides a mock function with given fields: vals
 func (_m *mockTopKItems) load(vals []*topKItem) {
 	_m.Called(vals)
@@ -16,6 +16,33 @@ type mockCommands interface {
 	// CreateCheckPoint will restore last state, aggregate new events
 	// and create a new checkpoint
 	CreateCheckPoint(ctx context.Context) error
+
+	// PruneCheckPoints will apply retention policy and delete
+	// blobs of pruned check points
+	PruneCheckPoints(ctx context.Context) error
+
+	// ListCheckPoints will return all known check points
+	ListCheckPoints(ctx context.Context) ([]CheckPoint, error)
+
+	// RollbackCheckPoint will make the historical check point current
+	RollbackCheckPoint(ctx context.Context, offset int64) error
+
+	// InspectCheckPoint will return details of the check point
+	InspectCheckPoint(ctx context.Context, params InspectCheckPointParams) (*CheckPointDetails, error)
+
+	// ExportCheckPoint will write all counters of the check point
+	ExportCheckPoint(ctx context.Context, offset int64, write func(itemID string, count int64) error) error
+
+	// ImportCheckPoint will create a new check point from imported counters
+	ImportCheckPoint(ctx context.Context, params ImportCheckPointParams) error
+
+	// RebuildCheckPoint will aggregate the stream into a fresh state and
+	// write it as a new check point (or compare with the current one)
+	RebuildCheckPoint(ctx context.Context, params RebuildCheckPointParams) (*RebuildCheckPointResult, error)
+
+	// VerifyCheckPoint will compare the check point aggregated till the offset
+	// of the live state with the live state
+	VerifyCheckPoint(ctx context.Context, params VerifyCheckPointParams) (*VerifyCheckPointResult, error)
 }
 
 var _ mockCommands = (*Commands)(nil)
@@ -25,6 +52,28 @@ type mockQueries interface {
 		_ context.Context,
 		params GetTopKItemsParams,
 	) (*GetTopKItemsResponse, error)
+
+	GetStateSnapshot(
+		ctx context.Context,
+		params GetStateSnapshotParams,
+	) (*GetStateSnapshotResponse, error)
+
+	GetAggregationStatus(_ context.Context) (*AggregationStatus, error)
+
+	GetItemStats(
+		_ context.Context,
+		params GetItemStatsParams,
+	) (*GetItemStatsResponse, error)
+
+	GetItemsCounts(
+		_ context.Context,
+		params GetItemsCountsParams,
+	) (*GetItemsCountsResponse, error)
+
+	GetItemRank(
+		_ context.Context,
+		params GetItemRankParams,
+	) (*GetItemRankResponse, error)
 }
 
 var _ mockQueries = (*Queries)(nil)
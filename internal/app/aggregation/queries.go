@@ -2,16 +2,38 @@ package aggregation
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/gemyago/top-k-system-go/internal/services"
 	"go.uber.org/dig"
 )
 
+// ErrLiveStateUnavailable indicates that the live state could not be read in time
+// (e.g the aggregation is not started yet).
+var ErrLiveStateUnavailable = errors.New("live state is not available")
+
+// ErrLiveStateNotReady indicates that the live state is still being restored or caught up
+// with the stream, so queries would return stale or empty results.
+var ErrLiveStateNotReady = errors.New("live state is not ready")
+
+// ErrLiveStateStale indicates that the live state is staler than requested.
+var ErrLiveStateStale = errors.New("live state is too stale")
+
+// ErrRankIndexDisabled indicates that ranking beyond top items is not enabled.
+var ErrRankIndexDisabled = errors.New("rank index is disabled")
+
 type Queries struct {
 	allTimeItems topKItems
+	deps         QueriesDeps
 }
 
 type GetTopKItemsParams struct {
 	Limit int
+
+	// MaxStaleness is a maximum acceptable staleness of the items. Not checked if 0.
+	MaxStaleness time.Duration
 }
 
 type TopKItem struct {
@@ -19,15 +41,32 @@ type TopKItem struct {
 	Count  int64  `json:"count"`
 }
 
+// TopKItemsMetadata describes how fresh the top items are.
+type TopKItemsMetadata struct {
+	// LastOffset is an offset the items are aggregated till
+	LastOffset int64 `json:"lastOffset"`
+
+	// TailOffset and OffsetLag are as of the last lag update. Nil until
+	// the tail of the stream is read for the first time.
+	TailOffset *int64 `json:"tailOffset,omitempty"`
+	OffsetLag  *int64 `json:"offsetLag,omitempty"`
+
+	// LastFlushedAt is nil until aggregated messages are flushed for the first time
+	LastFlushedAt *time.Time `json:"lastFlushedAt,omitempty"`
+
+	// StalenessSeconds is time since the items were last updated (flushed or restored)
+	StalenessSeconds float64 `json:"stalenessSeconds"`
+
+	// CheckPointAgeSeconds is an age of the latest check point. Nil if there are no check points.
+	CheckPointAgeSeconds *float64 `json:"checkPointAgeSeconds,omitempty"`
+}
+
 type GetTopKItemsResponse struct {
-	Data []TopKItem `json:"data"`
+	Data     []TopKItem        `json:"data"`
+	Metadata TopKItemsMetadata `json:"metadata"`
 }
 
-func (q *Queries) GetTopKItems(
-	_ context.Context,
-	params GetTopKItemsParams,
-) (*GetTopKItemsResponse, error) {
-	items := q.allTimeItems.getItems(params.Limit)
+func toTopKItems(items []*topKItem) []TopKItem {
 	result := make([]TopKItem, len(items))
 	for i, item := range items {
 		result[i] = TopKItem{
@@ -35,7 +74,230 @@ func (q *Queries) GetTopKItems(
 			Count:  item.Count,
 		}
 	}
-	return &GetTopKItemsResponse{Data: result}, nil
+	return result
+}
+
+// staleness is time since the live state was last flushed (or restored if
+// nothing is flushed yet).
+func (q *Queries) staleness(status AggregationStatus) time.Duration {
+	updatedAt := status.Restore.CompletedAt
+	if status.LastFlushedAt != nil {
+		updatedAt = status.LastFlushedAt
+	}
+	if updatedAt == nil {
+		return 0
+	}
+	return q.deps.Time.Now().Sub(*updatedAt)
+}
+
+func (q *Queries) topKItemsMetadata(status AggregationStatus, staleness time.Duration) TopKItemsMetadata {
+	metadata := TopKItemsMetadata{
+		LastOffset:       status.LastOffset,
+		TailOffset:       status.TailOffset,
+		OffsetLag:        status.OffsetLag,
+		LastFlushedAt:    status.LastFlushedAt,
+		StalenessSeconds: staleness.Seconds(),
+	}
+	if status.CheckPointCreatedAt != nil {
+		checkPointAge := q.deps.Time.Now().Sub(*status.CheckPointCreatedAt).Seconds()
+		metadata.CheckPointAgeSeconds = &checkPointAge
+	}
+	return metadata
+}
+
+// GetTopKItems returns all time top items along with the metadata describing
+// how fresh they are. Items are served once the live state has caught up.
+func (q *Queries) GetTopKItems(
+	_ context.Context,
+	params GetTopKItemsParams,
+) (*GetTopKItemsResponse, error) {
+	status := q.deps.AggregationState.status.snapshot()
+	if !status.Ready() {
+		return nil, fmt.Errorf("%w: aggregation is %s", ErrLiveStateNotReady, status.Phase)
+	}
+	staleness := q.staleness(status)
+	if params.MaxStaleness > 0 && staleness > params.MaxStaleness {
+		return nil, fmt.Errorf("%w: staleness %v exceeds %v", ErrLiveStateStale, staleness, params.MaxStaleness)
+	}
+	items := q.allTimeItems.getItems(params.Limit)
+	return &GetTopKItemsResponse{
+		Data:     toTopKItems(items),
+		Metadata: q.topKItemsMetadata(status, staleness),
+	}, nil
+}
+
+type GetItemStatsParams struct {
+	ItemID string
+}
+
+type GetItemStatsResponse struct {
+	ItemID string `json:"itemId"`
+
+	// Count is an exact all time count. Zero for unknown items.
+	Count int64 `json:"count"`
+
+	// Rank is a position of the item among all time top items (starting from 1).
+	// Nil if the item is not within top items (rank is greater than MaxRank).
+	Rank *int `json:"rank,omitempty"`
+
+	// MaxRank is a number of all time top items that are ranked
+	MaxRank int `json:"maxRank"`
+}
+
+// GetItemStats returns the count of the item along with its rank. Values are
+// read from the live state without pausing the aggregation.
+func (q *Queries) GetItemStats(
+	_ context.Context,
+	params GetItemStatsParams,
+) (*GetItemStatsResponse, error) {
+	status := q.deps.AggregationState.status.snapshot()
+	if !status.Ready() {
+		return nil, fmt.Errorf("%w: aggregation is %s", ErrLiveStateNotReady, status.Phase)
+	}
+	count, _ := q.deps.AggregationState.counters.getItemCount(params.ItemID)
+	result := &GetItemStatsResponse{
+		ItemID:  params.ItemID,
+		Count:   count,
+		MaxRank: q.deps.AllTimeItemsCapacity,
+	}
+	if rank, ok := q.allTimeItems.getItemRank(params.ItemID); ok {
+		result.Rank = &rank
+	}
+	return result, nil
+}
+
+type GetItemRankParams struct {
+	ItemID string
+}
+
+type GetItemRankResponse struct {
+	ItemID string `json:"itemId"`
+
+	// Count is an exact all time count. Zero for unknown items.
+	Count int64 `json:"count"`
+
+	// Rank is 1 + number of items with greater count, so items with the same
+	// count share the rank. Nil for unknown items.
+	Rank *int `json:"rank,omitempty"`
+
+	// RankedItems is a total number of ranked items
+	RankedItems int `json:"rankedItems"`
+}
+
+// GetItemRank returns the exact rank of the item among all items (including
+// those beyond top items). Requires the rank index to be enabled.
+func (q *Queries) GetItemRank(
+	_ context.Context,
+	params GetItemRankParams,
+) (*GetItemRankResponse, error) {
+	index := q.deps.AggregationState.rankIndex
+	if index == nil {
+		return nil, ErrRankIndexDisabled
+	}
+	status := q.deps.AggregationState.status.snapshot()
+	if !status.Ready() {
+		return nil, fmt.Errorf("%w: aggregation is %s", ErrLiveStateNotReady, status.Phase)
+	}
+	count, ok := q.deps.AggregationState.counters.getItemCount(params.ItemID)
+	rank, rankedItems := index.getRank(count)
+	result := &GetItemRankResponse{
+		ItemID:      params.ItemID,
+		Count:       count,
+		RankedItems: rankedItems,
+	}
+	if ok {
+		result.Rank = &rank
+	}
+	return result, nil
+}
+
+type GetItemsCountsParams struct {
+	ItemIDs []string
+}
+
+type GetItemsCountsResponse struct {
+	// Counts are exact all time counts of requested items. Unknown items are omitted.
+	Counts map[string]int64 `json:"counts"`
+}
+
+// GetItemsCounts returns counts of given items. Values are read from the live
+// state without pausing the aggregation.
+func (q *Queries) GetItemsCounts(
+	_ context.Context,
+	params GetItemsCountsParams,
+) (*GetItemsCountsResponse, error) {
+	status := q.deps.AggregationState.status.snapshot()
+	if !status.Ready() {
+		return nil, fmt.Errorf("%w: aggregation is %s", ErrLiveStateNotReady, status.Phase)
+	}
+	return &GetItemsCountsResponse{
+		Counts: q.deps.AggregationState.counters.getItemsCount(params.ItemIDs),
+	}, nil
+}
+
+type GetStateSnapshotParams struct {
+	// Limit is a number of top items to include
+	Limit int
+
+	// ItemIDs are items to include counters of
+	ItemIDs []string
+}
+
+type GetStateSnapshotResponse struct {
+	// LastOffset is an offset the state is aggregated till
+	LastOffset int64 `json:"lastOffset"`
+
+	TopItems []TopKItem `json:"topItems"`
+
+	// ItemsCounters are counters of requested items. Unknown items are omitted.
+	ItemsCounters map[string]int64 `json:"itemsCounters"`
+}
+
+// readLiveState will invoke the read function from the aggregation goroutine
+// so the state is not modified while it is being read.
+func (q *Queries) readLiveState(ctx context.Context, read func(state aggregationState)) error {
+	ctx, cancel := context.WithTimeout(ctx, q.deps.LiveStateReadTimeout)
+	defer cancel()
+	done := make(chan struct{})
+	select {
+	case q.deps.LiveStateReads <- func(state aggregationState) {
+		defer close(done)
+		read(state)
+	}:
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", ErrLiveStateUnavailable, ctx.Err())
+	}
+	<-done
+	return nil
+}
+
+// GetStateSnapshot returns the top items and counters of given items along with
+// the offset they are aggregated till. All values are consistent as of the last flush.
+func (q *Queries) GetStateSnapshot(
+	ctx context.Context,
+	params GetStateSnapshotParams,
+) (*GetStateSnapshotResponse, error) {
+	var result GetStateSnapshotResponse
+	if err := q.readLiveState(ctx, func(state aggregationState) {
+		itemsCounters := state.counters.getItemsCounters()
+		result.LastOffset = state.counters.getLastOffset()
+		result.TopItems = toTopKItems(state.allTimeItems.getItems(params.Limit))
+		result.ItemsCounters = make(map[string]int64, len(params.ItemIDs))
+		for _, itemID := range params.ItemIDs {
+			if count, ok := itemsCounters[itemID]; ok {
+				result.ItemsCounters[itemID] = count
+			}
+		}
+	}); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetAggregationStatus returns the progress of restoring and aggregating the live state.
+func (q *Queries) GetAggregationStatus(_ context.Context) (*AggregationStatus, error) {
+	status := q.deps.AggregationState.status.snapshot()
+	return &status, nil
 }
 
 type QueriesDeps struct {
@@ -44,12 +306,21 @@ type QueriesDeps struct {
 
 	dig.In
 
+	// config
+	LiveStateReadTimeout time.Duration `name:"config.aggregator.liveStateReadTimeout"`
+	AllTimeItemsCapacity int           `name:"config.aggregator.topK.allTime.capacity"`
+
+	// service layer
+	Time services.TimeProvider
+
 	// package private components
 	AggregationState aggregationState
+	LiveStateReads   liveStateReads
 }
 
 func NewQueries(deps QueriesDeps) *Queries {
 	return &Queries{
 		allTimeItems: deps.AggregationState.allTimeItems,
+		deps:         deps,
 	}
 }
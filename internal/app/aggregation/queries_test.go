@@ -2,26 +2,48 @@ package aggregation
 
 import (
 	"context"
+	"maps"
 	"math/rand/v2"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/gemyago/top-k-system-go/internal/services"
+	"github.com/go-faker/faker/v4"
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
 func TestQueries(t *testing.T) {
 	makeMockDeps := func(t *testing.T) QueriesDeps {
 		return QueriesDeps{
+			LiveStateReadTimeout: time.Second,
+			AllTimeItemsCapacity: topKMaxItemsSize + rand.IntN(1000),
 			AggregationState: aggregationState{
+				counters:     newCounters(),
 				allTimeItems: newMockTopKItems(t),
+				status:       newAggregationStatus(),
 			},
+			LiveStateReads: make(liveStateReads),
+			Time:           services.NewMockNow(),
 		}
 	}
 
+	// makeLive will make the aggregation live with the state flushed flushedAgo
+	makeLive := func(deps QueriesDeps, flushedAgo time.Duration) {
+		now := services.MockNowValue(deps.Time)
+		status := deps.AggregationState.status
+		status.beginRestore(now.Add(-flushedAgo - time.Minute))
+		status.beginCatchingUp(now.Add(-flushedAgo-time.Second), rand.Int64N(1000), 0, false)
+		status.flushed(rand.Int64N(1000), now.Add(-flushedAgo))
+	}
+
 	t.Run("GetTopKItems", func(t *testing.T) {
 		t.Run("should return all time top k items", func(t *testing.T) {
 			deps := makeMockDeps(t)
+			makeLive(deps, 0)
 
 			mockItems, _ := deps.AggregationState.allTimeItems.(*mockTopKItems)
 
@@ -46,5 +68,295 @@ func TestQueries(t *testing.T) {
 
 			assert.Equal(t, wantItems, got.Data)
 		})
+		t.Run("should include staleness metadata", func(t *testing.T) {
+			deps := makeMockDeps(t)
+			now := services.MockNowValue(deps.Time)
+			flushedAgo := time.Duration(1+rand.IntN(1000)) * time.Second
+			checkPointAge := time.Duration(1+rand.IntN(1000)) * time.Second
+			tailOffset := rand.Int64N(1000)
+			offsetLag := rand.Int64N(1000)
+			status := deps.AggregationState.status
+			status.beginRestore(now.Add(-time.Hour))
+			status.beginRestoreCheckPoint(rand.Int64N(1000), now.Add(-checkPointAge), checkPointBlobsCount)
+			status.beginCatchingUp(now.Add(-time.Hour), rand.Int64N(1000), offsetLag, true)
+			status.offsetLagUpdated(tailOffset, offsetLag)
+			lastOffset := rand.Int64N(1000)
+			status.flushed(lastOffset, now.Add(-flushedAgo))
+
+			mockItems, _ := deps.AggregationState.allTimeItems.(*mockTopKItems)
+			mockItems.EXPECT().getItems(mock.Anything).Return(randomTopKItems(5))
+
+			queries := NewQueries(deps)
+			got, err := queries.GetTopKItems(context.Background(), GetTopKItemsParams{
+				Limit:        10,
+				MaxStaleness: flushedAgo,
+			})
+			require.NoError(t, err)
+
+			wantLastFlushedAt := now.Add(-flushedAgo)
+			wantCheckPointAge := checkPointAge.Seconds()
+			assert.Equal(t, TopKItemsMetadata{
+				LastOffset:           lastOffset,
+				TailOffset:           &tailOffset,
+				OffsetLag:            &offsetLag,
+				LastFlushedAt:        &wantLastFlushedAt,
+				StalenessSeconds:     flushedAgo.Seconds(),
+				CheckPointAgeSeconds: &wantCheckPointAge,
+			}, got.Metadata)
+		})
+		t.Run("should measure staleness since restore if nothing flushed yet", func(t *testing.T) {
+			deps := makeMockDeps(t)
+			now := services.MockNowValue(deps.Time)
+			restoredAgo := time.Duration(1+rand.IntN(1000)) * time.Second
+			status := deps.AggregationState.status
+			status.beginRestore(now.Add(-restoredAgo - time.Minute))
+			status.beginCatchingUp(now.Add(-restoredAgo), rand.Int64N(1000), 0, false)
+
+			mockItems, _ := deps.AggregationState.allTimeItems.(*mockTopKItems)
+			mockItems.EXPECT().getItems(mock.Anything).Return(randomTopKItems(5))
+
+			queries := NewQueries(deps)
+			got, err := queries.GetTopKItems(context.Background(), GetTopKItemsParams{Limit: 10})
+			require.NoError(t, err)
+			assert.InDelta(t, restoredAgo.Seconds(), got.Metadata.StalenessSeconds, 0)
+			assert.Nil(t, got.Metadata.LastFlushedAt)
+			assert.Nil(t, got.Metadata.CheckPointAgeSeconds)
+		})
+		t.Run("should fail if aggregation is not live", func(t *testing.T) {
+			deps := makeMockDeps(t)
+			deps.AggregationState.status.beginRestore(time.Now())
+
+			queries := NewQueries(deps)
+			_, err := queries.GetTopKItems(context.Background(), GetTopKItemsParams{Limit: 10})
+			require.ErrorIs(t, err, ErrLiveStateNotReady)
+		})
+		t.Run("should fail if staler than requested", func(t *testing.T) {
+			deps := makeMockDeps(t)
+			flushedAgo := time.Duration(2+rand.IntN(1000)) * time.Second
+			makeLive(deps, flushedAgo)
+
+			queries := NewQueries(deps)
+			_, err := queries.GetTopKItems(context.Background(), GetTopKItemsParams{
+				Limit:        10,
+				MaxStaleness: flushedAgo - time.Second,
+			})
+			require.ErrorIs(t, err, ErrLiveStateStale)
+		})
+	})
+
+	t.Run("GetItemStats", func(t *testing.T) {
+		t.Run("should return count and rank of the item within top items", func(t *testing.T) {
+			deps := makeMockDeps(t)
+			makeLive(deps, 0)
+
+			itemID := faker.UUIDHyphenated()
+			wantCount := rand.Int64N(1000)
+			deps.AggregationState.counters.updateItemsCount(rand.Int64N(1000), map[string]int64{itemID: wantCount})
+			wantRank := 1 + rand.IntN(100)
+			mockItems, _ := deps.AggregationState.allTimeItems.(*mockTopKItems)
+			mockItems.EXPECT().getItemRank(itemID).Return(wantRank, true)
+
+			queries := NewQueries(deps)
+			got, err := queries.GetItemStats(context.Background(), GetItemStatsParams{ItemID: itemID})
+			require.NoError(t, err)
+			assert.Equal(t, &GetItemStatsResponse{
+				ItemID:  itemID,
+				Count:   wantCount,
+				Rank:    &wantRank,
+				MaxRank: deps.AllTimeItemsCapacity,
+			}, got)
+		})
+		t.Run("should return zero count of unknown item with no rank", func(t *testing.T) {
+			deps := makeMockDeps(t)
+			makeLive(deps, 0)
+
+			itemID := faker.UUIDHyphenated()
+			mockItems, _ := deps.AggregationState.allTimeItems.(*mockTopKItems)
+			mockItems.EXPECT().getItemRank(itemID).Return(0, false)
+
+			queries := NewQueries(deps)
+			got, err := queries.GetItemStats(context.Background(), GetItemStatsParams{ItemID: itemID})
+			require.NoError(t, err)
+			assert.Equal(t, &GetItemStatsResponse{
+				ItemID:  itemID,
+				MaxRank: deps.AllTimeItemsCapacity,
+			}, got)
+		})
+		t.Run("should fail if aggregation is not live", func(t *testing.T) {
+			deps := makeMockDeps(t)
+
+			queries := NewQueries(deps)
+			_, err := queries.GetItemStats(context.Background(), GetItemStatsParams{ItemID: faker.UUIDHyphenated()})
+			require.ErrorIs(t, err, ErrLiveStateNotReady)
+		})
+	})
+
+	t.Run("GetItemRank", func(t *testing.T) {
+		t.Run("should return exact rank of the item", func(t *testing.T) {
+			deps := makeMockDeps(t)
+			deps.AggregationState.rankIndex = newRankIndex()
+			makeLive(deps, 0)
+
+			itemID := faker.UUIDHyphenated()
+			wantCount := 10 + rand.Int64N(1000)
+			itemsCounters := map[string]int64{
+				itemID:                 wantCount,
+				faker.UUIDHyphenated(): wantCount + 1,
+				faker.UUIDHyphenated(): wantCount,
+				faker.UUIDHyphenated(): wantCount - 1,
+			}
+			deps.AggregationState.counters.updateItemsCount(rand.Int64N(1000), itemsCounters)
+			deps.AggregationState.rankIndex.load(itemsCounters)
+
+			queries := NewQueries(deps)
+			got, err := queries.GetItemRank(context.Background(), GetItemRankParams{ItemID: itemID})
+			require.NoError(t, err)
+			assert.Equal(t, &GetItemRankResponse{
+				ItemID:      itemID,
+				Count:       wantCount,
+				Rank:        lo.ToPtr(2),
+				RankedItems: len(itemsCounters),
+			}, got)
+		})
+		t.Run("should return zero count of unknown item with no rank", func(t *testing.T) {
+			deps := makeMockDeps(t)
+			deps.AggregationState.rankIndex = newRankIndex()
+			makeLive(deps, 0)
+
+			itemsCounters := randomCountersValues()
+			deps.AggregationState.counters.updateItemsCount(rand.Int64N(1000), itemsCounters)
+			deps.AggregationState.rankIndex.load(itemsCounters)
+
+			itemID := faker.UUIDHyphenated()
+			queries := NewQueries(deps)
+			got, err := queries.GetItemRank(context.Background(), GetItemRankParams{ItemID: itemID})
+			require.NoError(t, err)
+			assert.Equal(t, &GetItemRankResponse{
+				ItemID:      itemID,
+				RankedItems: len(itemsCounters),
+			}, got)
+		})
+		t.Run("should fail if rank index is disabled", func(t *testing.T) {
+			deps := makeMockDeps(t)
+			makeLive(deps, 0)
+
+			queries := NewQueries(deps)
+			_, err := queries.GetItemRank(context.Background(), GetItemRankParams{ItemID: faker.UUIDHyphenated()})
+			require.ErrorIs(t, err, ErrRankIndexDisabled)
+		})
+		t.Run("should fail if aggregation is not live", func(t *testing.T) {
+			deps := makeMockDeps(t)
+			deps.AggregationState.rankIndex = newRankIndex()
+
+			queries := NewQueries(deps)
+			_, err := queries.GetItemRank(context.Background(), GetItemRankParams{ItemID: faker.UUIDHyphenated()})
+			require.ErrorIs(t, err, ErrLiveStateNotReady)
+		})
+	})
+
+	t.Run("GetItemsCounts", func(t *testing.T) {
+		t.Run("should return counts of known items", func(t *testing.T) {
+			deps := makeMockDeps(t)
+			makeLive(deps, 0)
+
+			wantCounts := randomCountersValues()
+			deps.AggregationState.counters.updateItemsCount(rand.Int64N(1000), wantCounts)
+			itemIDs := append(slices.Collect(maps.Keys(wantCounts)), faker.UUIDHyphenated())
+
+			queries := NewQueries(deps)
+			got, err := queries.GetItemsCounts(context.Background(), GetItemsCountsParams{ItemIDs: itemIDs})
+			require.NoError(t, err)
+			assert.Equal(t, wantCounts, got.Counts)
+		})
+		t.Run("should fail if aggregation is not live", func(t *testing.T) {
+			deps := makeMockDeps(t)
+
+			queries := NewQueries(deps)
+			_, err := queries.GetItemsCounts(context.Background(), GetItemsCountsParams{
+				ItemIDs: []string{faker.UUIDHyphenated()},
+			})
+			require.ErrorIs(t, err, ErrLiveStateNotReady)
+		})
+	})
+
+	t.Run("GetStateSnapshot", func(t *testing.T) {
+		t.Run("should read top items and counters from the live state", func(t *testing.T) {
+			deps := makeMockDeps(t)
+			lastOffset := rand.Int64N(100000)
+			itemsCounters := randomCountersValues()
+			deps.AggregationState.counters.updateItemsCount(lastOffset, itemsCounters)
+
+			limit := 1 + rand.IntN(10)
+			wantRawItems := randomTopKItems(limit)
+			mockItems, _ := deps.AggregationState.allTimeItems.(*mockTopKItems)
+			mockItems.EXPECT().getItems(limit).Return(wantRawItems)
+
+			go func() {
+				read := <-deps.LiveStateReads
+				read(deps.AggregationState)
+			}()
+
+			knownItemID := lo.Keys(itemsCounters)[0]
+			queries := NewQueries(deps)
+			got, err := queries.GetStateSnapshot(context.Background(), GetStateSnapshotParams{
+				Limit:   limit,
+				ItemIDs: []string{knownItemID, faker.UUIDHyphenated()},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, &GetStateSnapshotResponse{
+				LastOffset: lastOffset,
+				TopItems:   toTopKItems(wantRawItems),
+				ItemsCounters: map[string]int64{
+					knownItemID: itemsCounters[knownItemID],
+				},
+			}, got)
+		})
+		t.Run("should fail if live state is not read in time", func(t *testing.T) {
+			deps := makeMockDeps(t)
+			deps.LiveStateReadTimeout = time.Millisecond
+			queries := NewQueries(deps)
+			_, err := queries.GetStateSnapshot(context.Background(), GetStateSnapshotParams{})
+			require.ErrorIs(t, err, ErrLiveStateUnavailable)
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+		})
+	})
+
+	t.Run("GetAggregationStatus", func(t *testing.T) {
+		t.Run("should return a copy of the live state status", func(t *testing.T) {
+			deps := makeMockDeps(t)
+			queries := NewQueries(deps)
+
+			status := deps.AggregationState.status
+			startedAt := time.Now().Add(-time.Duration(1+rand.IntN(1000)) * time.Second)
+			lastOffset := rand.Int64N(1000)
+			offsetLag := rand.Int64N(1000)
+			status.beginRestore(startedAt)
+			status.beginCatchingUp(time.Now(), lastOffset, offsetLag-1, true)
+			status.offsetLagUpdated(rand.Int64N(1000), offsetLag)
+
+			got, err := queries.GetAggregationStatus(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, AggregationPhaseCatchingUp, got.Phase)
+			assert.False(t, got.Ready())
+			assert.Equal(t, startedAt, got.Restore.StartedAt)
+			assert.NotNil(t, got.Restore.CompletedAt)
+			assert.Equal(t, lastOffset, got.LastOffset)
+			require.NotNil(t, got.OffsetLag)
+			assert.Equal(t, offsetLag, *got.OffsetLag)
+
+			*got.OffsetLag = -1
+			again, err := queries.GetAggregationStatus(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, offsetLag, *again.OffsetLag)
+		})
+		t.Run("should report starting phase if status is not tracked", func(t *testing.T) {
+			deps := makeMockDeps(t)
+			deps.AggregationState.status = nil
+			queries := NewQueries(deps)
+
+			got, err := queries.GetAggregationStatus(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, AggregationPhaseStarting, got.Phase)
+		})
 	})
 }
@@ -0,0 +1,170 @@
+package aggregation
+
+import (
+	"math/rand/v2"
+	"sync"
+)
+
+// rankIndexNode is a node of the treap ordered by count. Each node holds
+// the number of items with a given count, so the size of the tree depends
+// on the number of distinct counts rather than the number of items.
+type rankIndexNode struct {
+	count    int64
+	items    int
+	subtree  int
+	priority uint32
+	left     *rankIndexNode
+	right    *rankIndexNode
+}
+
+func subtreeItems(node *rankIndexNode) int {
+	if node == nil {
+		return 0
+	}
+	return node.subtree
+}
+
+func (node *rankIndexNode) updateSubtree() {
+	node.subtree = node.items + subtreeItems(node.left) + subtreeItems(node.right)
+}
+
+func rotateRankIndexRight(node *rankIndexNode) *rankIndexNode {
+	left := node.left
+	node.left = left.right
+	left.right = node
+	node.updateSubtree()
+	left.updateSubtree()
+	return left
+}
+
+func rotateRankIndexLeft(node *rankIndexNode) *rankIndexNode {
+	right := node.right
+	node.right = right.left
+	right.left = node
+	node.updateSubtree()
+	right.updateSubtree()
+	return right
+}
+
+// mergeRankIndexNodes will merge two subtrees where all counts of the left
+// one are lower than counts of the right one.
+func mergeRankIndexNodes(left, right *rankIndexNode) *rankIndexNode {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+	if left.priority > right.priority {
+		left.right = mergeRankIndexNodes(left.right, right)
+		left.updateSubtree()
+		return left
+	}
+	right.left = mergeRankIndexNodes(left, right.left)
+	right.updateSubtree()
+	return right
+}
+
+// addRankIndexItems will add (or remove if delta is negative) items with a given count.
+func addRankIndexItems(node *rankIndexNode, count int64, delta int) *rankIndexNode {
+	if node == nil {
+		if delta <= 0 {
+			return nil
+		}
+		return &rankIndexNode{
+			count:    count,
+			items:    delta,
+			subtree:  delta,
+			priority: rand.Uint32(), //nolint:gosec // not security related
+		}
+	}
+	switch {
+	case count < node.count:
+		node.left = addRankIndexItems(node.left, count, delta)
+		if node.left != nil && node.left.priority > node.priority {
+			node = rotateRankIndexRight(node)
+		}
+	case count > node.count:
+		node.right = addRankIndexItems(node.right, count, delta)
+		if node.right != nil && node.right.priority > node.priority {
+			node = rotateRankIndexLeft(node)
+		}
+	default:
+		node.items += delta
+		if node.items <= 0 {
+			return mergeRankIndexNodes(node.left, node.right)
+		}
+	}
+	node.updateSubtree()
+	return node
+}
+
+// rankIndex is an order statistic tree of all counters used to rank items
+// beyond top items. Items with the same count share the rank. The index is
+// maintained on every flush, so it is consistent with counters as of the last flush.
+// All methods are safe to call on nil (the index is optional).
+type rankIndex struct {
+	rwLock sync.RWMutex
+	root   *rankIndexNode
+}
+
+// load will add all given counters to the index.
+func (idx *rankIndex) load(itemCounters map[string]int64) {
+	if idx == nil {
+		return
+	}
+	itemsByCount := make(map[int64]int)
+	for _, count := range itemCounters {
+		itemsByCount[count]++
+	}
+	idx.rwLock.Lock()
+	defer idx.rwLock.Unlock()
+	for count, items := range itemsByCount {
+		idx.add(count, items)
+	}
+}
+
+func (idx *rankIndex) add(count int64, delta int) {
+	idx.root = addRankIndexItems(idx.root, count, delta)
+}
+
+// update will move the items from their previous counts to the new totals.
+// Items that are missing in prevCounts are not indexed yet and only added.
+func (idx *rankIndex) update(prevCounts map[string]int64, totals map[string]int64) {
+	if idx == nil {
+		return
+	}
+	idx.rwLock.Lock()
+	defer idx.rwLock.Unlock()
+	for itemID, total := range totals {
+		if prevCount, indexed := prevCounts[itemID]; indexed {
+			idx.add(prevCount, -1)
+		}
+		idx.add(total, 1)
+	}
+}
+
+// getRank returns the rank of the item with a given count (1 + number of items
+// with greater count) and the total number of ranked items.
+func (idx *rankIndex) getRank(count int64) (int, int) {
+	idx.rwLock.RLock()
+	defer idx.rwLock.RUnlock()
+	greater := 0
+	for node := idx.root; node != nil; {
+		switch {
+		case count < node.count:
+			greater += node.items + subtreeItems(node.right)
+			node = node.left
+		case count > node.count:
+			node = node.right
+		default:
+			greater += subtreeItems(node.right)
+			node = nil
+		}
+	}
+	return greater + 1, subtreeItems(idx.root)
+}
+
+func newRankIndex() *rankIndex {
+	return &rankIndex{}
+}
@@ -0,0 +1,68 @@
+package aggregation
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/samber/lo"
+)
+
+// zipfCountersOfSize will generate counters with the long tail distribution,
+// so most of items share low counts (as observed in the real traffic).
+func zipfCountersOfSize(size int) map[string]int64 {
+	zipf := rand.NewZipf(rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())), 1.1, 1, 1000000) //nolint:gosec // test data
+	counters := make(map[string]int64, size)
+	for range size {
+		counters[faker.UUIDHyphenated()] = 1 + int64(zipf.Uint64()) //nolint:gosec // values are capped
+	}
+	return counters
+}
+
+func heapAllocBytes() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+func BenchmarkRankIndex(b *testing.B) {
+	for _, size := range []int{10000, 100000} {
+		sizeName := lo.Ternary(size == 10000, "10k", "100k")
+		counters := zipfCountersOfSize(size)
+		itemIDs := lo.Keys(counters)
+
+		b.Run("load/"+sizeName, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				newRankIndex().load(counters)
+			}
+			before := heapAllocBytes()
+			idx := newRankIndex()
+			idx.load(counters)
+			b.ReportMetric(float64(heapAllocBytes()-before), "index-bytes")
+			runtime.KeepAlive(idx)
+		})
+
+		b.Run("update/"+sizeName, func(b *testing.B) {
+			idx := newRankIndex()
+			idx.load(counters)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				itemID := itemIDs[i%len(itemIDs)]
+				prevCount := counters[itemID]
+				counters[itemID]++
+				idx.update(map[string]int64{itemID: prevCount}, map[string]int64{itemID: counters[itemID]})
+			}
+		})
+
+		b.Run("getRank/"+sizeName, func(b *testing.B) {
+			idx := newRankIndex()
+			idx.load(counters)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				idx.getRank(counters[itemIDs[i%len(itemIDs)]])
+			}
+		})
+	}
+}
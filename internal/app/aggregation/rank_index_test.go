@@ -0,0 +1,135 @@
+package aggregation
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRankIndex(t *testing.T) {
+	// wantRank will rank the item by comparing with all other items
+	wantRank := func(itemCounters map[string]int64, count int64) int {
+		greater := 0
+		for _, otherCount := range itemCounters {
+			if otherCount > count {
+				greater++
+			}
+		}
+		return greater + 1
+	}
+
+	randomItemCounters := func(size int, maxCount int64) map[string]int64 {
+		result := make(map[string]int64, size)
+		for range size {
+			result[faker.UUIDHyphenated()] = 1 + rand.Int64N(maxCount)
+		}
+		return result
+	}
+
+	assertRanks := func(t *testing.T, idx *rankIndex, itemCounters map[string]int64) {
+		t.Helper()
+		for itemID, count := range itemCounters {
+			gotRank, gotRankedItems := idx.getRank(count)
+			require.Equal(t, wantRank(itemCounters, count), gotRank, itemID)
+			require.Equal(t, len(itemCounters), gotRankedItems)
+		}
+	}
+
+	t.Run("load", func(t *testing.T) {
+		t.Run("should rank loaded items", func(t *testing.T) {
+			idx := newRankIndex()
+			itemCounters := randomItemCounters(100+rand.IntN(100), 50)
+			idx.load(itemCounters)
+			assertRanks(t, idx, itemCounters)
+		})
+		t.Run("should share the rank of items with same count", func(t *testing.T) {
+			idx := newRankIndex()
+			count := 1 + rand.Int64N(1000)
+			idx.load(map[string]int64{
+				faker.UUIDHyphenated(): count + 1,
+				faker.UUIDHyphenated(): count,
+				faker.UUIDHyphenated(): count,
+				faker.UUIDHyphenated(): count - 1,
+			})
+
+			gotRank, gotRankedItems := idx.getRank(count)
+			assert.Equal(t, 2, gotRank)
+			assert.Equal(t, 4, gotRankedItems)
+			gotRank, _ = idx.getRank(count - 1)
+			assert.Equal(t, 4, gotRank)
+		})
+	})
+
+	t.Run("update", func(t *testing.T) {
+		t.Run("should move updated items to new counts", func(t *testing.T) {
+			idx := newRankIndex()
+			itemCounters := randomItemCounters(100+rand.IntN(100), 50)
+			idx.load(itemCounters)
+
+			for range 10 {
+				increments := make(map[string]int64)
+				for itemID := range itemCounters {
+					if rand.IntN(3) == 0 {
+						increments[itemID] = 1 + rand.Int64N(20)
+					}
+				}
+				for range 1 + rand.IntN(10) {
+					increments[faker.UUIDHyphenated()] = 1 + rand.Int64N(20)
+				}
+				prevCounts := make(map[string]int64, len(increments))
+				totals := make(map[string]int64, len(increments))
+				for itemID, increment := range increments {
+					if prevCount, ok := itemCounters[itemID]; ok {
+						prevCounts[itemID] = prevCount
+					}
+					itemCounters[itemID] += increment
+					totals[itemID] = itemCounters[itemID]
+				}
+				idx.update(prevCounts, totals)
+				assertRanks(t, idx, itemCounters)
+			}
+		})
+		t.Run("should move items with zero count", func(t *testing.T) {
+			idx := newRankIndex()
+			itemCounters := randomItemCounters(10+rand.IntN(10), 50)
+			zeroItemID := faker.UUIDHyphenated()
+			itemCounters[zeroItemID] = 0
+			idx.load(itemCounters)
+
+			newCount := 1 + rand.Int64N(100)
+			idx.update(map[string]int64{zeroItemID: 0}, map[string]int64{zeroItemID: newCount})
+			itemCounters[zeroItemID] = newCount
+			assertRanks(t, idx, itemCounters)
+		})
+	})
+
+	t.Run("getRank", func(t *testing.T) {
+		t.Run("should rank the count that is not indexed", func(t *testing.T) {
+			idx := newRankIndex()
+			idx.load(map[string]int64{
+				faker.UUIDHyphenated(): 30,
+				faker.UUIDHyphenated(): 20,
+				faker.UUIDHyphenated(): 10,
+			})
+
+			gotRank, _ := idx.getRank(25)
+			assert.Equal(t, 2, gotRank)
+			gotRank, _ = idx.getRank(0)
+			assert.Equal(t, 4, gotRank)
+		})
+		t.Run("should rank in empty index", func(t *testing.T) {
+			gotRank, gotRankedItems := newRankIndex().getRank(rand.Int64())
+			assert.Equal(t, 1, gotRank)
+			assert.Equal(t, 0, gotRankedItems)
+		})
+	})
+
+	t.Run("should ignore updates if disabled", func(t *testing.T) {
+		var idx *rankIndex
+		idx.load(randomItemCounters(10, 10))
+		idx.update(map[string]int64{faker.UUIDHyphenated(): 1}, map[string]int64{faker.UUIDHyphenated(): 1})
+	})
+}
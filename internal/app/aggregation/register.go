@@ -20,9 +20,8 @@ func Register(container *dig.Container) error {
 		di.ProvideValue(countersFactory(countersFactoryFunc(newCounters))),
 		di.ProvideValue(topKItemsFactory(topKItemsFactoryFunc(newTopKItems))),
 		newCheckPointer,
-		di.ProvideValue(aggregationState{
-			counters:     newCounters(),
-			allTimeItems: newTopKItems(topKMaxItemsSize),
-		}),
+		newLiveCheckPointer,
+		newLiveAggregationState,
+		di.ProvideValue(make(liveStateReads)),
 	)
 }
@@ -0,0 +1,187 @@
+package aggregation
+
+import (
+	"sync"
+	"time"
+)
+
+type AggregationPhase string
+
+const (
+	// AggregationPhaseStarting indicates that the aggregator is not started yet.
+	AggregationPhaseStarting AggregationPhase = "starting"
+
+	// AggregationPhaseRestoring indicates that the state is being restored from the check point.
+	AggregationPhaseRestoring AggregationPhase = "restoring"
+
+	// AggregationPhaseCatchingUp indicates that the state is restored and the aggregator
+	// is processing messages produced after the check point.
+	AggregationPhaseCatchingUp AggregationPhase = "catching-up"
+
+	// AggregationPhaseLive indicates that the aggregator has caught up with the stream.
+	AggregationPhaseLive AggregationPhase = "live"
+)
+
+type AggregationRestoreStatus struct {
+	// CheckPointOffset is an offset of the check point being restored. Zero if there
+	// is no check point to restore.
+	CheckPointOffset int64 `json:"checkPointOffset"`
+
+	RestoredBlobs int `json:"restoredBlobs"`
+	TotalBlobs    int `json:"totalBlobs"`
+
+	// RestoredItems is a number of items with counters restored so far
+	RestoredItems int `json:"restoredItems"`
+
+	StartedAt time.Time `json:"startedAt"`
+
+	// CompletedAt is nil while the restore is in progress
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+type AggregationStatus struct {
+	Phase AggregationPhase `json:"phase"`
+
+	Restore AggregationRestoreStatus `json:"restore"`
+
+	// LastOffset is an offset the live state is aggregated till (as of the last flush)
+	LastOffset int64 `json:"lastOffset"`
+
+	// LastFlushedAt is nil until aggregated messages are flushed for the first time
+	LastFlushedAt *time.Time `json:"lastFlushedAt,omitempty"`
+
+	// TailOffset is an offset of the next message to be produced to the stream.
+	// OffsetLag is a number of messages not aggregated yet. Both are nil until the
+	// tail of the stream is read for the first time.
+	TailOffset *int64 `json:"tailOffset,omitempty"`
+	OffsetLag  *int64 `json:"offsetLag,omitempty"`
+
+	// CheckPointCreatedAt is a creation time of the latest check point (restored or
+	// written by the live aggregation). Nil if there are no check points.
+	CheckPointCreatedAt *time.Time `json:"checkPointCreatedAt,omitempty"`
+}
+
+// Ready indicates if the live state is up to date enough to serve queries.
+func (s AggregationStatus) Ready() bool {
+	return s.Phase == AggregationPhaseLive
+}
+
+// aggregationStatus tracks the progress of the live aggregation. It is updated from
+// the aggregation goroutine and read concurrently by the queries. All methods are
+// safe to call on nil (status is tracked for the live state only).
+type aggregationStatus struct {
+	mu     sync.RWMutex
+	status AggregationStatus
+
+	// maxOffsetLag is the offset lag the aggregator is considered live at
+	maxOffsetLag int64
+}
+
+func (s *aggregationStatus) update(fn func(status *AggregationStatus)) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.status)
+}
+
+func (s *aggregationStatus) snapshot() AggregationStatus {
+	if s == nil {
+		return AggregationStatus{Phase: AggregationPhaseStarting}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := s.status
+	result.Restore.CompletedAt = clonePtr(s.status.Restore.CompletedAt)
+	result.LastFlushedAt = clonePtr(s.status.LastFlushedAt)
+	result.TailOffset = clonePtr(s.status.TailOffset)
+	result.OffsetLag = clonePtr(s.status.OffsetLag)
+	result.CheckPointCreatedAt = clonePtr(s.status.CheckPointCreatedAt)
+	return result
+}
+
+func clonePtr[T any](val *T) *T {
+	if val == nil {
+		return nil
+	}
+	result := *val
+	return &result
+}
+
+func (s *aggregationStatus) beginRestore(startedAt time.Time) {
+	s.update(func(status *AggregationStatus) {
+		status.Phase = AggregationPhaseRestoring
+		status.Restore = AggregationRestoreStatus{StartedAt: startedAt}
+	})
+}
+
+func (s *aggregationStatus) beginRestoreCheckPoint(checkPointOffset int64, createdAt time.Time, totalBlobs int) {
+	s.update(func(status *AggregationStatus) {
+		status.Restore.CheckPointOffset = checkPointOffset
+		status.Restore.TotalBlobs = totalBlobs
+
+		// creation time is unknown for check points produced before the history was introduced
+		if !createdAt.IsZero() {
+			status.CheckPointCreatedAt = &createdAt
+		}
+	})
+}
+
+func (s *aggregationStatus) blobRestored(restoredItems int) {
+	s.update(func(status *AggregationStatus) {
+		status.Restore.RestoredBlobs++
+		status.Restore.RestoredItems += restoredItems
+	})
+}
+
+// beginCatchingUp will complete the restore. The aggregator becomes live once the
+// offset lag drops to maxOffsetLag or immediately if the lag is not monitored.
+func (s *aggregationStatus) beginCatchingUp(
+	completedAt time.Time,
+	lastOffset int64,
+	maxOffsetLag int64,
+	lagMonitored bool,
+) {
+	s.update(func(status *AggregationStatus) {
+		status.Phase = AggregationPhaseCatchingUp
+		if !lagMonitored {
+			status.Phase = AggregationPhaseLive
+		}
+		status.Restore.CompletedAt = &completedAt
+		status.LastOffset = lastOffset
+		s.maxOffsetLag = maxOffsetLag
+	})
+}
+
+func (s *aggregationStatus) flushed(lastOffset int64, flushedAt time.Time) {
+	s.update(func(status *AggregationStatus) {
+		status.LastOffset = lastOffset
+		status.LastFlushedAt = &flushedAt
+	})
+}
+
+func (s *aggregationStatus) checkPointWritten(createdAt time.Time) {
+	s.update(func(status *AggregationStatus) {
+		status.CheckPointCreatedAt = &createdAt
+	})
+}
+
+// offsetLagUpdated will make the aggregator live once it has caught up. The aggregator
+// stays live if the lag grows again later, so the traffic spikes do not make all
+// replicas unready at once. The lag is still reported.
+func (s *aggregationStatus) offsetLagUpdated(tailOffset, offsetLag int64) {
+	s.update(func(status *AggregationStatus) {
+		status.TailOffset = &tailOffset
+		status.OffsetLag = &offsetLag
+		if status.Phase == AggregationPhaseCatchingUp && offsetLag <= s.maxOffsetLag {
+			status.Phase = AggregationPhaseLive
+		}
+	})
+}
+
+func newAggregationStatus() *aggregationStatus {
+	return &aggregationStatus{
+		status: AggregationStatus{Phase: AggregationPhaseStarting},
+	}
+}
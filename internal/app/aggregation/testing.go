@@ -3,7 +3,9 @@
 package aggregation
 
 import (
+	"fmt"
 	"math/rand/v2"
+	"time"
 
 	"github.com/go-faker/faker/v4"
 )
@@ -13,6 +15,37 @@ func randomManifest() checkPointManifest {
 		LastOffset:           rand.Int64N(10000),
 		CountersBlobFileName: faker.Word(),
 		AllTimeItemsFileName: faker.Word(),
+		CreatedAt:            time.Unix(faker.RandomUnixTime(), 0).UTC(),
+		FormatVersion:        rand.IntN(2),
+		Codec:                faker.Word(),
+	}
+}
+
+// randomManifestHistory will generate history of a given size with
+// manifests ordered by offset.
+func randomManifestHistory(size int) checkPointManifestHistory {
+	history := checkPointManifestHistory{
+		Manifests: make([]checkPointManifest, size),
+	}
+	offset := rand.Int64N(10000)
+	for i := range size {
+		offset += 1 + rand.Int64N(1000)
+		history.Manifests[i] = checkPointManifest{
+			LastOffset:           offset,
+			CountersBlobFileName: fmt.Sprintf("counters-%d", offset),
+			AllTimeItemsFileName: fmt.Sprintf("all-time-items-%d", offset),
+			CreatedAt:            time.Unix(faker.RandomUnixTime(), 0).UTC(),
+			FormatVersion:        rand.IntN(2),
+			Codec:                faker.Word(),
+		}
+	}
+	return history
+}
+
+func randomBlobsEncoding() blobsEncoding {
+	return blobsEncoding{
+		FormatVersion: rand.IntN(2),
+		Codec:         faker.Word(),
 	}
 }
 
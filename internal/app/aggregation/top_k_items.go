@@ -13,8 +13,8 @@ import (
 // topKGetAllItemsLimit is used to get all items in the topKItems.
 const topKGetAllItemsLimit = -1
 
-// topKMaxItemsSize is the maximum number of items that can be stored in the
-// topKItems.
+// topKMaxItemsSize is the default capacity of the all time items. Check points
+// produced before the capacity was recorded in the manifest are holding that many items.
 const topKMaxItemsSize = 1000
 
 type topKItem struct {
@@ -31,6 +31,13 @@ type topKItems interface {
 	load(vals []*topKItem)
 	getItems(limit int) []*topKItem
 	updateIfGreater(item topKItem)
+
+	// getMinItem returns the item with the lowest count and false if there are no items.
+	getMinItem() (*topKItem, bool)
+
+	// getItemRank returns the position of the item in descending order (starting from 1)
+	// and false if the item is not within top items.
+	getItemRank(itemID string) (int, bool)
 }
 
 type topKBTreeItems struct {
@@ -44,7 +51,7 @@ func (items *topKBTreeItems) getItems(limit int) []*topKItem {
 	if limit == topKGetAllItemsLimit {
 		limit = items.tree.Len()
 	}
-	result := make([]*topKItem, 0, limit)
+	result := make([]*topKItem, 0, min(limit, items.tree.Len()))
 	items.tree.Descend(func(i *topKItem) bool {
 		result = append(result, i)
 		return len(result) < limit
@@ -52,6 +59,23 @@ func (items *topKBTreeItems) getItems(limit int) []*topKItem {
 	return result
 }
 
+func (items *topKBTreeItems) getMinItem() (*topKItem, bool) {
+	return items.tree.Min()
+}
+
+func (items *topKBTreeItems) getItemRank(itemID string) (int, bool) {
+	item, ok := items.itemsByID[itemID]
+	if !ok {
+		return 0, false
+	}
+	rank := 0
+	items.tree.DescendGreaterThan(item, func(*topKItem) bool {
+		rank++
+		return true
+	})
+	return rank + 1, true
+}
+
 func (items *topKBTreeItems) load(vals []*topKItem) {
 	for _, val := range vals {
 		items.tree.ReplaceOrInsert(val)
@@ -181,6 +205,25 @@ func (items *topKHeapItems) getItems(limit int) []*topKItem {
 	return result[:limit]
 }
 
+// getMinItem returns the root of the heap. Items with the same count are not
+// ordered within the heap, so any of them may be returned.
+func (items *topKHeapItems) getMinItem() (*topKItem, bool) {
+	if len(items.items) == 0 {
+		return nil, false
+	}
+	return items.items[0], true
+}
+
+func (items *topKHeapItems) getItemRank(itemID string) (int, bool) {
+	index := slices.IndexFunc(items.getItems(topKGetAllItemsLimit), func(item *topKItem) bool {
+		return item.ItemID == itemID
+	})
+	if index < 0 {
+		return 0, false
+	}
+	return index + 1, true
+}
+
 func (items *topKHeapItems) updateIfGreater(item topKItem) {
 	if len(items.items) < items.maxSize {
 		heap.Push(&items.items, &item)
@@ -219,6 +262,18 @@ func (items *synchronisedTopKItems) getItems(limit int) []*topKItem {
 	return items.topKItems.getItems(limit)
 }
 
+func (items *synchronisedTopKItems) getMinItem() (*topKItem, bool) {
+	items.rwLock.RLock()
+	defer items.rwLock.RUnlock()
+	return items.topKItems.getMinItem()
+}
+
+func (items *synchronisedTopKItems) getItemRank(itemID string) (int, bool) {
+	items.rwLock.RLock()
+	defer items.rwLock.RUnlock()
+	return items.topKItems.getItemRank(itemID)
+}
+
 func (items *synchronisedTopKItems) updateIfGreater(item topKItem) {
 	items.rwLock.Lock()
 	defer items.rwLock.Unlock()
@@ -1,6 +1,7 @@
 package aggregation
 
 import (
+	"math"
 	"math/rand/v2"
 	"slices"
 	"strings"
@@ -151,6 +152,16 @@ func TestTopKItems(t *testing.T) {
 				wantItems = wantItems[:wantItemsCount]
 				assert.Equal(t, wantItems, actualItems)
 			})
+
+			t.Run("should return all items if limit is above the size", func(t *testing.T) {
+				originalItems := randomTopKItems(5 + rand.IntN(10))
+
+				items := newTopKBTreeItems(100)
+				items.load(originalItems)
+
+				actualItems := items.getItems(math.MaxInt)
+				assert.Len(t, actualItems, len(originalItems))
+			})
 		})
 
 		t.Run("updateIfGreater", func(t *testing.T) {
@@ -297,6 +308,84 @@ func TestTopKItems(t *testing.T) {
 				assert.Equal(t, wantItems, actualItems)
 			})
 		})
+
+		t.Run("getMinItem", func(t *testing.T) {
+			t.Run("should return item with the lowest count", func(t *testing.T) {
+				originalItems := randomTopKItems(5 + rand.IntN(5))
+				wantItem := &topKItem{ItemID: faker.UUIDHyphenated(), Count: -1 - rand.Int64N(1000)}
+				originalItems = append(originalItems, wantItem)
+
+				items := newTopKBTreeItems(100)
+				items.load(originalItems)
+
+				gotItem, ok := items.getMinItem()
+				assert.True(t, ok)
+				assert.Equal(t, wantItem, gotItem)
+			})
+
+			t.Run("should indicate there are no items", func(t *testing.T) {
+				items := newTopKBTreeItems(1 + rand.IntN(100))
+				_, ok := items.getMinItem()
+				assert.False(t, ok)
+			})
+		})
+
+		t.Run("getItemRank", func(t *testing.T) {
+			t.Run("should return rank of items in descending order", func(t *testing.T) {
+				var baseCount int64 = 10000
+				originalItems := []*topKItem{
+					{ItemID: "item1-" + faker.Word(), Count: baseCount + rand.Int64N(10)},
+					{ItemID: "item2-" + faker.Word(), Count: baseCount + 100 + rand.Int64N(10)},
+					{ItemID: "item3-" + faker.Word(), Count: baseCount + 200 + rand.Int64N(10)},
+					{ItemID: "item4-" + faker.Word(), Count: baseCount + 300 + rand.Int64N(10)},
+					{ItemID: "item5-" + faker.Word(), Count: baseCount + 400 + rand.Int64N(10)},
+				}
+
+				items := newTopKBTreeItems(100)
+				items.load(originalItems)
+
+				for i, item := range originalItems {
+					gotRank, ok := items.getItemRank(item.ItemID)
+					assert.True(t, ok, item.ItemID)
+					assert.Equal(t, len(originalItems)-i, gotRank, item.ItemID)
+				}
+			})
+
+			t.Run("should rank items with same count same as getItems", func(t *testing.T) {
+				count := rand.Int64N(1000)
+				originalItems := []*topKItem{
+					{ItemID: "item1-" + faker.Word(), Count: count},
+					{ItemID: "item2-" + faker.Word(), Count: count},
+					{ItemID: "item3-" + faker.Word(), Count: count},
+				}
+
+				items := newTopKBTreeItems(100)
+				items.load(originalItems)
+
+				for i, item := range items.getItems(topKGetAllItemsLimit) {
+					gotRank, ok := items.getItemRank(item.ItemID)
+					assert.True(t, ok, item.ItemID)
+					assert.Equal(t, i+1, gotRank, item.ItemID)
+				}
+			})
+
+			t.Run("should indicate item that is not within top items", func(t *testing.T) {
+				var baseCount int64 = 10000
+				originalItems := []*topKItem{
+					{ItemID: "item1-" + faker.Word(), Count: baseCount + rand.Int64N(10)},
+					{ItemID: "item2-" + faker.Word(), Count: baseCount + 100 + rand.Int64N(10)},
+					{ItemID: "item3-" + faker.Word(), Count: baseCount + 200 + rand.Int64N(10)},
+				}
+
+				items := newTopKBTreeItems(len(originalItems) - 1)
+				items.load(originalItems)
+
+				_, ok := items.getItemRank(originalItems[0].ItemID)
+				assert.False(t, ok)
+				_, ok = items.getItemRank(faker.UUIDHyphenated())
+				assert.False(t, ok)
+			})
+		})
 	}
 
 	t.Run("topKBTreeItems", func(t *testing.T) {
@@ -4,12 +4,33 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/gemyago/top-k-system-go/internal/app/models"
+	"github.com/gemyago/top-k-system-go/internal/diag"
+	"github.com/gemyago/top-k-system-go/internal/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/dig"
 )
 
+const tracerName = "github.com/gemyago/top-k-system-go/internal/app/ingestion"
+
+// heartbeatMessageKey is used as a key of heartbeat messages since they have no item id.
+const heartbeatMessageKey = "heartbeat"
+
+// Labels of the written events metric
+const (
+	writeResultSuccess = "success"
+	writeResultFailure = "failure"
+)
+
 type itemEventsWriter interface {
 	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
 }
@@ -17,34 +38,114 @@ type itemEventsWriter interface {
 type CommandsDeps struct {
 	dig.In
 
-	ItemEventsWriter itemEventsWriter
+	RootLogger *slog.Logger
+
+	// config
+	HeartbeatInterval time.Duration `name:"config.ingestion.heartbeatInterval"`
+
+	// service layer
+	ItemEventsWriter  itemEventsWriter
+	Time              services.TimeProvider
+	TickerFactory     func(d time.Duration) *time.Ticker
+	MetricsRegisterer prometheus.Registerer
+	TracerProvider    trace.TracerProvider
+	Propagator        propagation.TextMapPropagator
 }
 
 type Commands struct {
-	deps CommandsDeps
+	logger        *slog.Logger
+	tracer        trace.Tracer
+	writtenEvents *prometheus.CounterVec
+	deps          CommandsDeps
 }
 
 func (c *Commands) IngestItemEvent(ctx context.Context, evt *models.ItemEvent) error {
+	return c.writeItemEvent(ctx, []byte(evt.ItemID), evt)
+}
+
+// SendHeartbeat will submit heartbeat event to the item events stream.
+func (c *Commands) SendHeartbeat(ctx context.Context) error {
+	evt := models.NewHeartbeatEvent(c.deps.Time.Now())
+	return c.writeItemEvent(ctx, []byte(heartbeatMessageKey), &evt)
+}
+
+// StartHeartbeats will periodically submit heartbeat events until the context is done.
+// Heartbeats are disabled if the interval is not configured.
+func (c *Commands) StartHeartbeats(ctx context.Context) {
+	if c.deps.HeartbeatInterval <= 0 {
+		c.logger.InfoContext(ctx, "Heartbeats disabled")
+		return
+	}
+	c.logger.InfoContext(ctx, "Starting heartbeats", slog.Duration("interval", c.deps.HeartbeatInterval))
+	ticker := c.deps.TickerFactory(c.deps.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.SendHeartbeat(ctx); err != nil {
+				c.logger.ErrorContext(ctx, "Failed to send heartbeat", diag.ErrAttr(err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Commands) writeItemEvent(ctx context.Context, key []byte, evt *models.ItemEvent) error {
 	msgValue, err := json.Marshal(evt)
 	if err != nil { // coverage-ignore // unrealistic to simulate this error
 		return fmt.Errorf("failed to marshal event: %w", err)
 	}
 
+	ctx, span := c.tracer.Start(ctx, "item-events publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingKafkaMessageKey(string(key)),
+		),
+	)
+	defer span.End()
+
+	// consumers will continue the trace using the context passed in headers
+	msg := kafka.Message{
+		Key:   key,
+		Value: msgValue,
+	}
+	c.deps.Propagator.Inject(ctx, services.NewKafkaHeadersCarrier(&msg.Headers))
+
+	// correlation id lets following the event in the aggregator logs
+	if correlationID := diag.GetLogAttributesFromContext(ctx).CorrelationID; correlationID.Kind() == slog.KindString {
+		msg.Headers = append(msg.Headers, kafka.Header{
+			Key:   diag.CorrelationIDHeader,
+			Value: []byte(correlationID.String()),
+		})
+	}
+
 	err = c.deps.ItemEventsWriter.WriteMessages(
 		// It's going to write in batches outside of the API call
 		// we don't want to cancel to abort it
 		context.WithoutCancel(ctx),
-		kafka.Message{
-			Key:   []byte(evt.ItemID),
-			Value: msgValue,
-		},
+		msg,
 	)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to write item event")
+		c.writtenEvents.WithLabelValues(writeResultFailure).Inc()
 		return fmt.Errorf("failed to write item event (itemID=%v): %w", evt.ItemID, err)
 	}
+	c.writtenEvents.WithLabelValues(writeResultSuccess).Inc()
 	return nil
 }
 
 func NewCommands(deps CommandsDeps) *Commands {
-	return &Commands{deps}
+	return &Commands{
+		logger: deps.RootLogger.WithGroup("ingestion-commands"),
+		tracer: deps.TracerProvider.Tracer(tracerName),
+		writtenEvents: promauto.With(deps.MetricsRegisterer).NewCounterVec(prometheus.CounterOpts{
+			Name: "ingestion_item_events_written_total",
+			Help: "Number of item events (including heartbeats) submitted to the stream",
+		}, []string{"result"}),
+		deps: deps,
+	}
 }
@@ -4,21 +4,42 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"log/slog"
+	"math/rand/v2"
 	"testing"
+	"time"
 
 	"github.com/gemyago/top-k-system-go/internal/app/models"
+	"github.com/gemyago/top-k-system-go/internal/diag"
 	"github.com/gemyago/top-k-system-go/internal/services"
 	"github.com/go-faker/faker/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/samber/lo"
 	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestCommands(t *testing.T) {
 	newMockDeps := func(t *testing.T) CommandsDeps {
 		return CommandsDeps{
-			ItemEventsWriter: services.NewMockKafkaWriter(t),
+			RootLogger:        diag.RootTestLogger(),
+			HeartbeatInterval: time.Duration(1+rand.IntN(1000)) * time.Second,
+			ItemEventsWriter:  services.NewMockKafkaWriter(t),
+			Time:              services.NewMockNow(),
+			MetricsRegisterer: prometheus.NewRegistry(),
+			TracerProvider:    noop.NewTracerProvider(),
+			Propagator:        services.NewTextMapPropagator(),
+			TickerFactory: func(_ time.Duration) *time.Ticker {
+				return &time.Ticker{C: make(chan time.Time)}
+			},
 		}
 	}
 
@@ -40,6 +61,7 @@ func TestCommands(t *testing.T) {
 			).Return(nil)
 
 			lo.Must0(commands.IngestItemEvent(context.Background(), &wantEvt))
+			assert.InDelta(t, 1.0, testutil.ToFloat64(commands.writtenEvents.WithLabelValues(writeResultSuccess)), 0)
 		})
 		t.Run("should fail if write fails", func(t *testing.T) {
 			mockDeps := newMockDeps(t)
@@ -55,6 +77,129 @@ func TestCommands(t *testing.T) {
 
 			gotErr := commands.IngestItemEvent(context.Background(), &wantEvt)
 			require.ErrorIs(t, gotErr, wantErr)
+			assert.InDelta(t, 1.0, testutil.ToFloat64(commands.writtenEvents.WithLabelValues(writeResultFailure)), 0)
+		})
+		t.Run("should pass trace context of the producer span in headers", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			recorder := tracetest.NewSpanRecorder()
+			mockDeps.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			commands := NewCommands(mockDeps)
+			wantEvt := models.MakeRandomItemEvent()
+
+			var gotMessage kafka.Message
+			mockWriter, _ := mockDeps.ItemEventsWriter.(*services.MockKafkaWriter)
+			mockWriter.EXPECT().WriteMessages(mock.Anything, mock.Anything).
+				RunAndReturn(func(_ context.Context, msgs ...kafka.Message) error {
+					gotMessage = msgs[0]
+					return nil
+				})
+
+			lo.Must0(commands.IngestItemEvent(context.Background(), &wantEvt))
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, trace.SpanKindProducer, spans[0].SpanKind())
+			gotCtx := mockDeps.Propagator.Extract(
+				context.Background(),
+				services.NewKafkaHeadersCarrier(&gotMessage.Headers),
+			)
+			assert.Equal(t, spans[0].SpanContext().SpanID(), trace.SpanContextFromContext(gotCtx).SpanID())
+			assert.Equal(t, spans[0].SpanContext().TraceID(), trace.SpanContextFromContext(gotCtx).TraceID())
+		})
+		t.Run("should pass correlation id in headers", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+			wantEvt := models.MakeRandomItemEvent()
+			wantCorrelationID := faker.UUIDHyphenated()
+			ctx := diag.SetLogAttributesToContext(context.Background(), diag.LogAttributes{
+				CorrelationID: slog.StringValue(wantCorrelationID),
+			})
+
+			mockWriter, _ := mockDeps.ItemEventsWriter.(*services.MockKafkaWriter)
+			mockWriter.EXPECT().WriteMessages(
+				mock.AnythingOfType("withoutCancelCtx"),
+				kafka.Message{
+					Key:   []byte(wantEvt.ItemID),
+					Value: lo.Must(json.Marshal(&wantEvt)),
+					Headers: []kafka.Header{
+						{Key: diag.CorrelationIDHeader, Value: []byte(wantCorrelationID)},
+					},
+				},
+			).Return(nil)
+
+			lo.Must0(commands.IngestItemEvent(ctx, &wantEvt))
+		})
+		t.Run("should mark producer span as failed if write fails", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			recorder := tracetest.NewSpanRecorder()
+			mockDeps.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			commands := NewCommands(mockDeps)
+			wantEvt := models.MakeRandomItemEvent()
+
+			mockWriter, _ := mockDeps.ItemEventsWriter.(*services.MockKafkaWriter)
+			wantErr := errors.New(faker.Sentence())
+			mockWriter.EXPECT().WriteMessages(mock.Anything, mock.Anything).Return(wantErr)
+
+			require.ErrorIs(t, commands.IngestItemEvent(context.Background(), &wantEvt), wantErr)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, codes.Error, spans[0].Status().Code)
+		})
+	})
+
+	t.Run("SendHeartbeat", func(t *testing.T) {
+		t.Run("should write heartbeat event to the topic", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			commands := NewCommands(mockDeps)
+			wantEvt := models.NewHeartbeatEvent(services.MockNowValue(mockDeps.Time))
+
+			mockWriter, _ := mockDeps.ItemEventsWriter.(*services.MockKafkaWriter)
+			mockWriter.EXPECT().WriteMessages(
+				mock.AnythingOfType("withoutCancelCtx"),
+				kafka.Message{
+					Key:   []byte(heartbeatMessageKey),
+					Value: lo.Must(json.Marshal(&wantEvt)),
+				},
+			).Return(nil)
+
+			lo.Must0(commands.SendHeartbeat(context.Background()))
+		})
+	})
+
+	t.Run("StartHeartbeats", func(t *testing.T) {
+		t.Run("should send heartbeats on every tick until context is done", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			ticks := make(chan time.Time)
+			mockDeps.TickerFactory = func(d time.Duration) *time.Ticker {
+				assert.Equal(t, mockDeps.HeartbeatInterval, d)
+				return &time.Ticker{C: ticks}
+			}
+			commands := NewCommands(mockDeps)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			ticksCount := 2 + rand.IntN(3)
+			mockWriter, _ := mockDeps.ItemEventsWriter.(*services.MockKafkaWriter)
+			mockWriter.EXPECT().WriteMessages(mock.Anything, mock.Anything).
+				Return(errors.New(faker.Sentence())). // errors should not stop heartbeats
+				Times(ticksCount)
+
+			done := make(chan struct{})
+			go func() {
+				commands.StartHeartbeats(ctx)
+				close(done)
+			}()
+			for range ticksCount {
+				ticks <- time.Now()
+			}
+			cancel()
+			<-done
+		})
+		t.Run("should return immediately if disabled", func(t *testing.T) {
+			mockDeps := newMockDeps(t)
+			mockDeps.HeartbeatInterval = 0
+			commands := NewCommands(mockDeps)
+			commands.StartHeartbeats(context.Background())
 		})
 	})
 }
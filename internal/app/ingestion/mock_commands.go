@@ -71,6 +71,85 @@ func (_c *MockCommands_IngestItemEvent_Call) RunAndReturn(run func(context.Conte
 	return _c
 }
 
+// SendHeartbeat provides a mock function with given fields: ctx
+func (_m *MockCommands) SendHeartbeat(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendHeartbeat")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockCommands_SendHeartbeat_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SendHeartbeat'
+type MockCommands_SendHeartbeat_Call struct {
+	*mock.Call
+}
+
+// SendHeartbeat is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockCommands_Expecter) SendHeartbeat(ctx interface{}) *MockCommands_SendHeartbeat_Call {
+	return &MockCommands_SendHeartbeat_Call{Call: _e.mock.On("SendHeartbeat", ctx)}
+}
+
+func (_c *MockCommands_SendHeartbeat_Call) Run(run func(ctx context.Context)) *MockCommands_SendHeartbeat_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockCommands_SendHeartbeat_Call) Return(_a0 error) *MockCommands_SendHeartbeat_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockCommands_SendHeartbeat_Call) RunAndReturn(run func(context.Context) error) *MockCommands_SendHeartbeat_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// StartHeartbeats provides a mock function with given fields: ctx
+func (_m *MockCommands) StartHeartbeats(ctx context.Context) {
+	_m.Called(ctx)
+}
+
+// MockCommands_StartHeartbeats_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'StartHeartbeats'
+type MockCommands_StartHeartbeats_Call struct {
+	*mock.Call
+}
+
+// StartHeartbeats is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockCommands_Expecter) StartHeartbeats(ctx interface{}) *MockCommands_StartHeartbeats_Call {
+	return &MockCommands_StartHeartbeats_Call{Call: _e.mock.On("StartHeartbeats", ctx)}
+}
+
+func (_c *MockCommands_StartHeartbeats_Call) Run(run func(ctx context.Context)) *MockCommands_StartHeartbeats_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockCommands_StartHeartbeats_Call) Return() *MockCommands_StartHeartbeats_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockCommands_StartHeartbeats_Call) RunAndReturn(run func(context.Context)) *MockCommands_StartHeartbeats_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockCommands creates a new instance of MockCommands. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockCommands(t interface {
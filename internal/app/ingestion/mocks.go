@@ -14,6 +14,8 @@ import (
 
 type mockCommands interface {
 	IngestItemEvent(ctx context.Context, evt *models.ItemEvent) error
+	SendHeartbeat(ctx context.Context) error
+	StartHeartbeats(ctx context.Context)
 }
 
 var _ mockCommands = (*Commands)(nil)
@@ -5,4 +5,15 @@ import "time"
 type ItemEvent struct {
 	ItemID     string    `json:"itemId"`
 	IngestedAt time.Time `json:"ingestedAt"`
+
+	// Heartbeat events are not counted. They are periodically submitted
+	// to measure the latency of the aggregation.
+	Heartbeat bool `json:"heartbeat,omitempty"`
+}
+
+func NewHeartbeatEvent(ingestedAt time.Time) ItemEvent {
+	return ItemEvent{
+		IngestedAt: ingestedAt,
+		Heartbeat:  true,
+	}
 }
@@ -10,7 +10,9 @@ import (
 
 func MakeRandomItemEvent() ItemEvent {
 	return ItemEvent{
-		ItemID:     faker.UUIDHyphenated(),
-		IngestedAt: time.UnixMilli(faker.RandomUnixTime()),
+		ItemID: faker.UUIDHyphenated(),
+
+		// UTC time is restored from json with the same location regardless of the local time zone
+		IngestedAt: time.UnixMilli(faker.RandomUnixTime()).UTC(),
 	}
 }
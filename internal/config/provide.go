@@ -59,13 +59,55 @@ func Provide(container *dig.Container, cfg *viper.Viper) error {
 		provideConfigValue(cfg, "kafka.readerMaxWait").asDuration(),
 		provideConfigValue(cfg, "kafka.writeTimeout").asDuration(),
 		provideConfigValue(cfg, "kafka.maxWriteAttempts").asInt(),
+		provideConfigValue(cfg, "kafka.deadLetter.target").asString(),
+		provideConfigValue(cfg, "kafka.deadLetter.topic").asString(),
+		provideConfigValue(cfg, "kafka.deadLetter.file").asString(),
+
+		// tracing
+		provideConfigValue(cfg, "tracing.exporter").asString(),
+		provideConfigValue(cfg, "tracing.serviceName").asString(),
+		provideConfigValue(cfg, "tracing.otlpEndpoint").asString(),
+		provideConfigValue(cfg, "tracing.otlpInsecure").asBool(),
+		provideConfigValue(cfg, "tracing.file").asString(),
+
+		// ingestion
+		provideConfigValue(cfg, "ingestion.heartbeatInterval").asDuration(),
 
 		// aggregator
 		provideConfigValue(cfg, "aggregator.flushInterval").asDuration(),
 		provideConfigValue(cfg, "aggregator.verbose").asBool(),
 		provideConfigValue(cfg, "aggregator.itemEventLogRate").asInt64(),
+		provideConfigValue(cfg, "aggregator.restoreCheckPointOffset").asInt64(),
+		provideConfigValue(cfg, "aggregator.checkPoints.enabled").asBool(),
+		provideConfigValue(cfg, "aggregator.checkPoints.interval").asDuration(),
+		provideConfigValue(cfg, "aggregator.liveStateReadTimeout").asDuration(),
+		provideConfigValue(cfg, "aggregator.maxBufferedItems").asInt(),
+		provideConfigValue(cfg, "aggregator.offsetLagInterval").asDuration(),
+		provideConfigValue(cfg, "aggregator.liveMaxOffsetLag").asInt64(),
+		provideConfigValue(cfg, "aggregator.rankIndex.enabled").asBool(),
+		provideConfigValue(cfg, "aggregator.topK.allTime.capacity").asInt(),
+		provideConfigValue(cfg, "aggregator.fetchRetry.initialBackoff").asDuration(),
+		provideConfigValue(cfg, "aggregator.fetchRetry.maxBackoff").asDuration(),
+		provideConfigValue(cfg, "aggregator.fetchRetry.maxAttempts").asInt(),
+
+		// checkpointer
+		provideConfigValue(cfg, "checkpointer.retention.keepLast").asInt(),
+		provideConfigValue(cfg, "checkpointer.retention.maxAge").asDuration(),
+		provideConfigValue(cfg, "checkpointer.lease.ttl").asDuration(),
+		provideConfigValue(cfg, "checkpointer.blobs.format").asString(),
+		provideConfigValue(cfg, "checkpointer.blobs.compression").asString(),
+		provideConfigValue(cfg, "checkpointer.blobs.encryptionKey").asString(),
 
 		// blob storage
+		provideConfigValue(cfg, "blobstorage.type").asString(),
 		provideConfigValue(cfg, "blobstorage.localFolder").asString(),
+		provideConfigValue(cfg, "blobstorage.s3.endpoint").asString(),
+		provideConfigValue(cfg, "blobstorage.s3.region").asString(),
+		provideConfigValue(cfg, "blobstorage.s3.bucket").asString(),
+		provideConfigValue(cfg, "blobstorage.s3.prefix").asString(),
+		provideConfigValue(cfg, "blobstorage.s3.accessKeyId").asString(),
+		provideConfigValue(cfg, "blobstorage.s3.secretAccessKey").asString(),
+		provideConfigValue(cfg, "blobstorage.s3.usePathStyle").asBool(),
+		provideConfigValue(cfg, "blobstorage.s3.partSizeMB").asInt64(),
 	)
 }
@@ -13,6 +13,10 @@ const (
 	contextDiagAttrs = contextKey("diag.context-key.log-attribs")
 )
 
+// CorrelationIDHeader is a header to pass the correlation id with http requests
+// and kafka messages.
+const CorrelationIDHeader = "x-correlation-id"
+
 type LogAttributes struct {
 	CorrelationID slog.Value
 }
@@ -2,11 +2,59 @@ package blobstorage
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"time"
+
+	"go.uber.org/dig"
 )
 
+type BlobInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
 type Storage interface {
+	// Upload will atomically replace the blob with a given key. Readers will
+	// either see the previous contents or the new one, but never partial data.
 	Upload(ctx context.Context, key string, contents io.Reader) error
+
+	// UploadIfNotExists will upload the contents only if there is no blob with a given key.
+	// The error wrapping fs.ErrExist is returned otherwise. The check and the upload are atomic.
+	UploadIfNotExists(ctx context.Context, key string, contents io.Reader) error
+
 	Download(ctx context.Context, key string, out io.Writer) error
 	Delete(ctx context.Context, key string) error
+
+	// List will return blobs with keys starting with a given prefix sorted by key.
+	// Empty prefix will list all blobs.
+	List(ctx context.Context, prefix string) ([]BlobInfo, error)
+
+	// Stat will return the blob info. The error wrapping fs.ErrNotExist
+	// is returned if there is no blob with a given key.
+	Stat(ctx context.Context, key string) (BlobInfo, error)
+
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+type StorageDeps struct {
+	dig.In
+
+	StorageType string `name:"config.blobstorage.type"`
+
+	Local LocalStorageDeps
+	S3    S3StorageDeps
+}
+
+// NewStorage will create the storage of the configured type.
+func NewStorage(deps StorageDeps) (Storage, error) {
+	switch deps.StorageType {
+	case "local":
+		return NewLocalStorage(deps.Local), nil
+	case "s3":
+		return NewS3Storage(deps.S3)
+	default:
+		return nil, fmt.Errorf("unsupported blob storage type: %s", deps.StorageType)
+	}
 }
@@ -0,0 +1,208 @@
+package blobstorage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3Server is a minimal in-memory S3-compatible stand-in. It supports path style
+// requests of operations used by the s3Storage only.
+type fakeS3Server struct {
+	*httptest.Server
+
+	bucket   string
+	pageSize int
+
+	mu               sync.Mutex
+	objects          map[string]fakeS3Object
+	uploads          map[string]map[int][]byte
+	multipartUploads int
+}
+
+type fakeS3Object struct {
+	data         []byte
+	lastModified time.Time
+}
+
+func newFakeS3Server(t *testing.T, bucket string) *fakeS3Server {
+	srv := &fakeS3Server{
+		bucket:   bucket,
+		pageSize: 1000,
+		objects:  map[string]fakeS3Object{},
+		uploads:  map[string]map[int][]byte{},
+	}
+	srv.Server = httptest.NewServer(http.HandlerFunc(srv.handle))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (s *fakeS3Server) putObject(key string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = fakeS3Object{data: data, lastModified: time.Now().UTC().Truncate(time.Second)}
+}
+
+func (s *fakeS3Server) getObject(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[key]
+	return obj.data, ok
+}
+
+func (s *fakeS3Server) writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (s *fakeS3Server) writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	data, err := xml.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	_, _ = w.Write(data)
+}
+
+func (s *fakeS3Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s.bucket {
+		s.writeError(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		s.listObjects(w, query.Get("prefix"), query.Get("continuation-token"))
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID := strconv.Itoa(len(s.uploads) + 1)
+		s.uploads[uploadID] = map[int][]byte{}
+		s.multipartUploads++
+		s.writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadID string `xml:"UploadId"`
+		}{Bucket: bucket, Key: key, UploadID: uploadID})
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := s.uploads[query.Get("uploadId")]
+		if !ok {
+			s.writeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[partNumber] = readAllBody(r)
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, partNumber))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, ok := s.uploads[query.Get("uploadId")]
+		if !ok {
+			s.writeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		partNumbers := make([]int, 0, len(parts))
+		for partNumber := range parts {
+			partNumbers = append(partNumbers, partNumber)
+		}
+		slices.Sort(partNumbers)
+		var data bytes.Buffer
+		for _, partNumber := range partNumbers {
+			data.Write(parts[partNumber])
+		}
+		delete(s.uploads, query.Get("uploadId"))
+		s.objects[key] = fakeS3Object{data: data.Bytes(), lastModified: time.Now().UTC().Truncate(time.Second)}
+		s.writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucket, Key: key, ETag: `"multipart"`})
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(s.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		if _, exists := s.objects[key]; exists && r.Header.Get("If-None-Match") == "*" {
+			s.writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		s.objects[key] = fakeS3Object{data: readAllBody(r), lastModified: time.Now().UTC().Truncate(time.Second)}
+		w.Header().Set("ETag", `"object"`)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := s.objects[key]
+		if !ok {
+			s.writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		w.Header().Set("Last-Modified", obj.lastModified.Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(obj.data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+type fakeS3ListContents struct {
+	Key          string
+	LastModified string
+	Size         int
+}
+
+func (s *fakeS3Server) listObjects(w http.ResponseWriter, prefix, continuationToken string) {
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) && key > continuationToken {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Name                  string
+		Prefix                string
+		KeyCount              int
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+		Contents              []fakeS3ListContents
+	}{Name: s.bucket, Prefix: prefix}
+	if len(keys) > s.pageSize {
+		keys = keys[:s.pageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		obj := s.objects[key]
+		result.Contents = append(result.Contents, fakeS3ListContents{
+			Key:          key,
+			LastModified: obj.lastModified.Format("2006-01-02T15:04:05.000Z"),
+			Size:         len(obj.data),
+		})
+	}
+	result.KeyCount = len(result.Contents)
+	s.writeXML(w, result)
+}
+
+func readAllBody(r *http.Request) []byte {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -0,0 +1,120 @@
+package blobstorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// ErrLeaseHeld indicates that the lease is held by another owner and is not expired yet.
+var ErrLeaseHeld = errors.New("lease is held by another owner")
+
+type leaseRecord struct {
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type LeaseParams struct {
+	// Key of the lease blob
+	Key string
+
+	// Owner must be unique for each process that may acquire the lease
+	Owner string
+
+	// TTL is a duration after which the lease is considered expired and can
+	// be acquired by other owner
+	TTL time.Duration
+
+	// Now is a current time
+	Now time.Time
+}
+
+func readLeaseRecord(ctx context.Context, storage Storage, key string) (leaseRecord, error) {
+	var data bytes.Buffer
+	if err := storage.Download(ctx, key, &data); err != nil {
+		return leaseRecord{}, fmt.Errorf("failed to read lease %s: %w", key, err)
+	}
+	var record leaseRecord
+	if err := json.Unmarshal(data.Bytes(), &record); err != nil {
+		return leaseRecord{}, fmt.Errorf("failed to decode lease %s: %w", key, err)
+	}
+	return record, nil
+}
+
+// AcquireLease will acquire the storage based exclusive lease. The lease is acquired
+// by creating the blob conditionally, so only one owner may succeed. Expired lease is
+// taken over by removing and creating it again. Acquiring the lease again by the same
+// owner will extend it. The error wrapping ErrLeaseHeld is returned if the lease is
+// held by other owner.
+//
+// Note: two owners may take over the same expired lease concurrently in a rare case
+// when the removal of the expired lease happens right after other owner has acquired it.
+// Callers should keep their writes idempotent.
+func AcquireLease(ctx context.Context, storage Storage, params LeaseParams) error {
+	data, err := json.Marshal(leaseRecord{
+		Owner:     params.Owner,
+		ExpiresAt: params.Now.Add(params.TTL),
+	})
+	if err != nil { // coverage-ignore // should never happen
+		return fmt.Errorf("failed to encode lease %s: %w", params.Key, err)
+	}
+
+	err = storage.UploadIfNotExists(ctx, params.Key, bytes.NewReader(data))
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("failed to create lease %s: %w", params.Key, err)
+	}
+
+	existing, err := readLeaseRecord(ctx, storage, params.Key)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// released in between, will try to create it again
+	case err != nil:
+		return err
+	case existing.Owner == params.Owner:
+		if err = storage.Upload(ctx, params.Key, bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("failed to extend lease %s: %w", params.Key, err)
+		}
+		return nil
+	case existing.ExpiresAt.After(params.Now):
+		return fmt.Errorf("%w: key %s, owner %s, expires at %s",
+			ErrLeaseHeld, params.Key, existing.Owner, existing.ExpiresAt.Format(time.RFC3339),
+		)
+	default:
+		if err = storage.Delete(ctx, params.Key); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove expired lease %s: %w", params.Key, err)
+		}
+	}
+
+	if err = storage.UploadIfNotExists(ctx, params.Key, bytes.NewReader(data)); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%w: key %s", ErrLeaseHeld, params.Key)
+		}
+		return fmt.Errorf("failed to create lease %s: %w", params.Key, err)
+	}
+	return nil
+}
+
+// ReleaseLease will release the lease if it is held by a given owner.
+func ReleaseLease(ctx context.Context, storage Storage, key string, owner string) error {
+	existing, err := readLeaseRecord(ctx, storage, key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if existing.Owner != owner {
+		return nil
+	}
+	if err = storage.Delete(ctx, key); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to release lease %s: %w", key, err)
+	}
+	return nil
+}
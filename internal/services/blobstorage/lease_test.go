@@ -0,0 +1,196 @@
+package blobstorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/gemyago/top-k-system-go/internal/diag"
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLease(t *testing.T) {
+	newStorage := func(t *testing.T) Storage {
+		return NewLocalStorage(LocalStorageDeps{
+			LocalStorageFolder: t.TempDir(),
+			RootLogger:         diag.RootTestLogger(),
+		})
+	}
+
+	randomLeaseParams := func() LeaseParams {
+		return LeaseParams{
+			Key:   faker.UUIDHyphenated(),
+			Owner: faker.UUIDHyphenated(),
+			TTL:   time.Duration(1+rand.IntN(1000)) * time.Second,
+			Now:   time.UnixMilli(faker.RandomUnixTime()),
+		}
+	}
+
+	t.Run("AcquireLease", func(t *testing.T) {
+		t.Run("should acquire the lease if not exists", func(t *testing.T) {
+			storage := newStorage(t)
+			ctx := context.Background()
+			params := randomLeaseParams()
+
+			require.NoError(t, AcquireLease(ctx, storage, params))
+
+			record, err := readLeaseRecord(ctx, storage, params.Key)
+			require.NoError(t, err)
+			assert.Equal(t, params.Owner, record.Owner)
+			assert.True(t, params.Now.Add(params.TTL).Equal(record.ExpiresAt))
+		})
+		t.Run("should fail if the lease is held by other owner", func(t *testing.T) {
+			storage := newStorage(t)
+			ctx := context.Background()
+			params := randomLeaseParams()
+			require.NoError(t, AcquireLease(ctx, storage, params))
+
+			otherParams := params
+			otherParams.Owner = faker.UUIDHyphenated()
+			otherParams.Now = params.Now.Add(params.TTL - time.Second)
+			require.ErrorIs(t, AcquireLease(ctx, storage, otherParams), ErrLeaseHeld)
+
+			record, err := readLeaseRecord(ctx, storage, params.Key)
+			require.NoError(t, err)
+			assert.Equal(t, params.Owner, record.Owner)
+		})
+		t.Run("should extend the lease held by the same owner", func(t *testing.T) {
+			storage := newStorage(t)
+			ctx := context.Background()
+			params := randomLeaseParams()
+			require.NoError(t, AcquireLease(ctx, storage, params))
+
+			params.Now = params.Now.Add(time.Second)
+			require.NoError(t, AcquireLease(ctx, storage, params))
+
+			record, err := readLeaseRecord(ctx, storage, params.Key)
+			require.NoError(t, err)
+			assert.True(t, params.Now.Add(params.TTL).Equal(record.ExpiresAt))
+		})
+		t.Run("should take over expired lease", func(t *testing.T) {
+			storage := newStorage(t)
+			ctx := context.Background()
+			params := randomLeaseParams()
+			require.NoError(t, AcquireLease(ctx, storage, params))
+
+			otherParams := params
+			otherParams.Owner = faker.UUIDHyphenated()
+			otherParams.Now = params.Now.Add(params.TTL)
+			require.NoError(t, AcquireLease(ctx, storage, otherParams))
+
+			record, err := readLeaseRecord(ctx, storage, params.Key)
+			require.NoError(t, err)
+			assert.Equal(t, otherParams.Owner, record.Owner)
+		})
+		t.Run("should acquire the lease released in between", func(t *testing.T) {
+			storage := NewMockStorage(t)
+			ctx := context.Background()
+			params := randomLeaseParams()
+
+			storage.EXPECT().UploadIfNotExists(ctx, params.Key, mock.Anything).Return(
+				fs.ErrExist,
+			).Once()
+			storage.EXPECT().Download(ctx, params.Key, mock.Anything).Return(fs.ErrNotExist)
+			storage.EXPECT().UploadIfNotExists(ctx, params.Key, mock.Anything).Return(nil).Once()
+
+			require.NoError(t, AcquireLease(ctx, storage, params))
+		})
+		t.Run("should fail if other owner took over expired lease first", func(t *testing.T) {
+			storage := NewMockStorage(t)
+			ctx := context.Background()
+			params := randomLeaseParams()
+
+			storage.EXPECT().UploadIfNotExists(ctx, params.Key, mock.Anything).Return(fs.ErrExist)
+			storage.EXPECT().Download(ctx, params.Key, mock.Anything).Return(fs.ErrNotExist)
+
+			require.ErrorIs(t, AcquireLease(ctx, storage, params), ErrLeaseHeld)
+		})
+		t.Run("should fail if failed to create the lease", func(t *testing.T) {
+			storage := NewMockStorage(t)
+			ctx := context.Background()
+			params := randomLeaseParams()
+			wantErr := errors.New(faker.Sentence())
+
+			storage.EXPECT().UploadIfNotExists(ctx, params.Key, mock.Anything).Return(wantErr)
+
+			require.ErrorIs(t, AcquireLease(ctx, storage, params), wantErr)
+		})
+		t.Run("should fail if failed to read existing lease", func(t *testing.T) {
+			storage := NewMockStorage(t)
+			ctx := context.Background()
+			params := randomLeaseParams()
+			wantErr := errors.New(faker.Sentence())
+
+			storage.EXPECT().UploadIfNotExists(ctx, params.Key, mock.Anything).Return(fs.ErrExist)
+			storage.EXPECT().Download(ctx, params.Key, mock.Anything).Return(wantErr)
+
+			require.ErrorIs(t, AcquireLease(ctx, storage, params), wantErr)
+		})
+		t.Run("should fail if failed to remove expired lease", func(t *testing.T) {
+			storage := NewMockStorage(t)
+			ctx := context.Background()
+			params := randomLeaseParams()
+			wantErr := errors.New(faker.Sentence())
+
+			storage.EXPECT().UploadIfNotExists(ctx, params.Key, mock.Anything).Return(fs.ErrExist)
+			storage.EXPECT().Download(ctx, params.Key, mock.Anything).RunAndReturn(
+				func(_ context.Context, _ string, out io.Writer) error {
+					return json.NewEncoder(out).Encode(leaseRecord{
+						Owner:     faker.UUIDHyphenated(),
+						ExpiresAt: params.Now.Add(-time.Second),
+					})
+				},
+			)
+			storage.EXPECT().Delete(ctx, params.Key).Return(wantErr)
+
+			require.ErrorIs(t, AcquireLease(ctx, storage, params), wantErr)
+		})
+	})
+
+	t.Run("ReleaseLease", func(t *testing.T) {
+		t.Run("should release the lease held by the owner", func(t *testing.T) {
+			storage := newStorage(t)
+			ctx := context.Background()
+			params := randomLeaseParams()
+			require.NoError(t, AcquireLease(ctx, storage, params))
+
+			require.NoError(t, ReleaseLease(ctx, storage, params.Key, params.Owner))
+
+			_, err := readLeaseRecord(ctx, storage, params.Key)
+			require.ErrorIs(t, err, fs.ErrNotExist)
+		})
+		t.Run("should not release the lease held by other owner", func(t *testing.T) {
+			storage := newStorage(t)
+			ctx := context.Background()
+			params := randomLeaseParams()
+			require.NoError(t, AcquireLease(ctx, storage, params))
+
+			require.NoError(t, ReleaseLease(ctx, storage, params.Key, faker.UUIDHyphenated()))
+
+			record, err := readLeaseRecord(ctx, storage, params.Key)
+			require.NoError(t, err)
+			assert.Equal(t, params.Owner, record.Owner)
+		})
+		t.Run("should do nothing if no lease", func(t *testing.T) {
+			storage := newStorage(t)
+			require.NoError(t, ReleaseLease(context.Background(), storage, faker.UUIDHyphenated(), faker.UUIDHyphenated()))
+		})
+		t.Run("should fail if failed to read the lease", func(t *testing.T) {
+			storage := NewMockStorage(t)
+			ctx := context.Background()
+			key := faker.UUIDHyphenated()
+			wantErr := errors.New(faker.Sentence())
+			storage.EXPECT().Download(ctx, key, mock.Anything).Return(wantErr)
+
+			require.ErrorIs(t, ReleaseLease(ctx, storage, key, faker.UUIDHyphenated()), wantErr)
+		})
+	})
+}
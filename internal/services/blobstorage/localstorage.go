@@ -2,11 +2,15 @@ package blobstorage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"os"
-	"path"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"go.uber.org/dig"
 )
@@ -16,24 +20,72 @@ type localStorage struct {
 	logger *slog.Logger
 }
 
+func (s *localStorage) filePath(key string) string {
+	return filepath.Join(s.LocalStorageFolder, filepath.FromSlash(key))
+}
+
+// writeTempFile will write the contents to a temp file next to the target one.
+// Missing directories of the target are created. The temp file is synced to make
+// sure the data is on disk before it is renamed or linked to the target.
+func (s *localStorage) writeTempFile(key string, contents io.Reader) (string, error) {
+	filePath := s.filePath(key)
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create folder for %s: %w", key, err)
+	}
+
+	// Temp files are hidden so they are never listed
+	tmpFile, err := os.CreateTemp(dir, "."+filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file for %s: %w", key, err)
+	}
+	_, err = io.Copy(tmpFile, contents)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to write to temp file for %s: %w", key, err)
+	}
+	return tmpFile.Name(), nil
+}
+
 func (s *localStorage) Upload(ctx context.Context, key string, contents io.Reader) error {
-	filePath := path.Join(s.LocalStorageFolder, key)
+	filePath := s.filePath(key)
 	s.logger.DebugContext(ctx, "Writing file", slog.String("key", key), slog.String("path", filePath))
-	file, err := os.Create(filePath)
+
+	tmpFileName, err := s.writeTempFile(key, contents)
 	if err != nil {
-		return fmt.Errorf("failed to create file %s: %w", key, err)
+		return err
 	}
-	defer file.Close()
+	if err = os.Rename(tmpFileName, filePath); err != nil {
+		os.Remove(tmpFileName)
+		return fmt.Errorf("failed to write file %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *localStorage) UploadIfNotExists(ctx context.Context, key string, contents io.Reader) error {
+	filePath := s.filePath(key)
+	s.logger.DebugContext(ctx, "Writing file if not exists", slog.String("key", key), slog.String("path", filePath))
 
-	_, err = io.Copy(file, contents)
+	// Unlike rename, linking fails if the target exists
+	tmpFileName, err := s.writeTempFile(key, contents)
 	if err != nil {
-		return fmt.Errorf("failed to write to file %s: %w", key, err)
+		return err
+	}
+	defer os.Remove(tmpFileName)
+	if err = os.Link(tmpFileName, filePath); err != nil {
+		return fmt.Errorf("failed to create file %s: %w", key, err)
 	}
 	return nil
 }
 
 func (s *localStorage) Download(ctx context.Context, key string, out io.Writer) error {
-	filePath := path.Join(s.LocalStorageFolder, key)
+	filePath := s.filePath(key)
 	s.logger.DebugContext(ctx, "Reading file", slog.String("key", key), slog.String("path", filePath))
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -48,13 +100,70 @@ func (s *localStorage) Download(ctx context.Context, key string, out io.Writer)
 }
 
 func (s *localStorage) Delete(_ context.Context, key string) error {
-	filePath := path.Join(s.LocalStorageFolder, key)
+	filePath := s.filePath(key)
 	if err := os.Remove(filePath); err != nil {
 		return fmt.Errorf("failed to remove file %s: %w", filePath, err)
 	}
 	return nil
 }
 
+func (s *localStorage) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	s.logger.DebugContext(ctx, "Listing files", slog.String("prefix", prefix))
+	var result []BlobInfo
+	err := filepath.WalkDir(s.LocalStorageFolder, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+		relPath, err := filepath.Rel(s.LocalStorageFolder, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relPath)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		result = append(result, BlobInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return []BlobInfo{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files with prefix %s: %w", prefix, err)
+	}
+	slices.SortFunc(result, func(a, b BlobInfo) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return result, nil
+}
+
+func (s *localStorage) Stat(_ context.Context, key string) (BlobInfo, error) {
+	filePath := s.filePath(key)
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return BlobInfo{}, fmt.Errorf("failed to stat file %s: %w", filePath, err)
+	}
+	if info.IsDir() {
+		return BlobInfo{}, fmt.Errorf("file %s is a folder: %w", filePath, fs.ErrNotExist)
+	}
+	return BlobInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()}, nil
+}
+
+func (s *localStorage) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.Stat(ctx, key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 type LocalStorageDeps struct {
 	dig.In
 
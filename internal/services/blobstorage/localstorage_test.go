@@ -3,9 +3,13 @@ package blobstorage
 import (
 	"bytes"
 	"context"
+	"errors"
+	"io/fs"
 	"os"
 	"path"
+	"slices"
 	"testing"
+	"testing/iotest"
 
 	"github.com/gemyago/top-k-system-go/internal/diag"
 	"github.com/go-faker/faker/v4"
@@ -38,18 +42,143 @@ func TestLocalStorage(t *testing.T) {
 			assert.Equal(t, wantData, string(gotData))
 		})
 
-		t.Run("should return error if failed to create file", func(t *testing.T) {
+		t.Run("should replace existing file", func(t *testing.T) {
 			deps := newMockDeps(t)
 			storage := NewLocalStorage(deps)
 			ctx := context.Background()
+			wantData := faker.Sentence()
 			key := faker.UUIDHyphenated()
-			contents := bytes.NewReader([]byte(faker.Sentence()))
-			require.NoError(t, os.RemoveAll(deps.LocalStorageFolder))
+			require.NoError(t, os.WriteFile(path.Join(deps.LocalStorageFolder, key), []byte(faker.Sentence()), 0644))
+			require.NoError(t, storage.Upload(ctx, key, bytes.NewReader([]byte(wantData))))
 
-			err := storage.Upload(ctx, key, contents)
+			gotData, err := os.ReadFile(path.Join(deps.LocalStorageFolder, key))
+			require.NoError(t, err)
+			assert.Equal(t, wantData, string(gotData))
+
+			entries, err := os.ReadDir(deps.LocalStorageFolder)
+			require.NoError(t, err)
+			assert.Len(t, entries, 1, "temp file should be removed")
+		})
+
+		t.Run("should create nested folders", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			wantData := faker.Sentence()
+			key := path.Join(faker.Word(), faker.Word(), faker.UUIDHyphenated())
+			require.NoError(t, storage.Upload(ctx, key, bytes.NewReader([]byte(wantData))))
+
+			gotData, err := os.ReadFile(path.Join(deps.LocalStorageFolder, key))
+			require.NoError(t, err)
+			assert.Equal(t, wantData, string(gotData))
+		})
+
+		t.Run("should keep existing file if failed to read contents", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			wantData := faker.Sentence()
+			key := faker.UUIDHyphenated()
+			require.NoError(t, os.WriteFile(path.Join(deps.LocalStorageFolder, key), []byte(wantData), 0644))
+			wantErr := errors.New(faker.Sentence())
+
+			err := storage.Upload(ctx, key, iotest.ErrReader(wantErr))
+			require.ErrorIs(t, err, wantErr)
+
+			gotData, err := os.ReadFile(path.Join(deps.LocalStorageFolder, key))
+			require.NoError(t, err)
+			assert.Equal(t, wantData, string(gotData))
+
+			entries, err := os.ReadDir(deps.LocalStorageFolder)
+			require.NoError(t, err)
+			assert.Len(t, entries, 1, "temp file should be removed")
+		})
+
+		t.Run("should return error if failed to create folder", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			parent := faker.UUIDHyphenated()
+			require.NoError(t, os.WriteFile(path.Join(deps.LocalStorageFolder, parent), []byte(faker.Sentence()), 0644))
+			key := path.Join(parent, faker.UUIDHyphenated())
+
+			err := storage.Upload(ctx, key, bytes.NewReader([]byte(faker.Sentence())))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), key)
+		})
+
+		t.Run("should return error if target is a folder", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			key := faker.UUIDHyphenated()
+			require.NoError(t, os.MkdirAll(path.Join(deps.LocalStorageFolder, key, faker.Word()), 0755))
+
+			err := storage.Upload(ctx, key, bytes.NewReader([]byte(faker.Sentence())))
 			require.Error(t, err)
 			assert.Contains(t, err.Error(), key)
-			assert.ErrorIs(t, err, os.ErrNotExist)
+		})
+	})
+
+	t.Run("uploadIfNotExists", func(t *testing.T) {
+		t.Run("should write file if not exists", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			wantData := faker.Sentence()
+			key := faker.UUIDHyphenated()
+			require.NoError(t, storage.UploadIfNotExists(ctx, key, bytes.NewReader([]byte(wantData))))
+
+			gotData, err := os.ReadFile(path.Join(deps.LocalStorageFolder, key))
+			require.NoError(t, err)
+			assert.Equal(t, wantData, string(gotData))
+
+			entries, err := os.ReadDir(deps.LocalStorageFolder)
+			require.NoError(t, err)
+			assert.Len(t, entries, 1, "temp file should be removed")
+		})
+
+		t.Run("should not overwrite existing file", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			wantData := faker.Sentence()
+			key := faker.UUIDHyphenated()
+			require.NoError(t, os.WriteFile(path.Join(deps.LocalStorageFolder, key), []byte(wantData), 0644))
+
+			err := storage.UploadIfNotExists(ctx, key, bytes.NewReader([]byte(faker.Sentence())))
+			require.ErrorIs(t, err, fs.ErrExist)
+
+			gotData, err := os.ReadFile(path.Join(deps.LocalStorageFolder, key))
+			require.NoError(t, err)
+			assert.Equal(t, wantData, string(gotData))
+
+			entries, err := os.ReadDir(deps.LocalStorageFolder)
+			require.NoError(t, err)
+			assert.Len(t, entries, 1, "temp file should be removed")
+		})
+
+		t.Run("should create nested folders", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			wantData := faker.Sentence()
+			key := path.Join(faker.Word(), faker.UUIDHyphenated())
+			require.NoError(t, storage.UploadIfNotExists(ctx, key, bytes.NewReader([]byte(wantData))))
+
+			gotData, err := os.ReadFile(path.Join(deps.LocalStorageFolder, key))
+			require.NoError(t, err)
+			assert.Equal(t, wantData, string(gotData))
+		})
+
+		t.Run("should return error if failed to read contents", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+
+			err := storage.UploadIfNotExists(ctx, faker.UUIDHyphenated(), iotest.ErrReader(wantErr))
+			require.ErrorIs(t, err, wantErr)
 		})
 	})
 
@@ -102,4 +231,139 @@ func TestLocalStorage(t *testing.T) {
 			require.ErrorIs(t, err, os.ErrNotExist)
 		})
 	})
+
+	t.Run("list", func(t *testing.T) {
+		t.Run("should list files with a given prefix sorted by key", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			prefix := faker.UUIDHyphenated()
+			wantKeys := []string{
+				prefix + "-file.txt",
+				prefix + "/nested/file.txt",
+				prefix + "/file.txt",
+			}
+			otherKeys := []string{
+				faker.UUIDHyphenated(),
+				path.Join(faker.UUIDHyphenated(), faker.Word()),
+			}
+			for _, key := range append(wantKeys, otherKeys...) {
+				require.NoError(t, storage.Upload(ctx, key, bytes.NewReader([]byte(key))))
+			}
+
+			got, err := storage.List(ctx, prefix)
+			require.NoError(t, err)
+			slices.Sort(wantKeys)
+			assert.Equal(t, wantKeys, lo.Map(got, func(info BlobInfo, _ int) string { return info.Key }))
+			for _, info := range got {
+				assert.Equal(t, int64(len(info.Key)), info.Size)
+				assert.False(t, info.LastModified.IsZero())
+			}
+		})
+
+		t.Run("should list all files if no prefix", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			wantKeys := []string{
+				faker.UUIDHyphenated(),
+				path.Join(faker.UUIDHyphenated(), faker.Word()),
+			}
+			for _, key := range wantKeys {
+				require.NoError(t, storage.Upload(ctx, key, bytes.NewReader([]byte(faker.Sentence()))))
+			}
+
+			got, err := storage.List(ctx, "")
+			require.NoError(t, err)
+			slices.Sort(wantKeys)
+			assert.Equal(t, wantKeys, lo.Map(got, func(info BlobInfo, _ int) string { return info.Key }))
+		})
+
+		t.Run("should skip temp files", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			key := faker.UUIDHyphenated()
+			require.NoError(t, storage.Upload(ctx, key, bytes.NewReader([]byte(faker.Sentence()))))
+			require.NoError(t, os.WriteFile(
+				path.Join(deps.LocalStorageFolder, "."+key+".tmp-123"), []byte(faker.Sentence()), 0644),
+			)
+
+			got, err := storage.List(ctx, "")
+			require.NoError(t, err)
+			assert.Equal(t, []string{key}, lo.Map(got, func(info BlobInfo, _ int) string { return info.Key }))
+		})
+
+		t.Run("should return empty list if folder does not exist", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			require.NoError(t, os.RemoveAll(deps.LocalStorageFolder))
+
+			got, err := storage.List(ctx, faker.Word())
+			require.NoError(t, err)
+			assert.Empty(t, got)
+		})
+	})
+
+	t.Run("stat", func(t *testing.T) {
+		t.Run("should return file info", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			wantData := faker.Sentence()
+			key := path.Join(faker.Word(), faker.UUIDHyphenated())
+			require.NoError(t, storage.Upload(ctx, key, bytes.NewReader([]byte(wantData))))
+
+			got, err := storage.Stat(ctx, key)
+			require.NoError(t, err)
+			assert.Equal(t, key, got.Key)
+			assert.Equal(t, int64(len(wantData)), got.Size)
+			assert.False(t, got.LastModified.IsZero())
+		})
+
+		t.Run("should return not exist error if no file", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+
+			_, err := storage.Stat(ctx, faker.UUIDHyphenated())
+			require.ErrorIs(t, err, fs.ErrNotExist)
+		})
+
+		t.Run("should return not exist error for folders", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			key := faker.UUIDHyphenated()
+			require.NoError(t, os.Mkdir(path.Join(deps.LocalStorageFolder, key), 0755))
+
+			_, err := storage.Stat(ctx, key)
+			require.ErrorIs(t, err, fs.ErrNotExist)
+		})
+	})
+
+	t.Run("exists", func(t *testing.T) {
+		t.Run("should check if file exists", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			key := faker.UUIDHyphenated()
+			require.NoError(t, storage.Upload(ctx, key, bytes.NewReader([]byte(faker.Sentence()))))
+
+			assert.True(t, lo.Must(storage.Exists(ctx, key)))
+			assert.False(t, lo.Must(storage.Exists(ctx, faker.UUIDHyphenated())))
+		})
+
+		t.Run("should return error if failed to stat", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := NewLocalStorage(deps)
+			ctx := context.Background()
+			parent := faker.UUIDHyphenated()
+			require.NoError(t, os.WriteFile(path.Join(deps.LocalStorageFolder, parent), []byte(faker.Sentence()), 0644))
+
+			_, err := storage.Exists(ctx, path.Join(parent, faker.Word()))
+			require.Error(t, err)
+		})
+	})
 }
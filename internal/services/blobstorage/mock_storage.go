@@ -119,6 +119,179 @@ func (_c *MockStorage_Download_Call) RunAndReturn(run func(context.Context, stri
 	return _c
 }
 
+// Exists provides a mock function with given fields: ctx, key
+func (_m *MockStorage) Exists(ctx context.Context, key string) (bool, error) {
+	ret := _m.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Exists")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return rf(ctx, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockStorage_Exists_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Exists'
+type MockStorage_Exists_Call struct {
+	*mock.Call
+}
+
+// Exists is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+func (_e *MockStorage_Expecter) Exists(ctx interface{}, key interface{}) *MockStorage_Exists_Call {
+	return &MockStorage_Exists_Call{Call: _e.mock.On("Exists", ctx, key)}
+}
+
+func (_c *MockStorage_Exists_Call) Run(run func(ctx context.Context, key string)) *MockStorage_Exists_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockStorage_Exists_Call) Return(_a0 bool, _a1 error) *MockStorage_Exists_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockStorage_Exists_Call) RunAndReturn(run func(context.Context, string) (bool, error)) *MockStorage_Exists_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// List provides a mock function with given fields: ctx, prefix
+func (_m *MockStorage) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	ret := _m.Called(ctx, prefix)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 []BlobInfo
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]BlobInfo, error)); ok {
+		return rf(ctx, prefix)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []BlobInfo); ok {
+		r0 = rf(ctx, prefix)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]BlobInfo)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, prefix)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockStorage_List_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'List'
+type MockStorage_List_Call struct {
+	*mock.Call
+}
+
+// List is a helper method to define mock.On call
+//   - ctx context.Context
+//   - prefix string
+func (_e *MockStorage_Expecter) List(ctx interface{}, prefix interface{}) *MockStorage_List_Call {
+	return &MockStorage_List_Call{Call: _e.mock.On("List", ctx, prefix)}
+}
+
+func (_c *MockStorage_List_Call) Run(run func(ctx context.Context, prefix string)) *MockStorage_List_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockStorage_List_Call) Return(_a0 []BlobInfo, _a1 error) *MockStorage_List_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockStorage_List_Call) RunAndReturn(run func(context.Context, string) ([]BlobInfo, error)) *MockStorage_List_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Stat provides a mock function with given fields: ctx, key
+func (_m *MockStorage) Stat(ctx context.Context, key string) (BlobInfo, error) {
+	ret := _m.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Stat")
+	}
+
+	var r0 BlobInfo
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (BlobInfo, error)); ok {
+		return rf(ctx, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) BlobInfo); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Get(0).(BlobInfo)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockStorage_Stat_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Stat'
+type MockStorage_Stat_Call struct {
+	*mock.Call
+}
+
+// Stat is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+func (_e *MockStorage_Expecter) Stat(ctx interface{}, key interface{}) *MockStorage_Stat_Call {
+	return &MockStorage_Stat_Call{Call: _e.mock.On("Stat", ctx, key)}
+}
+
+func (_c *MockStorage_Stat_Call) Run(run func(ctx context.Context, key string)) *MockStorage_Stat_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockStorage_Stat_Call) Return(_a0 BlobInfo, _a1 error) *MockStorage_Stat_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockStorage_Stat_Call) RunAndReturn(run func(context.Context, string) (BlobInfo, error)) *MockStorage_Stat_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Upload provides a mock function with given fields: ctx, key, contents
 func (_m *MockStorage) Upload(ctx context.Context, key string, contents io.Reader) error {
 	ret := _m.Called(ctx, key, contents)
@@ -167,6 +340,54 @@ func (_c *MockStorage_Upload_Call) RunAndReturn(run func(context.Context, string
 	return _c
 }
 
+// UploadIfNotExists provides a mock function with given fields: ctx, key, contents
+func (_m *MockStorage) UploadIfNotExists(ctx context.Context, key string, contents io.Reader) error {
+	ret := _m.Called(ctx, key, contents)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UploadIfNotExists")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, io.Reader) error); ok {
+		r0 = rf(ctx, key, contents)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockStorage_UploadIfNotExists_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UploadIfNotExists'
+type MockStorage_UploadIfNotExists_Call struct {
+	*mock.Call
+}
+
+// UploadIfNotExists is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - contents io.Reader
+func (_e *MockStorage_Expecter) UploadIfNotExists(ctx interface{}, key interface{}, contents interface{}) *MockStorage_UploadIfNotExists_Call {
+	return &MockStorage_UploadIfNotExists_Call{Call: _e.mock.On("UploadIfNotExists", ctx, key, contents)}
+}
+
+func (_c *MockStorage_UploadIfNotExists_Call) Run(run func(ctx context.Context, key string, contents io.Reader)) *MockStorage_UploadIfNotExists_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(io.Reader))
+	})
+	return _c
+}
+
+func (_c *MockStorage_UploadIfNotExists_Call) Return(_a0 error) *MockStorage_UploadIfNotExists_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockStorage_UploadIfNotExists_Call) RunAndReturn(run func(context.Context, string, io.Reader) error) *MockStorage_UploadIfNotExists_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockStorage creates a new instance of MockStorage. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockStorage(t interface {
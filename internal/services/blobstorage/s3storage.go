@@ -0,0 +1,221 @@
+package blobstorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/dig"
+)
+
+const bytesInMB = 1024 * 1024
+
+type s3Storage struct {
+	S3StorageDeps
+	logger   *slog.Logger
+	client   *s3.Client
+	uploader *manager.Uploader
+	prefix   string
+}
+
+func (s *s3Storage) objectKey(key string) string {
+	return s.prefix + key
+}
+
+// Upload will upload the contents using multipart upload if it is larger than
+// a single part. S3 makes the object visible only when the upload is completed.
+func (s *s3Storage) Upload(ctx context.Context, key string, contents io.Reader) error {
+	s.logger.DebugContext(ctx, "Uploading object", slog.String("key", key))
+	if _, err := s.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.objectKey(key)),
+		Body:   contents,
+	}); err != nil {
+		return fmt.Errorf("failed to upload object %s: %w", key, err)
+	}
+	return nil
+}
+
+// UploadIfNotExists will upload the contents with a single request. It is intended for
+// small blobs (e.g leases) so the contents is buffered in memory.
+func (s *s3Storage) UploadIfNotExists(ctx context.Context, key string, contents io.Reader) error {
+	s.logger.DebugContext(ctx, "Uploading object if not exists", slog.String("key", key))
+	data, err := io.ReadAll(contents)
+	if err != nil {
+		return fmt.Errorf("failed to read contents of %s: %w", key, err)
+	}
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(s.objectKey(key)),
+		Body:        bytes.NewReader(data),
+		IfNoneMatch: aws.String("*"),
+	})
+	if err != nil {
+		if isS3ResponseStatus(err, http.StatusPreconditionFailed, http.StatusConflict) {
+			err = errors.Join(err, fs.ErrExist)
+		}
+		return fmt.Errorf("failed to upload object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *s3Storage) Download(ctx context.Context, key string, out io.Writer) error {
+	s.logger.DebugContext(ctx, "Downloading object", slog.String("key", key))
+	res, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.objectKey(key)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get object %s: %w", key, wrapS3NotFound(err))
+	}
+	defer res.Body.Close()
+
+	if _, err = io.Copy(out, res.Body); err != nil {
+		return fmt.Errorf("failed to read object %s: %w", key, err)
+	}
+	return nil
+}
+
+// Delete will remove the object. Unlike local storage, removing missing
+// object is not an error.
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	s.logger.DebugContext(ctx, "Deleting object", slog.String("key", key))
+	if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.objectKey(key)),
+	}); err != nil {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *s3Storage) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	s.logger.DebugContext(ctx, "Listing objects", slog.String("prefix", prefix))
+	result := []BlobInfo{}
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(s.objectKey(prefix)),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects with prefix %s: %w", prefix, err)
+		}
+		for _, obj := range page.Contents {
+			result = append(result, BlobInfo{
+				Key:          strings.TrimPrefix(aws.ToString(obj.Key), s.prefix),
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+	return result, nil
+}
+
+func (s *s3Storage) Stat(ctx context.Context, key string) (BlobInfo, error) {
+	res, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.objectKey(key)),
+	})
+	if err != nil {
+		return BlobInfo{}, fmt.Errorf("failed to stat object %s: %w", key, wrapS3NotFound(err))
+	}
+	return BlobInfo{
+		Key:          key,
+		Size:         aws.ToInt64(res.ContentLength),
+		LastModified: aws.ToTime(res.LastModified),
+	}, nil
+}
+
+func (s *s3Storage) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.Stat(ctx, key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func isS3ResponseStatus(err error, statuses ...int) bool {
+	var respErr *awshttp.ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+	for _, status := range statuses {
+		if respErr.HTTPStatusCode() == status {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapS3NotFound will make missing objects errors compatible with fs.ErrNotExist
+// so callers can handle them the same way as for local storage.
+func wrapS3NotFound(err error) error {
+	if isS3ResponseStatus(err, http.StatusNotFound) {
+		return errors.Join(err, fs.ErrNotExist)
+	}
+	return err
+}
+
+type S3StorageDeps struct {
+	dig.In
+
+	RootLogger *slog.Logger
+
+	// config
+	Endpoint        string `name:"config.blobstorage.s3.endpoint"`
+	Region          string `name:"config.blobstorage.s3.region"`
+	Bucket          string `name:"config.blobstorage.s3.bucket"`
+	Prefix          string `name:"config.blobstorage.s3.prefix"`
+	AccessKeyID     string `name:"config.blobstorage.s3.accessKeyId"`
+	SecretAccessKey string `name:"config.blobstorage.s3.secretAccessKey"`
+	UsePathStyle    bool   `name:"config.blobstorage.s3.usePathStyle"`
+	PartSizeMB      int64  `name:"config.blobstorage.s3.partSizeMB"`
+}
+
+func NewS3Storage(deps S3StorageDeps) (Storage, error) {
+	if deps.Bucket == "" {
+		return nil, errors.New("s3 bucket is not configured")
+	}
+	partSize := deps.PartSizeMB * bytesInMB
+	if partSize < manager.MinUploadPartSize {
+		return nil, fmt.Errorf("s3 part size must be at least %dMB", manager.MinUploadPartSize/bytesInMB)
+	}
+	opts := s3.Options{
+		Region:       deps.Region,
+		UsePathStyle: deps.UsePathStyle,
+	}
+	if deps.Endpoint != "" {
+		opts.BaseEndpoint = aws.String(deps.Endpoint)
+	}
+	if deps.AccessKeyID != "" {
+		opts.Credentials = credentials.NewStaticCredentialsProvider(deps.AccessKeyID, deps.SecretAccessKey, "")
+	}
+	client := s3.New(opts)
+
+	prefix := strings.Trim(deps.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return &s3Storage{
+		S3StorageDeps: deps,
+		logger:        deps.RootLogger.WithGroup("s3-storage"),
+		client:        client,
+		uploader: manager.NewUploader(client, func(u *manager.Uploader) {
+			u.PartSize = partSize
+		}),
+		prefix: prefix,
+	}, nil
+}
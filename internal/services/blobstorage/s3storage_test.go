@@ -0,0 +1,376 @@
+package blobstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io/fs"
+	mathrand "math/rand/v2"
+	"path"
+	"slices"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/gemyago/top-k-system-go/internal/diag"
+	"github.com/go-faker/faker/v4"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Storage(t *testing.T) {
+	type testDeps struct {
+		S3StorageDeps
+		server *fakeS3Server
+	}
+
+	newMockDeps := func(t *testing.T) testDeps {
+		bucket := faker.Username()
+		server := newFakeS3Server(t, bucket)
+		return testDeps{
+			S3StorageDeps: S3StorageDeps{
+				RootLogger:      diag.RootTestLogger(),
+				Endpoint:        server.URL,
+				Region:          "us-east-1",
+				Bucket:          bucket,
+				Prefix:          faker.Word(),
+				AccessKeyID:     faker.Word(),
+				SecretAccessKey: faker.Password(),
+				UsePathStyle:    true,
+				PartSizeMB:      5,
+			},
+			server: server,
+		}
+	}
+
+	newStorage := func(deps testDeps) Storage {
+		return lo.Must(NewS3Storage(deps.S3StorageDeps))
+	}
+
+	withBadBucket := func(deps testDeps) testDeps {
+		deps.Bucket = faker.Username() + "-bad"
+		return deps
+	}
+
+	t.Run("NewS3Storage", func(t *testing.T) {
+		t.Run("should fail if no bucket", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.Bucket = ""
+			_, err := NewS3Storage(deps.S3StorageDeps)
+			require.Error(t, err)
+		})
+		t.Run("should fail if part size is too small", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.PartSizeMB = 4
+			_, err := NewS3Storage(deps.S3StorageDeps)
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("upload", func(t *testing.T) {
+		t.Run("should upload object with a prefix", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := newStorage(deps)
+			ctx := context.Background()
+			wantData := faker.Sentence()
+			key := path.Join(faker.Word(), faker.UUIDHyphenated())
+			require.NoError(t, storage.Upload(ctx, key, bytes.NewReader([]byte(wantData))))
+
+			gotData, ok := deps.server.getObject(deps.Prefix + "/" + key)
+			require.True(t, ok)
+			assert.Equal(t, wantData, string(gotData))
+			assert.Equal(t, 0, deps.server.multipartUploads)
+		})
+
+		t.Run("should use multipart upload for large objects", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := newStorage(deps)
+			ctx := context.Background()
+			wantData := make([]byte, 2*deps.PartSizeMB*bytesInMB+1+mathrand.Int64N(bytesInMB))
+			lo.Must(rand.Read(wantData))
+			key := faker.UUIDHyphenated()
+
+			// not seekable reader to make sure it is streamed
+			require.NoError(t, storage.Upload(ctx, key, iotest.OneByteReader(bytes.NewReader(wantData))))
+
+			gotData, ok := deps.server.getObject(deps.Prefix + "/" + key)
+			require.True(t, ok)
+			assert.Equal(t, wantData, gotData)
+			assert.Equal(t, 1, deps.server.multipartUploads)
+		})
+
+		t.Run("should return error if failed to upload", func(t *testing.T) {
+			deps := withBadBucket(newMockDeps(t))
+			storage := newStorage(deps)
+			ctx := context.Background()
+			key := faker.UUIDHyphenated()
+
+			err := storage.Upload(ctx, key, bytes.NewReader([]byte(faker.Sentence())))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), key)
+		})
+	})
+
+	t.Run("uploadIfNotExists", func(t *testing.T) {
+		t.Run("should upload object if not exists", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := newStorage(deps)
+			ctx := context.Background()
+			wantData := faker.Sentence()
+			key := faker.UUIDHyphenated()
+			require.NoError(t, storage.UploadIfNotExists(ctx, key, bytes.NewReader([]byte(wantData))))
+
+			gotData, ok := deps.server.getObject(deps.Prefix + "/" + key)
+			require.True(t, ok)
+			assert.Equal(t, wantData, string(gotData))
+		})
+
+		t.Run("should not overwrite existing object", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := newStorage(deps)
+			ctx := context.Background()
+			wantData := faker.Sentence()
+			key := faker.UUIDHyphenated()
+			deps.server.putObject(deps.Prefix+"/"+key, []byte(wantData))
+
+			err := storage.UploadIfNotExists(ctx, key, bytes.NewReader([]byte(faker.Sentence())))
+			require.ErrorIs(t, err, fs.ErrExist)
+
+			gotData, _ := deps.server.getObject(deps.Prefix + "/" + key)
+			assert.Equal(t, wantData, string(gotData))
+		})
+
+		t.Run("should return error if failed to read contents", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := newStorage(deps)
+			ctx := context.Background()
+			wantErr := errors.New(faker.Sentence())
+
+			err := storage.UploadIfNotExists(ctx, faker.UUIDHyphenated(), iotest.ErrReader(wantErr))
+			require.ErrorIs(t, err, wantErr)
+		})
+
+		t.Run("should return error if failed to upload", func(t *testing.T) {
+			deps := withBadBucket(newMockDeps(t))
+			storage := newStorage(deps)
+			ctx := context.Background()
+
+			err := storage.UploadIfNotExists(ctx, faker.UUIDHyphenated(), bytes.NewReader([]byte(faker.Sentence())))
+			require.Error(t, err)
+			assert.NotErrorIs(t, err, fs.ErrExist)
+		})
+	})
+
+	t.Run("download", func(t *testing.T) {
+		t.Run("should read given object", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := newStorage(deps)
+			ctx := context.Background()
+			wantData := faker.Sentence()
+			key := faker.UUIDHyphenated()
+			deps.server.putObject(deps.Prefix+"/"+key, []byte(wantData))
+
+			var result bytes.Buffer
+			require.NoError(t, storage.Download(ctx, key, &result))
+			assert.Equal(t, wantData, result.String())
+		})
+
+		t.Run("should return not exist error if no object", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := newStorage(deps)
+			ctx := context.Background()
+
+			var result bytes.Buffer
+			err := storage.Download(ctx, faker.UUIDHyphenated(), &result)
+			require.ErrorIs(t, err, fs.ErrNotExist)
+		})
+
+		t.Run("should return error if failed to get object", func(t *testing.T) {
+			deps := withBadBucket(newMockDeps(t))
+			storage := newStorage(deps)
+			ctx := context.Background()
+
+			var result bytes.Buffer
+			err := storage.Download(ctx, faker.UUIDHyphenated(), &result)
+			require.Error(t, err)
+			assert.NotErrorIs(t, err, fs.ErrNotExist)
+		})
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		t.Run("should remove given object", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := newStorage(deps)
+			ctx := context.Background()
+			key := faker.UUIDHyphenated()
+			deps.server.putObject(deps.Prefix+"/"+key, []byte(faker.Sentence()))
+
+			require.NoError(t, storage.Delete(ctx, key))
+			_, ok := deps.server.getObject(deps.Prefix + "/" + key)
+			assert.False(t, ok)
+		})
+
+		t.Run("should return error if failed to delete", func(t *testing.T) {
+			deps := withBadBucket(newMockDeps(t))
+			storage := newStorage(deps)
+			ctx := context.Background()
+			key := faker.UUIDHyphenated()
+
+			err := storage.Delete(ctx, key)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), key)
+		})
+	})
+
+	t.Run("list", func(t *testing.T) {
+		t.Run("should list objects with a given prefix on all pages", func(t *testing.T) {
+			deps := newMockDeps(t)
+			deps.server.pageSize = 2
+			storage := newStorage(deps)
+			ctx := context.Background()
+			prefix := faker.UUIDHyphenated()
+			wantKeys := []string{
+				prefix + "-file.txt",
+				prefix + "/nested/file.txt",
+				prefix + "/file-1.txt",
+				prefix + "/file-2.txt",
+				prefix + "/file-3.txt",
+			}
+			for _, key := range wantKeys {
+				require.NoError(t, storage.Upload(ctx, key, bytes.NewReader([]byte(key))))
+			}
+			deps.server.putObject(faker.UUIDHyphenated(), []byte(faker.Sentence()))
+			deps.server.putObject(deps.Prefix+"/"+faker.UUIDHyphenated(), []byte(faker.Sentence()))
+
+			got, err := storage.List(ctx, prefix)
+			require.NoError(t, err)
+			slices.Sort(wantKeys)
+			assert.Equal(t, wantKeys, lo.Map(got, func(info BlobInfo, _ int) string { return info.Key }))
+			for _, info := range got {
+				assert.Equal(t, int64(len(info.Key)), info.Size)
+				assert.False(t, info.LastModified.IsZero())
+			}
+		})
+
+		t.Run("should return empty list if no objects", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := newStorage(deps)
+			ctx := context.Background()
+
+			got, err := storage.List(ctx, faker.Word())
+			require.NoError(t, err)
+			assert.Empty(t, got)
+		})
+
+		t.Run("should return error if failed to list", func(t *testing.T) {
+			deps := withBadBucket(newMockDeps(t))
+			storage := newStorage(deps)
+			ctx := context.Background()
+
+			_, err := storage.List(ctx, faker.Word())
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("stat", func(t *testing.T) {
+		t.Run("should return object info", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := newStorage(deps)
+			ctx := context.Background()
+			wantData := faker.Sentence()
+			key := faker.UUIDHyphenated()
+			deps.server.putObject(deps.Prefix+"/"+key, []byte(wantData))
+
+			got, err := storage.Stat(ctx, key)
+			require.NoError(t, err)
+			assert.Equal(t, key, got.Key)
+			assert.Equal(t, int64(len(wantData)), got.Size)
+			assert.False(t, got.LastModified.IsZero())
+		})
+
+		t.Run("should return not exist error if no object", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := newStorage(deps)
+			ctx := context.Background()
+
+			_, err := storage.Stat(ctx, faker.UUIDHyphenated())
+			require.ErrorIs(t, err, fs.ErrNotExist)
+		})
+	})
+
+	t.Run("exists", func(t *testing.T) {
+		t.Run("should check if object exists", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := newStorage(deps)
+			ctx := context.Background()
+			key := faker.UUIDHyphenated()
+			deps.server.putObject(deps.Prefix+"/"+key, []byte(faker.Sentence()))
+
+			assert.True(t, lo.Must(storage.Exists(ctx, key)))
+			assert.False(t, lo.Must(storage.Exists(ctx, faker.UUIDHyphenated())))
+		})
+
+		t.Run("should return error if failed to stat", func(t *testing.T) {
+			deps := withBadBucket(newMockDeps(t))
+			storage := newStorage(deps)
+			ctx := context.Background()
+
+			_, err := storage.Exists(ctx, faker.UUIDHyphenated())
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("lease", func(t *testing.T) {
+		t.Run("should acquire and release the lease", func(t *testing.T) {
+			deps := newMockDeps(t)
+			storage := newStorage(deps)
+			ctx := context.Background()
+			params := LeaseParams{
+				Key:   faker.UUIDHyphenated(),
+				Owner: faker.UUIDHyphenated(),
+				TTL:   time.Hour,
+				Now:   time.Now(),
+			}
+			require.NoError(t, AcquireLease(ctx, storage, params))
+			otherParams := params
+			otherParams.Owner = faker.UUIDHyphenated()
+			require.ErrorIs(t, AcquireLease(ctx, storage, otherParams), ErrLeaseHeld)
+
+			require.NoError(t, ReleaseLease(ctx, storage, params.Key, params.Owner))
+			require.NoError(t, AcquireLease(ctx, storage, otherParams))
+		})
+	})
+}
+
+func TestNewStorage(t *testing.T) {
+	t.Run("should create local storage", func(t *testing.T) {
+		storage, err := NewStorage(StorageDeps{
+			StorageType: "local",
+			Local: LocalStorageDeps{
+				RootLogger:         diag.RootTestLogger(),
+				LocalStorageFolder: t.TempDir(),
+			},
+		})
+		require.NoError(t, err)
+		assert.IsType(t, &localStorage{}, storage)
+	})
+	t.Run("should create s3 storage", func(t *testing.T) {
+		storage, err := NewStorage(StorageDeps{
+			StorageType: "s3",
+			S3: S3StorageDeps{
+				RootLogger: diag.RootTestLogger(),
+				Region:     "us-east-1",
+				Bucket:     faker.Username(),
+				PartSizeMB: 5,
+			},
+		})
+		require.NoError(t, err)
+		assert.IsType(t, &s3Storage{}, storage)
+	})
+	t.Run("should fail if unknown storage type", func(t *testing.T) {
+		_, err := NewStorage(StorageDeps{StorageType: faker.Word()})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/dig"
+)
+
+// Supported dead letter targets
+const (
+	DeadLetterTargetTopic = "topic"
+	DeadLetterTargetFile  = "file"
+	DeadLetterTargetNone  = "none"
+)
+
+// Headers of dead letter messages. The original key and payload are kept as is.
+const (
+	DeadLetterHeaderError     = "dead-letter-error"
+	DeadLetterHeaderTopic     = "dead-letter-topic"
+	DeadLetterHeaderPartition = "dead-letter-partition"
+	DeadLetterHeaderOffset    = "dead-letter-offset"
+	DeadLetterHeaderFailedAt  = "dead-letter-failed-at"
+)
+
+// DeadLetter is a message that could not be processed. The original payload is
+// kept so the message can be inspected or replayed later.
+type DeadLetter struct {
+	Topic     string    `json:"topic"`
+	Partition int       `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Key       []byte    `json:"key"`
+	Payload   []byte    `json:"payload"`
+	Error     string    `json:"error"`
+	FailedAt  time.Time `json:"failedAt"`
+}
+
+type DeadLetterWriter interface {
+	WriteDeadLetter(ctx context.Context, letter DeadLetter) error
+}
+
+type kafkaMessagesWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// kafkaDeadLetterWriter will produce dead letters to the dead letter topic.
+type kafkaDeadLetterWriter struct {
+	writer kafkaMessagesWriter
+}
+
+func (w *kafkaDeadLetterWriter) WriteDeadLetter(ctx context.Context, letter DeadLetter) error {
+	if err := w.writer.WriteMessages(ctx, kafka.Message{
+		Key:   letter.Key,
+		Value: letter.Payload,
+		Headers: []kafka.Header{
+			{Key: DeadLetterHeaderError, Value: []byte(letter.Error)},
+			{Key: DeadLetterHeaderTopic, Value: []byte(letter.Topic)},
+			{Key: DeadLetterHeaderPartition, Value: []byte(strconv.Itoa(letter.Partition))},
+			{Key: DeadLetterHeaderOffset, Value: []byte(strconv.FormatInt(letter.Offset, 10))},
+			{Key: DeadLetterHeaderFailedAt, Value: []byte(letter.FailedAt.Format(time.RFC3339Nano))},
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	return nil
+}
+
+// fileDeadLetterWriter will append dead letters to a local file as json lines.
+// Intended for local development.
+type fileDeadLetterWriter struct {
+	fileName string
+	mu       sync.Mutex
+}
+
+func (w *fileDeadLetterWriter) WriteDeadLetter(_ context.Context, letter DeadLetter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = os.MkdirAll(filepath.Dir(w.fileName), 0o755); err != nil {
+		return fmt.Errorf("failed to create dead letters folder: %w", err)
+	}
+	file, err := os.OpenFile(w.fileName, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644) //nolint:gosec // configured path
+	if err != nil {
+		return fmt.Errorf("failed to open dead letters file: %w", err)
+	}
+	defer file.Close()
+	if _, err = file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	return nil
+}
+
+// noopDeadLetterWriter will drop dead letters. Callers are still expected to log them.
+type noopDeadLetterWriter struct{}
+
+func (noopDeadLetterWriter) WriteDeadLetter(_ context.Context, _ DeadLetter) error {
+	return nil
+}
+
+type DeadLetterWriterDeps struct {
+	dig.In
+
+	RootLogger *slog.Logger
+
+	// config
+	Target                      string        `name:"config.kafka.deadLetter.target"`
+	Topic                       string        `name:"config.kafka.deadLetter.topic"`
+	FileName                    string        `name:"config.kafka.deadLetter.file"`
+	KafkaAddress                string        `name:"config.kafka.address"`
+	KafkaAllowAutoTopicCreation bool          `name:"config.kafka.allowAutoTopicCreation"`
+	KafkaWriteTimeout           time.Duration `name:"config.kafka.writeTimeout"`
+	KafkaMaxWriteAttempts       int           `name:"config.kafka.maxWriteAttempts"`
+
+	// services
+	*ShutdownHooks
+}
+
+// NewDeadLetterWriter will create the writer for a configured target. Dead letters
+// are written synchronously so the failure to write them is not lost.
+func NewDeadLetterWriter(deps DeadLetterWriterDeps) (DeadLetterWriter, error) {
+	switch deps.Target {
+	case DeadLetterTargetTopic:
+		if deps.Topic == "" {
+			return nil, fmt.Errorf("dead letter topic is not configured")
+		}
+		logger := deps.RootLogger.WithGroup("dead-letter-writer")
+		writer := &kafka.Writer{
+			Topic:                  deps.Topic,
+			AllowAutoTopicCreation: deps.KafkaAllowAutoTopicCreation,
+			Addr:                   kafka.TCP(deps.KafkaAddress),
+			ErrorLogger: kafka.LoggerFunc(func(s string, i ...interface{}) { // coverage-ignore
+				// no context here
+				logger.ErrorContext(context.Background(), fmt.Sprintf(s, i...))
+			}),
+			MaxAttempts:  deps.KafkaMaxWriteAttempts,
+			WriteTimeout: deps.KafkaWriteTimeout,
+		}
+		deps.ShutdownHooks.RegisterNoCtx("dead-letter-writer", writer.Close)
+		return &kafkaDeadLetterWriter{writer: writer}, nil
+	case DeadLetterTargetFile:
+		if deps.FileName == "" {
+			return nil, fmt.Errorf("dead letter file is not configured")
+		}
+		return &fileDeadLetterWriter{fileName: deps.FileName}, nil
+	case DeadLetterTargetNone:
+		return noopDeadLetterWriter{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported dead letter target: %s", deps.Target)
+	}
+}
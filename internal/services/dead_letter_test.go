@@ -0,0 +1,144 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gemyago/top-k-system-go/internal/diag"
+	"github.com/go-faker/faker/v4"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLetter(t *testing.T) {
+	randomDeadLetter := func() DeadLetter {
+		return DeadLetter{
+			Topic:     faker.Word(),
+			Partition: rand.Intn(10),
+			Offset:    rand.Int63(),
+			Key:       []byte(faker.UUIDHyphenated()),
+			Payload:   []byte(faker.Sentence()),
+			Error:     faker.Sentence(),
+			FailedAt:  time.UnixMilli(faker.RandomUnixTime()).UTC(),
+		}
+	}
+
+	makeMockDeps := func(target string) DeadLetterWriterDeps {
+		return DeadLetterWriterDeps{
+			RootLogger:            diag.RootTestLogger(),
+			Target:                target,
+			Topic:                 faker.Word(),
+			FileName:              filepath.Join(t.TempDir(), faker.Word(), faker.Word()+".jsonl"),
+			KafkaAddress:          faker.DomainName(),
+			KafkaWriteTimeout:     1 * time.Millisecond,
+			KafkaMaxWriteAttempts: 1,
+			ShutdownHooks:         NewTestShutdownHooks(),
+		}
+	}
+
+	t.Run("NewDeadLetterWriter", func(t *testing.T) {
+		t.Run("should create kafka writer and register shutdown hook", func(t *testing.T) {
+			deps := makeMockDeps(DeadLetterTargetTopic)
+			writer, err := NewDeadLetterWriter(deps)
+			require.NoError(t, err)
+			assert.IsType(t, &kafkaDeadLetterWriter{}, writer)
+			require.NoError(t, deps.ShutdownHooks.PerformShutdown(context.Background()))
+		})
+		t.Run("should create file writer", func(t *testing.T) {
+			deps := makeMockDeps(DeadLetterTargetFile)
+			writer, err := NewDeadLetterWriter(deps)
+			require.NoError(t, err)
+			assert.Equal(t, &fileDeadLetterWriter{fileName: deps.FileName}, writer)
+		})
+		t.Run("should create noop writer", func(t *testing.T) {
+			writer, err := NewDeadLetterWriter(makeMockDeps(DeadLetterTargetNone))
+			require.NoError(t, err)
+			require.NoError(t, writer.WriteDeadLetter(context.Background(), randomDeadLetter()))
+		})
+		t.Run("should fail if topic is not configured", func(t *testing.T) {
+			deps := makeMockDeps(DeadLetterTargetTopic)
+			deps.Topic = ""
+			_, err := NewDeadLetterWriter(deps)
+			require.Error(t, err)
+		})
+		t.Run("should fail if file is not configured", func(t *testing.T) {
+			deps := makeMockDeps(DeadLetterTargetFile)
+			deps.FileName = ""
+			_, err := NewDeadLetterWriter(deps)
+			require.Error(t, err)
+		})
+		t.Run("should fail if target is not supported", func(t *testing.T) {
+			_, err := NewDeadLetterWriter(makeMockDeps(faker.Word()))
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("kafkaDeadLetterWriter", func(t *testing.T) {
+		t.Run("should write original payload with error details in headers", func(t *testing.T) {
+			mockWriter := NewMockKafkaWriter(t)
+			writer := &kafkaDeadLetterWriter{writer: mockWriter}
+			letter := randomDeadLetter()
+			ctx := context.Background()
+			mockWriter.EXPECT().WriteMessages(ctx, kafka.Message{
+				Key:   letter.Key,
+				Value: letter.Payload,
+				Headers: []kafka.Header{
+					{Key: DeadLetterHeaderError, Value: []byte(letter.Error)},
+					{Key: DeadLetterHeaderTopic, Value: []byte(letter.Topic)},
+					{Key: DeadLetterHeaderPartition, Value: []byte(strconv.Itoa(letter.Partition))},
+					{Key: DeadLetterHeaderOffset, Value: []byte(strconv.FormatInt(letter.Offset, 10))},
+					{Key: DeadLetterHeaderFailedAt, Value: []byte(letter.FailedAt.Format(time.RFC3339Nano))},
+				},
+			}).Return(nil)
+			require.NoError(t, writer.WriteDeadLetter(ctx, letter))
+		})
+		t.Run("should return write errors", func(t *testing.T) {
+			mockWriter := NewMockKafkaWriter(t)
+			writer := &kafkaDeadLetterWriter{writer: mockWriter}
+			wantErr := errors.New(faker.Sentence())
+			ctx := context.Background()
+			mockWriter.EXPECT().WriteMessages(ctx, mock.Anything).Return(wantErr)
+			require.ErrorIs(t, writer.WriteDeadLetter(ctx, randomDeadLetter()), wantErr)
+		})
+	})
+
+	t.Run("fileDeadLetterWriter", func(t *testing.T) {
+		t.Run("should append dead letters as json lines", func(t *testing.T) {
+			deps := makeMockDeps(DeadLetterTargetFile)
+			writer := &fileDeadLetterWriter{fileName: deps.FileName}
+			letters := []DeadLetter{randomDeadLetter(), randomDeadLetter(), randomDeadLetter()}
+			for _, letter := range letters {
+				require.NoError(t, writer.WriteDeadLetter(context.Background(), letter))
+			}
+
+			file, err := os.Open(deps.FileName)
+			require.NoError(t, err)
+			defer file.Close()
+			gotLetters := make([]DeadLetter, 0, len(letters))
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				var letter DeadLetter
+				require.NoError(t, json.Unmarshal(scanner.Bytes(), &letter))
+				gotLetters = append(gotLetters, letter)
+			}
+			require.NoError(t, scanner.Err())
+			assert.Equal(t, letters, gotLetters)
+		})
+		t.Run("should fail if file can not be created", func(t *testing.T) {
+			parent := filepath.Join(t.TempDir(), faker.Word())
+			require.NoError(t, os.WriteFile(parent, []byte(faker.Sentence()), 0o600))
+			writer := &fileDeadLetterWriter{fileName: filepath.Join(parent, faker.Word())}
+			require.Error(t, writer.WriteDeadLetter(context.Background(), randomDeadLetter()))
+		})
+	})
+}
@@ -6,12 +6,14 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/segmentio/kafka-go"
 	"go.uber.org/dig"
 )
 
 type ItemEventsKafkaWriter struct {
 	*kafka.Writer
+	stats *kafkaWriterStats
 }
 
 // We may want to remove this once below PR is merged and new version is released:
@@ -21,7 +23,7 @@ func (w ItemEventsKafkaWriter) Close() error {
 	if err != nil {
 		return fmt.Errorf("failed to close kafka writer: %w", err)
 	}
-	errorsCount := w.Writer.Stats().Errors
+	errorsCount := w.stats.collect().Errors
 	if errorsCount > 0 {
 		return fmt.Errorf("failed to close writer gracefully, %d errors occurred", errorsCount)
 	}
@@ -42,6 +44,7 @@ type ItemEventsKafkaWriterDeps struct {
 
 	// services
 	*ShutdownHooks
+	MetricsRegisterer prometheus.Registerer
 }
 
 func NewItemEventsKafkaWriter(deps ItemEventsKafkaWriterDeps) ItemEventsKafkaWriter {
@@ -64,7 +67,10 @@ func NewItemEventsKafkaWriter(deps ItemEventsKafkaWriterDeps) ItemEventsKafkaWri
 
 	deps.ShutdownHooks.RegisterNoCtx("item-events-writer", writer.Close)
 
-	return ItemEventsKafkaWriter{Writer: writer}
+	stats := newKafkaWriterStats(deps.KafkaTopic, writer)
+	deps.MetricsRegisterer.MustRegister(stats)
+
+	return ItemEventsKafkaWriter{Writer: writer, stats: stats}
 }
 
 type kafkaConn interface {
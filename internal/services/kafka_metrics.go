@@ -0,0 +1,76 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+)
+
+type kafkaStatsWriter interface {
+	Stats() kafka.WriterStats
+}
+
+// kafkaWriterStats is a collector of kafka writer stats. The writer resets stats
+// each time they are read, so the totals are accumulated here.
+type kafkaWriterStats struct {
+	writer kafkaStatsWriter
+
+	mu     sync.Mutex
+	totals kafka.WriterStats
+
+	writesDesc   *prometheus.Desc
+	messagesDesc *prometheus.Desc
+	bytesDesc    *prometheus.Desc
+	errorsDesc   *prometheus.Desc
+	retriesDesc  *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*kafkaWriterStats)(nil)
+
+// collect will read stats of the writer and return the totals.
+func (s *kafkaWriterStats) collect() kafka.WriterStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.writer.Stats()
+	s.totals.Writes += stats.Writes
+	s.totals.Messages += stats.Messages
+	s.totals.Bytes += stats.Bytes
+	s.totals.Errors += stats.Errors
+	s.totals.Retries += stats.Retries
+	return s.totals
+}
+
+func (s *kafkaWriterStats) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.writesDesc
+	ch <- s.messagesDesc
+	ch <- s.bytesDesc
+	ch <- s.errorsDesc
+	ch <- s.retriesDesc
+}
+
+func (s *kafkaWriterStats) Collect(ch chan<- prometheus.Metric) {
+	totals := s.collect()
+	ch <- prometheus.MustNewConstMetric(s.writesDesc, prometheus.CounterValue, float64(totals.Writes))
+	ch <- prometheus.MustNewConstMetric(s.messagesDesc, prometheus.CounterValue, float64(totals.Messages))
+	ch <- prometheus.MustNewConstMetric(s.bytesDesc, prometheus.CounterValue, float64(totals.Bytes))
+	ch <- prometheus.MustNewConstMetric(s.errorsDesc, prometheus.CounterValue, float64(totals.Errors))
+	ch <- prometheus.MustNewConstMetric(s.retriesDesc, prometheus.CounterValue, float64(totals.Retries))
+}
+
+func newKafkaWriterStats(topic string, writer kafkaStatsWriter) *kafkaWriterStats {
+	labels := prometheus.Labels{"topic": topic}
+	return &kafkaWriterStats{
+		writer: writer,
+		writesDesc: prometheus.NewDesc("kafka_writer_writes_total",
+			"Number of write requests made by the kafka writer", nil, labels),
+		messagesDesc: prometheus.NewDesc("kafka_writer_messages_total",
+			"Number of messages written by the kafka writer", nil, labels),
+		bytesDesc: prometheus.NewDesc("kafka_writer_bytes_total",
+			"Number of bytes written by the kafka writer", nil, labels),
+		errorsDesc: prometheus.NewDesc("kafka_writer_errors_total",
+			"Number of errors occurred while writing messages", nil, labels),
+		retriesDesc: prometheus.NewDesc("kafka_writer_retries_total",
+			"Number of retried write requests", nil, labels),
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type kafkaStatsWriterFunc func() kafka.WriterStats
+
+func (f kafkaStatsWriterFunc) Stats() kafka.WriterStats {
+	return f()
+}
+
+func TestKafkaMetrics(t *testing.T) {
+	randomWriterStats := func() kafka.WriterStats {
+		return kafka.WriterStats{
+			Writes:   rand.Int64N(1000),
+			Messages: rand.Int64N(1000),
+			Bytes:    rand.Int64N(1000),
+			Errors:   rand.Int64N(1000),
+			Retries:  rand.Int64N(1000),
+		}
+	}
+
+	t.Run("kafkaWriterStats", func(t *testing.T) {
+		t.Run("should accumulate stats of the writer", func(t *testing.T) {
+			reads := []kafka.WriterStats{randomWriterStats(), randomWriterStats()}
+			readsCount := 0
+			stats := newKafkaWriterStats(faker.Word(), kafkaStatsWriterFunc(func() kafka.WriterStats {
+				defer func() { readsCount++ }()
+				return reads[readsCount]
+			}))
+
+			stats.collect()
+			got := stats.collect()
+			assert.Equal(t, reads[0].Writes+reads[1].Writes, got.Writes)
+			assert.Equal(t, reads[0].Messages+reads[1].Messages, got.Messages)
+			assert.Equal(t, reads[0].Bytes+reads[1].Bytes, got.Bytes)
+			assert.Equal(t, reads[0].Errors+reads[1].Errors, got.Errors)
+			assert.Equal(t, reads[0].Retries+reads[1].Retries, got.Retries)
+		})
+		t.Run("should expose totals as metrics", func(t *testing.T) {
+			topic := faker.Word()
+			want := randomWriterStats()
+			stats := newKafkaWriterStats(topic, kafkaStatsWriterFunc(func() kafka.WriterStats {
+				return want
+			}))
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(stats)
+
+			wantMetrics := []string{
+				"kafka_writer_writes_total",
+				"kafka_writer_messages_total",
+				"kafka_writer_bytes_total",
+				"kafka_writer_errors_total",
+				"kafka_writer_retries_total",
+			}
+			count, err := testutil.GatherAndCount(registry, wantMetrics...)
+			require.NoError(t, err)
+			assert.Equal(t, len(wantMetrics), count)
+
+			// stats are read on every gather, so totals are doubled on a second one
+			require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(
+				"# HELP kafka_writer_messages_total Number of messages written by the kafka writer\n"+
+					"# TYPE kafka_writer_messages_total counter\n"+
+					"kafka_writer_messages_total{topic=\""+topic+"\"} "+strconv.FormatInt(want.Messages*2, 10)+"\n",
+			), "kafka_writer_messages_total"))
+		})
+	})
+}
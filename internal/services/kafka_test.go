@@ -9,6 +9,7 @@ import (
 
 	"github.com/gemyago/top-k-system-go/internal/diag"
 	"github.com/go-faker/faker/v4"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -22,6 +23,7 @@ func TestKafka(t *testing.T) {
 				KafkaTopic:        faker.DomainName(),
 				KafkaWriteTimeout: 1 * time.Millisecond,
 				ShutdownHooks:     NewTestShutdownHooks(),
+				MetricsRegisterer: prometheus.NewRegistry(),
 			}
 		}
 
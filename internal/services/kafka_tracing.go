@@ -0,0 +1,46 @@
+package services
+
+import (
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// KafkaHeadersCarrier will let the propagator inject and extract the trace
+// context using headers of the kafka message.
+type KafkaHeadersCarrier struct {
+	headers *[]kafka.Header
+}
+
+var _ propagation.TextMapCarrier = KafkaHeadersCarrier{}
+
+func (c KafkaHeadersCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set will replace the header with a given key or add a new one.
+func (c KafkaHeadersCarrier) Set(key string, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c KafkaHeadersCarrier) Keys() []string {
+	keys := make([]string, len(*c.headers))
+	for i, h := range *c.headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+func NewKafkaHeadersCarrier(headers *[]kafka.Header) KafkaHeadersCarrier {
+	return KafkaHeadersCarrier{headers: headers}
+}
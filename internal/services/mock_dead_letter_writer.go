@@ -0,0 +1,85 @@
+// Code generated by mockery. DO NOT EDIT.
+
+//go:build !release
+
+package services
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockDeadLetterWriter is an autogenerated mock type for the mockDeadLetterWriter type
+type MockDeadLetterWriter struct {
+	mock.Mock
+}
+
+type MockDeadLetterWriter_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockDeadLetterWriter) EXPECT() *MockDeadLetterWriter_Expecter {
+	return &MockDeadLetterWriter_Expecter{mock: &_m.Mock}
+}
+
+// WriteDeadLetter provides a mock function with given fields: ctx, letter
+func (_m *MockDeadLetterWriter) WriteDeadLetter(ctx context.Context, letter DeadLetter) error {
+	ret := _m.Called(ctx, letter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WriteDeadLetter")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, DeadLetter) error); ok {
+		r0 = rf(ctx, letter)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockDeadLetterWriter_WriteDeadLetter_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WriteDeadLetter'
+type MockDeadLetterWriter_WriteDeadLetter_Call struct {
+	*mock.Call
+}
+
+// WriteDeadLetter is a helper method to define mock.On call
+//   - ctx context.Context
+//   - letter DeadLetter
+func (_e *MockDeadLetterWriter_Expecter) WriteDeadLetter(ctx interface{}, letter interface{}) *MockDeadLetterWriter_WriteDeadLetter_Call {
+	return &MockDeadLetterWriter_WriteDeadLetter_Call{Call: _e.mock.On("WriteDeadLetter", ctx, letter)}
+}
+
+func (_c *MockDeadLetterWriter_WriteDeadLetter_Call) Run(run func(ctx context.Context, letter DeadLetter)) *MockDeadLetterWriter_WriteDeadLetter_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(DeadLetter))
+	})
+	return _c
+}
+
+func (_c *MockDeadLetterWriter_WriteDeadLetter_Call) Return(_a0 error) *MockDeadLetterWriter_WriteDeadLetter_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockDeadLetterWriter_WriteDeadLetter_Call) RunAndReturn(run func(context.Context, DeadLetter) error) *MockDeadLetterWriter_WriteDeadLetter_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockDeadLetterWriter creates a new instance of MockDeadLetterWriter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockDeadLetterWriter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockDeadLetterWriter {
+	mock := &MockDeadLetterWriter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
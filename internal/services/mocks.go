@@ -28,3 +28,7 @@ type mockKafkaReader interface {
 }
 
 var _ mockKafkaReader = (*ItemEventsKafkaReader)(nil)
+
+type mockDeadLetterWriter interface {
+	DeadLetterWriter
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gemyago/top-k-system-go/internal/di"
 	"github.com/gemyago/top-k-system-go/internal/services/blobstorage"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/segmentio/kafka-go"
 	"go.uber.org/dig"
 )
@@ -17,8 +18,14 @@ func Register(container *dig.Container) error {
 		NewItemEventsKafkaReader,
 		NewItemEventsKafkaWriter,
 		NewShutdownHooks,
+		NewDeadLetterWriter,
+		NewMetricsRegistry,
+		di.ProvideAs[*prometheus.Registry, prometheus.Registerer],
+		di.ProvideAs[*prometheus.Registry, prometheus.Gatherer],
+		NewTracerProvider,
+		NewTextMapPropagator,
 		di.ProvideValue(time.NewTicker),
-		blobstorage.NewLocalStorage,
+		blobstorage.NewStorage,
 
 		// package private deps
 		di.ProvideValue[kafkaLeaderDialer](
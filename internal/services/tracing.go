@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/dig"
+)
+
+// Supported trace exporters
+const (
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+	TracingExporterFile   = "file"
+	TracingExporterNone   = "none"
+)
+
+type TracerProviderDeps struct {
+	dig.In
+
+	// config
+	Exporter     string `name:"config.tracing.exporter"`
+	ServiceName  string `name:"config.tracing.serviceName"`
+	OTLPEndpoint string `name:"config.tracing.otlpEndpoint"`
+	OTLPInsecure bool   `name:"config.tracing.otlpInsecure"`
+	FileName     string `name:"config.tracing.file"`
+
+	// services
+	*ShutdownHooks
+}
+
+// lazyFileWriter will open the file on the first write, so the file is not
+// created unless spans are exported.
+type lazyFileWriter struct {
+	fileName string
+	mu       sync.Mutex
+	file     *os.File
+}
+
+func (w *lazyFileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		if err := os.MkdirAll(filepath.Dir(w.fileName), 0o755); err != nil {
+			return 0, fmt.Errorf("failed to create traces folder: %w", err)
+		}
+		file, err := os.OpenFile(w.fileName, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644) //nolint:gosec // configured path
+		if err != nil {
+			return 0, fmt.Errorf("failed to open traces file: %w", err)
+		}
+		w.file = file
+	}
+	return w.file.Write(p)
+}
+
+func (w *lazyFileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
+
+// closingSpanExporter will close the underlying output once the exporter is shut down.
+type closingSpanExporter struct {
+	sdktrace.SpanExporter
+	closer io.Closer
+}
+
+func (e *closingSpanExporter) Shutdown(ctx context.Context) error {
+	if err := e.SpanExporter.Shutdown(ctx); err != nil {
+		return err
+	}
+	return e.closer.Close()
+}
+
+func newSpanExporter(deps TracerProviderDeps) (sdktrace.SpanExporter, error) {
+	switch deps.Exporter {
+	case TracingExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(deps.OTLPEndpoint)}
+		if deps.OTLPInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		// The exporter connects lazily, so the context is only used for setup
+		return otlptracehttp.New(context.Background(), opts...)
+	case TracingExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case TracingExporterFile:
+		if deps.FileName == "" {
+			return nil, fmt.Errorf("traces file is not configured")
+		}
+		file := &lazyFileWriter{fileName: deps.FileName}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil { // coverage-ignore // fails only with invalid options
+			return nil, fmt.Errorf("failed to create file exporter: %w", err)
+		}
+		return &closingSpanExporter{SpanExporter: exporter, closer: file}, nil
+	default:
+		return nil, fmt.Errorf("unsupported trace exporter: %s", deps.Exporter)
+	}
+}
+
+// NewTracerProvider will create the tracer provider that exports spans to a configured
+// target. Spans are exported in batches, remaining spans are flushed on shutdown.
+func NewTracerProvider(deps TracerProviderDeps) (trace.TracerProvider, error) {
+	if deps.Exporter == TracingExporterNone {
+		return noop.NewTracerProvider(), nil
+	}
+	exporter, err := newSpanExporter(deps)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(deps.ServiceName))),
+	)
+	deps.ShutdownHooks.Register("tracer-provider", provider.Shutdown)
+	return provider, nil
+}
+
+// NewTextMapPropagator will create the propagator of the trace context (W3C format)
+// used to pass it across http requests and kafka messages.
+func NewTextMapPropagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	)
+}
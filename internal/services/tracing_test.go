@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestTracing(t *testing.T) {
+	makeMockDeps := func(exporter string) TracerProviderDeps {
+		return TracerProviderDeps{
+			Exporter:      exporter,
+			ServiceName:   faker.Word(),
+			OTLPEndpoint:  "localhost:4318",
+			OTLPInsecure:  true,
+			FileName:      filepath.Join(t.TempDir(), faker.Word(), faker.Word()+".jsonl"),
+			ShutdownHooks: NewTestShutdownHooks(),
+		}
+	}
+
+	t.Run("NewTracerProvider", func(t *testing.T) {
+		t.Run("should create noop provider if tracing is disabled", func(t *testing.T) {
+			deps := makeMockDeps(TracingExporterNone)
+			provider, err := NewTracerProvider(deps)
+			require.NoError(t, err)
+			assert.IsType(t, noop.TracerProvider{}, provider)
+			assert.False(t, deps.ShutdownHooks.HasHook("tracer-provider", nil))
+		})
+		t.Run("should create sdk provider for supported exporters", func(t *testing.T) {
+			for _, exporter := range []string{TracingExporterOTLP, TracingExporterStdout} {
+				deps := makeMockDeps(exporter)
+				provider, err := NewTracerProvider(deps)
+				require.NoError(t, err, exporter)
+				assert.IsType(t, &sdktrace.TracerProvider{}, provider, exporter)
+				sdkProvider, _ := provider.(*sdktrace.TracerProvider)
+				assert.True(t, deps.ShutdownHooks.HasHook("tracer-provider", sdkProvider.Shutdown), exporter)
+			}
+		})
+		t.Run("should export spans to file on shutdown", func(t *testing.T) {
+			deps := makeMockDeps(TracingExporterFile)
+			provider, err := NewTracerProvider(deps)
+			require.NoError(t, err)
+
+			spanName := faker.Word()
+			_, span := provider.Tracer(faker.Word()).Start(context.Background(), spanName)
+			span.End()
+			require.NoError(t, deps.ShutdownHooks.PerformShutdown(context.Background()))
+
+			data, err := os.ReadFile(deps.FileName)
+			require.NoError(t, err)
+			assert.Contains(t, string(data), spanName)
+		})
+		t.Run("should not create traces file if no spans exported", func(t *testing.T) {
+			deps := makeMockDeps(TracingExporterFile)
+			_, err := NewTracerProvider(deps)
+			require.NoError(t, err)
+			require.NoError(t, deps.ShutdownHooks.PerformShutdown(context.Background()))
+
+			_, err = os.Stat(deps.FileName)
+			require.ErrorIs(t, err, os.ErrNotExist)
+		})
+		t.Run("should fail if traces file is not configured", func(t *testing.T) {
+			deps := makeMockDeps(TracingExporterFile)
+			deps.FileName = ""
+			_, err := NewTracerProvider(deps)
+			require.ErrorContains(t, err, "traces file is not configured")
+		})
+		t.Run("should fail for unsupported exporter", func(t *testing.T) {
+			_, err := NewTracerProvider(makeMockDeps(faker.Word()))
+			require.ErrorContains(t, err, "unsupported trace exporter")
+		})
+	})
+
+	t.Run("KafkaHeadersCarrier", func(t *testing.T) {
+		t.Run("should get, set and list headers", func(t *testing.T) {
+			existingKey := faker.Word()
+			headers := []kafka.Header{{Key: existingKey, Value: []byte(faker.Word())}}
+			carrier := NewKafkaHeadersCarrier(&headers)
+
+			newKey := existingKey + "-" + faker.Word()
+			newValue := faker.Word()
+			updatedValue := faker.Word()
+			carrier.Set(newKey, newValue)
+			carrier.Set(existingKey, updatedValue)
+
+			assert.Equal(t, []string{existingKey, newKey}, carrier.Keys())
+			assert.Equal(t, updatedValue, carrier.Get(existingKey))
+			assert.Equal(t, newValue, carrier.Get(newKey))
+			assert.Empty(t, carrier.Get(faker.UUIDHyphenated()))
+		})
+		t.Run("should propagate trace context", func(t *testing.T) {
+			propagator := NewTextMapPropagator()
+			wantSpanContext := trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    trace.TraceID{1, 2, 3},
+				SpanID:     trace.SpanID{4, 5, 6},
+				TraceFlags: trace.FlagsSampled,
+			})
+
+			var headers []kafka.Header
+			propagator.Inject(
+				trace.ContextWithSpanContext(context.Background(), wantSpanContext),
+				NewKafkaHeadersCarrier(&headers),
+			)
+			assert.NotEmpty(t, headers)
+
+			gotCtx := propagator.Extract(context.Background(), NewKafkaHeadersCarrier(&headers))
+			assert.Equal(t, wantSpanContext.WithRemote(true), trace.SpanContextFromContext(gotCtx))
+		})
+	})
+}